@@ -0,0 +1,106 @@
+// Command import-members creates members from a CSV or XLSX export of
+// another gym system, the same way POST /members/import does. It uses the
+// DB_* and SMTP settings of the API.
+//
+//	go run ./cmd/import-members -file members.xlsx -actor admin@example.com -dry-run
+//
+// The report is written as CSV to -report, or to stdout.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"go-blog/internal/config"
+	"go-blog/internal/importer"
+	"go-blog/internal/mailer"
+	"go-blog/internal/models"
+	services "go-blog/internal/service"
+	"go-blog/repositories"
+
+	"github.com/google/uuid"
+)
+
+func main() {
+	file := flag.String("file", "", "CSV or XLSX file to import (required)")
+	actor := flag.String("actor", "", "email of the admin or staff user the import is audited under (required)")
+	dryRun := flag.Bool("dry-run", false, "validate every row without creating anything")
+	passwordMode := flag.String("password-mode", services.ImportPasswordInvite, "\"invite\" to email a set-password link, \"generate\" to put random passwords in the report")
+	gym := flag.String("gym", "", "ID of the home gym of the imported members")
+	batch := flag.Int("batch", 0, "rows per transaction (default 100)")
+	reportPath := flag.String("report", "", "where to write the CSV report (default stdout)")
+	flag.Parse()
+
+	if *file == "" || *actor == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", *file, err)
+	}
+	rows, err := importer.Read(*file, f)
+	f.Close()
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", *file, err)
+	}
+
+	config.InitDB()
+	db := config.DB
+
+	userRepo := repositories.NewUserRepository(db)
+	actorUser, err := userRepo.GetUserByEmail(*actor)
+	if err != nil {
+		log.Fatalf("No user with email %s", *actor)
+	}
+	if role := models.NormalizeRole(actorUser.UserType); role != models.RoleAdmin && role != models.RoleStaff {
+		log.Fatalf("%s is a %s; imports must run as admin or staff", *actor, role)
+	}
+
+	opts := services.ImportOptions{
+		ActorID:      actorUser.UserID,
+		DryRun:       *dryRun,
+		PasswordMode: *passwordMode,
+		BatchSize:    *batch,
+		Filename:     *file,
+	}
+	if *gym != "" {
+		gymID, err := uuid.Parse(*gym)
+		if err != nil {
+			log.Fatalf("Invalid -gym: %v", err)
+		}
+		opts.GymID = &gymID
+	}
+
+	gymRepo := repositories.NewGymRepository(db)
+	refreshTokenRepo := repositories.NewRefreshTokenRepository(db)
+	revocations := services.NewTokenRevocationService(repositories.NewTokenRevocationRepository(db), refreshTokenRepo, db)
+	// Registration never consults the login throttle
+	auth := services.NewAuthService(userRepo, refreshTokenRepo, gymRepo, repositories.NewHouseholdRepository(db), nil, db)
+	accounts := services.NewAccountService(userRepo, repositories.NewUserTokenRepository(db), revocations, mailer.NewFromEnv(), db)
+	imports := services.NewMemberImportService(auth, accounts, repositories.NewPlanRepository(db), gymRepo, db)
+
+	report, err := imports.Import(rows, opts)
+	if err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+
+	out := os.Stdout
+	if *reportPath != "" {
+		if out, err = os.Create(*reportPath); err != nil {
+			log.Fatalf("Failed to create %s: %v", *reportPath, err)
+		}
+		defer out.Close()
+	}
+	if err := report.WriteCSV(out); err != nil {
+		log.Fatalf("Failed to write the report: %v", err)
+	}
+
+	if report.DryRun {
+		log.Printf("Dry run, nothing saved. %d rows: %d valid, %d failed", report.Total, report.Valid, report.Failed)
+		return
+	}
+	log.Printf("%d rows: %d created, %d failed", report.Total, report.Created, report.Failed)
+}
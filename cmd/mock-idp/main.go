@@ -0,0 +1,36 @@
+// Command mock-idp runs a local OpenID Connect provider that signs everyone
+// in, for trying the OIDC login without a real provider. Point the API at it:
+//
+//	OIDC_PROVIDERS=mock
+//	OIDC_MOCK_ISSUER=http://localhost:9999
+//	OIDC_MOCK_CLIENT_ID=gym-backend
+//
+// and open http://localhost:8888/auth/oidc/mock/login. Add
+// ?login_hint=someone@example.com to sign in as someone other than -email.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"go-blog/internal/oidc/mockidp"
+)
+
+func main() {
+	addr := flag.String("addr", ":9999", "listen address")
+	issuer := flag.String("issuer", "http://localhost:9999", "issuer URL, as reachable by the API and the browser")
+	clientID := flag.String("client-id", "gym-backend", "accepted client_id")
+	email := flag.String("email", "member@example.com", "email of the signed-in user when no login_hint is given")
+	unverified := flag.Bool("unverified", false, "report email_verified=false")
+	flag.Parse()
+
+	server, err := mockidp.New(*issuer, *clientID, *email)
+	if err != nil {
+		log.Fatalf("Failed to start mock IdP: %v", err)
+	}
+	server.EmailVerified = !*unverified
+
+	log.Printf("Mock OIDC provider %s listening on %s", *issuer, *addr)
+	log.Fatal(http.ListenAndServe(*addr, server.Handler()))
+}
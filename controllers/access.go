@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"errors"
+	"go-blog/internal/models"
+	services "go-blog/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondAccessDenied answers 403 with a machine-readable reason when err
+// refuses a booking or check-in, and reports whether it did.
+func respondAccessDenied(ctx *gin.Context, err error) bool {
+	var denial *models.AccessDenial
+	switch {
+	case errors.As(err, &denial):
+		ctx.JSON(http.StatusForbidden, denial)
+	case errors.Is(err, services.ErrNoActiveMembership):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "reason": models.AccessNoActiveMembership})
+	case errors.Is(err, services.ErrQuotaExhausted):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "reason": models.AccessSessionQuotaReached})
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"errors"
+	services "go-blog/internal/service"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccountController serves the emailed-link flows under /auth
+type AccountController struct {
+	service *services.AccountService
+}
+
+func NewAccountController(service *services.AccountService) *AccountController {
+	return &AccountController{service: service}
+}
+
+// ForgotPassword mails a reset link (POST /auth/forgot-password).
+// It always answers 200 so it cannot be used to probe for accounts.
+func (c *AccountController) ForgotPassword(ctx *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.service.RequestPasswordReset(input.Email); err != nil {
+		log.Printf("ERROR: password reset request failed: %v", err)
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "If the email is registered, a reset link has been sent"})
+}
+
+// ResetPassword sets a new password using a mailed token (POST /auth/reset-password)
+func (c *AccountController) ResetPassword(ctx *gin.Context) {
+	var input struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.service.ResetPassword(input.Token, input.Password); err != nil {
+		if errors.Is(err, services.ErrInvalidUserToken) || errors.Is(err, services.ErrWeakPassword) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Password has been reset, please log in again"})
+}
+
+// VerifyEmail confirms an email address using a mailed token (POST /auth/verify-email)
+func (c *AccountController) VerifyEmail(ctx *gin.Context) {
+	var input struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.service.VerifyEmail(input.Token); err != nil {
+		if errors.Is(err, services.ErrInvalidUserToken) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+// ResendVerification mails a new verification link (POST /auth/resend-verification)
+func (c *AccountController) ResendVerification(ctx *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.service.ResendVerification(input.Email); err != nil {
+		log.Printf("ERROR: resend verification failed: %v", err)
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "If the account needs verification, a new link has been sent"})
+}
@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"errors"
+	"go-blog/internal/models"
+	services "go-blog/internal/service"
+	"go-blog/middlewares"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// APIKeyController lets admins manage API keys
+type APIKeyController struct {
+	service *services.APIKeyService
+}
+
+func NewAPIKeyController(service *services.APIKeyService) *APIKeyController {
+	return &APIKeyController{service: service}
+}
+
+// Create issues a key (POST /api-keys). The plaintext key is only part of
+// this response.
+func (c *APIKeyController) Create(ctx *gin.Context) {
+	var input services.APIKeyInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	actorID, _ := middlewares.CurrentUserID(ctx)
+
+	key, plaintext, err := c.service.Create(actorID, input)
+	if err != nil {
+		respondAPIKeyError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"api_key": key, "key": plaintext})
+}
+
+// List returns one page of keys (GET /api-keys)
+func (c *APIKeyController) List(ctx *gin.Context) {
+	req, ok := listRequest(ctx)
+	if !ok {
+		return
+	}
+	keys, err := c.service.List(req)
+	respondList(ctx, keys, err)
+}
+
+// Get returns one key (GET /api-keys/:id)
+func (c *APIKeyController) Get(ctx *gin.Context) {
+	id, ok := apiKeyID(ctx)
+	if !ok {
+		return
+	}
+	key, err := c.service.Get(id)
+	if err != nil {
+		respondAPIKeyError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, key)
+}
+
+// Update changes the name, scopes or expiry of a key (PATCH /api-keys/:id)
+func (c *APIKeyController) Update(ctx *gin.Context) {
+	id, ok := apiKeyID(ctx)
+	if !ok {
+		return
+	}
+	var input services.APIKeyInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	actorID, _ := middlewares.CurrentUserID(ctx)
+
+	key, err := c.service.Update(actorID, id, input)
+	if err != nil {
+		respondAPIKeyError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, key)
+}
+
+// Revoke disables a key (DELETE /api-keys/:id)
+func (c *APIKeyController) Revoke(ctx *gin.Context) {
+	id, ok := apiKeyID(ctx)
+	if !ok {
+		return
+	}
+	actorID, _ := middlewares.CurrentUserID(ctx)
+
+	if err := c.service.Revoke(actorID, id); err != nil {
+		respondAPIKeyError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
+
+// Usage returns the audit trail of a key (GET /api-keys/:id/usage)
+func (c *APIKeyController) Usage(ctx *gin.Context) {
+	id, ok := apiKeyID(ctx)
+	if !ok {
+		return
+	}
+	entries, err := c.service.Usage(id)
+	if err != nil {
+		respondAPIKeyError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": entries})
+}
+
+// Scopes lists the scopes a key can be granted (GET /api-keys/scopes)
+func (c *APIKeyController) Scopes(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"data": models.APIKeyScopes})
+}
+
+func apiKeyID(ctx *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid api key id"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func respondAPIKeyError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrAPIKeyNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidAPIKeyArg):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAPIKeyRevoked):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
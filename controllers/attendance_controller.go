@@ -1,9 +1,11 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 
 	services "go-blog/internal/service"
+	"go-blog/middlewares"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -17,12 +19,21 @@ func NewAttendanceController(service *services.AttendanceService) *AttendanceCon
 	return &AttendanceController{service: service}
 }
 
+// scoped limits API key callers, such as door controllers, to their gym
+func (c *AttendanceController) scoped(ctx *gin.Context) *services.AttendanceService {
+	if gymID, ok := middlewares.APIKeyGymID(ctx); ok {
+		return c.service.ForGym(gymID)
+	}
+	return c.service
+}
+
 // ✅ POST /attendance/checkin
 func (c *AttendanceController) CheckIn(ctx *gin.Context) {
 	var payload struct {
 		MemberID string `json:"member_id" binding:"required,uuid"`
 		SessionID string `json:"session_id" binding:"required,uuid"`
 		Method string `json:"method" binding:"required"` // qr, staff
+		Guests int `json:"guests" binding:"min=0"` // guests coming in with the member
 	}
 
 	if err := ctx.ShouldBindJSON(&payload); err != nil {
@@ -33,7 +44,14 @@ func (c *AttendanceController) CheckIn(ctx *gin.Context) {
 	memberID, _ := uuid.Parse(payload.MemberID)
 	sessionID, _ := uuid.Parse(payload.SessionID)
 
-	if err := c.service.CheckIn(memberID, sessionID, payload.Method); err != nil {
+	if err := c.scoped(ctx).CheckIn(memberID, sessionID, payload.Method, payload.Guests); err != nil {
+		if respondAccessDenied(ctx, err) {
+			return
+		}
+		if errors.Is(err, services.ErrOutsideGymScope) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -49,21 +67,44 @@ func (c *AttendanceController) GetMemberAttendance(ctx *gin.Context) {
 		return
 	}
 
-	records, err := c.service.GetMemberAttendance(memberID)
+	req, ok := listRequest(ctx)
+	if !ok {
+		return
+	}
+	records, err := c.scoped(ctx).GetMemberAttendance(memberID, req)
+	respondList(ctx, records, err)
+}
+
+// GET /attendance/member/:member_id/remaining
+func (c *AttendanceController) GetRemainingSessions(ctx *gin.Context) {
+	memberID, err := uuid.Parse(ctx.Param("member_id"))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid member_id"})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, records)
+	balance, err := c.scoped(ctx).RemainingSessions(memberID)
+	if errors.Is(err, services.ErrOutsideGymScope) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrNoActiveMembership) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load remaining sessions"})
+		return
+	}
+	ctx.JSON(http.StatusOK, balance)
 }
 
 // ✅ GET /attendance/all
 func (c *AttendanceController) GetAllAttendance(ctx *gin.Context) {
-	records, err := c.service.GetAllAttendance()
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	req, ok := listRequest(ctx)
+	if !ok {
 		return
 	}
-	ctx.JSON(http.StatusOK, records)
+	records, err := c.scoped(ctx).GetAllAttendance(req)
+	respondList(ctx, records, err)
 }
@@ -1,9 +1,14 @@
 package controllers
 
 import (
+	"errors"
 	"go-blog/internal/models"
+	"log"
+	"math"
 	services "go-blog/internal/service"
+	"go-blog/middlewares"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -11,11 +16,15 @@ import (
 )
 
 type AuthController struct {
-	service *services.AuthService
+	service     *services.AuthService
+	revocations *services.TokenRevocationService
+	accounts    *services.AccountService
+	throttle    *services.LoginThrottle
+	mfa         *services.MFAService
 }
 
-func NewAuthController(service *services.AuthService) *AuthController {
-	return &AuthController{service}
+func NewAuthController(service *services.AuthService, revocations *services.TokenRevocationService, accounts *services.AccountService, throttle *services.LoginThrottle, mfa *services.MFAService) *AuthController {
+	return &AuthController{service: service, revocations: revocations, accounts: accounts, throttle: throttle, mfa: mfa}
 }
 func (c *AuthController) Register(ctx *gin.Context) {
 	var input struct {
@@ -30,12 +39,25 @@ func (c *AuthController) Register(ctx *gin.Context) {
 		FitnessGoals          string     `json:"fitness_goals"`
 		UserType              string     `json:"user_type"`
 		Gender                string     `json:"gender"`
+		GymID                 *uuid.UUID `json:"gym_id"`
 
 		// Membership info (optional, only for members)
 		PlanID          string     `json:"plan_id"`
 		MembershipStart *time.Time `json:"membership_start"`
 		MembershipEnd   *time.Time `json:"membership_end"`
 		AutoRenew       *bool      `json:"auto_renew"`
+		// A trial pass product members may join on instead of a plan
+		TrialProductID *uuid.UUID `json:"trial_product_id"`
+		// A promo code discounting the plan, and the referral code of the
+		// member who brought them
+		PromoCode    string `json:"promo_code"`
+		ReferralCode string `json:"referral_code"`
+
+		// Guardian consent, required for minors. The signed-in caller is the
+		// guardian; admins may name another one.
+		GuardianConsent      bool       `json:"guardian_consent"`
+		GuardianID           *uuid.UUID `json:"guardian_id"`
+		GuardianRelationship string     `json:"guardian_relationship"`
 	}
 
 	if err := ctx.ShouldBindJSON(&input); err != nil {
@@ -54,6 +76,13 @@ func (c *AuthController) Register(ctx *gin.Context) {
 		dob = &t
 	}
 
+	// Only admins may create staff, trainer or admin accounts
+	role := models.NormalizeRole(input.UserType)
+	if role != models.RoleMember && !middlewares.HasRole(ctx, models.RoleAdmin) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "only admins can register non-member accounts"})
+		return
+	}
+
 	user := &models.User{
 		FirstName:             input.FirstName,
 		LastName:              input.LastName,
@@ -63,8 +92,9 @@ func (c *AuthController) Register(ctx *gin.Context) {
 		EmergencyContactName:  input.EmergencyContactName,
 		EmergencyContactPhone: input.EmergencyContactPhone,
 		FitnessGoals:          input.FitnessGoals,
-		UserType:              input.UserType,
+		UserType:              role,
 		Gender:                input.Gender,
+		GymID:                 input.GymID,
 	}
 
 	// Handle membership defaults
@@ -88,16 +118,18 @@ func (c *AuthController) Register(ctx *gin.Context) {
 
 	// Handle plan_id for members
 	var planID string
-	if user.UserType == "member" || user.UserType == "Member" {
-		if input.PlanID == "" {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "plan_id is required for members"})
+	if user.UserType == models.RoleMember {
+		if input.PlanID == "" && input.TrialProductID == nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "plan_id or trial_product_id is required for members"})
 			return
 		}
 		
 		// Validate UUID format in controller
-		if _, err := uuid.Parse(input.PlanID); err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid plan_id: " + err.Error()})
-			return
+		if input.PlanID != "" {
+			if _, err := uuid.Parse(input.PlanID); err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid plan_id: " + err.Error()})
+				return
+			}
 		}
 		planID = input.PlanID
 	} else {
@@ -105,67 +137,263 @@ func (c *AuthController) Register(ctx *gin.Context) {
 		planID = ""
 	}
 
+	var consent *services.GuardianConsent
+	if input.GuardianConsent {
+		callerID, ok := middlewares.CurrentUserID(ctx)
+		if !ok {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "guardians must be signed in to give consent"})
+			return
+		}
+		consent = &services.GuardianConsent{
+			GuardianID:   callerID,
+			Relationship: input.GuardianRelationship,
+			ConsentedBy:  callerID,
+			IP:           ctx.ClientIP(),
+		}
+		if input.GuardianID != nil {
+			if !middlewares.HasRole(ctx, models.RoleAdmin) {
+				ctx.JSON(http.StatusForbidden, gin.H{"error": "only admins can record consent for another guardian"})
+				return
+			}
+			consent.GuardianID = *input.GuardianID
+		}
+	}
+
 	// Call service
-	if err := c.service.Register(user, input.Password, planID, startDate, endDate, autoRenew); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	signup := services.Signup{
+		TrialProductID: input.TrialProductID,
+		PromoCode:      input.PromoCode,
+		ReferralCode:   input.ReferralCode,
+	}
+	if err := c.service.Register(user, input.Password, planID, signup, startDate, endDate, autoRenew, consent); err != nil {
+		switch {
+		case errors.Is(err, services.ErrGuardianConsentRequired):
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidGuardian), errors.Is(err, services.ErrInvalidPass), errors.Is(err, services.ErrPassProductNotFound),
+			errors.Is(err, services.ErrInvalidReferral), errors.Is(err, services.ErrPromoNotFound):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrPromoNotUsable):
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
+	// The account exists even if the mail cannot be sent; the user can ask
+	// for a new link through /auth/resend-verification.
+	if err := c.accounts.SendVerificationEmail(user); err != nil {
+		log.Printf("ERROR: failed to send verification email to %s: %v", user.Email, err)
+	}
+
 	ctx.JSON(http.StatusCreated, gin.H{"message": "User registered successfully"})
 }
 func (c *AuthController) Login(ctx *gin.Context) {
 	var input struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		DeviceID string `json:"device_id"`
 	}
 	if err := ctx.ShouldBindJSON(&input); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	user, ok := c.service.Login(input.Email, input.Password)
-	if !ok {
+	user, err := c.service.Login(input.Email, input.Password, ctx.ClientIP())
+	if respondThrottled(ctx, err) {
+		return
+	}
+	if errors.Is(err, services.ErrEmailNotVerified) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
 		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	accessToken, refreshToken, err := c.service.GenerateTokens(user)
+	result, err := completeLogin(c.service, c.mfa, user, clientInfo(ctx, input.DeviceID))
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	ctx.JSON(http.StatusOK, result)
+}
 
-	ctx.JSON(http.StatusOK, gin.H{
+// completeLogin builds the response for a user whose first factor checked
+// out. With 2FA it only earns a short-lived challenge token, which
+// /auth/login/mfa exchanges for real tokens.
+func completeLogin(auth *services.AuthService, mfa *services.MFAService, user *models.User, client services.ClientInfo) (gin.H, error) {
+	if user.TOTPEnabled {
+		mfaToken, err := mfa.IssueChallenge(user)
+		if err != nil {
+			return nil, err
+		}
+		return gin.H{"mfa_required": true, "mfa_token": mfaToken}, nil
+	}
+	if mfa.EnrollmentRequired(user) {
+		enrollmentToken, err := mfa.IssueEnrollmentToken(user)
+		if err != nil {
+			return nil, err
+		}
+		return gin.H{"mfa_enrollment_required": true, "enrollment_token": enrollmentToken}, nil
+	}
+
+	accessToken, refreshToken, err := auth.GenerateTokens(user, client)
+	if err != nil {
+		return nil, err
+	}
+	return gin.H{
 		"access_token":  accessToken,
 		"refresh_token": refreshToken,
 		"user":          user,
+	}, nil
+}
+
+// respondThrottled answers 429 with a Retry-After header when err is a
+// LoginThrottledError and reports whether it did.
+func respondThrottled(ctx *gin.Context, err error) bool {
+	var throttled *services.LoginThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+	retryAfter := int(math.Ceil(throttled.RetryAfter.Seconds()))
+	ctx.Header("Retry-After", strconv.Itoa(retryAfter))
+	ctx.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       throttled.Error(),
+		"locked":      throttled.Locked,
+		"retry_after": retryAfter,
 	})
+	return true
 }
 
-// GetAllUsers returns all users
-func (c *AuthController) GetAllUsers(ctx *gin.Context) {
-	users, err := c.service.GetAllUsers()
+// Refresh exchanges a refresh token for a new token pair (POST /auth/refresh)
+func (c *AuthController) Refresh(ctx *gin.Context) {
+	var input struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+		DeviceID     string `json:"device_id"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	accessToken, refreshToken, err := c.service.Refresh(input.RefreshToken, clientInfo(ctx, input.DeviceID))
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{
-		"users": users,
-		"count": len(users),
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
 	})
 }
 
-// GetTrainers returns only users with user_type "Trainer"
-func (c *AuthController) GetTrainers(ctx *gin.Context) {
-	trainers, err := c.service.GetUsersByType("Trainer")
+// Logout revokes the caller's access token and, if sent, the refresh token
+// of the same session (POST /auth/logout)
+func (c *AuthController) Logout(ctx *gin.Context) {
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	// The body is optional
+	_ = ctx.ShouldBindJSON(&input)
+
+	userID, _ := middlewares.CurrentUserID(ctx)
+	jti := ctx.GetString("jti")
+	expiresAt := ctx.GetTime("token_expires_at")
+
+	if err := c.revocations.Logout(userID, jti, expiresAt, input.RefreshToken); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// LogoutAll revokes every token of the caller on every device (POST /auth/logout-all)
+func (c *AuthController) LogoutAll(ctx *gin.Context) {
+	userID, _ := middlewares.CurrentUserID(ctx)
+
+	if err := c.revocations.LogoutAll(userID, userID, "logout_all"); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Logged out of all sessions"})
+}
+
+// UnlockUser lifts a login lockout (POST /auth/users/:id/unlock)
+func (c *AuthController) UnlockUser(ctx *gin.Context) {
+	targetID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	adminID, _ := middlewares.CurrentUserID(ctx)
+
+	if err := c.throttle.Unlock(adminID, targetID); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "User unlocked"})
+}
+
+// RevokeUserSessions lets an admin end every session of another user, e.g.
+// when an employee leaves (POST /auth/users/:id/logout-all)
+func (c *AuthController) RevokeUserSessions(ctx *gin.Context) {
+	targetID, err := uuid.Parse(ctx.Param("id"))
 	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	adminID, _ := middlewares.CurrentUserID(ctx)
+
+	if err := c.revocations.LogoutAll(adminID, targetID, "revoked_by_admin"); err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "All sessions of the user were revoked"})
+}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"trainers": trainers,
-		"count":    len(trainers),
-	})
+// clientInfo describes the calling device for refresh token bookkeeping.
+// The device ID may come from the request body or the X-Device-ID header.
+func clientInfo(ctx *gin.Context, deviceID string) services.ClientInfo {
+	if deviceID == "" {
+		deviceID = ctx.GetHeader("X-Device-ID")
+	}
+	return services.ClientInfo{
+		DeviceID:  truncate(deviceID, 100),
+		UserAgent: truncate(ctx.Request.UserAgent(), 255),
+		IPAddress: truncate(ctx.ClientIP(), 45),
+	}
+}
+
+func truncate(s string, max int) string {
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
+}
+
+// GetAllUsers returns one page of the user directory
+func (c *AuthController) GetAllUsers(ctx *gin.Context) {
+	req, ok := listRequest(ctx)
+	if !ok {
+		return
+	}
+	users, err := c.service.GetAllUsers(req)
+	respondList(ctx, users, err)
+}
+
+// GetTrainers returns one page of users with user_type "Trainer"
+func (c *AuthController) GetTrainers(ctx *gin.Context) {
+	req, ok := listRequest(ctx)
+	if !ok {
+		return
+	}
+	trainers, err := c.service.GetUsersByType(models.RoleTrainer, req)
+	respondList(ctx, trainers, err)
 }
\ No newline at end of file
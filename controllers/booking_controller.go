@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	services "go-blog/internal/service"
+	"go-blog/middlewares"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// BookingController books class sessions for members. Guardians may book
+// and cancel for their dependents.
+type BookingController struct {
+	service *services.BookingService
+}
+
+func NewBookingController(service *services.BookingService) *BookingController {
+	return &BookingController{service: service}
+}
+
+// Book reserves a place (POST /bookings). member_id defaults to the caller's
+// own member profile.
+func (c *BookingController) Book(ctx *gin.Context) {
+	var payload struct {
+		SessionID uuid.UUID  `json:"session_id" binding:"required"`
+		MemberID  *uuid.UUID `json:"member_id"`
+	}
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var memberID uuid.UUID
+	if payload.MemberID != nil {
+		memberID = *payload.MemberID
+	} else {
+		own, err := middlewares.CurrentMemberID(ctx)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "member_id is required"})
+			return
+		}
+		memberID = own
+	}
+	if !middlewares.CanActForMember(ctx, memberID) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "you may only book for yourself or your dependents"})
+		return
+	}
+	actorID, _ := middlewares.CurrentUserID(ctx)
+
+	booking, err := c.service.Book(actorID, memberID, payload.SessionID)
+	if err != nil {
+		respondBookingError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, booking)
+}
+
+// Cancel frees a booked place (DELETE /bookings/:id)
+func (c *BookingController) Cancel(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid booking id"})
+		return
+	}
+	booking, err := c.service.Get(id)
+	if err == nil && !middlewares.CanActForMember(ctx, booking.MemberID) {
+		err = services.ErrBookingNotFound
+	}
+	if err == nil {
+		err = c.service.Cancel(booking)
+	}
+	if err != nil {
+		respondBookingError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Booking cancelled"})
+}
+
+// GetMemberBookings lists a member's bookings (GET /bookings/member/:member_id)
+func (c *BookingController) GetMemberBookings(ctx *gin.Context) {
+	memberID, err := uuid.Parse(ctx.Param("member_id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid member_id"})
+		return
+	}
+	req, ok := listRequest(ctx)
+	if !ok {
+		return
+	}
+	bookings, err := c.service.ListByMember(memberID, req)
+	respondList(ctx, bookings, err)
+}
+
+func respondBookingError(ctx *gin.Context, err error) {
+	if respondAccessDenied(ctx, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, services.ErrBookingNotFound), errors.Is(err, services.ErrSessionNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSessionFull), errors.Is(err, services.ErrAlreadyBooked):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSessionNotBookable), errors.Is(err, services.ErrNotCancellable):
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process booking"})
+	}
+}
@@ -1,7 +1,9 @@
 package controllers
 
 import (
+	"errors"
 	services "go-blog/internal/service"
+	"go-blog/middlewares"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -15,6 +17,14 @@ func NewClassController(service *services.ClassService) *ClassController {
 	return &ClassController{service: service}
 }
 
+// scoped limits API key callers to classes of their gym
+func (c *ClassController) scoped(ctx *gin.Context) *services.ClassService {
+	if gymID, ok := middlewares.APIKeyGymID(ctx); ok {
+		return c.service.ForGym(gymID)
+	}
+	return c.service
+}
+
 // POST /class
 func (c *ClassController) CreateClass(ctx *gin.Context) {
 	var body struct {
@@ -24,6 +34,7 @@ func (c *ClassController) CreateClass(ctx *gin.Context) {
 		Description     string `json:"description"`
 		Capacity        int    `json:"capacity" binding:"required"`
 		DurationMinutes int    `json:"duration_minutes" binding:"required"`
+		Category        string `json:"category"` // e.g. yoga, spin; plans may limit access by category
 	}
 	if err := ctx.ShouldBindJSON(&body); err != nil {
 		ctx.JSON(400, gin.H{"error": err.Error()})
@@ -33,7 +44,11 @@ func (c *ClassController) CreateClass(ctx *gin.Context) {
 	gymUUID, _ := uuid.Parse(body.GymID)
 	trainerUUID, _ := uuid.Parse(body.TrainerID)
 
-	class, err := c.service.CreateClass(gymUUID, trainerUUID, body.Title, body.Description, body.Capacity, body.DurationMinutes)
+	class, err := c.scoped(ctx).CreateClass(gymUUID, trainerUUID, body.Title, body.Description, body.Category, body.Capacity, body.DurationMinutes)
+	if errors.Is(err, services.ErrOutsideGymScope) {
+		ctx.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		ctx.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -43,12 +58,12 @@ func (c *ClassController) CreateClass(ctx *gin.Context) {
 
 // GET /class
 func (c *ClassController) ListClasses(ctx *gin.Context) {
-	classes, err := c.service.ListClasses()
-	if err != nil {
-		ctx.JSON(500, gin.H{"error": err.Error()})
+	req, ok := listRequest(ctx)
+	if !ok {
 		return
 	}
-	ctx.JSON(200, classes)
+	classes, err := c.scoped(ctx).ListClasses(req)
+	respondList(ctx, classes, err)
 }
 
 // GET /class/:id
@@ -56,7 +71,11 @@ func (c *ClassController) GetClass(ctx *gin.Context) {
 	id := ctx.Param("id")
 	classUUID, _ := uuid.Parse(id)
 
-	class, err := c.service.GetClass(classUUID)
+	class, err := c.scoped(ctx).GetClass(classUUID)
+	if errors.Is(err, services.ErrOutsideGymScope) {
+		ctx.JSON(404, gin.H{"error": "class not found"})
+		return
+	}
 	if err != nil {
 		ctx.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -2,7 +2,9 @@
 package controllers
 
 import (
+	"errors"
 	services "go-blog/internal/service"
+	"go-blog/middlewares"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -17,6 +19,14 @@ func NewClassSessionController(service *services.ClassSessionService) *ClassSess
 	return &ClassSessionController{service: service}
 }
 
+// scoped limits API key callers to sessions of their gym
+func (c *ClassSessionController) scoped(ctx *gin.Context) *services.ClassSessionService {
+	if gymID, ok := middlewares.APIKeyGymID(ctx); ok {
+		return c.service.ForGym(gymID)
+	}
+	return c.service
+}
+
 // POST /classsession
 // In controllers/classsession_controller.go
 func (c *ClassSessionController) CreateSession(ctx *gin.Context) {
@@ -67,7 +77,11 @@ func (c *ClassSessionController) CreateSession(ctx *gin.Context) {
 		}
 	}
 
-	session, err := c.service.CreateSession(classUUID, startTime, endTime)
+	session, err := c.scoped(ctx).CreateSession(classUUID, startTime, endTime)
+	if errors.Is(err, services.ErrOutsideGymScope) {
+		ctx.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		ctx.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -77,12 +91,12 @@ func (c *ClassSessionController) CreateSession(ctx *gin.Context) {
 
 // GET /classsession
 func (c *ClassSessionController) ListSessions(ctx *gin.Context) {
-	sessions, err := c.service.ListSessions()
-	if err != nil {
-		ctx.JSON(500, gin.H{"error": err.Error()})
+	req, ok := listRequest(ctx)
+	if !ok {
 		return
 	}
-	ctx.JSON(200, sessions)
+	sessions, err := c.scoped(ctx).ListSessions(req)
+	respondList(ctx, sessions, err)
 }
 
 // GET /classsession/:id
@@ -94,7 +108,11 @@ func (c *ClassSessionController) GetSession(ctx *gin.Context) {
 		return
 	}
 	
-	session, err := c.service.GetSession(sessionUUID)
+	session, err := c.scoped(ctx).GetSession(sessionUUID)
+	if errors.Is(err, services.ErrOutsideGymScope) {
+		ctx.JSON(404, gin.H{"error": "session not found"})
+		return
+	}
 	if err != nil {
 		ctx.JSON(500, gin.H{"error": err.Error()})
 		return
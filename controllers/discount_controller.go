@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"go-blog/internal/models"
+	services "go-blog/internal/service"
+	"go-blog/middlewares"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type DiscountController struct {
+	service *services.DiscountService
+}
+
+func NewDiscountController(service *services.DiscountService) *DiscountController {
+	return &DiscountController{service: service}
+}
+
+// POST /api/promo-codes
+func (c *DiscountController) CreatePromo(ctx *gin.Context) {
+	var promo models.PromoCode
+	if err := ctx.ShouldBindJSON(&promo); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	actorID, _ := middlewares.CurrentUserID(ctx)
+	if err := c.service.CreatePromo(actorID, &promo); err != nil {
+		respondDiscountError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, promo)
+}
+
+// GET /api/promo-codes lists the promo codes on offer; add
+// ?include_archived=true for all of them
+func (c *DiscountController) ListPromos(ctx *gin.Context) {
+	req, ok := listRequest(ctx)
+	if !ok {
+		return
+	}
+	promos, err := c.service.ListPromos(req, ctx.Query("include_archived") == "true")
+	respondList(ctx, promos, err)
+}
+
+// POST /api/promo-codes/:id/archive
+func (c *DiscountController) ArchivePromo(ctx *gin.Context) {
+	id, ok := promoID(ctx)
+	if !ok {
+		return
+	}
+	actorID, _ := middlewares.CurrentUserID(ctx)
+	promo, err := c.service.ArchivePromo(actorID, id)
+	if err != nil {
+		respondDiscountError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, promo)
+}
+
+// GET /api/promo-codes/:id/redemptions
+func (c *DiscountController) Redemptions(ctx *gin.Context) {
+	id, ok := promoID(ctx)
+	if !ok {
+		return
+	}
+	req, ok := listRequest(ctx)
+	if !ok {
+		return
+	}
+	redemptions, err := c.service.Redemptions(id, req)
+	if errors.Is(err, services.ErrPromoNotFound) {
+		respondDiscountError(ctx, err)
+		return
+	}
+	respondList(ctx, redemptions, err)
+}
+
+// CheckPromo quotes a promo code against a plan before registering
+// (GET /api/promo-codes/check?code=&plan_id=)
+func (c *DiscountController) CheckPromo(ctx *gin.Context) {
+	var input struct {
+		Code   string    `form:"code" binding:"required"`
+		PlanID uuid.UUID `form:"plan_id" binding:"required"`
+	}
+	if err := ctx.ShouldBindQuery(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	quote, err := c.service.CheckPromo(input.Code, input.PlanID)
+	if err != nil {
+		respondDiscountError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, quote)
+}
+
+// Referrals returns a member's referral code, the members they referred and
+// their account credit (GET /api/referrals/:memberID)
+func (c *DiscountController) Referrals(ctx *gin.Context) {
+	memberID, err := uuid.Parse(ctx.Param("memberID"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid member id"})
+		return
+	}
+	summary, err := c.service.Referrals(memberID)
+	if err != nil {
+		respondDiscountError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, summary)
+}
+
+func promoID(ctx *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid promo code id"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func respondDiscountError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPromoNotFound), errors.Is(err, services.ErrPlanNotFound), errors.Is(err, services.ErrMemberNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPromoNotUsable):
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPromo), errors.Is(err, services.ErrInvalidReferral):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process promo code"})
+	}
+}
@@ -42,12 +42,12 @@ func (c *GymController) CreateGym(ctx *gin.Context) {
 
 // GET /gym
 func (c *GymController) ListGyms(ctx *gin.Context) {
-	gyms, err := c.service.ListGyms()
-	if err != nil {
-		ctx.JSON(500, gin.H{"error": err.Error()})
+	req, ok := listRequest(ctx)
+	if !ok {
 		return
 	}
-	ctx.JSON(200, gyms)
+	gyms, err := c.service.ListGyms(req)
+	respondList(ctx, gyms, err)
 }
 
 // GET /gym/:id
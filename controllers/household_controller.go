@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"errors"
+	services "go-blog/internal/service"
+	"go-blog/middlewares"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// HouseholdController manages households and guardian links
+type HouseholdController struct {
+	service *services.HouseholdService
+}
+
+func NewHouseholdController(service *services.HouseholdService) *HouseholdController {
+	return &HouseholdController{service: service}
+}
+
+// Create starts a household paid for by the caller (POST /households)
+func (c *HouseholdController) Create(ctx *gin.Context) {
+	var input struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	household, err := c.service.Create(householdActor(ctx).UserID, input.Name)
+	if err != nil {
+		respondHouseholdError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, household)
+}
+
+// List returns one page of households (GET /households)
+func (c *HouseholdController) List(ctx *gin.Context) {
+	req, ok := listRequest(ctx)
+	if !ok {
+		return
+	}
+	households, err := c.service.List(req)
+	respondList(ctx, households, err)
+}
+
+// Get returns a household with its members (GET /households/:id)
+func (c *HouseholdController) Get(ctx *gin.Context) {
+	id, ok := householdID(ctx)
+	if !ok {
+		return
+	}
+	household, err := c.service.Get(householdActor(ctx), id)
+	if err != nil {
+		respondHouseholdError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, household)
+}
+
+// Mine returns the caller's household (GET /me/household)
+func (c *HouseholdController) Mine(ctx *gin.Context) {
+	household, err := c.service.Mine(householdActor(ctx).UserID)
+	if err != nil {
+		respondHouseholdError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, household)
+}
+
+// AddMember puts a user into a household (POST /households/:id/members)
+func (c *HouseholdController) AddMember(ctx *gin.Context) {
+	id, ok := householdID(ctx)
+	if !ok {
+		return
+	}
+	var input struct {
+		UserID uuid.UUID `json:"user_id" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	household, err := c.service.AddMember(householdActor(ctx), id, input.UserID)
+	if err != nil {
+		respondHouseholdError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, household)
+}
+
+// RemoveMember takes a user out of a household
+// (DELETE /households/:id/members/:user_id)
+func (c *HouseholdController) RemoveMember(ctx *gin.Context) {
+	id, ok := householdID(ctx)
+	if !ok {
+		return
+	}
+	userID, err := uuid.Parse(ctx.Param("user_id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	if err := c.service.RemoveMember(householdActor(ctx), id, userID); err != nil {
+		respondHouseholdError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Member removed from household"})
+}
+
+// SetPrimaryPayer hands billing to another adult
+// (PUT /households/:id/primary-payer)
+func (c *HouseholdController) SetPrimaryPayer(ctx *gin.Context) {
+	id, ok := householdID(ctx)
+	if !ok {
+		return
+	}
+	var input struct {
+		UserID uuid.UUID `json:"user_id" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	household, err := c.service.SetPrimaryPayer(householdActor(ctx), id, input.UserID)
+	if err != nil {
+		respondHouseholdError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, household)
+}
+
+// Payments lists the payments of the whole household
+// (GET /households/:id/payments)
+func (c *HouseholdController) Payments(ctx *gin.Context) {
+	id, ok := householdID(ctx)
+	if !ok {
+		return
+	}
+	req, ok := listRequest(ctx)
+	if !ok {
+		return
+	}
+	payments, err := c.service.Payments(householdActor(ctx), id, req)
+	if errors.Is(err, services.ErrHouseholdNotFound) || errors.Is(err, services.ErrHouseholdForbidden) {
+		respondHouseholdError(ctx, err)
+		return
+	}
+	respondList(ctx, payments, err)
+}
+
+// Dependents lists the users the caller is a guardian of (GET /me/dependents)
+func (c *HouseholdController) Dependents(ctx *gin.Context) {
+	links, err := c.service.Dependents(householdActor(ctx).UserID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load dependents"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": links})
+}
+
+// LinkGuardian records guardian consent for an existing account
+// (POST /guardians)
+func (c *HouseholdController) LinkGuardian(ctx *gin.Context) {
+	var input struct {
+		GuardianID   uuid.UUID `json:"guardian_id" binding:"required"`
+		DependentID  uuid.UUID `json:"dependent_id" binding:"required"`
+		Relationship string    `json:"relationship"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	actorID := householdActor(ctx).UserID
+	consent := services.GuardianConsent{
+		GuardianID:   input.GuardianID,
+		Relationship: input.Relationship,
+		IP:           ctx.ClientIP(),
+	}
+	link, err := c.service.LinkGuardian(actorID, consent, input.DependentID)
+	if err != nil {
+		respondHouseholdError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, link)
+}
+
+// RevokeGuardian ends a guardian link (DELETE /guardians/:id)
+func (c *HouseholdController) RevokeGuardian(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid guardian link id"})
+		return
+	}
+	if err := c.service.RevokeGuardianLink(householdActor(ctx).UserID, id); err != nil {
+		respondHouseholdError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Guardian link revoked"})
+}
+
+func householdActor(ctx *gin.Context) services.HouseholdActor {
+	userID, _ := middlewares.CurrentUserID(ctx)
+	return services.HouseholdActor{UserID: userID, IsStaff: middlewares.HasRole(ctx, middlewares.StaffRoles()...)}
+}
+
+func householdID(ctx *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid household id"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func respondHouseholdError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrHouseholdNotFound), errors.Is(err, services.ErrGuardianLinkNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrHouseholdForbidden), errors.Is(err, services.ErrGuardianConsentRequired):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAlreadyInHousehold):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNotInHousehold), errors.Is(err, services.ErrInvalidHouseholdArg), errors.Is(err, services.ErrInvalidGuardian):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"errors"
+	services "go-blog/internal/service"
+	"go-blog/middlewares"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ImpersonationController lets admins view the app as another user
+type ImpersonationController struct {
+	service *services.ImpersonationService
+}
+
+func NewImpersonationController(service *services.ImpersonationService) *ImpersonationController {
+	return &ImpersonationController{service: service}
+}
+
+// Start returns a short-lived, read-only access token for the user
+// (POST /auth/users/:id/impersonate). POST /auth/logout with the token ends
+// the impersonation early.
+func (c *ImpersonationController) Start(ctx *gin.Context) {
+	targetID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	// The body is optional
+	_ = ctx.ShouldBindJSON(&input)
+	adminID, _ := middlewares.CurrentUserID(ctx)
+
+	impersonation, err := c.service.Start(adminID, targetID, input.Reason, ctx.ClientIP())
+	switch {
+	case errors.Is(err, services.ErrImpersonationTargetNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCannotImpersonate):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case err != nil:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusOK, impersonation)
+	}
+}
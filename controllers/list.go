@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"errors"
+	"go-blog/internal/query"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listRequest parses pagination, sort and filter parameters. On error it
+// has already answered 400.
+func listRequest(ctx *gin.Context) (*query.Request, bool) {
+	req, err := query.Parse(ctx.Request.URL.Query())
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return req, true
+}
+
+// respondList writes a query.Page, or the error of building it.
+func respondList(ctx *gin.Context, page interface{}, err error) {
+	var queryErr *query.Error
+	if errors.As(err, &queryErr) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": queryErr.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, page)
+}
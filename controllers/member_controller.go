@@ -1,7 +1,9 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -36,12 +38,28 @@ func (c *MemberController) CreateMember(ctx *gin.Context) {
 
 // GET /members
 func (c *MemberController) GetAllMembers(ctx *gin.Context) {
-	members, err := c.service.GetAllMembers()
+	req, ok := listRequest(ctx)
+	if !ok {
+		return
+	}
+	members, err := c.service.GetAllMembers(req)
+	respondList(ctx, members, err)
+}
+
+// GET /members/search?q=&limit=
+func (c *MemberController) SearchMembers(ctx *gin.Context) {
+	limit, _ := strconv.Atoi(ctx.Query("limit"))
+
+	results, err := c.service.SearchMembers(ctx.Query("q"), limit)
+	if errors.Is(err, services.ErrSearchTermTooShort) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	ctx.JSON(http.StatusOK, members)
+	ctx.JSON(http.StatusOK, gin.H{"data": results})
 }
 
 // GET /members/:id
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"errors"
+	"go-blog/internal/importer"
+	services "go-blog/internal/service"
+	"go-blog/middlewares"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxImportFileBytes caps uploaded spreadsheets
+const maxImportFileBytes = 10 << 20
+
+// MemberImportController imports members from CSV or XLSX spreadsheets
+type MemberImportController struct {
+	service *services.MemberImportService
+}
+
+func NewMemberImportController(service *services.MemberImportService) *MemberImportController {
+	return &MemberImportController{service: service}
+}
+
+// Import creates members from the multipart "file" field
+// (POST /members/import). Form fields: dry_run, password_mode (generate or
+// invite), gym_id and batch_size. With ?format=csv the report comes back as
+// CSV.
+func (c *MemberImportController) Import(ctx *gin.Context) {
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxImportFileBytes+64<<10)
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "the file may be at most 10 MB"})
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "multipart field \"file\" is required"})
+		return
+	}
+
+	opts := services.ImportOptions{
+		PasswordMode: ctx.PostForm("password_mode"),
+		Filename:     header.Filename,
+	}
+	opts.ActorID, _ = middlewares.CurrentUserID(ctx)
+	if raw := ctx.PostForm("dry_run"); raw != "" {
+		if opts.DryRun, err = strconv.ParseBool(raw); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "dry_run must be true or false"})
+			return
+		}
+	}
+	if raw := ctx.PostForm("batch_size"); raw != "" {
+		if opts.BatchSize, err = strconv.Atoi(raw); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "batch_size must be a number"})
+			return
+		}
+	}
+	if raw := ctx.PostForm("gym_id"); raw != "" {
+		gymID, err := uuid.Parse(raw)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid gym_id"})
+			return
+		}
+		opts.GymID = &gymID
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+	rows, err := importer.Read(header.Filename, file)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := c.service.Import(rows, opts)
+	if errors.Is(err, services.ErrInvalidImport) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "import failed: " + err.Error()})
+		return
+	}
+
+	if ctx.Query("format") == "csv" {
+		ctx.Header("Content-Type", "text/csv")
+		ctx.Header("Content-Disposition", `attachment; filename="import-report.csv"`)
+		if err := report.WriteCSV(ctx.Writer); err != nil {
+			ctx.Error(err)
+		}
+		return
+	}
+	ctx.JSON(http.StatusOK, report)
+}
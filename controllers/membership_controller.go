@@ -1,8 +1,10 @@
 package controllers
 
 import (
+	"errors"
 	"go-blog/internal/models"
 	services "go-blog/internal/service"
+	"go-blog/middlewares"
 	"net/http"
 	"strings" // Added for error string checking
 	"time"
@@ -19,7 +21,7 @@ type MembershipRequest struct {
 	PlanID    uuid.UUID `json:"plan_id" binding:"required"`
 	StartDate time.Time `json:"start_date" binding:"required"`
 	EndDate   time.Time `json:"end_date" binding:"required"`
-	Status    string    `json:"status" binding:"required"`
+	Status    string    `json:"status"` // pending or active; defaults from start_date
 	AutoRenew bool      `json:"auto_renew"`
 }
 
@@ -64,8 +66,17 @@ func (c *MembershipController) CreateMembership(ctx *gin.Context) {
 	}
 
 	// Call service layer with the populated model
-	if err := c.service.CreateMembership(m); err != nil {
-		
+	actorID, _ := middlewares.CurrentUserID(ctx)
+	if err := c.service.CreateMembership(actorID, m); err != nil {
+		if errors.Is(err, services.ErrInvalidMembership) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrPlanArchived) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+
 		errMsg := err.Error()
 
 		// Intercept Foreign Key violation errors (SQLSTATE 23503) for better client feedback (400 Bad Request).
@@ -88,14 +99,205 @@ func (c *MembershipController) CreateMembership(ctx *gin.Context) {
 	ctx.JSON(http.StatusCreated, m)
 }
 
-// GetByMember retrieves all memberships associated with a given member ID.
+// GetByMember retrieves one page of the memberships of a given member ID.
 func (c *MembershipController) GetByMember(ctx *gin.Context) {
 	memberID := ctx.Param("memberID")
 	// The service layer should handle validation and parsing of memberID string into uuid.UUID
-	memberships, err := c.service.GetByMember(memberID)
+	req, ok := listRequest(ctx)
+	if !ok {
+		return
+	}
+	memberships, err := c.service.GetByMember(memberID, req)
+	respondList(ctx, memberships, err)
+}
+
+// Update changes the renewal settings or end date of a membership
+// (PUT /api/memberships/:id)
+func (c *MembershipController) Update(ctx *gin.Context) {
+	id, ok := membershipID(ctx)
+	if !ok {
+		return
+	}
+	var input struct {
+		AutoRenew       *bool      `json:"auto_renew"`
+		PaymentMethodID *string    `json:"payment_method_id"`
+		EndDate         *time.Time `json:"end_date"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	actorID, _ := middlewares.CurrentUserID(ctx)
+	m, err := c.service.Update(actorID, id, services.MembershipUpdate{
+		AutoRenew:       input.AutoRenew,
+		PaymentMethodID: input.PaymentMethodID,
+		EndDate:         input.EndDate,
+	})
+	if err != nil {
+		respondMembershipError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, m)
+}
+
+// Delete removes a membership that has not started (DELETE /api/memberships/:id)
+func (c *MembershipController) Delete(ctx *gin.Context) {
+	id, ok := membershipID(ctx)
+	if !ok {
+		return
+	}
+	actorID, _ := middlewares.CurrentUserID(ctx)
+	if err := c.service.Delete(actorID, id); err != nil {
+		respondMembershipError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Membership deleted"})
+}
+
+// Freeze pauses a membership for a number of days and extends its end date
+// (POST /api/memberships/:id/freeze)
+func (c *MembershipController) Freeze(ctx *gin.Context) {
+	id, ok := membershipID(ctx)
+	if !ok {
+		return
+	}
+	var input struct {
+		Days   int    `json:"days" binding:"required"`
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	actorID, _ := middlewares.CurrentUserID(ctx)
+	m, err := c.service.Freeze(actorID, id, input.Days, input.Reason)
+	if err != nil {
+		respondMembershipError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, m)
+}
+
+// Unfreeze ends a freeze early (POST /api/memberships/:id/unfreeze)
+func (c *MembershipController) Unfreeze(ctx *gin.Context) {
+	id, ok := membershipID(ctx)
+	if !ok {
+		return
+	}
+	var input struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	actorID, _ := middlewares.CurrentUserID(ctx)
+	m, err := c.service.Unfreeze(actorID, id, input.Reason)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondMembershipError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, memberships)
+	ctx.JSON(http.StatusOK, m)
+}
+
+// Cancel ends a membership now or at the end of its period
+// (POST /api/memberships/:id/cancel)
+func (c *MembershipController) Cancel(ctx *gin.Context) {
+	id, ok := membershipID(ctx)
+	if !ok {
+		return
+	}
+	var input struct {
+		AtPeriodEnd bool   `json:"at_period_end"`
+		Reason      string `json:"reason" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	actorID, _ := middlewares.CurrentUserID(ctx)
+	m, err := c.service.Cancel(actorID, id, input.AtPeriodEnd, input.Reason)
+	if err != nil {
+		respondMembershipError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, m)
+}
+
+// PreviewPlanChange quotes moving a membership to another plan: the credit
+// for the unused days, any promo code discount and the amount due
+// (GET /api/memberships/change-plan/preview?membership_id=&plan_id=&promo_code=)
+func (c *MembershipController) PreviewPlanChange(ctx *gin.Context) {
+	var input struct {
+		MembershipID uuid.UUID `form:"membership_id" binding:"required"`
+		PlanID       uuid.UUID `form:"plan_id" binding:"required"`
+		PromoCode    string    `form:"promo_code"`
+	}
+	if err := ctx.ShouldBindQuery(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	m, err := c.service.Get(input.MembershipID)
+	if err == nil && !middlewares.CanActForMember(ctx, m.MemberID) {
+		err = services.ErrMembershipNotFound
+	}
+	if err != nil {
+		respondMembershipError(ctx, err)
+		return
+	}
+	quote, err := c.service.PreviewPlanChange(input.MembershipID, input.PlanID, input.PromoCode)
+	if err != nil {
+		respondMembershipError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, quote)
+}
+
+// ChangePlan moves a membership to another plan and raises the payment for
+// the difference (POST /api/memberships/:id/change-plan)
+func (c *MembershipController) ChangePlan(ctx *gin.Context) {
+	id, ok := membershipID(ctx)
+	if !ok {
+		return
+	}
+	var input struct {
+		PlanID    uuid.UUID `json:"plan_id" binding:"required"`
+		PromoCode string    `json:"promo_code"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	actorID, _ := middlewares.CurrentUserID(ctx)
+	change, err := c.service.ChangePlan(actorID, id, input.PlanID, input.PromoCode)
+	if err != nil {
+		respondMembershipError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, change)
+}
+
+func membershipID(ctx *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid membership id"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func respondMembershipError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrMembershipNotFound), errors.Is(err, services.ErrPromoNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPromoNotUsable):
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidTransition), errors.Is(err, services.ErrMembershipClosed), errors.Is(err, services.ErrNotDeletable),
+		errors.Is(err, services.ErrNotChangeable), errors.Is(err, services.ErrPlanArchived):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidMembership), errors.Is(err, services.ErrInvalidReason):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update membership"})
+	}
 }
@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"errors"
+	"go-blog/internal/config"
+	services "go-blog/internal/service"
+	"go-blog/middlewares"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MFAController serves two-factor login, enrollment and recovery codes
+type MFAController struct {
+	service *services.MFAService
+	auth    *services.AuthService
+}
+
+func NewMFAController(service *services.MFAService, auth *services.AuthService) *MFAController {
+	return &MFAController{service: service, auth: auth}
+}
+
+// LoginMFA finishes a login with a TOTP or recovery code (POST /auth/login/mfa)
+func (c *MFAController) LoginMFA(ctx *gin.Context) {
+	var input struct {
+		MFAToken     string `json:"mfa_token" binding:"required"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+		DeviceID     string `json:"device_id"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Code == "" && input.RecoveryCode == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "code or recovery_code is required"})
+		return
+	}
+
+	user, err := c.service.VerifyChallenge(input.MFAToken, input.Code, input.RecoveryCode, ctx.ClientIP())
+	if respondThrottled(ctx, err) {
+		return
+	}
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidMFAToken) || errors.Is(err, services.ErrInvalidMFACode) {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	accessToken, refreshToken, err := c.auth.GenerateTokens(user, clientInfo(ctx, input.DeviceID))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+		"user":          user,
+	})
+}
+
+// Enroll creates a new TOTP secret for the caller (POST /auth/2fa/enroll).
+// 2FA is only switched on once /auth/2fa/verify accepts a code.
+func (c *MFAController) Enroll(ctx *gin.Context) {
+	userID, _ := middlewares.CurrentUserID(ctx)
+
+	enrollment, err := c.service.StartEnrollment(userID)
+	if err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+
+	png, err := c.service.QRCode(userID, 256)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"secret":      enrollment.Secret,
+		"otpauth_url": enrollment.OTPAuthURL,
+		"qr_code":     "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	})
+}
+
+// QRCode returns the provisioning QR code of a pending enrollment as an
+// image (GET /auth/2fa/qr.png). Admins impersonating a user never see it.
+func (c *MFAController) QRCode(ctx *gin.Context) {
+	if _, ok := middlewares.ImpersonatorID(ctx); ok {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "two-factor secrets are not shown to impersonation sessions"})
+		return
+	}
+	userID, _ := middlewares.CurrentUserID(ctx)
+
+	png, err := c.service.QRCode(userID, 256)
+	if err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Data(http.StatusOK, "image/png", png)
+}
+
+// Verify confirms enrollment with a code from the app (POST /auth/2fa/verify).
+// Callers holding an enrollment token are logged in at the same time.
+func (c *MFAController) Verify(ctx *gin.Context) {
+	var input struct {
+		Code     string `json:"code" binding:"required"`
+		DeviceID string `json:"device_id"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := middlewares.CurrentUserID(ctx)
+
+	codes, err := c.service.ConfirmEnrollment(userID, input.Code)
+	if err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+
+	response := gin.H{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	}
+	if ctx.GetString("token_type") == config.TokenTypeMFAEnroll {
+		user, err := c.auth.GetUserByID(userID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		accessToken, refreshToken, err := c.auth.GenerateTokens(user, clientInfo(ctx, input.DeviceID))
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		response["access_token"] = accessToken
+		response["refresh_token"] = refreshToken
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes
+// (POST /auth/2fa/recovery-codes)
+func (c *MFAController) RegenerateRecoveryCodes(ctx *gin.Context) {
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := middlewares.CurrentUserID(ctx)
+
+	if err := c.service.VerifyCode(userID, input.Code); err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+	codes, err := c.service.RegenerateRecoveryCodes(userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// Disable turns 2FA off (POST /auth/2fa/disable)
+func (c *MFAController) Disable(ctx *gin.Context) {
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := middlewares.CurrentUserID(ctx)
+
+	if err := c.service.Disable(userID, input.Code); err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+func (c *MFAController) respondError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidMFACode):
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMFARequired):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMFAAlreadyEnabled), errors.Is(err, services.ErrMFANotEnabled), errors.Is(err, services.ErrMFANotEnrolled):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
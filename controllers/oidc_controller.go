@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	services "go-blog/internal/service"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oidcFlowCookie holds the signed state of a provider login between the
+// redirect and the callback. It is scoped to the OIDC routes.
+const (
+	oidcFlowCookie     = "oidc_flow"
+	oidcFlowCookiePath = "/auth/oidc"
+)
+
+// OIDCController serves login through external OpenID Connect providers
+type OIDCController struct {
+	service *services.OIDCService
+	auth    *services.AuthService
+	mfa     *services.MFAService
+}
+
+func NewOIDCController(service *services.OIDCService, auth *services.AuthService, mfa *services.MFAService) *OIDCController {
+	return &OIDCController{service: service, auth: auth, mfa: mfa}
+}
+
+// Providers lists the configured providers (GET /auth/oidc/providers)
+func (c *OIDCController) Providers(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"data": c.service.Providers()})
+}
+
+// Login redirects the browser to the provider
+// (GET /auth/oidc/:provider/login?return_to=&login_hint=)
+func (c *OIDCController) Login(ctx *gin.Context) {
+	authURL, flowToken, err := c.service.Begin(ctx.Request.Context(), ctx.Param("provider"), ctx.Query("return_to"), ctx.Query("login_hint"))
+	switch {
+	case errors.Is(err, services.ErrUnknownOIDCProvider):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrInvalidReturnTo):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.setFlowCookie(ctx, flowToken, 600)
+	ctx.Redirect(http.StatusFound, authURL)
+}
+
+// Callback finishes the login (GET /auth/oidc/:provider/callback). The
+// result has the same shape as /auth/login. When the login was started with
+// return_to, the browser is sent there with the result in the URL fragment;
+// otherwise it is returned as JSON.
+func (c *OIDCController) Callback(ctx *gin.Context) {
+	flowToken, _ := ctx.Cookie(oidcFlowCookie)
+	c.setFlowCookie(ctx, "", -1)
+	returnTo := c.service.ReturnTo(flowToken)
+
+	if providerErr := ctx.Query("error"); providerErr != "" {
+		c.respond(ctx, returnTo, http.StatusUnauthorized, gin.H{"error": fmt.Sprintf("login was cancelled or refused: %s", providerErr)})
+		return
+	}
+
+	login, err := c.service.Complete(ctx.Request.Context(), ctx.Param("provider"), flowToken, ctx.Query("state"), ctx.Query("code"))
+	if err != nil {
+		var throttled *services.LoginThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			c.respond(ctx, returnTo, http.StatusTooManyRequests, gin.H{"error": throttled.Error(), "locked": throttled.Locked})
+		case errors.Is(err, services.ErrUnknownOIDCProvider):
+			c.respond(ctx, returnTo, http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidOIDCState):
+			c.respond(ctx, returnTo, http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrOIDCEmailNotVerified), errors.Is(err, services.ErrOIDCDomainNotAllowed):
+			c.respond(ctx, returnTo, http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.respond(ctx, returnTo, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	result, err := completeLogin(c.auth, c.mfa, login.User, clientInfo(ctx, ""))
+	if err != nil {
+		c.respond(ctx, returnTo, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	result["created"] = login.Created
+	c.respond(ctx, returnTo, http.StatusOK, result)
+}
+
+// respond answers with JSON, or redirects to returnTo with the scalar fields
+// of body in the fragment, which never reaches any server logs.
+func (c *OIDCController) respond(ctx *gin.Context, returnTo string, status int, body gin.H) {
+	if returnTo == "" {
+		ctx.JSON(status, body)
+		return
+	}
+
+	fragment := url.Values{}
+	for key, value := range body {
+		switch v := value.(type) {
+		case string:
+			fragment.Set(key, v)
+		case bool:
+			fragment.Set(key, fmt.Sprint(v))
+		}
+	}
+	target, _ := url.Parse(returnTo)
+	target.Fragment = ""
+	ctx.Redirect(http.StatusFound, target.String()+"#"+fragment.Encode())
+}
+
+func (c *OIDCController) setFlowCookie(ctx *gin.Context, value string, maxAge int) {
+	secure := ctx.Request.TLS != nil || ctx.GetHeader("X-Forwarded-Proto") == "https"
+	// Lax, because the callback is a top-level navigation from the provider
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(oidcFlowCookie, value, maxAge, oidcFlowCookiePath, "", secure, true)
+}
@@ -0,0 +1,299 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"go-blog/internal/models"
+	services "go-blog/internal/service"
+	"go-blog/middlewares"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type PassController struct {
+	service *services.PassService
+}
+
+func NewPassController(service *services.PassService) *PassController {
+	return &PassController{service: service}
+}
+
+// scoped limits API key callers, such as check-in kiosks, to their gym
+func (c *PassController) scoped(ctx *gin.Context) *services.PassService {
+	if gymID, ok := middlewares.APIKeyGymID(ctx); ok {
+		return c.service.ForGym(gymID)
+	}
+	return c.service
+}
+
+// POST /passes/products
+func (c *PassController) CreateProduct(ctx *gin.Context) {
+	var product models.PassProduct
+	if err := ctx.ShouldBindJSON(&product); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := c.service.CreateProduct(&product); err != nil {
+		respondPassError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, product)
+}
+
+// GET /passes/products lists the pass products on sale; staff may add
+// ?include_archived=true
+func (c *PassController) ListProducts(ctx *gin.Context) {
+	req, ok := listRequest(ctx)
+	if !ok {
+		return
+	}
+	includeArchived := ctx.Query("include_archived") == "true" && middlewares.HasRole(ctx, middlewares.StaffRoles()...)
+	products, err := c.service.ListProducts(req, includeArchived)
+	respondList(ctx, products, err)
+}
+
+// POST /passes/products/:id/archive
+func (c *PassController) ArchiveProduct(ctx *gin.Context) {
+	id, ok := passID(ctx)
+	if !ok {
+		return
+	}
+	product, err := c.service.ArchiveProduct(id)
+	if err != nil {
+		respondPassError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, product)
+}
+
+// POST /passes sells a single visit or trial, to a member or a walk-in
+func (c *PassController) Sell(ctx *gin.Context) {
+	var input struct {
+		ProductID     uuid.UUID  `json:"product_id" binding:"required"`
+		MemberID      *uuid.UUID `json:"member_id"`
+		HolderName    string     `json:"holder_name"`
+		HolderEmail   string     `json:"holder_email"`
+		HolderPhone   string     `json:"holder_phone"`
+		PaymentMethod string     `json:"payment_method"` // cash, card, ...; empty when not paid yet
+		ValidFrom     *time.Time `json:"valid_from"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	actorID, _ := middlewares.CurrentUserID(ctx)
+	pass, err := c.service.Sell(actorID, services.PassSale{
+		ProductID:     input.ProductID,
+		MemberID:      input.MemberID,
+		HolderName:    input.HolderName,
+		HolderEmail:   input.HolderEmail,
+		HolderPhone:   input.HolderPhone,
+		PaymentMethod: input.PaymentMethod,
+		ValidFrom:     input.ValidFrom,
+	})
+	if err != nil {
+		respondPassError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, pass)
+}
+
+// POST /passes/guest issues a guest pass sponsored by the caller, or by a
+// member staff name
+func (c *PassController) IssueGuestPass(ctx *gin.Context) {
+	var input struct {
+		ProductID  uuid.UUID  `json:"product_id" binding:"required"`
+		SponsorID  *uuid.UUID `json:"sponsor_id"`
+		GuestName  string     `json:"guest_name" binding:"required"`
+		GuestEmail string     `json:"guest_email"`
+		GuestPhone string     `json:"guest_phone"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var sponsorID uuid.UUID
+	if input.SponsorID != nil {
+		sponsorID = *input.SponsorID
+	} else {
+		own, err := middlewares.CurrentMemberID(ctx)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "sponsor_id is required"})
+			return
+		}
+		sponsorID = own
+	}
+	if !middlewares.CanActForMember(ctx, sponsorID) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "you may only sponsor guests yourself or for your dependents"})
+		return
+	}
+	actorID, _ := middlewares.CurrentUserID(ctx)
+
+	pass, err := c.service.IssueGuestPass(actorID, sponsorID, input.ProductID, services.PassHolder{
+		Name:  input.GuestName,
+		Email: input.GuestEmail,
+		Phone: input.GuestPhone,
+	})
+	if err != nil {
+		respondPassError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, pass)
+}
+
+// GET /passes
+func (c *PassController) List(ctx *gin.Context) {
+	req, ok := listRequest(ctx)
+	if !ok {
+		return
+	}
+	passes, err := c.service.List(req)
+	respondList(ctx, passes, err)
+}
+
+// GET /passes/member/:member_id lists the passes a member holds or sponsored
+func (c *PassController) ListByMember(ctx *gin.Context) {
+	memberID, err := uuid.Parse(ctx.Param("member_id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid member_id"})
+		return
+	}
+	req, ok := listRequest(ctx)
+	if !ok {
+		return
+	}
+	passes, err := c.service.ListByMember(memberID, req)
+	respondList(ctx, passes, err)
+}
+
+// GET /passes/:id returns a pass with its visits
+func (c *PassController) Get(ctx *gin.Context) {
+	id, ok := passID(ctx)
+	if !ok {
+		return
+	}
+	pass, err := c.service.Get(id)
+	if err == nil && !canSeePass(ctx, pass) {
+		err = services.ErrPassNotFound
+	}
+	if err != nil {
+		respondPassError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, pass)
+}
+
+// POST /passes/:id/convert turns a trial into a membership
+func (c *PassController) Convert(ctx *gin.Context) {
+	id, ok := passID(ctx)
+	if !ok {
+		return
+	}
+	var input struct {
+		PlanID    uuid.UUID  `json:"plan_id" binding:"required"`
+		MemberID  *uuid.UUID `json:"member_id"` // required for walk-ins, once registered
+		StartDate *time.Time `json:"start_date"`
+		AutoRenew *bool      `json:"auto_renew"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	autoRenew := true
+	if input.AutoRenew != nil {
+		autoRenew = *input.AutoRenew
+	}
+	actorID, _ := middlewares.CurrentUserID(ctx)
+	membership, err := c.service.ConvertTrial(actorID, id, services.TrialConversion{
+		PlanID:    input.PlanID,
+		MemberID:  input.MemberID,
+		StartDate: input.StartDate,
+		AutoRenew: autoRenew,
+	})
+	if err != nil {
+		respondPassError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, membership)
+}
+
+// POST /passes/:id/void
+func (c *PassController) Void(ctx *gin.Context) {
+	id, ok := passID(ctx)
+	if !ok {
+		return
+	}
+	actorID, _ := middlewares.CurrentUserID(ctx)
+	pass, err := c.service.Void(actorID, id)
+	if err != nil {
+		respondPassError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, pass)
+}
+
+// POST /attendance/pass-checkin redeems a pass by its code, for a session or
+// for open gym at gym_id
+func (c *PassController) Redeem(ctx *gin.Context) {
+	var input struct {
+		Code      string     `json:"code" binding:"required"`
+		SessionID *uuid.UUID `json:"session_id"`
+		GymID     *uuid.UUID `json:"gym_id"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var actorID *uuid.UUID
+	if id, ok := middlewares.CurrentUserID(ctx); ok {
+		actorID = &id
+	}
+	visit, err := c.scoped(ctx).Redeem(actorID, input.Code, input.GymID, input.SessionID)
+	if err != nil {
+		respondPassError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, visit)
+}
+
+// canSeePass lets members see the passes they hold or sponsored
+func canSeePass(ctx *gin.Context, pass *models.Pass) bool {
+	if pass.MemberID != nil && middlewares.CanActForMember(ctx, *pass.MemberID) {
+		return true
+	}
+	if pass.SponsorID != nil && middlewares.CanActForMember(ctx, *pass.SponsorID) {
+		return true
+	}
+	return middlewares.HasRole(ctx, middlewares.StaffRoles()...)
+}
+
+func passID(ctx *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func respondPassError(ctx *gin.Context, err error) {
+	if respondAccessDenied(ctx, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, services.ErrPassNotFound), errors.Is(err, services.ErrPassProductNotFound),
+		errors.Is(err, services.ErrMembershipNotFound), errors.Is(err, services.ErrSessionNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrOutsideGymScope):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPassNotUsable), errors.Is(err, services.ErrPassAlreadyUsed), errors.Is(err, services.ErrPlanArchived):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPass), errors.Is(err, services.ErrInvalidMembership):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process pass"})
+	}
+}
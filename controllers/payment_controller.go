@@ -1,8 +1,10 @@
 package controllers
 
 import (
+	"errors"
 	"go-blog/internal/models"
 	services "go-blog/internal/service"
+	"go-blog/middlewares"
 	"net/http"
 	"strings"
 
@@ -18,6 +20,8 @@ type PaymentRequest struct {
 	Method      string    `json:"method" binding:"required"`
 	Status      string    `json:"status" binding:"required"`
 	Reference   string    `json:"reference"`
+	// PayerID defaults to the primary payer of the member's household
+	PayerID *uuid.UUID `json:"payer_id"`
 }
 
 type PaymentController struct {
@@ -50,6 +54,7 @@ func (c *PaymentController) RecordPayment(ctx *gin.Context) {
 		Method:      req.Method,
 		Status:      req.Status,
 		Reference:   req.Reference,
+		PayerID:     req.PayerID,
 	}
 	
 	if err := c.service.RecordPayment(&p); err != nil {
@@ -70,18 +75,49 @@ func (c *PaymentController) RecordPayment(ctx *gin.Context) {
 
 func (c *PaymentController) GetPayments(ctx *gin.Context) {
 	memberID := ctx.Param("memberID")
-	payments, err := c.service.GetPayments(memberID)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	req, ok := listRequest(ctx)
+	if !ok {
 		return
 	}
-	ctx.JSON(http.StatusOK, payments)
+	payments, err := c.service.GetPayments(memberID, req)
+	respondList(ctx, payments, err)
 }
 func (c *PaymentController) GetAllPayments(ctx *gin.Context) {
-	payments, err := c.service.GetAllPayments()
+	req, ok := listRequest(ctx)
+	if !ok {
+		return
+	}
+	payments, err := c.service.GetAllPayments(req)
+	respondList(ctx, payments, err)
+}
+
+// SetStatus records whether a payment went through
+// (PUT /api/payments/:id/status)
+func (c *PaymentController) SetStatus(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid payment id"})
 		return
 	}
-	ctx.JSON(http.StatusOK, payments)
+	var input struct {
+		Status string `json:"status" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	actorID, _ := middlewares.CurrentUserID(ctx)
+	payment, err := c.service.SetStatus(actorID, id, input.Status)
+	switch {
+	case errors.Is(err, services.ErrPaymentNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPaymentStatus):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPaymentStatusChange):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update payment"})
+	default:
+		ctx.JSON(http.StatusOK, payment)
+	}
 }
@@ -1,11 +1,14 @@
 package controllers
 
 import (
+	"errors"
 	"go-blog/internal/models"
 	services "go-blog/internal/service"
+	"go-blog/middlewares"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type PlanController struct {
@@ -22,18 +25,185 @@ func (c *PlanController) CreatePlan(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := c.service.CreatePlan(&plan); err != nil {
+	actorID, _ := middlewares.CurrentUserID(ctx)
+	if err := c.service.CreatePlan(actorID, &plan); err != nil {
+		if errors.Is(err, services.ErrInvalidPlan) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	ctx.JSON(http.StatusCreated, plan)
 }
 
+// GetPlans lists the plans on sale
 func (c *PlanController) GetPlans(ctx *gin.Context) {
-	plans, err := c.service.GetPlans()
+	req, ok := listRequest(ctx)
+	if !ok {
+		return
+	}
+	plans, err := c.service.GetPlans(req, false)
+	respondList(ctx, plans, err)
+}
+
+// GetAllPlans lists every plan, archived ones included (GET /api/plans/all)
+func (c *PlanController) GetAllPlans(ctx *gin.Context) {
+	req, ok := listRequest(ctx)
+	if !ok {
+		return
+	}
+	plans, err := c.service.GetPlans(req, true)
+	respondList(ctx, plans, err)
+}
+
+// GetPlan returns one plan (GET /api/plans/:id)
+func (c *PlanController) GetPlan(ctx *gin.Context) {
+	id, ok := planID(ctx)
+	if !ok {
+		return
+	}
+	plan, err := c.service.GetPlan(id)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondPlanError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, plan)
+}
+
+// UpdatePlan changes a plan; a new price or billing cycle starts a new
+// version (PUT /api/plans/:id)
+func (c *PlanController) UpdatePlan(ctx *gin.Context) {
+	id, ok := planID(ctx)
+	if !ok {
+		return
+	}
+	var input struct {
+		Title        *string              `json:"title"`
+		Description  *string              `json:"description"`
+		PriceCents   *int                 `json:"price_cents"`
+		BillingCycle *string              `json:"billing_cycle"`
+		NumSessions  *int                 `json:"num_sessions"`
+		Unlimited    bool                 `json:"unlimited"` // clears num_sessions
+		Access       *models.AccessPolicy `json:"access"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	actorID, _ := middlewares.CurrentUserID(ctx)
+	plan, err := c.service.UpdatePlan(actorID, id, services.PlanUpdate{
+		Title:        input.Title,
+		Description:  input.Description,
+		PriceCents:   input.PriceCents,
+		BillingCycle: input.BillingCycle,
+		NumSessions:  input.NumSessions,
+		Unlimited:    input.Unlimited,
+		Access:       input.Access,
+	})
+	if err != nil {
+		respondPlanError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, plan)
+}
+
+// ArchivePlan takes a plan off sale (POST /api/plans/:id/archive)
+func (c *PlanController) ArchivePlan(ctx *gin.Context) {
+	id, ok := planID(ctx)
+	if !ok {
+		return
+	}
+	actorID, _ := middlewares.CurrentUserID(ctx)
+	plan, err := c.service.Archive(actorID, id)
+	if err != nil {
+		respondPlanError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, plan)
+}
+
+// UnarchivePlan puts a plan back on sale (POST /api/plans/:id/unarchive)
+func (c *PlanController) UnarchivePlan(ctx *gin.Context) {
+	id, ok := planID(ctx)
+	if !ok {
 		return
 	}
-	ctx.JSON(http.StatusOK, plans)
+	actorID, _ := middlewares.CurrentUserID(ctx)
+	plan, err := c.service.Unarchive(actorID, id)
+	if err != nil {
+		respondPlanError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, plan)
+}
+
+// DeletePlan removes a plan that has never been sold (DELETE /api/plans/:id)
+func (c *PlanController) DeletePlan(ctx *gin.Context) {
+	id, ok := planID(ctx)
+	if !ok {
+		return
+	}
+	actorID, _ := middlewares.CurrentUserID(ctx)
+	if err := c.service.DeletePlan(actorID, id); err != nil {
+		respondPlanError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Plan deleted"})
+}
+
+// Versions lists a plan's versions with membership counts
+// (GET /api/plans/:id/versions)
+func (c *PlanController) Versions(ctx *gin.Context) {
+	id, ok := planID(ctx)
+	if !ok {
+		return
+	}
+	versions, err := c.service.Versions(id)
+	if err != nil {
+		respondPlanError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": versions})
+}
+
+// Memberships lists the memberships sold on a plan; filter by plan_version
+// for one version (GET /api/plans/:id/memberships)
+func (c *PlanController) Memberships(ctx *gin.Context) {
+	id, ok := planID(ctx)
+	if !ok {
+		return
+	}
+	req, ok := listRequest(ctx)
+	if !ok {
+		return
+	}
+	memberships, err := c.service.Memberships(id, req)
+	if errors.Is(err, services.ErrPlanNotFound) {
+		respondPlanError(ctx, err)
+		return
+	}
+	respondList(ctx, memberships, err)
+}
+
+func planID(ctx *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid plan id"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func respondPlanError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPlanNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPlanInUse):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPlan):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update plan"})
+	}
 }
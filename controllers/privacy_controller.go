@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	services "go-blog/internal/service"
+	"go-blog/middlewares"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// PrivacyController serves personal data exports and erasure requests
+type PrivacyController struct {
+	service *services.PrivacyService
+}
+
+func NewPrivacyController(service *services.PrivacyService) *PrivacyController {
+	return &PrivacyController{service: service}
+}
+
+// ExportMine downloads the caller's data (GET /me/data-export?format=json|zip)
+func (c *PrivacyController) ExportMine(ctx *gin.Context) {
+	userID, _ := middlewares.CurrentUserID(ctx)
+	c.export(ctx, userID)
+}
+
+// ExportUser downloads the data of any user (GET /privacy/users/:id/export)
+func (c *PrivacyController) ExportUser(ctx *gin.Context) {
+	userID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	c.export(ctx, userID)
+}
+
+func (c *PrivacyController) export(ctx *gin.Context, userID uuid.UUID) {
+	format := ctx.DefaultQuery("format", "json")
+	if format != "json" && format != "zip" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or zip"})
+		return
+	}
+
+	export, err := c.service.Export(ctx.Request.Context(), privacyActor(ctx), userID, format)
+	if errors.Is(err, services.ErrProfileNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("personal-data-%s-%s", userID, export.GeneratedAt.Format("20060102"))
+	if format == "json" {
+		ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+		ctx.JSON(http.StatusOK, export)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := export.WriteZip(&buf); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, filename))
+	ctx.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// RequestMyErasure asks for the caller's data to be erased (POST /me/erasure-request)
+func (c *PrivacyController) RequestMyErasure(ctx *gin.Context) {
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	// The body is optional
+	_ = ctx.ShouldBindJSON(&input)
+	userID, _ := middlewares.CurrentUserID(ctx)
+
+	request, err := c.service.RequestErasure(userID, userID, input.Reason)
+	if err != nil {
+		respondPrivacyError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusAccepted, request)
+}
+
+// MyErasureRequests lists the caller's erasure requests (GET /me/erasure-requests)
+func (c *PrivacyController) MyErasureRequests(ctx *gin.Context) {
+	userID, _ := middlewares.CurrentUserID(ctx)
+	requests, err := c.service.ErasureRequestsOf(userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": requests})
+}
+
+// CreateErasureRequest files a request on behalf of a user, e.g. one received
+// by mail (POST /privacy/erasure-requests)
+func (c *PrivacyController) CreateErasureRequest(ctx *gin.Context) {
+	var input struct {
+		UserID uuid.UUID `json:"user_id" binding:"required"`
+		Reason string    `json:"reason"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	adminID, _ := middlewares.CurrentUserID(ctx)
+
+	request, err := c.service.RequestErasure(adminID, input.UserID, input.Reason)
+	if err != nil {
+		respondPrivacyError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, request)
+}
+
+// ListErasureRequests returns one page of requests (GET /privacy/erasure-requests)
+func (c *PrivacyController) ListErasureRequests(ctx *gin.Context) {
+	req, ok := listRequest(ctx)
+	if !ok {
+		return
+	}
+	requests, err := c.service.ListErasureRequests(req)
+	respondList(ctx, requests, err)
+}
+
+// Approve erases the user's data (POST /privacy/erasure-requests/:id/approve)
+func (c *PrivacyController) Approve(ctx *gin.Context) {
+	c.review(ctx, true)
+}
+
+// Reject closes the request (POST /privacy/erasure-requests/:id/reject)
+func (c *PrivacyController) Reject(ctx *gin.Context) {
+	c.review(ctx, false)
+}
+
+func (c *PrivacyController) review(ctx *gin.Context, approve bool) {
+	requestID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid erasure request id"})
+		return
+	}
+	var input struct {
+		Note string `json:"note"`
+	}
+	// The body is optional
+	_ = ctx.ShouldBindJSON(&input)
+	adminID, _ := middlewares.CurrentUserID(ctx)
+
+	if approve {
+		request, err := c.service.Approve(ctx.Request.Context(), adminID, requestID, input.Note)
+		if err != nil {
+			respondPrivacyError(ctx, err)
+			return
+		}
+		ctx.JSON(http.StatusOK, request)
+		return
+	}
+	request, err := c.service.Reject(adminID, requestID, input.Note)
+	if err != nil {
+		respondPrivacyError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, request)
+}
+
+// privacyActor is who to audit an export under: the admin when impersonating
+func privacyActor(ctx *gin.Context) uuid.UUID {
+	if actorID, ok := middlewares.ImpersonatorID(ctx); ok {
+		return actorID
+	}
+	userID, _ := middlewares.CurrentUserID(ctx)
+	return userID
+}
+
+func respondPrivacyError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrErasureRequestNotFound), errors.Is(err, services.ErrProfileNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrErasureAlreadyPending), errors.Is(err, services.ErrErasureNotPending):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrErasureSelfReview):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"errors"
+	services "go-blog/internal/service"
+	"go-blog/middlewares"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProfileController serves /me, the caller's own account
+type ProfileController struct {
+	service *services.ProfileService
+	auth    *services.AuthService
+}
+
+func NewProfileController(service *services.ProfileService, auth *services.AuthService) *ProfileController {
+	return &ProfileController{service: service, auth: auth}
+}
+
+// GetProfile returns the caller's user and member profile (GET /me)
+func (c *ProfileController) GetProfile(ctx *gin.Context) {
+	userID, _ := middlewares.CurrentUserID(ctx)
+
+	user, err := c.service.GetProfile(userID)
+	if err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, user)
+}
+
+// UpdateProfile changes the caller's contact details (PATCH /me)
+func (c *ProfileController) UpdateProfile(ctx *gin.Context) {
+	var input services.ProfileUpdate
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := middlewares.CurrentUserID(ctx)
+
+	user, err := c.service.UpdateProfile(userID, input)
+	if err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, user)
+}
+
+// ChangePassword sets a new password (POST /me/password). All sessions are
+// ended, so a fresh token pair for the calling device is returned.
+func (c *ProfileController) ChangePassword(ctx *gin.Context) {
+	var input struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required"`
+		DeviceID        string `json:"device_id"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := middlewares.CurrentUserID(ctx)
+
+	if err := c.service.ChangePassword(userID, input.CurrentPassword, input.NewPassword); err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+
+	user, err := c.auth.GetUserByID(userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	accessToken, refreshToken, err := c.auth.GenerateTokens(user, clientInfo(ctx, input.DeviceID))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":       "Password changed, other sessions were signed out",
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+	})
+}
+
+// GetOverview returns the caller's active membership, upcoming bookings and
+// recent attendance (GET /me/overview)
+func (c *ProfileController) GetOverview(ctx *gin.Context) {
+	userID, _ := middlewares.CurrentUserID(ctx)
+
+	overview, err := c.service.Overview(userID)
+	if err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, overview)
+}
+
+func (c *ProfileController) respondError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrProfileNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrIncorrectPassword):
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrWeakPassword), errors.Is(err, services.ErrSamePassword):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"errors"
+	"go-blog/internal/imaging"
+	services "go-blog/internal/service"
+	"go-blog/middlewares"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ProfilePhotoController handles profile picture uploads, by the user
+// themselves or by front-desk staff for a member.
+type ProfilePhotoController struct {
+	service *services.ProfilePhotoService
+	members services.MemberService
+}
+
+func NewProfilePhotoController(service *services.ProfilePhotoService, members services.MemberService) *ProfilePhotoController {
+	return &ProfilePhotoController{service: service, members: members}
+}
+
+// UploadMine sets the caller's picture from the multipart field "photo" (PUT /me/photo)
+func (c *ProfilePhotoController) UploadMine(ctx *gin.Context) {
+	userID, _ := middlewares.CurrentUserID(ctx)
+	c.upload(ctx, userID)
+}
+
+// DeleteMine removes the caller's picture (DELETE /me/photo)
+func (c *ProfilePhotoController) DeleteMine(ctx *gin.Context) {
+	userID, _ := middlewares.CurrentUserID(ctx)
+	c.remove(ctx, userID)
+}
+
+// UploadForMember sets a member's picture, e.g. taken at the front desk (PUT /members/:id/photo)
+func (c *ProfilePhotoController) UploadForMember(ctx *gin.Context) {
+	if userID, ok := c.memberUserID(ctx); ok {
+		c.upload(ctx, userID)
+	}
+}
+
+// DeleteForMember removes a member's picture (DELETE /members/:id/photo)
+func (c *ProfilePhotoController) DeleteForMember(ctx *gin.Context) {
+	if userID, ok := c.memberUserID(ctx); ok {
+		c.remove(ctx, userID)
+	}
+}
+
+func (c *ProfilePhotoController) upload(ctx *gin.Context, userID uuid.UUID) {
+	// Leave room for the multipart envelope around the file itself
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, services.MaxProfilePhotoBytes+64<<10)
+
+	header, err := ctx.FormFile("photo")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": services.ErrPhotoTooLarge.Error()})
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "multipart field \"photo\" is required"})
+		return
+	}
+	if header.Size > services.MaxProfilePhotoBytes {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": services.ErrPhotoTooLarge.Error()})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, services.MaxProfilePhotoBytes+1))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := c.service.Upload(ctx.Request.Context(), userID, data, header.Header.Get("Content-Type"))
+	if err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"profile_picture_url":   user.ProfilePictureURL,
+		"profile_thumbnail_url": user.ProfileThumbnailURL,
+	})
+}
+
+func (c *ProfilePhotoController) remove(ctx *gin.Context, userID uuid.UUID) {
+	if err := c.service.Remove(ctx.Request.Context(), userID); err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Profile picture removed"})
+}
+
+func (c *ProfilePhotoController) memberUserID(ctx *gin.Context) (uuid.UUID, bool) {
+	memberID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid member id"})
+		return uuid.Nil, false
+	}
+	member, err := c.members.GetMemberByID(memberID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "member not found"})
+		return uuid.Nil, false
+	}
+	return member.UserID, true
+}
+
+func (c *ProfilePhotoController) respondError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPhotoTooLarge):
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUnsupportedPhotoType):
+		ctx.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	case errors.Is(err, imaging.ErrTooLarge):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrProfileNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -3,16 +3,14 @@ package config
 import (
 	"log"
 	"os"
+	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
-var JwtSecret []byte
-var jwtKey = []byte("your-secret-key")
 
 func InitDB() {
 	// Get database connection details from environment variables with fallbacks
@@ -58,19 +56,9 @@ func InitDB() {
 	log.Println("Successfully connected to database")
 }
 
-func InitJWT() {
-	JwtSecret = []byte(getEnv("JWT_SECRET", "fallback-secret-key-change-in-production"))
-}
-
-func GenerateJWT(userID string, userType string, duration time.Duration) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id":   userID, // UUID stored as string
-		"user_type": userType,
-		"exp":       time.Now().Add(duration).Unix(),
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+// AppBaseURL is the public URL of the web app, used to build links in emails
+func AppBaseURL() string {
+	return strings.TrimRight(getEnv("APP_BASE_URL", "http://localhost:3000"), "/")
 }
 
 // Helper function to get environment variables with fallback
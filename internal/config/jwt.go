@@ -0,0 +1,156 @@
+package config
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"go-blog/internal/keyring"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Keys is the single source of JWT signing and verification keys.
+var Keys *keyring.KeyRing
+
+// Token types carried in the "typ" claim. Only access tokens are accepted by
+// AuthMiddleware; refresh tokens are only accepted by /auth/refresh.
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+	// TokenTypeMFA is the challenge returned by /auth/login when the user
+	// still has to enter a TOTP code
+	TokenTypeMFA = "mfa"
+	// TokenTypeMFAEnroll only allows enrolling in 2FA, for users who must
+	// set it up before they can log in
+	TokenTypeMFAEnroll = "mfa_enroll"
+	// TokenTypeOIDCState carries the state, nonce and PKCE verifier of an
+	// OIDC login between the redirect and the callback
+	TokenTypeOIDCState = "oidc_state"
+)
+
+// InitJWT builds the key ring from the environment:
+//
+//	JWT_ALG                HS256 (default), RS256 or EdDSA
+//	JWT_SECRET             signing secret when JWT_ALG=HS256
+//	JWT_PRIVATE_KEY_FILE   PEM private key when JWT_ALG is RS256 or EdDSA
+//	JWT_PREVIOUS_SECRETS   comma separated HMAC secrets still accepted for verification
+//	JWT_PREVIOUS_KEY_FILES comma separated PEM keys still accepted for verification
+//
+// To rotate, move the current secret or key file to the PREVIOUS variable and
+// configure a new one. Old tokens keep validating until they expire.
+func InitJWT() {
+	Keys = keyring.New()
+
+	alg := getEnv("JWT_ALG", keyring.AlgHS256)
+	switch alg {
+	case keyring.AlgHS256:
+		secret := getEnv("JWT_SECRET", "fallback-secret-key-change-in-production")
+		mustSetSigningKey(keyring.NewHMACKey([]byte(secret)))
+	case keyring.AlgRS256, keyring.AlgEdDSA:
+		var key *keyring.Key
+		var err error
+		if path := getEnv("JWT_PRIVATE_KEY_FILE", ""); path != "" {
+			key, err = keyring.LoadPEMFile(path)
+		} else {
+			log.Printf("WARN: JWT_PRIVATE_KEY_FILE not set, generating an ephemeral %s key; tokens will not survive a restart", alg)
+			key, err = keyring.Generate(alg)
+		}
+		if err != nil {
+			log.Fatalf("Failed to load JWT signing key: %v", err)
+		}
+		if key.Algorithm != alg {
+			log.Fatalf("JWT signing key is %s but JWT_ALG is %s", key.Algorithm, alg)
+		}
+		mustSetSigningKey(key)
+	default:
+		log.Fatalf("Unsupported JWT_ALG %q", alg)
+	}
+
+	for _, secret := range splitList(getEnv("JWT_PREVIOUS_SECRETS", "")) {
+		Keys.AddVerificationKey(keyring.NewHMACKey([]byte(secret)))
+	}
+	for _, path := range splitList(getEnv("JWT_PREVIOUS_KEY_FILES", "")) {
+		key, err := keyring.LoadPEMFile(path)
+		if err != nil {
+			log.Fatalf("Failed to load JWT verification key: %v", err)
+		}
+		Keys.AddVerificationKey(key)
+	}
+
+	log.Printf("JWT signing with %s key %s", alg, Keys.SigningKeyID())
+}
+
+func mustSetSigningKey(key *keyring.Key) {
+	if err := Keys.SetSigningKey(key); err != nil {
+		log.Fatalf("Failed to configure JWT signing key: %v", err)
+	}
+}
+
+func GenerateJWT(userID string, userType string, tokenType string, duration time.Duration) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"user_id":   userID, // UUID stored as string
+		"user_type": userType,
+		"typ":       tokenType,
+		"jti":       uuid.New().String(),
+		"iat":       now.Unix(),
+		"exp":       now.Add(duration).Unix(),
+	}
+
+	return Keys.Sign(claims)
+}
+
+// GenerateImpersonationJWT issues an access token for userID that carries
+// the admin acting as them in an RFC 8693 "act" claim.
+func GenerateImpersonationJWT(userID, userType, actorID string, duration time.Duration) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"user_id":   userID,
+		"user_type": userType,
+		"typ":       TokenTypeAccess,
+		"act":       map[string]interface{}{"sub": actorID},
+		"jti":       uuid.New().String(),
+		"iat":       now.Unix(),
+		"exp":       now.Add(duration).Unix(),
+	}
+
+	return Keys.Sign(claims)
+}
+
+// ActorID returns the "act" subject of an impersonation token, or "".
+func ActorID(claims jwt.MapClaims) string {
+	act, _ := claims["act"].(map[string]interface{})
+	sub, _ := act["sub"].(string)
+	return sub
+}
+
+// ParseJWT verifies a token issued by GenerateJWT and checks that its "typ"
+// claim is one of tokenTypes.
+func ParseJWT(tokenString string, tokenTypes ...string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, Keys.Keyfunc, jwt.WithValidMethods(Keys.Algorithms()))
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	typ, _ := claims["typ"].(string)
+	for _, allowed := range tokenTypes {
+		if typ == allowed {
+			return claims, nil
+		}
+	}
+	return nil, errors.New("unexpected token type")
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,145 @@
+// Package importer reads spreadsheets of members exported from other gym
+// software. It only turns files into rows of named cells; mapping the cells
+// to accounts is up to the caller.
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// MaxRows caps the size of one import
+const MaxRows = 5000
+
+var ErrUnsupportedFormat = errors.New("importer: unsupported file type, upload a .csv or .xlsx file")
+
+// Row is one data row of a spreadsheet. Line is the spreadsheet line number,
+// counting the header as line 1. Cells are keyed by canonical column name.
+type Row struct {
+	Line  int
+	Cells map[string]string
+}
+
+// Get returns the trimmed value of a column, or ""
+func (r Row) Get(column string) string {
+	return strings.TrimSpace(r.Cells[column])
+}
+
+// Empty reports whether every cell of the row is blank
+func (r Row) Empty() bool {
+	for _, v := range r.Cells {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// aliases maps the header spellings we have seen in other systems' exports
+// to our column names.
+var aliases = map[string]string{
+	"first":                  "first_name",
+	"firstname":              "first_name",
+	"given_name":             "first_name",
+	"last":                   "last_name",
+	"lastname":               "last_name",
+	"surname":                "last_name",
+	"family_name":            "last_name",
+	"e_mail":                 "email",
+	"email_address":          "email",
+	"mail":                   "email",
+	"phone":                  "phone_number",
+	"mobile":                 "phone_number",
+	"telephone":              "phone_number",
+	"dob":                    "date_of_birth",
+	"birthdate":              "date_of_birth",
+	"birth_date":             "date_of_birth",
+	"birthday":               "date_of_birth",
+	"sex":                    "gender",
+	"goals":                  "fitness_goals",
+	"emergency_contact":      "emergency_contact_name",
+	"emergency_phone":        "emergency_contact_phone",
+	"plan_id":                "plan",
+	"plan_title":             "plan",
+	"plan_name":              "plan",
+	"membership":             "plan",
+	"start_date":             "membership_start",
+	"membership_start_date":  "membership_start",
+	"end_date":               "membership_end",
+	"expiry_date":            "membership_end",
+	"membership_end_date":    "membership_end",
+	"autorenew":              "auto_renew",
+	"guardian":               "guardian_email",
+	"parent_email":           "guardian_email",
+	"relationship_to_member": "guardian_relationship",
+}
+
+// Column turns a header cell into its canonical column name
+func Column(header string) string {
+	name := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header, "\ufeff")))
+	name = strings.NewReplacer(" ", "_", "-", "_", ".", "_").Replace(name)
+	if canonical, ok := aliases[name]; ok {
+		return canonical
+	}
+	return name
+}
+
+// Read parses a CSV or XLSX file, chosen by the file name's extension. The
+// first row is the header; blank rows are skipped.
+func Read(filename string, r io.Reader) ([]Row, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var records [][]string
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv", ".txt":
+		reader := csv.NewReader(bytes.NewReader(data))
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		if records, err = reader.ReadAll(); err != nil {
+			return nil, fmt.Errorf("importer: invalid CSV: %w", err)
+		}
+	case ".xlsx":
+		if records, err = readXLSX(data); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	return toRows(records)
+}
+
+func toRows(records [][]string) ([]Row, error) {
+	if len(records) == 0 {
+		return nil, errors.New("importer: the file is empty")
+	}
+	header := make([]string, len(records[0]))
+	for i, cell := range records[0] {
+		header[i] = Column(cell)
+	}
+
+	rows := make([]Row, 0, len(records)-1)
+	for i, record := range records[1:] {
+		row := Row{Line: i + 2, Cells: make(map[string]string, len(header))}
+		for j, cell := range record {
+			if j < len(header) && header[j] != "" {
+				row.Cells[header[j]] = cell
+			}
+		}
+		if row.Empty() {
+			continue
+		}
+		if len(rows) == MaxRows {
+			return nil, fmt.Errorf("importer: the file has more than %d rows; split it into several imports", MaxRows)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
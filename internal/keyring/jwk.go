@@ -0,0 +1,110 @@
+package keyring
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"sort"
+)
+
+// JWK is the public half of a key as published at /.well-known/jwks.json.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// OKP (Ed25519)
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet is a JSON Web Key Set.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of every asymmetric key in the ring, so that
+// clients can verify tokens offline. Shared HMAC secrets are never included.
+func (r *KeyRing) JWKS() JWKSet {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range r.keys {
+		if jwk, ok := key.publicJWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+func (k *Key) publicJWK() (JWK, bool) {
+	switch pub := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Kid: k.ID,
+			Use: "sig",
+			Alg: k.Algorithm,
+			N:   b64(pub.N.Bytes()),
+			E:   b64(big.NewInt(int64(pub.E)).Bytes()),
+		}, true
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Kid: k.ID,
+			Use: "sig",
+			Alg: k.Algorithm,
+			Crv: "Ed25519",
+			X:   b64(pub),
+		}, true
+	}
+	return JWK{}, false
+}
+
+// Key IDs of asymmetric keys are RFC 7638 thumbprints, so the same key
+// always gets the same kid on every replica.
+func rsaKeyID(pub *rsa.PublicKey) string {
+	// Members must be in lexicographic order: e, kty, n
+	return thumbprint(map[string]string{
+		"e":   b64(big.NewInt(int64(pub.E)).Bytes()),
+		"kty": "RSA",
+		"n":   b64(pub.N.Bytes()),
+	})
+}
+
+func ed25519KeyID(pub ed25519.PublicKey) string {
+	return thumbprint(map[string]string{
+		"crv": "Ed25519",
+		"kty": "OKP",
+		"x":   b64(pub),
+	})
+}
+
+func thumbprint(members map[string]string) string {
+	// encoding/json writes map keys in sorted order, which is what RFC 7638 requires
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+	return b64(sum[:])
+}
+
+// HMAC secrets get a kid derived from a hash of the secret. The kid is visible
+// in every token header, so it must not reveal the secret itself.
+func hmacKeyID(secret []byte) string {
+	sum := sha256.Sum256(append([]byte("hmac-kid:"), secret...))
+	return "hs-" + hex.EncodeToString(sum[:8])
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
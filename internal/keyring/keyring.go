@@ -0,0 +1,191 @@
+// Package keyring holds the keys used to sign and verify JWTs.
+//
+// Exactly one key signs new tokens at a time. Any number of older keys can
+// stay in the ring for verification only, so tokens signed before a rotation
+// remain valid until they expire. Every token carries the "kid" of the key
+// that signed it.
+package keyring
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+var (
+	ErrNoSigningKey = errors.New("keyring: no signing key configured")
+	ErrUnknownKey   = errors.New("keyring: token signed with unknown key")
+)
+
+// Key is a single signing or verification key.
+type Key struct {
+	ID        string
+	Algorithm string
+
+	signKey   interface{} // []byte, *rsa.PrivateKey or ed25519.PrivateKey; nil when verify-only
+	verifyKey interface{} // []byte, *rsa.PublicKey or ed25519.PublicKey
+}
+
+// NewHMACKey wraps a shared secret. HMAC keys are never published in the JWKS.
+func NewHMACKey(secret []byte) *Key {
+	return &Key{
+		ID:        hmacKeyID(secret),
+		Algorithm: AlgHS256,
+		signKey:   secret,
+		verifyKey: secret,
+	}
+}
+
+// NewRSAKey wraps an RSA private key for RS256 signing.
+func NewRSAKey(priv *rsa.PrivateKey) *Key {
+	return &Key{
+		ID:        rsaKeyID(&priv.PublicKey),
+		Algorithm: AlgRS256,
+		signKey:   priv,
+		verifyKey: &priv.PublicKey,
+	}
+}
+
+// NewRSAPublicKey wraps an RSA public key that can only verify.
+func NewRSAPublicKey(pub *rsa.PublicKey) *Key {
+	return &Key{ID: rsaKeyID(pub), Algorithm: AlgRS256, verifyKey: pub}
+}
+
+// NewEd25519Key wraps an Ed25519 private key for EdDSA signing.
+func NewEd25519Key(priv ed25519.PrivateKey) *Key {
+	pub := priv.Public().(ed25519.PublicKey)
+	return &Key{
+		ID:        ed25519KeyID(pub),
+		Algorithm: AlgEdDSA,
+		signKey:   priv,
+		verifyKey: pub,
+	}
+}
+
+// NewEd25519PublicKey wraps an Ed25519 public key that can only verify.
+func NewEd25519PublicKey(pub ed25519.PublicKey) *Key {
+	return &Key{ID: ed25519KeyID(pub), Algorithm: AlgEdDSA, verifyKey: pub}
+}
+
+// CanSign reports whether the key holds private material.
+func (k *Key) CanSign() bool {
+	return k.signKey != nil
+}
+
+func (k *Key) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// KeyRing is safe for concurrent use.
+type KeyRing struct {
+	mu      sync.RWMutex
+	signing *Key
+	keys    map[string]*Key
+}
+
+func New() *KeyRing {
+	return &KeyRing{keys: make(map[string]*Key)}
+}
+
+// SetSigningKey makes key the one used for new tokens. The previous signing
+// key stays in the ring for verification.
+func (r *KeyRing) SetSigningKey(key *Key) error {
+	if !key.CanSign() {
+		return fmt.Errorf("keyring: key %s has no private part", key.ID)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.signing = key
+	r.keys[key.ID] = key
+	return nil
+}
+
+// AddVerificationKey accepts tokens signed by key without using it for new tokens.
+func (r *KeyRing) AddVerificationKey(key *Key) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.keys[key.ID]; !exists {
+		r.keys[key.ID] = key
+	}
+}
+
+// RemoveKey stops accepting tokens signed by kid. The signing key cannot be removed.
+func (r *KeyRing) RemoveKey(kid string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.signing != nil && r.signing.ID == kid {
+		return
+	}
+	delete(r.keys, kid)
+}
+
+// SigningKeyID returns the kid of the active signing key.
+func (r *KeyRing) SigningKeyID() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.signing == nil {
+		return ""
+	}
+	return r.signing.ID
+}
+
+// Sign signs claims with the active key and sets the kid header.
+func (r *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	r.mu.RLock()
+	key := r.signing
+	r.mu.RUnlock()
+	if key == nil {
+		return "", ErrNoSigningKey
+	}
+
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signKey)
+}
+
+// Keyfunc resolves the verification key named by the token's kid header.
+// It is meant to be passed to jwt.Parse.
+func (r *KeyRing) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	r.mu.RLock()
+	key, ok := r.keys[kid]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	// A token must be verified with the algorithm of its key, otherwise an
+	// attacker could e.g. sign HS256 with a published RSA public key.
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("keyring: algorithm %s does not match key %s", token.Method.Alg(), kid)
+	}
+	return key.verifyKey, nil
+}
+
+// Algorithms lists the algorithms of all keys in the ring.
+func (r *KeyRing) Algorithms() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var algs []string
+	for _, key := range r.keys {
+		if !seen[key.Algorithm] {
+			seen[key.Algorithm] = true
+			algs = append(algs, key.Algorithm)
+		}
+	}
+	return algs
+}
@@ -0,0 +1,93 @@
+package keyring
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// LoadPEMFile reads a PEM encoded private or public key from disk.
+// Private keys can sign; public keys are verification-only.
+func LoadPEMFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParsePEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// ParsePEM parses PKCS#8, PKCS#1 and PKIX encoded RSA or Ed25519 keys.
+func ParsePEM(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("keyring: no PEM block found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewRSAKey(priv), nil
+	case "RSA PUBLIC KEY":
+		pub, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewRSAPublicKey(pub), nil
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch priv := parsed.(type) {
+		case *rsa.PrivateKey:
+			return NewRSAKey(priv), nil
+		case ed25519.PrivateKey:
+			return NewEd25519Key(priv), nil
+		}
+		return nil, fmt.Errorf("keyring: unsupported private key type %T", parsed)
+	case "PUBLIC KEY":
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch pub := parsed.(type) {
+		case *rsa.PublicKey:
+			return NewRSAPublicKey(pub), nil
+		case ed25519.PublicKey:
+			return NewEd25519PublicKey(pub), nil
+		}
+		return nil, fmt.Errorf("keyring: unsupported public key type %T", parsed)
+	}
+	return nil, fmt.Errorf("keyring: unsupported PEM block %q", block.Type)
+}
+
+// Generate creates a fresh asymmetric key for the given algorithm.
+func Generate(alg string) (*Key, error) {
+	switch alg {
+	case AlgRS256:
+		priv, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		return NewRSAKey(priv), nil
+	case AlgEdDSA:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewEd25519Key(priv), nil
+	}
+	return nil, fmt.Errorf("keyring: cannot generate key for algorithm %q", alg)
+}
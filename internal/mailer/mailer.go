@@ -0,0 +1,48 @@
+// Package mailer delivers transactional email (verification links, password
+// resets, invitations) through a pluggable backend.
+package mailer
+
+import (
+	"log"
+	"os"
+	"strings"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends a Message.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// NewFromEnv picks a Mailer based on MAIL_DRIVER:
+//
+//	smtp  SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD, MAIL_FROM
+//	log   (default) writes every message to MAIL_OUTBOX_DIR, for local testing
+func NewFromEnv() Mailer {
+	switch strings.ToLower(os.Getenv("MAIL_DRIVER")) {
+	case "smtp":
+		return &SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     getEnv("SMTP_PORT", "587"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     getEnv("MAIL_FROM", "no-reply@localhost"),
+		}
+	default:
+		log.Printf("Mail delivery uses the log sink; set MAIL_DRIVER=smtp to send real email")
+		return &LogMailer{Dir: getEnv("MAIL_OUTBOX_DIR", "logs/mail")}
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
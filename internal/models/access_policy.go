@@ -0,0 +1,268 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reasons an access check refuses entry or a booking. Kiosks show the
+// message and may act on the reason.
+const (
+	AccessGymNotAllowed       = "gym_not_allowed"
+	AccessCategoryNotAllowed  = "category_not_allowed"
+	AccessDayNotAllowed       = "day_not_allowed"
+	AccessOutsideTimeWindow   = "outside_time_window"
+	AccessGuestLimitReached   = "guest_allowance_exceeded"
+	AccessNoActiveMembership  = "no_active_membership"
+	AccessSessionQuotaReached = "session_quota_exhausted"
+)
+
+// TimeWindow is a daily window in the gym's local time, "HH:MM" to "HH:MM"
+type TimeWindow struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// AccessPolicy is what a plan gives access to. Empty lists allow
+// everything, so a policy with only a label is unrestricted. It is stored as
+// JSON in plans.access; older plans hold a plain label there.
+type AccessPolicy struct {
+	Label      string       `json:"label,omitempty"`
+	Gyms       []uuid.UUID  `json:"gyms,omitempty"`
+	Categories []string     `json:"categories,omitempty"`
+	Days       []string     `json:"days,omitempty"` // mon, tue, ... sun
+	Windows    []TimeWindow `json:"windows,omitempty"`
+	// GuestsPerPeriod is how many guests a member may bring per membership
+	// period
+	GuestsPerPeriod int `json:"guests_per_period,omitempty"`
+}
+
+// AccessRequest is one attempt to enter or book
+type AccessRequest struct {
+	GymID      uuid.UUID
+	Category   string
+	At         time.Time // in the gym's local time
+	Guests     int
+	GuestsUsed int
+}
+
+// AccessDenial explains why a policy refused a request
+type AccessDenial struct {
+	Reason  string                 `json:"reason"`
+	Message string                 `json:"error"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+func (d *AccessDenial) Error() string {
+	return d.Message
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Validate checks the days and time windows of a policy
+func (p AccessPolicy) Validate() error {
+	for _, day := range p.Days {
+		if _, ok := weekdays[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("unknown day %q, use mon, tue, wed, thu, fri, sat or sun", day)
+		}
+	}
+	for _, w := range p.Windows {
+		from, err := minuteOfDay(w.From)
+		if err != nil {
+			return err
+		}
+		to, err := minuteOfDay(w.To)
+		if err != nil {
+			return err
+		}
+		if from >= to {
+			return fmt.Errorf("time window %s-%s must end after it starts", w.From, w.To)
+		}
+	}
+	if p.GuestsPerPeriod < 0 {
+		return fmt.Errorf("guests_per_period cannot be negative")
+	}
+	return nil
+}
+
+// Check evaluates a request against the policy of the plan called planTitle.
+// It returns nil when the request is allowed.
+func (p AccessPolicy) Check(planTitle string, req AccessRequest) *AccessDenial {
+	if len(p.Gyms) > 0 && !containsUUID(p.Gyms, req.GymID) {
+		return &AccessDenial{
+			Reason:  AccessGymNotAllowed,
+			Message: fmt.Sprintf("Your %s plan does not include this gym", planTitle),
+		}
+	}
+	if len(p.Categories) > 0 && !containsFold(p.Categories, req.Category) {
+		return &AccessDenial{
+			Reason:  AccessCategoryNotAllowed,
+			Message: fmt.Sprintf("Your %s plan does not include %s classes", planTitle, categoryName(req.Category)),
+			Details: map[string]interface{}{"allowed_categories": p.Categories},
+		}
+	}
+	if len(p.Days) > 0 && !p.allowsDay(req.At.Weekday()) {
+		return &AccessDenial{
+			Reason:  AccessDayNotAllowed,
+			Message: fmt.Sprintf("Your %s plan does not allow entry on %s", planTitle, req.At.Weekday()),
+			Details: map[string]interface{}{"allowed_days": p.Days},
+		}
+	}
+	if len(p.Windows) > 0 {
+		if denial := p.checkWindows(planTitle, req.At); denial != nil {
+			return denial
+		}
+	}
+	return p.CheckGuests(planTitle, req.Guests, req.GuestsUsed)
+}
+
+// CheckGuests checks that a number of guests still fits in the allowance of
+// a period in which used guests have already come
+func (p AccessPolicy) CheckGuests(planTitle string, guests, used int) *AccessDenial {
+	if guests > 0 && used+guests > p.GuestsPerPeriod {
+		return &AccessDenial{
+			Reason:  AccessGuestLimitReached,
+			Message: fmt.Sprintf("Your %s plan allows %d guests per period and %d have been used", planTitle, p.GuestsPerPeriod, used),
+			Details: map[string]interface{}{"guests_per_period": p.GuestsPerPeriod, "guests_used": used},
+		}
+	}
+	return nil
+}
+
+func (p AccessPolicy) allowsDay(day time.Weekday) bool {
+	for _, d := range p.Days {
+		if weekdays[strings.ToLower(d)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWindows names the nearest window boundary in the refusal, e.g. "does
+// not allow entry before 10:00".
+func (p AccessPolicy) checkWindows(planTitle string, at time.Time) *AccessDenial {
+	now := at.Hour()*60 + at.Minute()
+	windows := make([]TimeWindow, len(p.Windows))
+	copy(windows, p.Windows)
+	sort.Slice(windows, func(i, j int) bool {
+		a, _ := minuteOfDay(windows[i].From)
+		b, _ := minuteOfDay(windows[j].From)
+		return a < b
+	})
+
+	var previous *TimeWindow
+	for i := range windows {
+		from, _ := minuteOfDay(windows[i].From)
+		to, _ := minuteOfDay(windows[i].To)
+		if now >= from && now < to {
+			return nil
+		}
+		if now < from {
+			message := fmt.Sprintf("Your %s plan does not allow entry before %s", planTitle, windows[i].From)
+			if previous != nil {
+				message = fmt.Sprintf("Your %s plan does not allow entry between %s and %s", planTitle, previous.To, windows[i].From)
+			}
+			return &AccessDenial{
+				Reason:  AccessOutsideTimeWindow,
+				Message: message,
+				Details: map[string]interface{}{"next_allowed_at": windows[i].From, "windows": p.Windows},
+			}
+		}
+		previous = &windows[i]
+	}
+	return &AccessDenial{
+		Reason:  AccessOutsideTimeWindow,
+		Message: fmt.Sprintf("Your %s plan does not allow entry after %s", planTitle, previous.To),
+		Details: map[string]interface{}{"windows": p.Windows},
+	}
+}
+
+// Value stores the policy as JSON, or as the bare label when it has no rules
+func (p AccessPolicy) Value() (driver.Value, error) {
+	if len(p.Gyms) == 0 && len(p.Categories) == 0 && len(p.Days) == 0 && len(p.Windows) == 0 && p.GuestsPerPeriod == 0 {
+		return p.Label, nil
+	}
+	data, err := json.Marshal(p)
+	return string(data), err
+}
+
+// Scan reads a JSON policy, or a plain label written before policies existed
+func (p *AccessPolicy) Scan(value interface{}) error {
+	var text string
+	switch v := value.(type) {
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	case nil:
+	default:
+		return fmt.Errorf("models: cannot scan %T into AccessPolicy", value)
+	}
+	*p = AccessPolicy{}
+	if strings.HasPrefix(strings.TrimSpace(text), "{") {
+		return json.Unmarshal([]byte(text), p)
+	}
+	p.Label = text
+	return nil
+}
+
+// UnmarshalJSON accepts a policy object or, as before, a plain label string
+func (p *AccessPolicy) UnmarshalJSON(data []byte) error {
+	var label string
+	if err := json.Unmarshal(data, &label); err == nil {
+		return p.Scan(label)
+	}
+	type plain AccessPolicy
+	var policy plain
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return err
+	}
+	*p = AccessPolicy(policy)
+	return nil
+}
+
+// minuteOfDay parses "HH:MM"; "24:00" is the end of the day
+func minuteOfDay(hhmm string) (int, error) {
+	if hhmm == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, use HH:MM", hhmm)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func containsUUID(ids []uuid.UUID, id uuid.UUID) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(strings.TrimSpace(candidate), strings.TrimSpace(value)) {
+			return true
+		}
+	}
+	return false
+}
+
+func categoryName(category string) string {
+	if category == "" {
+		return "uncategorised"
+	}
+	return category
+}
@@ -0,0 +1,159 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestAccessPolicyCheck(t *testing.T) {
+	gymA, gymB := uuid.New(), uuid.New()
+	monday := func(hhmm string) time.Time {
+		at, _ := time.Parse("2006-01-02 15:04", "2026-03-02 "+hhmm)
+		return at
+	}
+	split := []TimeWindow{{From: "17:00", To: "22:00"}, {From: "06:00", To: "09:00"}}
+
+	tests := []struct {
+		name        string
+		policy      AccessPolicy
+		req         AccessRequest
+		wantReason  string // "" when allowed
+		wantMessage string
+	}{
+		{"label only allows everything", AccessPolicy{Label: "Gold"},
+			AccessRequest{GymID: gymB, Category: "yoga", At: monday("03:00")}, "", ""},
+		{"listed gym", AccessPolicy{Gyms: []uuid.UUID{gymA}},
+			AccessRequest{GymID: gymA, At: monday("12:00")}, "", ""},
+		{"other gym", AccessPolicy{Gyms: []uuid.UUID{gymA}},
+			AccessRequest{GymID: gymB, At: monday("12:00")}, AccessGymNotAllowed, "Your Basic plan does not include this gym"},
+		{"category ignores case and spaces", AccessPolicy{Categories: []string{"Yoga "}},
+			AccessRequest{Category: "yoga", At: monday("12:00")}, "", ""},
+		{"other category", AccessPolicy{Categories: []string{"yoga"}},
+			AccessRequest{Category: "boxing", At: monday("12:00")}, AccessCategoryNotAllowed, "Your Basic plan does not include boxing classes"},
+		{"uncategorised class", AccessPolicy{Categories: []string{"yoga"}},
+			AccessRequest{At: monday("12:00")}, AccessCategoryNotAllowed, "Your Basic plan does not include uncategorised classes"},
+		{"allowed day", AccessPolicy{Days: []string{"Mon", "wed"}},
+			AccessRequest{At: monday("12:00")}, "", ""},
+		{"other day", AccessPolicy{Days: []string{"sat", "sun"}},
+			AccessRequest{At: monday("12:00")}, AccessDayNotAllowed, "Your Basic plan does not allow entry on Monday"},
+		{"inside a window", AccessPolicy{Windows: split},
+			AccessRequest{At: monday("07:30")}, "", ""},
+		{"window start is inside", AccessPolicy{Windows: split},
+			AccessRequest{At: monday("17:00")}, "", ""},
+		{"window end is outside", AccessPolicy{Windows: split},
+			AccessRequest{At: monday("22:00")}, AccessOutsideTimeWindow, "Your Basic plan does not allow entry after 22:00"},
+		{"before the first window", AccessPolicy{Windows: split},
+			AccessRequest{At: monday("05:59")}, AccessOutsideTimeWindow, "Your Basic plan does not allow entry before 06:00"},
+		{"between windows", AccessPolicy{Windows: split},
+			AccessRequest{At: monday("12:00")}, AccessOutsideTimeWindow, "Your Basic plan does not allow entry between 09:00 and 17:00"},
+		{"window to the end of the day", AccessPolicy{Windows: []TimeWindow{{From: "20:00", To: "24:00"}}},
+			AccessRequest{At: monday("23:59")}, "", ""},
+		{"guests within the allowance", AccessPolicy{GuestsPerPeriod: 2},
+			AccessRequest{At: monday("12:00"), Guests: 1, GuestsUsed: 1}, "", ""},
+		{"guests over the allowance", AccessPolicy{GuestsPerPeriod: 2},
+			AccessRequest{At: monday("12:00"), Guests: 2, GuestsUsed: 1}, AccessGuestLimitReached, "Your Basic plan allows 2 guests per period and 1 have been used"},
+		{"no guests on a plan without an allowance", AccessPolicy{},
+			AccessRequest{At: monday("12:00"), Guests: 1}, AccessGuestLimitReached, "Your Basic plan allows 0 guests per period and 0 have been used"},
+		{"gym is checked before the window", AccessPolicy{Gyms: []uuid.UUID{gymA}, Windows: split},
+			AccessRequest{GymID: gymB, At: monday("12:00")}, AccessGymNotAllowed, "Your Basic plan does not include this gym"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			denial := tt.policy.Check("Basic", tt.req)
+			if tt.wantReason == "" {
+				if denial != nil {
+					t.Fatalf("Check() = %q, want allowed", denial.Message)
+				}
+				return
+			}
+			if denial == nil {
+				t.Fatalf("Check() allowed, want %s", tt.wantReason)
+			}
+			if denial.Reason != tt.wantReason || denial.Message != tt.wantMessage {
+				t.Errorf("Check() = %s %q, want %s %q", denial.Reason, denial.Message, tt.wantReason, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestAccessPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  AccessPolicy
+		wantErr bool
+	}{
+		{"empty", AccessPolicy{}, false},
+		{"days in any case", AccessPolicy{Days: []string{"MON", "sun"}}, false},
+		{"unknown day", AccessPolicy{Days: []string{"monday"}}, true},
+		{"window", AccessPolicy{Windows: []TimeWindow{{From: "06:00", To: "24:00"}}}, false},
+		{"window ending before it starts", AccessPolicy{Windows: []TimeWindow{{From: "10:00", To: "09:00"}}}, true},
+		{"empty window", AccessPolicy{Windows: []TimeWindow{{From: "10:00", To: "10:00"}}}, true},
+		{"malformed time", AccessPolicy{Windows: []TimeWindow{{From: "6am", To: "09:00"}}}, true},
+		{"negative guests", AccessPolicy{GuestsPerPeriod: -1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAccessPolicyStorage(t *testing.T) {
+	gym := uuid.New()
+	tests := []struct {
+		name   string
+		policy AccessPolicy
+		stored string // what Value writes, "" to skip checking it
+	}{
+		{"label only is stored bare", AccessPolicy{Label: "Off-peak"}, "Off-peak"},
+		{"rules are stored as JSON", AccessPolicy{Label: "Gold", Gyms: []uuid.UUID{gym}, Days: []string{"mon"}, GuestsPerPeriod: 1}, ""},
+		{"windows", AccessPolicy{Windows: []TimeWindow{{From: "06:00", To: "09:00"}}}, `{"windows":[{"from":"06:00","to":"09:00"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := tt.policy.Value()
+			if err != nil {
+				t.Fatalf("Value() error = %v", err)
+			}
+			if tt.stored != "" && value != tt.stored {
+				t.Errorf("Value() = %v, want %v", value, tt.stored)
+			}
+
+			var scanned AccessPolicy
+			if err := scanned.Scan([]byte(value.(string))); err != nil {
+				t.Fatalf("Scan() error = %v", err)
+			}
+			if !reflect.DeepEqual(scanned, tt.policy) {
+				t.Errorf("Scan() = %+v, want %+v", scanned, tt.policy)
+			}
+		})
+	}
+}
+
+func TestAccessPolicyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want AccessPolicy
+	}{
+		{"plain label", `"Gold"`, AccessPolicy{Label: "Gold"}},
+		{"object", `{"label":"Gold","categories":["yoga"]}`, AccessPolicy{Label: "Gold", Categories: []string{"yoga"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got AccessPolicy
+			if err := json.Unmarshal([]byte(tt.data), &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -28,6 +28,16 @@ type User struct {
 	Status                  string     `gorm:"type:varchar(20);default:'Active'" json:"status"`
 	UserType                string     `gorm:"type:varchar(20);default:'Member'" json:"user_type"`
 	ProfilePictureURL       string     `gorm:"size:255" json:"profile_picture_url"`
+	ProfileThumbnailURL     string     `gorm:"size:255" json:"profile_thumbnail_url"`
+	ProfilePictureKey       string     `gorm:"size:255" json:"-"` // blob key prefix of the current picture
+	GymID                   *uuid.UUID `gorm:"type:uuid" json:"gym_id"` // home gym, whose Settings apply to this user
+	EmailVerified           bool       `gorm:"default:false" json:"email_verified"`
+	EmailVerifiedAt         *time.Time `json:"email_verified_at"`
+	FailedLoginAttempts     int        `gorm:"not null;default:0" json:"-"`
+	LockedUntil             *time.Time `json:"locked_until,omitempty"`
+	TOTPSecret              string     `gorm:"size:64" json:"-"`
+	TOTPEnabled             bool       `gorm:"default:false" json:"totp_enabled"`
+	TOTPLastStep            int64      `gorm:"not null;default:0" json:"-"` // last accepted TOTP time step, to refuse replays
 	CreatedAt               time.Time  `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt               time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
 	Gender           string
@@ -48,8 +58,13 @@ type Member struct {
 	Gender           string
 	EmergencyContact datatypes.JSON `gorm:"type:jsonb"`
 	Notes            string         `gorm:"type:text"`
+	// ReferralCode is handed out by the member to refer others; it is
+	// created the first time it is asked for
+	ReferralCode *string `gorm:"size:16;uniqueIndex" json:"referral_code"`
 	CreatedAt        time.Time
 	UpdatedAt        time.Time
+	// Members are soft deleted so payments and memberships keep their owner
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
 	// Relationships
 	// User (One-to-One): Corrected the constraint tag. The foreign key is the UserID on this table.
@@ -78,16 +93,22 @@ type Gym struct {
 }
 
 // Plan model
+
 type Plan struct {
-	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	Title        string    `gorm:"not null"`
-	Description  string    `gorm:"type:text"`
-	PriceCents   int       `gorm:"not null"`
-	BillingCycle string    `gorm:"not null"`
-	NumSessions  *int      // null = unlimited
-	Access       string    `gorm:"not null"`
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
+	ID           uuid.UUID    `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Title        string       `gorm:"not null"`
+	Description  string       `gorm:"type:text"`
+	PriceCents   int          `gorm:"not null"`
+	BillingCycle string       `gorm:"not null"`
+	NumSessions  *int         // null = unlimited
+	Access       AccessPolicy `gorm:"type:text;not null"`
+	// Version is the current PlanVersion; a new one starts whenever the
+	// price or billing cycle changes
+	Version int `gorm:"not null;default:1"`
+	// ArchivedAt takes the plan off sale; its memberships stay valid
+	ArchivedAt *time.Time `gorm:"index"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
 
 	// Relationships
 	Memberships []Membership `gorm:"foreignKey:PlanID"`
@@ -100,9 +121,25 @@ type Membership struct {
     PlanID   uuid.UUID `gorm:"type:uuid;not null"`
 	StartDate       time.Time `gorm:"not null"`
 	EndDate         time.Time `gorm:"not null"`
-	Status          string    `gorm:"not null;default:'active'"`
+	Status          string    `gorm:"not null;default:'active';index"`
 	AutoRenew       bool      `gorm:"default:true"`
 	PaymentMethodID *string
+	PlanVersion     int       `gorm:"not null;default:1"` // the plan version whose price this period was sold at
+	// StatusReason is the reason code of the last status change
+	StatusReason    string
+	FrozenUntil     *time.Time
+	// CancelAt is set when the membership is cancelled at the end of its period
+	CancelAt        *time.Time
+	// RenewedFromID is the membership this one renews; unique so a period
+	// is only ever renewed once
+	RenewedFromID   *uuid.UUID `gorm:"type:uuid;uniqueIndex"`
+	// GraceUntil is when a past due membership is cancelled if still unpaid
+	GraceUntil      *time.Time
+	// ChangedFromID is the membership this one replaced in a plan change
+	ChangedFromID   *uuid.UUID `gorm:"type:uuid;index"`
+	// PromoCodeID is the promo code the membership was sold with; renewals
+	// inherit it unless it is for the first period only
+	PromoCodeID     *uuid.UUID `gorm:"type:uuid;index"`
 	CreatedAt       time.Time
 	UpdatedAt       time.Time
 
@@ -126,6 +163,7 @@ type Class struct {
 	Capacity        int            `gorm:"not null"`
 	RecurringRule   datatypes.JSON `gorm:"type:jsonb"`
 	DurationMinutes int            `gorm:"not null"`
+	Category        string         `gorm:"size:50;index"` // matched against plan access policies
 	CreatedAt       time.Time
 	UpdatedAt       time.Time
 
@@ -152,14 +190,21 @@ type ClassSession struct {
 	Attendance []Attendance `gorm:"foreignKey:SessionID"`
 }
 
+// Booking statuses
+const (
+	BookingBooked    = "booked"
+	BookingCancelled = "cancelled"
+)
+
 // Booking model
 type Booking struct {
-	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	SessionID uuid.UUID `gorm:"type:uuid;not null"`
-	MemberID  uuid.UUID `gorm:"type:uuid;not null"`
-	Status    string    `gorm:"not null;default:'booked'"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	SessionID  uuid.UUID  `gorm:"type:uuid;not null"`
+	MemberID   uuid.UUID  `gorm:"type:uuid;not null"`
+	Status     string     `gorm:"not null;default:'booked'"`
+	BookedByID *uuid.UUID `gorm:"type:uuid"` // user who booked, e.g. a guardian booking for a dependent
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
 
 	// Relationships
 	Session ClassSession `gorm:"foreignKey:SessionID"`
@@ -173,6 +218,7 @@ type Attendance struct {
 	MemberID        uuid.UUID `gorm:"type:uuid;not null"`
 	CheckinMethod   string    `gorm:"not null"`
 	CheckedInAt     time.Time `gorm:"not null"`
+	Guests          int       `gorm:"not null;default:0"` // guests who came in with the member
 	CreatedAt       time.Time
 
 	// Relationships
@@ -181,16 +227,24 @@ type Attendance struct {
 }
 
 // Payment model
+
 type Payment struct {
-	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	MemberID    uuid.UUID `gorm:"type:uuid;not null"`
-	AmountCents int       `gorm:"not null"`
-	Currency    string    `gorm:"not null;default:'Birr'"`
-	Method      string    `gorm:"not null"`
-	Status      string    `gorm:"not null;default:'pending'"`
-	Reference   string
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	MemberID     uuid.UUID `gorm:"type:uuid;not null"`
+	AmountCents  int       `gorm:"not null"`
+	Currency     string    `gorm:"not null;default:'Birr'"`
+	Method       string    `gorm:"not null"`
+	Status       string    `gorm:"not null;default:'pending'"`
+	Reference    string
+	PayerID      *uuid.UUID `gorm:"type:uuid;index"` // user who paid; a household's primary payer pays for its dependents
+	MembershipID *uuid.UUID `gorm:"type:uuid;index"` // the membership period this payment is for
+	// AmountCents is what is due after DiscountCents off by PromoCodeID and
+	// CreditCents of account credit
+	DiscountCents int        `gorm:"not null;default:0"`
+	PromoCodeID   *uuid.UUID `gorm:"type:uuid;index"`
+	CreditCents   int        `gorm:"not null;default:0"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
 
 	// Relationships
 	Member Member `gorm:"foreignKey:MemberID"`
@@ -239,6 +293,322 @@ type AuditLog struct {
 	Actor User `gorm:"foreignKey:ActorUserID;references:UserID"`
 }
 
+// RefreshToken is the server-side record of an issued refresh token.
+// Tokens issued from one login share a FamilyID; every use rotates to a new
+// token in the same family.
+type RefreshToken struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	FamilyID  uuid.UUID `gorm:"type:uuid;not null;index"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	DeviceID  string    `gorm:"size:100"`
+	UserAgent string    `gorm:"size:255"`
+	IPAddress string    `gorm:"size:45"`
+	ExpiresAt time.Time `gorm:"not null"`
+	RotatedAt *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+
+	User User `gorm:"foreignKey:UserID;references:UserID;constraint:OnDelete:CASCADE"`
+}
+
+// RevokedToken is an access token revoked before its expiry, keyed by jti
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;size:36"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	RevokedAt time.Time `gorm:"not null"`
+}
+
+// TokenWatermark invalidates every token of a user issued before RevokedBefore
+type TokenWatermark struct {
+	UserID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	RevokedBefore time.Time `gorm:"not null;index"`
+	UpdatedAt     time.Time
+}
+
+// UserToken is a hashed, expiring, single-use token mailed to a user
+// (password reset, email verification)
+type UserToken struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	Purpose   string    `gorm:"size:30;not null"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+
+	User User `gorm:"foreignKey:UserID;references:UserID;constraint:OnDelete:CASCADE"`
+}
+
+// UserToken purposes
+const (
+	TokenPurposePasswordReset = "password_reset"
+	TokenPurposeVerifyEmail   = "verify_email"
+)
+
+// RecoveryCode is a hashed single-use 2FA backup code
+type RecoveryCode struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	CodeHash  string    `gorm:"size:64;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+
+	User User `gorm:"foreignKey:UserID;references:UserID;constraint:OnDelete:CASCADE"`
+}
+
+// UserIdentity links a user to an account at an external OIDC provider
+type UserIdentity struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Provider    string     `gorm:"size:50;not null;uniqueIndex:idx_user_identity_subject" json:"provider"`
+	Subject     string     `gorm:"size:255;not null;uniqueIndex:idx_user_identity_subject" json:"-"` // the provider's "sub" claim
+	Email       string     `gorm:"size:100" json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+
+	User User `gorm:"foreignKey:UserID;references:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// APIKey authenticates a device or integration instead of a user. Only the
+// SHA-256 hash of the key is stored; Prefix is kept to tell keys apart.
+type APIKey struct {
+	ID          uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	Name        string         `gorm:"size:100;not null" json:"name"`
+	Prefix      string         `gorm:"size:16;not null" json:"prefix"`
+	KeyHash     string         `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	GymID       uuid.UUID      `gorm:"type:uuid;not null;index" json:"gym_id"` // the only gym the key may act on
+	Scopes      datatypes.JSON `gorm:"type:jsonb;not null" json:"scopes"`
+	ExpiresAt   *time.Time     `json:"expires_at"`
+	LastUsedAt  *time.Time     `json:"last_used_at"`
+	LastUsedIP  string         `gorm:"size:45" json:"last_used_ip"`
+	RevokedAt   *time.Time     `json:"revoked_at"`
+	CreatedByID uuid.UUID      `gorm:"type:uuid;not null" json:"created_by_id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+
+	Gym       Gym  `gorm:"foreignKey:GymID;constraint:OnDelete:CASCADE" json:"-"`
+	CreatedBy User `gorm:"foreignKey:CreatedByID;references:UserID" json:"-"`
+}
+
+// Erasure request statuses
+const (
+	ErasurePending   = "pending"
+	ErasureCompleted = "completed"
+	ErasureRejected  = "rejected"
+)
+
+// ErasureRequest asks for a user's personal data to be anonymized. An admin
+// must approve it; approval carries out the erasure.
+type ErasureRequest struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	RequestedByID uuid.UUID  `gorm:"type:uuid;not null" json:"requested_by_id"`
+	Reason        string     `gorm:"type:text" json:"reason"`
+	Status        string     `gorm:"size:20;not null;default:'pending';index" json:"status"`
+	ReviewedByID  *uuid.UUID `gorm:"type:uuid" json:"reviewed_by_id"`
+	ReviewedAt    *time.Time `json:"reviewed_at"`
+	ReviewNote    string     `gorm:"type:text" json:"review_note"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+
+	User User `gorm:"foreignKey:UserID;references:UserID" json:"-"`
+}
+
+// Household groups family members under one billing account. The primary
+// payer pays for everyone in it.
+type Household struct {
+	ID             uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	Name           string    `gorm:"size:100;not null" json:"name"`
+	PrimaryPayerID uuid.UUID `gorm:"type:uuid;not null;index" json:"primary_payer_id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+
+	PrimaryPayer User              `gorm:"foreignKey:PrimaryPayerID;references:UserID" json:"-"`
+	Members      []HouseholdMember `gorm:"foreignKey:HouseholdID" json:"members,omitempty"`
+}
+
+// HouseholdMember places a user in a household. A user belongs to at most one.
+type HouseholdMember struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	HouseholdID uuid.UUID `gorm:"type:uuid;not null;index" json:"household_id"`
+	UserID      uuid.UUID `gorm:"type:uuid;not null;uniqueIndex" json:"user_id"`
+	Role        string    `gorm:"size:20;not null" json:"role"` // primary, adult or dependent
+	CreatedAt   time.Time `json:"created_at"`
+
+	Household Household `gorm:"foreignKey:HouseholdID;constraint:OnDelete:CASCADE" json:"-"`
+	User      User      `gorm:"foreignKey:UserID;references:UserID" json:"user"`
+}
+
+// GuardianLink records that a guardian consented to, and may act for, a
+// dependent, usually a minor.
+type GuardianLink struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	GuardianID   uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_guardian_dependent" json:"guardian_id"`
+	DependentID  uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_guardian_dependent;index" json:"dependent_id"`
+	Relationship string     `gorm:"size:30" json:"relationship"`
+	ConsentedAt  time.Time  `gorm:"not null" json:"consented_at"`
+	ConsentedBy  uuid.UUID  `gorm:"type:uuid;not null" json:"consented_by"` // the guardian, or the admin who recorded consent
+	ConsentIP    string     `gorm:"size:45" json:"-"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+
+	Guardian  User `gorm:"foreignKey:GuardianID;references:UserID" json:"-"`
+	Dependent User `gorm:"foreignKey:DependentID;references:UserID" json:"dependent"`
+}
+
+// SessionUsage is one credit of a membership period's session quota, taken
+// by a booking or a check-in. A booking and the check-in for the same
+// session share one credit; refunded credits stay for the record.
+type SessionUsage struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	MembershipID uuid.UUID  `gorm:"type:uuid;not null;index" json:"membership_id"`
+	MemberID     uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_session_usage_member_session,where:refunded_at IS NULL" json:"member_id"`
+	SessionID    uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_session_usage_member_session,where:refunded_at IS NULL" json:"session_id"`
+	Source       string     `gorm:"size:20;not null" json:"source"` // booking or checkin
+	BookingID    *uuid.UUID `gorm:"type:uuid;index" json:"booking_id"`
+	RefundedAt   *time.Time `json:"refunded_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+
+	Membership Membership `gorm:"foreignKey:MembershipID" json:"-"`
+}
+
+// PlanVersion is the price and billing cycle a plan had between changes.
+// Memberships keep the version they were sold at until they renew.
+type PlanVersion struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	PlanID       uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_plan_version" json:"plan_id"`
+	Version      int        `gorm:"not null;uniqueIndex:idx_plan_version" json:"version"`
+	PriceCents   int        `gorm:"not null" json:"price_cents"`
+	BillingCycle string     `gorm:"not null" json:"billing_cycle"`
+	CreatedByID  *uuid.UUID `gorm:"type:uuid" json:"created_by_id"`
+	CreatedAt    time.Time  `json:"created_at"`
+
+	Plan Plan `gorm:"foreignKey:PlanID" json:"-"`
+}
+
+// PassProduct is a pass on sale: a single visit, an N-day trial or a guest
+// pass
+type PassProduct struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	Name       string     `gorm:"not null" json:"name"`
+	Kind       string     `gorm:"size:20;not null;index" json:"kind"`
+	Visits     *int       `json:"visits"` // null = unlimited while valid
+	ValidDays  int        `gorm:"not null;default:1" json:"valid_days"`
+	PriceCents int        `gorm:"not null;default:0" json:"price_cents"`
+	GymID      *uuid.UUID `gorm:"type:uuid" json:"gym_id"` // null = every gym
+	ArchivedAt *time.Time `gorm:"index" json:"archived_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// Pass is one pass sold or issued. Its holder is a member, or a walk-in
+// known only by name and contact details. It is redeemed with its code.
+type Pass struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ProductID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"product_id"`
+	Code        string     `gorm:"size:16;not null;uniqueIndex" json:"code"`
+	Status      string     `gorm:"size:20;not null;default:'active';index" json:"status"`
+	MemberID    *uuid.UUID `gorm:"type:uuid;index" json:"member_id"`
+	HolderName  string     `json:"holder_name"`
+	HolderEmail string     `json:"holder_email"`
+	HolderPhone string     `json:"holder_phone"`
+	// SponsorID is the member who issued a guest pass
+	SponsorID  *uuid.UUID `gorm:"type:uuid;index" json:"sponsor_id"`
+	VisitsLeft *int       `json:"visits_left"` // null = unlimited until ValidUntil
+	ValidFrom  time.Time  `gorm:"not null" json:"valid_from"`
+	ValidUntil time.Time  `gorm:"not null" json:"valid_until"`
+	PriceCents int        `gorm:"not null;default:0" json:"price_cents"`
+	// PaymentID is the payment of a pass sold to a member; walk-in sales are
+	// recorded on the pass by PriceCents and PaymentMethod
+	PaymentID     *uuid.UUID `gorm:"type:uuid" json:"payment_id"`
+	PaymentMethod string     `json:"payment_method"`
+	// MembershipID is the membership a trial was converted into
+	MembershipID *uuid.UUID `gorm:"type:uuid;index" json:"membership_id"`
+	IssuedByID   *uuid.UUID `gorm:"type:uuid" json:"issued_by_id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+
+	Product PassProduct `gorm:"foreignKey:ProductID" json:"product"`
+	Visits  []PassVisit `gorm:"foreignKey:PassID" json:"visits,omitempty"`
+}
+
+// PassVisit is one redemption of a pass at a gym, for a class session or
+// for open gym
+type PassVisit struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	PassID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"pass_id"`
+	GymID         uuid.UUID  `gorm:"type:uuid;not null" json:"gym_id"`
+	SessionID     *uuid.UUID `gorm:"type:uuid" json:"session_id"`
+	CheckedInByID *uuid.UUID `gorm:"type:uuid" json:"checked_in_by_id"`
+	VisitedAt     time.Time  `gorm:"not null" json:"visited_at"`
+}
+
+// PromoCode is a discount entered at registration or on a plan change.
+// Unless it is for the first period only, it keeps discounting renewals.
+type PromoCode struct {
+	ID             uuid.UUID   `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	Code           string      `gorm:"size:40;not null;uniqueIndex" json:"code"`
+	Description    string      `json:"description"`
+	Kind           string      `gorm:"size:20;not null" json:"kind"`
+	PercentOff     int         `gorm:"not null;default:0" json:"percent_off"`
+	AmountOffCents int         `gorm:"not null;default:0" json:"amount_off_cents"`
+	PlanIDs        []uuid.UUID `gorm:"serializer:json;type:jsonb" json:"plan_ids"` // empty = every plan
+	MaxRedemptions *int        `json:"max_redemptions"`                            // null = no cap
+	MaxPerMember   int         `gorm:"not null;default:1" json:"max_per_member"`   // 0 = no cap
+	// Redemptions counts first uses; renewals a code keeps discounting do
+	// not count towards MaxRedemptions
+	Redemptions     int        `gorm:"not null;default:0" json:"redemptions"`
+	ValidFrom       *time.Time `json:"valid_from"`
+	ValidUntil      *time.Time `json:"valid_until"`
+	FirstPeriodOnly bool       `gorm:"not null;default:false" json:"first_period_only"`
+	ArchivedAt      *time.Time `gorm:"index" json:"archived_at"`
+	CreatedByID     *uuid.UUID `gorm:"type:uuid" json:"created_by_id"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// PromoRedemption is one discount a promo code gave on a payment
+type PromoRedemption struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	PromoCodeID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"promo_code_id"`
+	MemberID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"member_id"`
+	MembershipID  *uuid.UUID `gorm:"type:uuid" json:"membership_id"`
+	PaymentID     *uuid.UUID `gorm:"type:uuid;index" json:"payment_id"`
+	DiscountCents int        `gorm:"not null" json:"discount_cents"`
+	Renewal       bool       `gorm:"not null;default:false" json:"renewal"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// Referral records that a member brought in another. The referrer is
+// rewarded when the referred member's first payment is paid.
+type Referral struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ReferrerID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"referrer_id"`
+	ReferredID  uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex" json:"referred_id"` // a member is referred once
+	Status      string     `gorm:"size:20;not null;default:'pending';index" json:"status"`
+	RewardCents int        `gorm:"not null;default:0" json:"reward_cents"`
+	RewardDays  int        `gorm:"not null;default:0" json:"reward_days"`
+	PaymentID   *uuid.UUID `gorm:"type:uuid" json:"payment_id"` // the payment that earned the reward
+	RewardedAt  *time.Time `json:"rewarded_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+
+	Referred Member `gorm:"foreignKey:ReferredID" json:"-"`
+}
+
+// MemberCredit is an entry in a member's account credit: positive when
+// earned, negative when spent on a payment
+type MemberCredit struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	MemberID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"member_id"`
+	AmountCents int        `gorm:"not null" json:"amount_cents"`
+	Reason      string     `gorm:"size:20;not null" json:"reason"`
+	ReferralID  *uuid.UUID `gorm:"type:uuid" json:"referral_id"`
+	PaymentID   *uuid.UUID `gorm:"type:uuid" json:"payment_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
 func MigrateModels(db *gorm.DB) {
 	// Make sure pgcrypto extension exists before anything else
 	if err := db.Exec(`CREATE EXTENSION IF NOT EXISTS "pgcrypto";`).Error; err != nil {
@@ -262,6 +632,26 @@ func MigrateModels(db *gorm.DB) {
 		&InventoryItem{},   // 11. Depends on Gym
 		&Notification{},    // 12. Depends on User
 		&AuditLog{},        // 13. Depends on User
+		&RefreshToken{},    // 14. Depends on User
+		&RevokedToken{},    // 15. Independent
+		&TokenWatermark{},  // 16. Independent
+		&UserToken{},       // 17. Depends on User
+		&RecoveryCode{},    // 18. Depends on User
+		&UserIdentity{},    // 19. Depends on User
+		&APIKey{},          // 20. Depends on Gym, User
+		&ErasureRequest{},  // 21. Depends on User
+		&Household{},       // 22. Depends on User
+		&HouseholdMember{}, // 23. Depends on Household, User
+		&GuardianLink{},    // 24. Depends on User
+		&SessionUsage{},    // 25. Depends on Membership
+		&PlanVersion{},     // 26. Depends on Plan
+		&PassProduct{},     // 27. Depends on Gym
+		&Pass{},            // 28. Depends on PassProduct, Member
+		&PassVisit{},       // 29. Depends on Pass
+		&PromoCode{},       // 30. Independent
+		&PromoRedemption{}, // 31. Depends on PromoCode, Member, Payment
+		&Referral{},        // 32. Depends on Member
+		&MemberCredit{},    // 33. Depends on Member
 	}
 
 	for _, m := range models {
@@ -270,5 +660,12 @@ func MigrateModels(db *gorm.DB) {
 		}
 	}
 
+	if err := migrateSearchIndexes(db); err != nil {
+		panic("❌ " + err.Error())
+	}
+	if err := migratePlanVersions(db); err != nil {
+		panic("❌ " + err.Error())
+	}
+
 	fmt.Println("✅ All database migrations completed successfully!")
 }
\ No newline at end of file
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// API key scopes are "<resource>:<access>". A write scope does not imply read.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// API key resources, matching the Scope of a route policy
+const (
+	ScopeResourceAttendance = "attendance"
+	ScopeResourceSessions   = "sessions"
+	ScopeResourceClasses    = "classes"
+)
+
+// APIKeyScopes lists every scope a key can be granted.
+var APIKeyScopes = []string{
+	ScopeResourceAttendance + ":" + ScopeRead,
+	ScopeResourceAttendance + ":" + ScopeWrite,
+	ScopeResourceSessions + ":" + ScopeRead,
+	ScopeResourceSessions + ":" + ScopeWrite,
+	ScopeResourceClasses + ":" + ScopeRead,
+	ScopeResourceClasses + ":" + ScopeWrite,
+}
+
+// IsValidScope reports whether scope is one of APIKeyScopes.
+func IsValidScope(scope string) bool {
+	for _, s := range APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopeList decodes Scopes.
+func (k *APIKey) ScopeList() []string {
+	var scopes []string
+	if len(k.Scopes) > 0 {
+		_ = json.Unmarshal(k.Scopes, &scopes)
+	}
+	return scopes
+}
+
+// Usable reports whether the key is neither revoked nor expired at now.
+func (k *APIKey) Usable(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || k.ExpiresAt.After(now))
+}
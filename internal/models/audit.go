@@ -0,0 +1,63 @@
+package models
+
+import "github.com/google/uuid"
+
+// Action types written to AuditLog.ActionType
+const (
+	AuditRefreshTokenReuse         = "auth.refresh_token_reuse"
+	AuditSessionsRevoked           = "auth.sessions_revoked"
+	AuditAccountLocked             = "auth.account_locked"
+	AuditAccountUnlocked           = "auth.account_unlocked"
+	AuditMFAEnabled                = "auth.mfa_enabled"
+	AuditMFADisabled               = "auth.mfa_disabled"
+	AuditRecoveryCodeUsed          = "auth.recovery_code_used"
+	AuditIdentityLinked            = "auth.identity_linked"
+	AuditUserProvisioned           = "auth.user_provisioned"
+	AuditAPIKeyCreated             = "api_key.created"
+	AuditAPIKeyUpdated             = "api_key.updated"
+	AuditAPIKeyRevoked             = "api_key.revoked"
+	AuditAPIKeyUsed                = "api_key.used"
+	AuditImpersonationStarted      = "auth.impersonation_started"
+	AuditImpersonatedRequest       = "auth.impersonated_request"
+	AuditDataExported              = "privacy.data_exported"
+	AuditErasureRequested          = "privacy.erasure_requested"
+	AuditErasureRejected           = "privacy.erasure_rejected"
+	AuditErasureCompleted          = "privacy.erasure_completed"
+	AuditHouseholdCreated          = "household.created"
+	AuditHouseholdMemberAdded      = "household.member_added"
+	AuditHouseholdMemberRemoved    = "household.member_removed"
+	AuditHouseholdPayerChanged     = "household.primary_payer_changed"
+	AuditGuardianLinked            = "guardian.linked"
+	AuditGuardianRevoked           = "guardian.revoked"
+	AuditMembersImported           = "member.imported"
+	AuditMembershipCreated         = "membership.created"
+	AuditMembershipUpdated         = "membership.updated"
+	AuditMembershipDeleted         = "membership.deleted"
+	AuditMembershipStatusChanged   = "membership.status_changed"
+	AuditMembershipCancelScheduled = "membership.cancel_scheduled"
+	AuditMembershipRenewed         = "membership.renewed"
+	AuditMembershipPlanChanged     = "membership.plan_changed"
+	AuditPaymentStatusChanged      = "payment.status_changed"
+	AuditPlanCreated               = "plan.created"
+	AuditPlanUpdated               = "plan.updated"
+	AuditPlanArchived              = "plan.archived"
+	AuditPlanUnarchived            = "plan.unarchived"
+	AuditPlanDeleted               = "plan.deleted"
+	AuditPassIssued                = "pass.issued"
+	AuditPassConverted             = "pass.converted"
+	AuditPassVoided                = "pass.voided"
+	AuditPromoCreated              = "promo.created"
+	AuditPromoArchived             = "promo.archived"
+	AuditReferralRewarded          = "referral.rewarded"
+)
+
+// NewAuditLog builds an AuditLog entry with its metadata encoded as JSON.
+func NewAuditLog(actorID uuid.UUID, action, targetType string, targetID uuid.UUID, metadata map[string]interface{}) *AuditLog {
+	return &AuditLog{
+		ActorUserID: actorID,
+		ActionType:  action,
+		TargetType:  targetType,
+		TargetID:    targetID,
+		Metadata:    MapToJSON(metadata),
+	}
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// GymSettings is the typed view of the keys we read from Gym.Settings.
+// Unknown keys are kept in the JSON column untouched.
+type GymSettings struct {
+	// RequireEmailVerification refuses login until the user confirmed their email
+	RequireEmailVerification bool `json:"require_email_verification"`
+	// RequireStaff2FA forces TOTP for every user whose role is not Member
+	RequireStaff2FA bool `json:"require_staff_2fa"`
+	// GuardianConsentAge is the age below which members need a guardian.
+	// Zero means DefaultGuardianConsentAge.
+	GuardianConsentAge int `json:"guardian_consent_age"`
+	// LateCancelHours is how close to the start of a session a booking can
+	// be cancelled and still get its credit back. Zero means
+	// DefaultLateCancelHours.
+	LateCancelHours int `json:"late_cancel_hours"`
+	// ReferralRewardCents is the account credit a member earns for a
+	// referral, ReferralRewardDays the free days added to their membership.
+	// When both are zero the reward is DefaultReferralRewardDays.
+	ReferralRewardCents int `json:"referral_reward_cents"`
+	ReferralRewardDays  int `json:"referral_reward_days"`
+}
+
+// ConsentAge returns GuardianConsentAge with the default applied.
+func (s GymSettings) ConsentAge() int {
+	if s.GuardianConsentAge <= 0 {
+		return DefaultGuardianConsentAge
+	}
+	return s.GuardianConsentAge
+}
+
+// LateCancelWindow returns LateCancelHours with the default applied.
+func (s GymSettings) LateCancelWindow() time.Duration {
+	if s.LateCancelHours <= 0 {
+		return DefaultLateCancelHours * time.Hour
+	}
+	return time.Duration(s.LateCancelHours) * time.Hour
+}
+
+// ReferralReward returns the credit and free days earned for a referral
+// with the default applied.
+func (s GymSettings) ReferralReward() (cents, days int) {
+	if s.ReferralRewardCents <= 0 && s.ReferralRewardDays <= 0 {
+		return 0, DefaultReferralRewardDays
+	}
+	return max(s.ReferralRewardCents, 0), max(s.ReferralRewardDays, 0)
+}
+
+// Location returns the gym's time zone, or the server's when it has none or
+// an unknown one.
+func (g *Gym) Location() *time.Location {
+	if g.Timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(g.Timezone)
+	if err != nil {
+		log.Printf("WARN: gym %s has unknown timezone %q: %v", g.ID, g.Timezone, err)
+		return time.Local
+	}
+	return loc
+}
+
+// ParsedSettings decodes Gym.Settings, falling back to zero values.
+func (g *Gym) ParsedSettings() GymSettings {
+	var settings GymSettings
+	if len(g.Settings) == 0 {
+		return settings
+	}
+	if err := json.Unmarshal(g.Settings, &settings); err != nil {
+		log.Printf("WARN: gym %s has invalid settings JSON: %v", g.ID, err)
+	}
+	return settings
+}
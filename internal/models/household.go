@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Roles within a household
+const (
+	HouseholdPrimary   = "primary"
+	HouseholdAdult     = "adult"
+	HouseholdDependent = "dependent"
+)
+
+// DefaultGuardianConsentAge applies when a gym does not set
+// guardian_consent_age.
+const DefaultGuardianConsentAge = 16
+
+// AgeOn returns the age in whole years of someone born on dob at the given time.
+func AgeOn(dob, at time.Time) int {
+	age := at.Year() - dob.Year()
+	if at.Month() < dob.Month() || (at.Month() == dob.Month() && at.Day() < dob.Day()) {
+		age--
+	}
+	return age
+}
+
+// IsMinor reports whether a user is younger than consentAge. Users without a
+// date of birth are not treated as minors.
+func (u *User) IsMinor(consentAge int, at time.Time) bool {
+	return u.DateOfBirth != nil && AgeOn(*u.DateOfBirth, at) < consentAge
+}
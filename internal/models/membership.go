@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+// Membership statuses
+const (
+	MembershipPending   = "pending"
+	MembershipActive    = "active"
+	MembershipFrozen    = "frozen"
+	MembershipPastDue   = "past_due"
+	MembershipCancelled = "cancelled"
+	MembershipExpired   = "expired"
+)
+
+// Reason codes recorded with each membership status change. The first group
+// is given by staff; the second is set by the lifecycle job.
+const (
+	ReasonMemberRequest = "member_request"
+	ReasonMedical       = "medical"
+	ReasonTravel        = "travel"
+	ReasonNonPayment    = "non_payment"
+	ReasonRelocation    = "relocation"
+	ReasonStaffDecision = "staff_decision"
+	ReasonOther         = "other"
+
+	ReasonStarted         = "start_date_reached"
+	ReasonFreezeEnded     = "freeze_ended"
+	ReasonPeriodEnded     = "period_ended"
+	ReasonPaymentOverdue  = "payment_overdue"
+	ReasonPaymentFailed   = "payment_failed"
+	ReasonPaymentReceived = "payment_received"
+	ReasonPlanChanged     = "plan_changed"
+)
+
+// MembershipReasons are the reason codes staff may give
+var MembershipReasons = map[string]bool{
+	ReasonMemberRequest: true,
+	ReasonMedical:       true,
+	ReasonTravel:        true,
+	ReasonNonPayment:    true,
+	ReasonRelocation:    true,
+	ReasonStaffDecision: true,
+	ReasonOther:         true,
+}
+
+// membershipTransitions lists the statuses each status may move to.
+// Cancelled and expired are final. Past due is an active period whose
+// payment has not come in; it returns to active once paid.
+var membershipTransitions = map[string][]string{
+	MembershipPending: {MembershipActive, MembershipPastDue, MembershipCancelled},
+	MembershipActive:  {MembershipFrozen, MembershipPastDue, MembershipCancelled, MembershipExpired},
+	MembershipFrozen:  {MembershipActive, MembershipCancelled},
+	MembershipPastDue: {MembershipActive, MembershipCancelled, MembershipExpired},
+}
+
+// CanTransition reports whether a membership may move from one status to another
+func CanTransition(from, to string) bool {
+	for _, next := range membershipTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// InitialMembershipStatus is the status of a new membership starting on start
+func InitialMembershipStatus(start, now time.Time) string {
+	if start.After(now) {
+		return MembershipPending
+	}
+	return MembershipActive
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Pass product kinds. Single visits and trials are sold to walk-ins; guest
+// passes are issued by a member for someone they bring along and count
+// against the guest allowance of the member's plan.
+const (
+	PassSingleVisit = "single_visit"
+	PassTrial       = "trial"
+	PassGuest       = "guest"
+)
+
+// Pass statuses. A pass that has run out of visits or days stays active;
+// Usable tells whether it can still be redeemed.
+const (
+	PassActive    = "active"
+	PassConverted = "converted" // a trial that became a membership
+	PassVoid      = "void"
+)
+
+// Normalize fills in the defaults of a kind and checks the product.
+// Single visits and guest passes are one visit on one day unless set
+// otherwise; trials need a number of days.
+func (p *PassProduct) Normalize() error {
+	if p.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if p.PriceCents < 0 {
+		return fmt.Errorf("price_cents cannot be negative")
+	}
+	switch p.Kind {
+	case PassSingleVisit, PassGuest:
+		if p.Visits == nil {
+			one := 1
+			p.Visits = &one
+		}
+		if p.ValidDays == 0 {
+			p.ValidDays = 1
+		}
+	case PassTrial:
+		if p.ValidDays < 1 {
+			return fmt.Errorf("trials need valid_days")
+		}
+	default:
+		return fmt.Errorf("kind must be %s, %s or %s", PassSingleVisit, PassTrial, PassGuest)
+	}
+	if p.Visits != nil && *p.Visits < 1 {
+		return fmt.Errorf("visits must be at least 1, or left out for unlimited visits")
+	}
+	if p.ValidDays < 1 {
+		return fmt.Errorf("valid_days must be at least 1")
+	}
+	return nil
+}
+
+// Unusable explains why a pass cannot be redeemed at now, or returns "" when
+// it can
+func (p *Pass) Unusable(now time.Time) string {
+	switch {
+	case p.Status == PassConverted:
+		return "this trial has been converted to a membership"
+	case p.Status != PassActive:
+		return "this pass has been voided"
+	case now.Before(p.ValidFrom):
+		return fmt.Sprintf("this pass is valid from %s", p.ValidFrom.Format("2006-01-02"))
+	case !now.Before(p.ValidUntil):
+		return "this pass has expired"
+	case p.VisitsLeft != nil && *p.VisitsLeft <= 0:
+		return "this pass has no visits left"
+	}
+	return ""
+}
@@ -0,0 +1,40 @@
+package models
+
+// Payment statuses
+const (
+	PaymentPending = "pending"
+	PaymentPaid    = "paid"
+	PaymentFailed  = "failed"
+	PaymentVoid    = "void"
+)
+
+// paymentTransitions lists the statuses each payment status may move to.
+// Paid and void are final; a failed payment may still be paid, for example
+// by a retried charge, or written off as void.
+var paymentTransitions = map[string][]string{
+	PaymentPending: {PaymentPaid, PaymentFailed, PaymentVoid},
+	PaymentFailed:  {PaymentPaid, PaymentVoid},
+}
+
+// CanChangePaymentStatus reports whether a payment may move from one status
+// to another
+func CanChangePaymentStatus(from, to string) bool {
+	for _, next := range paymentTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Methods of payments the system raises itself: PaymentMethodAutoRenewal by
+// the renewal worker, PaymentMethodPlanChange for the difference owed when a
+// membership changes plan, PaymentMethodPass for passes not paid at the
+// desk and PaymentMethodSignup for the first period of members who
+// registered with a promo code.
+const (
+	PaymentMethodAutoRenewal = "auto_renewal"
+	PaymentMethodPlanChange  = "plan_change"
+	PaymentMethodPass        = "pass"
+	PaymentMethodSignup      = "signup"
+)
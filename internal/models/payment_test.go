@@ -0,0 +1,28 @@
+package models
+
+import "testing"
+
+func TestCanChangePaymentStatus(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{PaymentPending, PaymentPaid, true},
+		{PaymentPending, PaymentFailed, true},
+		{PaymentPending, PaymentVoid, true},
+		{PaymentFailed, PaymentPaid, true},
+		{PaymentFailed, PaymentVoid, true},
+		{PaymentFailed, PaymentPending, false},
+		{PaymentPaid, PaymentPending, false},
+		{PaymentPaid, PaymentFailed, false},
+		{PaymentPaid, PaymentVoid, false},
+		{PaymentVoid, PaymentPaid, false},
+		{PaymentVoid, PaymentPending, false},
+		{PaymentPending, "refunded", false},
+	}
+	for _, tt := range tests {
+		if got := CanChangePaymentStatus(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanChangePaymentStatus(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PeriodEnd returns when a billing period of the plan that starts at start
+// ends. Unknown cycles are treated as monthly.
+func (p *Plan) PeriodEnd(start time.Time) time.Time {
+	switch strings.ToLower(p.BillingCycle) {
+	case "daily", "day":
+		return start.AddDate(0, 0, 1)
+	case "weekly", "week":
+		return start.AddDate(0, 0, 7)
+	case "quarterly", "quarter":
+		return start.AddDate(0, 3, 0)
+	case "semiannual", "semi-annual", "biannual":
+		return start.AddDate(0, 6, 0)
+	case "yearly", "annual", "annually", "year":
+		return start.AddDate(1, 0, 0)
+	default:
+		return start.AddDate(0, 1, 0)
+	}
+}
+
+// Snapshot returns the plan's current terms as a PlanVersion
+func (p *Plan) Snapshot() PlanVersion {
+	return PlanVersion{
+		PlanID:       p.ID,
+		Version:      p.Version,
+		PriceCents:   p.PriceCents,
+		BillingCycle: p.BillingCycle,
+	}
+}
+
+// planVersionBackfill records version 1 of plans created before versions
+// existed.
+const planVersionBackfill = `
+INSERT INTO plan_versions (plan_id, version, price_cents, billing_cycle, created_at)
+SELECT p.id, p.version, p.price_cents, p.billing_cycle, p.created_at FROM plans p
+WHERE NOT EXISTS (SELECT 1 FROM plan_versions v WHERE v.plan_id = p.id AND v.version = p.version)`
+
+func migratePlanVersions(db *gorm.DB) error {
+	if err := db.Exec(planVersionBackfill).Error; err != nil {
+		return fmt.Errorf("plan version migration failed: %w", err)
+	}
+	return nil
+}
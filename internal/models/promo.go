@@ -0,0 +1,100 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Promo code kinds: PromoPercent takes PercentOff percent off the price,
+// PromoFixed takes AmountOffCents off it
+const (
+	PromoPercent = "percent"
+	PromoFixed   = "fixed"
+)
+
+// Referral statuses
+const (
+	ReferralPending  = "pending"
+	ReferralRewarded = "rewarded"
+)
+
+// Reasons of member credit entries
+const (
+	CreditReferral = "referral"
+	CreditSpent    = "spent"
+	// CreditReturned gives back credit spent on a payment that failed or
+	// was voided
+	CreditReturned = "returned"
+)
+
+// DefaultReferralRewardDays is the free days a referrer gets when the gym
+// sets neither ReferralRewardCents nor ReferralRewardDays
+const DefaultReferralRewardDays = 7
+
+// Normalize upper-cases the code and checks the discount
+func (p *PromoCode) Normalize() error {
+	p.Code = strings.ToUpper(strings.TrimSpace(p.Code))
+	if p.Code == "" {
+		return fmt.Errorf("code is required")
+	}
+	switch p.Kind {
+	case PromoPercent:
+		if p.PercentOff < 1 || p.PercentOff > 100 {
+			return fmt.Errorf("percent_off must be between 1 and 100")
+		}
+		p.AmountOffCents = 0
+	case PromoFixed:
+		if p.AmountOffCents < 1 {
+			return fmt.Errorf("amount_off_cents must be positive")
+		}
+		p.PercentOff = 0
+	default:
+		return fmt.Errorf("kind must be %s or %s", PromoPercent, PromoFixed)
+	}
+	if p.MaxRedemptions != nil && *p.MaxRedemptions < 1 {
+		return fmt.Errorf("max_redemptions must be positive")
+	}
+	if p.MaxPerMember < 0 {
+		return fmt.Errorf("max_per_member cannot be negative")
+	}
+	if p.ValidFrom != nil && p.ValidUntil != nil && !p.ValidUntil.After(*p.ValidFrom) {
+		return fmt.Errorf("valid_until must be after valid_from")
+	}
+	return nil
+}
+
+// Unusable returns why the code cannot be redeemed on planID at now, or ""
+// when it can. Per member limits are checked by the caller.
+func (p *PromoCode) Unusable(planID uuid.UUID, now time.Time) string {
+	switch {
+	case p.ArchivedAt != nil:
+		return "promo code is no longer offered"
+	case p.ValidFrom != nil && now.Before(*p.ValidFrom):
+		return "promo code is not valid yet"
+	case p.ValidUntil != nil && !now.Before(*p.ValidUntil):
+		return "promo code has expired"
+	case p.MaxRedemptions != nil && p.Redemptions >= *p.MaxRedemptions:
+		return "promo code has been fully redeemed"
+	case len(p.PlanIDs) > 0 && !containsUUID(p.PlanIDs, planID):
+		return "promo code does not apply to this plan"
+	}
+	return ""
+}
+
+// Discount is how much the code takes off a price, never more than the price
+func (p *PromoCode) Discount(priceCents int) int {
+	if priceCents <= 0 {
+		return 0
+	}
+	discount := p.AmountOffCents
+	if p.Kind == PromoPercent {
+		discount = priceCents * p.PercentOff / 100
+	}
+	if discount > priceCents {
+		return priceCents
+	}
+	return discount
+}
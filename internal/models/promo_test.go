@@ -0,0 +1,102 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPromoCodeDiscount(t *testing.T) {
+	tests := []struct {
+		name  string
+		promo PromoCode
+		price int
+		want  int
+	}{
+		{"percent", PromoCode{Kind: PromoPercent, PercentOff: 25}, 4000, 1000},
+		{"percent rounds down", PromoCode{Kind: PromoPercent, PercentOff: 33}, 999, 329},
+		{"full percent", PromoCode{Kind: PromoPercent, PercentOff: 100}, 4000, 4000},
+		{"fixed", PromoCode{Kind: PromoFixed, AmountOffCents: 500}, 4000, 500},
+		{"fixed capped at the price", PromoCode{Kind: PromoFixed, AmountOffCents: 5000}, 4000, 4000},
+		{"free plan", PromoCode{Kind: PromoFixed, AmountOffCents: 500}, 0, 0},
+		{"negative price", PromoCode{Kind: PromoPercent, PercentOff: 50}, -100, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.promo.Discount(tt.price); got != tt.want {
+				t.Errorf("Discount(%d) = %d, want %d", tt.price, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPromoCodeUnusable(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	earlier, later := now.Add(-time.Hour), now.Add(time.Hour)
+	plan, other := uuid.New(), uuid.New()
+	one := 1
+
+	tests := []struct {
+		name  string
+		promo PromoCode
+		want  string
+	}{
+		{"usable", PromoCode{ValidFrom: &earlier, ValidUntil: &later, PlanIDs: []uuid.UUID{other, plan}}, ""},
+		{"archived", PromoCode{ArchivedAt: &earlier}, "promo code is no longer offered"},
+		{"not valid yet", PromoCode{ValidFrom: &later}, "promo code is not valid yet"},
+		{"valid from now", PromoCode{ValidFrom: &now}, ""},
+		{"expired", PromoCode{ValidUntil: &earlier}, "promo code has expired"},
+		{"expires now", PromoCode{ValidUntil: &now}, "promo code has expired"},
+		{"fully redeemed", PromoCode{MaxRedemptions: &one, Redemptions: 1}, "promo code has been fully redeemed"},
+		{"redemptions left", PromoCode{MaxRedemptions: &one}, ""},
+		{"other plan", PromoCode{PlanIDs: []uuid.UUID{other}}, "promo code does not apply to this plan"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.promo.Unusable(plan, now); got != tt.want {
+				t.Errorf("Unusable() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPromoCodeNormalize(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	zero := 0
+
+	tests := []struct {
+		name     string
+		promo    PromoCode
+		wantErr  bool
+		wantCode string
+	}{
+		{"percent", PromoCode{Code: " spring10 ", Kind: PromoPercent, PercentOff: 10, AmountOffCents: 99}, false, "SPRING10"},
+		{"fixed", PromoCode{Code: "tenoff", Kind: PromoFixed, AmountOffCents: 1000, PercentOff: 5}, false, "TENOFF"},
+		{"missing code", PromoCode{Code: "  ", Kind: PromoPercent, PercentOff: 10}, true, ""},
+		{"percent over 100", PromoCode{Code: "X", Kind: PromoPercent, PercentOff: 101}, true, ""},
+		{"zero percent", PromoCode{Code: "X", Kind: PromoPercent}, true, ""},
+		{"zero amount", PromoCode{Code: "X", Kind: PromoFixed}, true, ""},
+		{"unknown kind", PromoCode{Code: "X", Kind: "bogo", PercentOff: 10}, true, ""},
+		{"no redemptions allowed", PromoCode{Code: "X", Kind: PromoPercent, PercentOff: 10, MaxRedemptions: &zero}, true, ""},
+		{"negative per member", PromoCode{Code: "X", Kind: PromoPercent, PercentOff: 10, MaxPerMember: -1}, true, ""},
+		{"ends when it starts", PromoCode{Code: "X", Kind: PromoPercent, PercentOff: 10, ValidFrom: &from, ValidUntil: &from}, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.promo.Normalize()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Normalize() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if tt.promo.Code != tt.wantCode {
+				t.Errorf("Code = %q, want %q", tt.promo.Code, tt.wantCode)
+			}
+			if tt.promo.Kind == PromoPercent && tt.promo.AmountOffCents != 0 || tt.promo.Kind == PromoFixed && tt.promo.PercentOff != 0 {
+				t.Errorf("Normalize() kept the other kind's discount: %+v", tt.promo)
+			}
+		})
+	}
+}
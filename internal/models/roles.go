@@ -0,0 +1,26 @@
+package models
+
+import "strings"
+
+// Roles stored in User.UserType
+const (
+	RoleAdmin   = "Admin"
+	RoleStaff   = "Staff"
+	RoleTrainer = "Trainer"
+	RoleMember  = "Member"
+)
+
+// NormalizeRole maps a stored user_type onto one of the Role constants.
+// Older rows were saved with lower-case values such as "member".
+func NormalizeRole(userType string) string {
+	userType = strings.TrimSpace(userType)
+	if userType == "" {
+		return RoleMember
+	}
+	for _, role := range []string{RoleAdmin, RoleStaff, RoleTrainer, RoleMember} {
+		if strings.EqualFold(userType, role) {
+			return role
+		}
+	}
+	return userType
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// searchMigrations add the full-text and trigram indexes behind member search.
+// The tsvector columns are generated by Postgres, so they never go stale and
+// are not part of the Go models.
+var searchMigrations = []string{
+	`CREATE EXTENSION IF NOT EXISTS pg_trgm;`,
+
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('simple'::regconfig, coalesce(first_name, '') || ' ' || coalesce(last_name, '')), 'A') ||
+		setweight(to_tsvector('simple'::regconfig, coalesce(email, '')), 'B') ||
+		setweight(to_tsvector('simple'::regconfig, coalesce(phone_number, '')), 'C')
+	) STORED;`,
+	`CREATE INDEX IF NOT EXISTS idx_users_search_vector ON users USING GIN (search_vector);`,
+	`CREATE INDEX IF NOT EXISTS idx_users_full_name_trgm ON users USING GIN ((first_name || ' ' || last_name) gin_trgm_ops);`,
+	`CREATE INDEX IF NOT EXISTS idx_users_email_trgm ON users USING GIN (email gin_trgm_ops);`,
+	`CREATE INDEX IF NOT EXISTS idx_users_phone_digits_trgm ON users USING GIN ((regexp_replace(phone_number, '\D', '', 'g')) gin_trgm_ops);`,
+
+	`ALTER TABLE members ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('simple'::regconfig, coalesce(first_name, '') || ' ' || coalesce(last_name, '')), 'A') ||
+		setweight(to_tsvector('simple'::regconfig, coalesce(emergency_contact->>'name', '') || ' ' || coalesce(emergency_contact->>'phone', '')), 'D')
+	) STORED;`,
+	`CREATE INDEX IF NOT EXISTS idx_members_search_vector ON members USING GIN (search_vector);`,
+	`CREATE INDEX IF NOT EXISTS idx_members_full_name_trgm ON members USING GIN ((first_name || ' ' || last_name) gin_trgm_ops);`,
+}
+
+func migrateSearchIndexes(db *gorm.DB) error {
+	for _, stmt := range searchMigrations {
+		if err := db.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("search migration failed: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+package models
+
+// What took a session credit
+const (
+	UsageBooking = "booking"
+	UsageCheckIn = "checkin"
+)
+
+// DefaultLateCancelHours applies when a gym does not set late_cancel_hours.
+// Bookings cancelled later than this before the session keep their credit
+// used.
+const DefaultLateCancelHours = 12
@@ -0,0 +1,59 @@
+package oidc
+
+import (
+	"log"
+	"os"
+	"strings"
+)
+
+// ProvidersFromEnv configures the providers named in OIDC_PROVIDERS, e.g.
+// "google,acme". Each NAME is configured with:
+//
+//	OIDC_NAME_ISSUER           e.g. https://accounts.google.com
+//	OIDC_NAME_CLIENT_ID
+//	OIDC_NAME_CLIENT_SECRET    empty for public clients
+//	OIDC_NAME_DISPLAY_NAME     shown on the login page
+//	OIDC_NAME_SCOPES           space separated, default "openid email profile"
+//	OIDC_NAME_ALLOWED_DOMAINS  comma separated email domains, for company IdPs
+//	OIDC_NAME_GYM_ID           home gym of users created through this provider
+//
+// The redirect URL is OIDC_REDIRECT_BASE_URL + /auth/oidc/NAME/callback.
+func ProvidersFromEnv() map[string]*Provider {
+	providers := make(map[string]*Provider)
+	redirectBase := strings.TrimRight(os.Getenv("OIDC_REDIRECT_BASE_URL"), "/")
+	if redirectBase == "" {
+		redirectBase = "http://localhost:8888"
+	}
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		cfg := Config{
+			Name:         name,
+			DisplayName:  os.Getenv(prefix + "DISPLAY_NAME"),
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  redirectBase + "/auth/oidc/" + name + "/callback",
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+			GymID:        os.Getenv(prefix + "GYM_ID"),
+		}
+		for _, domain := range strings.Split(os.Getenv(prefix+"ALLOWED_DOMAINS"), ",") {
+			if domain = strings.TrimSpace(domain); domain != "" {
+				cfg.AllowedDomains = append(cfg.AllowedDomains, domain)
+			}
+		}
+		if cfg.DisplayName == "" {
+			cfg.DisplayName = name
+		}
+		if cfg.Issuer == "" || cfg.ClientID == "" {
+			log.Printf("WARN: OIDC provider %q is missing %sISSUER or %sCLIENT_ID, skipping it", name, prefix, prefix)
+			continue
+		}
+		providers[name] = NewProvider(cfg)
+	}
+	return providers
+}
@@ -0,0 +1,221 @@
+package query
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Pagination describes where a page sits in the full result.
+type Pagination struct {
+	Limit      int    `json:"limit"`
+	Page       int    `json:"page,omitempty"`
+	Total      int64  `json:"total"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Page is the response envelope of every list endpoint.
+type Page[T any] struct {
+	Data       []T        `json:"data"`
+	Pagination Pagination `json:"pagination"`
+}
+
+// Find runs req against db, which may already be scoped with Where, and
+// returns one page of T with the given associations preloaded. Filters are
+// applied to the total count too.
+func Find[T any](db *gorm.DB, spec Spec, req *Request, preloads ...string) (*Page[T], error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, err
+	}
+
+	sorts, columns, err := spec.sortFields(req)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := db.Model(new(T))
+	for _, cond := range req.Conditions {
+		var err error
+		if tx, err = applyCondition(tx, spec, cond); err != nil {
+			return nil, err
+		}
+	}
+
+	var total int64
+	if err := tx.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	tx = tx.Session(&gorm.Session{})
+	for _, preload := range preloads {
+		tx = tx.Preload(preload)
+	}
+	if req.Cursor != "" {
+		values, err := decodeCursor(req.Cursor, stmt.Schema, columns)
+		if err != nil {
+			return nil, err
+		}
+		clause, args := keysetCondition(sorts, columns, values)
+		tx = tx.Where(clause, args...)
+	} else if req.Page > 1 {
+		tx = tx.Offset((req.Page - 1) * req.Limit)
+	}
+	for i, column := range columns {
+		if sorts[i].Desc {
+			tx = tx.Order(column + " DESC")
+		} else {
+			tx = tx.Order(column + " ASC")
+		}
+	}
+
+	var rows []T
+	if err := tx.Limit(req.Limit + 1).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	page := &Page[T]{Data: rows, Pagination: Pagination{Limit: req.Limit, Total: total}}
+	if req.Cursor == "" {
+		page.Pagination.Page = req.Page
+	}
+	if len(rows) > req.Limit {
+		page.Data = rows[:req.Limit]
+		page.Pagination.HasMore = true
+		page.Pagination.NextCursor, err = encodeCursor(stmt.Schema, columns, page.Data[len(page.Data)-1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	if page.Data == nil {
+		page.Data = []T{}
+	}
+	return page, nil
+}
+
+func applyCondition(tx *gorm.DB, spec Spec, cond Condition) (*gorm.DB, error) {
+	param := cond.Field
+	if cond.Op != OpEq {
+		param = cond.Field + "[" + cond.Op + "]"
+	}
+	field, ok := spec.Filters[cond.Field]
+	if !ok {
+		return nil, &Error{Param: param, Message: "unknown filter"}
+	}
+
+	switch cond.Op {
+	case OpIn:
+		var values []interface{}
+		for _, raw := range strings.Split(cond.Value, ",") {
+			v, err := field.parseValue(param, strings.TrimSpace(raw))
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return tx.Where(field.Column+" IN ?", values), nil
+	case OpLike:
+		if field.Type != String {
+			return nil, &Error{Param: param, Message: "like is only supported on text fields"}
+		}
+		pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(cond.Value) + "%"
+		return tx.Where(field.Column+" ILIKE ?", pattern), nil
+	}
+
+	op, ok := sqlOperators[cond.Op]
+	if !ok {
+		return nil, &Error{Param: param, Message: "unknown operator " + cond.Op}
+	}
+	v, err := field.parseValue(param, cond.Value)
+	if err != nil {
+		return nil, err
+	}
+	return tx.Where(field.Column+" "+op+" ?", v), nil
+}
+
+// keysetCondition builds "rows after the cursor" for a mixed-direction sort:
+// (c1 > v1) OR (c1 = v1 AND c2 < v2) OR ...
+func keysetCondition(sorts []SortField, columns []string, values []interface{}) (string, []interface{}) {
+	var ors []string
+	var args []interface{}
+	for i := range columns {
+		var ands []string
+		for j := 0; j < i; j++ {
+			ands = append(ands, columns[j]+" = ?")
+			args = append(args, values[j])
+		}
+		op := ">"
+		if sorts[i].Desc {
+			op = "<"
+		}
+		ands = append(ands, columns[i]+" "+op+" ?")
+		args = append(args, values[i])
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return "(" + strings.Join(ors, " OR ") + ")", args
+}
+
+// A cursor holds the sort column values of the last row of a page. The
+// column list is included so a cursor cannot be reused with another sort.
+type cursor struct {
+	Columns []string          `json:"c"`
+	Values  []json.RawMessage `json:"v"`
+}
+
+func encodeCursor(s *schema.Schema, columns []string, row interface{}) (string, error) {
+	rv := reflect.ValueOf(row)
+	c := cursor{Columns: columns}
+	for _, column := range columns {
+		field := s.LookUpField(column)
+		if field == nil {
+			return "", fmt.Errorf("query: %s has no column %s", s.Name, column)
+		}
+		value, _ := field.ValueOf(context.Background(), rv)
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		c.Values = append(c.Values, raw)
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(raw string, s *schema.Schema, columns []string) ([]interface{}, error) {
+	invalid := &Error{Param: "cursor", Message: "invalid or does not match the sort"}
+
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, invalid
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || len(c.Columns) != len(columns) || len(c.Values) != len(columns) {
+		return nil, invalid
+	}
+
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		field := s.LookUpField(column)
+		if c.Columns[i] != column || field == nil {
+			return nil, invalid
+		}
+		// Decode into the column's own Go type so times and UUIDs are
+		// compared as such, not as strings.
+		target := reflect.New(field.FieldType)
+		if err := json.Unmarshal(c.Values[i], target.Interface()); err != nil {
+			return nil, invalid
+		}
+		values[i] = target.Elem().Interface()
+	}
+	return values, nil
+}
@@ -0,0 +1,110 @@
+package query
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Type tells how filter values are parsed.
+type Type int
+
+const (
+	String Type = iota
+	Int
+	Bool
+	Time
+	UUID
+)
+
+// Field is a filterable column.
+type Field struct {
+	Column string
+	Type   Type
+}
+
+// Spec whitelists what a list endpoint may be filtered and sorted by.
+type Spec struct {
+	// Filters maps query parameter names to columns
+	Filters map[string]Field
+	// Sorts maps sort keys to columns. Sort columns must be NOT NULL so
+	// cursors stay well defined.
+	Sorts map[string]string
+	// DefaultSort is used when the request has no ?sort=, e.g. "-created_at"
+	DefaultSort string
+	// Key is a unique column used to break ties; defaults to "id"
+	Key string
+}
+
+func (s Spec) key() string {
+	if s.Key == "" {
+		return "id"
+	}
+	return s.Key
+}
+
+// sortFields validates the requested sort and appends the tie-breaker.
+func (s Spec) sortFields(req *Request) ([]SortField, []string, error) {
+	fields := req.Sort
+	if len(fields) == 0 && s.DefaultSort != "" {
+		for _, key := range strings.Split(s.DefaultSort, ",") {
+			fields = append(fields, SortField{Key: strings.TrimPrefix(key, "-"), Desc: strings.HasPrefix(key, "-")})
+		}
+	}
+
+	columns := make([]string, 0, len(fields)+1)
+	out := make([]SortField, 0, len(fields)+1)
+	hasKey := false
+	for _, f := range fields {
+		column, ok := s.Sorts[f.Key]
+		if !ok {
+			return nil, nil, &Error{Param: "sort", Message: "cannot sort by " + f.Key}
+		}
+		if column == s.key() {
+			hasKey = true
+		}
+		out = append(out, f)
+		columns = append(columns, column)
+	}
+	if !hasKey {
+		out = append(out, SortField{Key: s.key()})
+		columns = append(columns, s.key())
+	}
+	return out, columns, nil
+}
+
+// parseValue converts a raw filter value to the column's Go type.
+func (f Field) parseValue(param, raw string) (interface{}, error) {
+	switch f.Type {
+	case Int:
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, &Error{Param: param, Message: "must be an integer"}
+		}
+		return v, nil
+	case Bool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, &Error{Param: param, Message: "must be true or false"}
+		}
+		return v, nil
+	case Time:
+		if v, err := time.Parse(time.RFC3339, raw); err == nil {
+			return v, nil
+		}
+		v, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			return nil, &Error{Param: param, Message: "must be a date (YYYY-MM-DD) or RFC3339 timestamp"}
+		}
+		return v, nil
+	case UUID:
+		v, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, &Error{Param: param, Message: "must be a UUID"}
+		}
+		return v, nil
+	}
+	return raw, nil
+}
@@ -0,0 +1,99 @@
+// Package ratelimit provides an in-process sliding-window counter.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// SlidingWindow counts events per key over the last Window and reports when
+// a key went over Limit. It is safe for concurrent use.
+type SlidingWindow struct {
+	Limit  int
+	Window time.Duration
+
+	mu     sync.Mutex
+	events map[string][]time.Time
+}
+
+func NewSlidingWindow(limit int, window time.Duration) *SlidingWindow {
+	return &SlidingWindow{Limit: limit, Window: window, events: make(map[string][]time.Time)}
+}
+
+// Allow reports whether key is still under the limit. When it is not, it
+// also returns how long until the oldest event leaves the window.
+func (w *SlidingWindow) Allow(key string, now time.Time) (bool, time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	events := w.prune(key, now)
+	if len(events) < w.Limit {
+		return true, 0
+	}
+	return false, events[0].Add(w.Window).Sub(now)
+}
+
+// Record adds one event for key and returns the number of events in the window.
+func (w *SlidingWindow) Record(key string, now time.Time) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	events := append(w.prune(key, now), now)
+	w.events[key] = events
+	return len(events)
+}
+
+// Count returns the number of events for key in the window.
+func (w *SlidingWindow) Count(key string, now time.Time) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.prune(key, now))
+}
+
+// Last returns the time of the newest event for key in the window.
+func (w *SlidingWindow) Last(key string, now time.Time) (time.Time, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	events := w.prune(key, now)
+	if len(events) == 0 {
+		return time.Time{}, false
+	}
+	return events[len(events)-1], true
+}
+
+// Reset forgets every event for key.
+func (w *SlidingWindow) Reset(key string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.events, key)
+}
+
+// Cleanup drops keys without events in the window. Call it periodically to
+// bound memory.
+func (w *SlidingWindow) Cleanup(now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for key := range w.events {
+		w.prune(key, now)
+	}
+}
+
+// prune drops events older than the window. Callers must hold mu.
+func (w *SlidingWindow) prune(key string, now time.Time) []time.Time {
+	events := w.events[key]
+	cutoff := now.Add(-w.Window)
+
+	i := 0
+	for i < len(events) && !events[i].After(cutoff) {
+		i++
+	}
+	events = events[i:]
+
+	if len(events) == 0 {
+		delete(w.events, key)
+		return nil
+	}
+	w.events[key] = events
+	return events
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"go-blog/internal/config"
+	"go-blog/internal/mailer"
+	"go-blog/internal/models"
+	"go-blog/repositories"
+	"go-blog/utils"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	passwordResetTTL = time.Hour
+	verifyEmailTTL   = 48 * time.Hour
+	inviteTTL        = 7 * 24 * time.Hour
+	minPasswordLen   = 8
+)
+
+var (
+	ErrInvalidUserToken = errors.New("invalid or expired token")
+	ErrWeakPassword     = fmt.Errorf("password must be at least %d characters", minPasswordLen)
+)
+
+// AccountService owns the emailed-link flows: password reset and email verification.
+type AccountService struct {
+	users       repositories.UserRepository
+	tokens      *repositories.UserTokenRepository
+	revocations *TokenRevocationService
+	mailer      mailer.Mailer
+	db          *gorm.DB
+}
+
+func NewAccountService(users repositories.UserRepository, tokens *repositories.UserTokenRepository, revocations *TokenRevocationService, m mailer.Mailer, db *gorm.DB) *AccountService {
+	return &AccountService{users: users, tokens: tokens, revocations: revocations, mailer: m, db: db}
+}
+
+// RequestPasswordReset mails a reset link if the email belongs to a user.
+// Unknown emails are silently ignored so the endpoint cannot be used to
+// discover accounts.
+func (s *AccountService) RequestPasswordReset(email string) error {
+	user, err := s.users.GetUserByEmail(email)
+	if err != nil {
+		return nil
+	}
+
+	token, err := s.issueToken(user.UserID, models.TokenPurposePasswordReset, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to choose a new password. It expires in one hour.\n\n%s\n\nIf you did not ask for this, you can ignore this email.\n",
+			user.FirstName, link("/reset-password", token)),
+	})
+}
+
+// ResetPassword consumes a reset token, sets the new password and signs the
+// user out everywhere.
+func (s *AccountService) ResetPassword(token, newPassword string) error {
+	if len(newPassword) < minPasswordLen {
+		return ErrWeakPassword
+	}
+	hashed, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to process password")
+	}
+
+	var userID uuid.UUID
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		record, err := s.consumeToken(tx, models.TokenPurposePasswordReset, token)
+		if err != nil {
+			return err
+		}
+		userID = record.UserID
+
+		// Receiving the reset link proves the user owns the mailbox
+		return tx.Model(&models.User{}).Where("user_id = ?", record.UserID).Updates(map[string]interface{}{
+			"password_hash":     hashed,
+			"email_verified":    true,
+			"email_verified_at": gorm.Expr("COALESCE(email_verified_at, ?)", time.Now()),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.revocations.LogoutAll(userID, userID, "password_reset")
+}
+
+// SendVerificationEmail mails a fresh verification link to user.
+func (s *AccountService) SendVerificationEmail(user *models.User) error {
+	token, err := s.issueToken(user.UserID, models.TokenPurposeVerifyEmail, verifyEmailTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below.\n\n%s\n",
+			user.FirstName, link("/verify-email", token)),
+	})
+}
+
+// SendInvite mails a user whose account someone else created a link to
+// choose their password. It is a password reset link that lasts a week.
+func (s *AccountService) SendInvite(user *models.User, gymName string) error {
+	token, err := s.issueToken(user.UserID, models.TokenPurposePasswordReset, inviteTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your gym account is ready",
+		Body: fmt.Sprintf("Hi %s,\n\n%s has moved your membership to our app. Open the link below within a week to choose a password and sign in.\n\n%s\n",
+			user.FirstName, gymName, link("/reset-password", token)),
+	})
+}
+
+// ResendVerification re-sends the verification link for an unverified account.
+// Like RequestPasswordReset it does not reveal whether the email exists.
+func (s *AccountService) ResendVerification(email string) error {
+	user, err := s.users.GetUserByEmail(email)
+	if err != nil || user.EmailVerified {
+		return nil
+	}
+	return s.SendVerificationEmail(user)
+}
+
+// VerifyEmail consumes a verification token and marks the email verified.
+func (s *AccountService) VerifyEmail(token string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		record, err := s.consumeToken(tx, models.TokenPurposeVerifyEmail, token)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("user_id = ?", record.UserID).Updates(map[string]interface{}{
+			"email_verified":    true,
+			"email_verified_at": time.Now(),
+		}).Error
+	})
+}
+
+func (s *AccountService) issueToken(userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		tokens := s.tokens.WithTx(tx)
+		if err := tokens.InvalidateForUser(userID, purpose, now); err != nil {
+			return err
+		}
+		return tokens.Create(&models.UserToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: utils.HashToken(token),
+			ExpiresAt: now.Add(ttl),
+		})
+	})
+	if err != nil {
+		log.Printf("ERROR: AccountService failed to issue %s token for user %s: %v", purpose, userID, err)
+		return "", fmt.Errorf("failed to issue token")
+	}
+	return token, nil
+}
+
+func (s *AccountService) consumeToken(tx *gorm.DB, purpose, token string) (*models.UserToken, error) {
+	tokens := s.tokens.WithTx(tx)
+	now := time.Now()
+
+	record, err := tokens.GetUsableForUpdate(purpose, utils.HashToken(token), now)
+	if err != nil {
+		return nil, ErrInvalidUserToken
+	}
+	if err := tokens.MarkUsed(record.ID, now); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func link(path, token string) string {
+	return config.AppBaseURL() + path + "?token=" + url.QueryEscape(token)
+}
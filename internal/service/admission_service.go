@@ -0,0 +1,194 @@
+package services
+
+import (
+	"errors"
+	"go-blog/internal/models"
+	"go-blog/repositories"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNoActiveMembership = errors.New("no active membership covers this date")
+	ErrQuotaExhausted     = errors.New("no sessions left on the membership for this period")
+)
+
+// SessionBalance is what is left of the session quota of a membership period
+type SessionBalance struct {
+	MembershipID uuid.UUID `json:"membership_id"`
+	PlanTitle    string    `json:"plan_title"`
+	PeriodStart  time.Time `json:"period_start"`
+	PeriodEnd    time.Time `json:"period_end"`
+	Unlimited    bool      `json:"unlimited"`
+	Total        *int      `json:"total"`
+	Used         int       `json:"used"`
+	Remaining    *int      `json:"remaining"`
+}
+
+// AdmissionService decides whether a member may book or enter a session.
+// The plan's access policy has to allow it, and each booking or check-in
+// takes a credit from Plan.NumSessions for the membership period. Plans
+// without a number of sessions are unlimited.
+type AdmissionService struct {
+	repo *repositories.SessionUsageRepository
+}
+
+func NewAdmissionService(repo *repositories.SessionUsageRepository) *AdmissionService {
+	return &AdmissionService{repo: repo}
+}
+
+// Remaining returns the balance of the membership covering at
+func (s *AdmissionService) Remaining(memberID uuid.UUID, at time.Time) (*SessionBalance, error) {
+	m, err := s.repo.CoveringMembership(memberID, at, models.MembershipActive, models.MembershipPastDue)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoActiveMembership
+	}
+	if err != nil {
+		return nil, err
+	}
+	used, err := s.repo.CountUsed(m.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	balance := &SessionBalance{
+		MembershipID: m.ID,
+		PlanTitle:    m.Plan.Title,
+		PeriodStart:  m.StartDate,
+		PeriodEnd:    m.EndDate,
+		Unlimited:    m.Plan.NumSessions == nil,
+		Total:        m.Plan.NumSessions,
+		Used:         int(used),
+	}
+	if m.Plan.NumSessions != nil {
+		remaining := *m.Plan.NumSessions - int(used)
+		if remaining < 0 {
+			remaining = 0
+		}
+		balance.Remaining = &remaining
+	}
+	return balance, nil
+}
+
+// admission is one attempt to use a session, by booking it or checking in
+type admission struct {
+	memberID  uuid.UUID
+	sessionID uuid.UUID
+	at        time.Time
+	source    string
+	bookingID *uuid.UUID
+	guests    int
+	statuses  []string // membership statuses that may cover the session
+}
+
+// admit checks a session against the access policy of the member's plan
+// and takes a credit from the membership covering it. A member who already
+// holds a credit for the session, such as a booking being checked in, is
+// not charged again.
+func (s *AdmissionService) admit(tx *gorm.DB, a admission) error {
+	repo := s.repo.WithTx(tx)
+	m, err := repo.CoveringMembership(a.memberID, a.at, a.statuses...)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNoActiveMembership
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.checkAccess(repo, m, a); err != nil {
+		return err
+	}
+
+	if _, err := repo.ActiveForSession(a.memberID, a.sessionID); err == nil {
+		return nil
+	}
+	if m.Plan.NumSessions != nil {
+		if err := repo.LockMembership(m.ID); err != nil {
+			return err
+		}
+		used, err := repo.CountUsed(m.ID)
+		if err != nil {
+			return err
+		}
+		if used >= int64(*m.Plan.NumSessions) {
+			return ErrQuotaExhausted
+		}
+	}
+
+	return repo.Create(&models.SessionUsage{
+		MembershipID: m.ID,
+		MemberID:     a.memberID,
+		SessionID:    a.sessionID,
+		Source:       a.source,
+		BookingID:    a.bookingID,
+	})
+}
+
+// checkAccess evaluates the plan's access policy in the gym's local time.
+// Refusals are *models.AccessDenial.
+func (s *AdmissionService) checkAccess(repo *repositories.SessionUsageRepository, m *models.Membership, a admission) error {
+	class, err := repo.SessionClass(a.sessionID)
+	if err != nil {
+		return ErrSessionNotFound
+	}
+	req := models.AccessRequest{
+		GymID:    class.GymID,
+		Category: class.Category,
+		At:       a.at.In(class.Gym.Location()),
+		Guests:   a.guests,
+	}
+	if a.guests > 0 {
+		if req.GuestsUsed, err = repo.GuestsUsed(a.memberID, m.StartDate, m.EndDate); err != nil {
+			return err
+		}
+	}
+	if denial := m.Plan.Access.Check(m.Plan.Title, req); denial != nil {
+		return denial
+	}
+	return nil
+}
+
+// admitGuests checks that the plan of the member's active membership lets
+// them bring more guests at at, for a guest pass. The membership is locked
+// so two passes cannot both take the last place.
+func (s *AdmissionService) admitGuests(tx *gorm.DB, memberID uuid.UUID, at time.Time, guests int) error {
+	repo := s.repo.WithTx(tx)
+	m, err := repo.CoveringMembership(memberID, at, models.MembershipActive)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNoActiveMembership
+	}
+	if err != nil {
+		return err
+	}
+	if err := repo.LockMembership(m.ID); err != nil {
+		return err
+	}
+	used, err := repo.GuestsUsed(memberID, m.StartDate, m.EndDate)
+	if err != nil {
+		return err
+	}
+	if denial := m.Plan.Access.CheckGuests(m.Plan.Title, guests, used); denial != nil {
+		return denial
+	}
+	return nil
+}
+
+// refund gives back the credit of a cancelled booking when it was cancelled
+// before the gym's late cancellation window.
+func (s *AdmissionService) refund(tx *gorm.DB, booking *models.Booking, now time.Time) error {
+	if booking.Session.StartsAt.Sub(now) < s.lateCancelWindow(booking.SessionID) {
+		return nil
+	}
+	return s.repo.WithTx(tx).RefundBooking(booking.ID, now)
+}
+
+func (s *AdmissionService) lateCancelWindow(sessionID uuid.UUID) time.Duration {
+	class, err := s.repo.SessionClass(sessionID)
+	if err != nil {
+		log.Printf("WARN: AdmissionService could not load the gym of session %s: %v", sessionID, err)
+		return models.GymSettings{}.LateCancelWindow()
+	}
+	return class.Gym.ParsedSettings().LateCancelWindow()
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-blog/internal/models"
+	"go-blog/internal/query"
+	"go-blog/repositories"
+	"go-blog/utils"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// apiKeyPrefix marks our keys so they are easy to spot in leaked configs
+const apiKeyPrefix = "gk_"
+
+var (
+	ErrAPIKeyNotFound   = errors.New("api key not found")
+	ErrInvalidAPIKey    = errors.New("invalid, expired or revoked API key")
+	ErrAPIKeyRevoked    = errors.New("api key is revoked")
+	ErrInvalidAPIKeyArg = errors.New("invalid api key")
+	// ErrOutsideGymScope is returned when an API key touches another gym's records
+	ErrOutsideGymScope = errors.New("the record belongs to a gym outside the API key's scope")
+)
+
+// APIKeyInput describes a key to create. On update, nil fields are left as they are.
+type APIKeyInput struct {
+	Name      *string    `json:"name"`
+	GymID     *uuid.UUID `json:"gym_id"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// APIKeyService manages API keys for kiosks, door controllers and other
+// integrations that cannot hold a user's JWT. Keys are scoped to one gym and
+// a set of permission scopes, and every use is written to the audit log.
+type APIKeyService struct {
+	repo  *repositories.APIKeyRepository
+	gyms  *repositories.GymRepository
+	audit *repositories.AuditLogRepository
+	db    *gorm.DB
+}
+
+func NewAPIKeyService(repo *repositories.APIKeyRepository, gyms *repositories.GymRepository, audit *repositories.AuditLogRepository, db *gorm.DB) *APIKeyService {
+	return &APIKeyService{repo: repo, gyms: gyms, audit: audit, db: db}
+}
+
+// Create stores a new key and returns it with its plaintext, which is never
+// shown again.
+func (s *APIKeyService) Create(actorID uuid.UUID, input APIKeyInput) (*models.APIKey, string, error) {
+	if input.Name == nil || strings.TrimSpace(*input.Name) == "" {
+		return nil, "", fmt.Errorf("%w: name is required", ErrInvalidAPIKeyArg)
+	}
+	if input.GymID == nil {
+		return nil, "", fmt.Errorf("%w: gym_id is required", ErrInvalidAPIKeyArg)
+	}
+	if _, err := s.gyms.GetByID(input.GymID.String()); err != nil {
+		return nil, "", fmt.Errorf("%w: gym not found", ErrInvalidAPIKeyArg)
+	}
+	scopes, err := encodeScopes(input.Scopes)
+	if err != nil {
+		return nil, "", err
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return nil, "", fmt.Errorf("%w: expires_at must be in the future", ErrInvalidAPIKeyArg)
+	}
+
+	secret := make([]byte, 30)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	plaintext := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	key := &models.APIKey{
+		Name:        strings.TrimSpace(*input.Name),
+		Prefix:      plaintext[:12],
+		KeyHash:     utils.HashToken(plaintext),
+		GymID:       *input.GymID,
+		Scopes:      scopes,
+		ExpiresAt:   input.ExpiresAt,
+		CreatedByID: actorID,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.repo.WithTx(tx).Create(key); err != nil {
+			return err
+		}
+		return tx.Create(models.NewAuditLog(actorID, models.AuditAPIKeyCreated, "api_key", key.ID, map[string]interface{}{
+			"name":   key.Name,
+			"gym_id": key.GymID,
+			"scopes": input.Scopes,
+		})).Error
+	})
+	if err != nil {
+		log.Printf("ERROR: APIKeyService.Create failed: %v", err)
+		return nil, "", fmt.Errorf("failed to create api key")
+	}
+	return key, plaintext, nil
+}
+
+func (s *APIKeyService) List(req *query.Request) (*query.Page[models.APIKey], error) {
+	return s.repo.List(req)
+}
+
+func (s *APIKeyService) Get(id uuid.UUID) (*models.APIKey, error) {
+	key, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+// Update renames a key or changes its scopes or expiry. The gym of a key
+// cannot change; create a new key instead.
+func (s *APIKeyService) Update(actorID, id uuid.UUID, input APIKeyInput) (*models.APIKey, error) {
+	key, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt != nil {
+		return nil, ErrAPIKeyRevoked
+	}
+	if input.GymID != nil && *input.GymID != key.GymID {
+		return nil, fmt.Errorf("%w: the gym of a key cannot be changed", ErrInvalidAPIKeyArg)
+	}
+
+	fields := map[string]interface{}{}
+	if input.Name != nil {
+		if strings.TrimSpace(*input.Name) == "" {
+			return nil, fmt.Errorf("%w: name cannot be empty", ErrInvalidAPIKeyArg)
+		}
+		fields["name"] = strings.TrimSpace(*input.Name)
+	}
+	if input.Scopes != nil {
+		scopes, err := encodeScopes(input.Scopes)
+		if err != nil {
+			return nil, err
+		}
+		fields["scopes"] = scopes
+	}
+	if input.ExpiresAt != nil {
+		if !input.ExpiresAt.After(time.Now()) {
+			return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidAPIKeyArg)
+		}
+		fields["expires_at"] = *input.ExpiresAt
+	}
+	if len(fields) == 0 {
+		return key, nil
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.repo.WithTx(tx).UpdateFields(id, fields); err != nil {
+			return err
+		}
+		return tx.Create(models.NewAuditLog(actorID, models.AuditAPIKeyUpdated, "api_key", id, fields)).Error
+	})
+	if err != nil {
+		log.Printf("ERROR: APIKeyService.Update failed for key %s: %v", id, err)
+		return nil, fmt.Errorf("failed to update api key")
+	}
+	return s.Get(id)
+}
+
+// Revoke disables a key for good
+func (s *APIKeyService) Revoke(actorID, id uuid.UUID) error {
+	key, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.repo.WithTx(tx).UpdateFields(id, map[string]interface{}{"revoked_at": time.Now()}); err != nil {
+			return err
+		}
+		return tx.Create(models.NewAuditLog(actorID, models.AuditAPIKeyRevoked, "api_key", id, nil)).Error
+	})
+}
+
+// Usage returns the audit trail of a key, including every request made with it
+func (s *APIKeyService) Usage(id uuid.UUID) ([]models.AuditLog, error) {
+	if _, err := s.Get(id); err != nil {
+		return nil, err
+	}
+	return s.audit.GetByTarget("api_key", id)
+}
+
+// Authenticate resolves a key sent in X-API-Key
+func (s *APIKeyService) Authenticate(rawKey string) (*models.APIKey, error) {
+	if !strings.HasPrefix(rawKey, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+	key, err := s.repo.GetByHash(utils.HashToken(rawKey))
+	if err != nil || !key.Usable(time.Now()) {
+		return nil, ErrInvalidAPIKey
+	}
+	return key, nil
+}
+
+// RecordUse attributes one request to the key. The audit entry names the
+// admin who created the key as actor.
+func (s *APIKeyService) RecordUse(key *models.APIKey, method, path string, status int, ip string) {
+	now := time.Now()
+	if err := s.repo.TouchUsage(key.ID, ip, now); err != nil {
+		log.Printf("ERROR: APIKeyService failed to record use of key %s: %v", key.ID, err)
+	}
+
+	entry := models.NewAuditLog(key.CreatedByID, models.AuditAPIKeyUsed, "api_key", key.ID, map[string]interface{}{
+		"method":     method,
+		"path":       path,
+		"status":     status,
+		"ip_address": ip,
+		"gym_id":     key.GymID,
+	})
+	if err := s.audit.Create(entry); err != nil {
+		log.Printf("ERROR: APIKeyService failed to audit use of key %s: %v", key.ID, err)
+	}
+}
+
+func encodeScopes(scopes []string) (datatypes.JSON, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidAPIKeyArg)
+	}
+	seen := make(map[string]bool, len(scopes))
+	unique := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !models.IsValidScope(scope) {
+			return nil, fmt.Errorf("%w: unknown scope %q, expected one of %s", ErrInvalidAPIKeyArg, scope, strings.Join(models.APIKeyScopes, ", "))
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			unique = append(unique, scope)
+		}
+	}
+	encoded, err := json.Marshal(unique)
+	if err != nil {
+		return nil, err
+	}
+	return datatypes.JSON(encoded), nil
+}
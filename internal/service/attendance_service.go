@@ -6,20 +6,37 @@ import (
 	"time"
 
 	"go-blog/internal/models"
+	"go-blog/internal/query"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type AttendanceService struct {
-	repo *repositories.AttendanceRepository
+	repo       *repositories.AttendanceRepository
+	admissions *AdmissionService
+	db         *gorm.DB
+	gymID      *uuid.UUID // set by ForGym
 }
 
-func NewAttendanceService(repo *repositories.AttendanceRepository) *AttendanceService {
-	return &AttendanceService{repo: repo}
+func NewAttendanceService(repo *repositories.AttendanceRepository, admissions *AdmissionService, db *gorm.DB) *AttendanceService {
+	return &AttendanceService{repo: repo, admissions: admissions, db: db}
+}
+
+// ForGym returns a copy of the service limited to sessions of one gym, for
+// API key callers such as check-in kiosks.
+func (s *AttendanceService) ForGym(gymID uuid.UUID) *AttendanceService {
+	return &AttendanceService{repo: s.repo, admissions: s.admissions, db: s.db, gymID: &gymID}
 }
 
 // ✅ Check-in logic
-func (s *AttendanceService) CheckIn(memberID, sessionID uuid.UUID, method string) error {
+func (s *AttendanceService) CheckIn(memberID, sessionID uuid.UUID, method string, guests int) error {
+	if s.gymID != nil {
+		if gymID, err := s.repo.SessionGymID(sessionID); err != nil || !s.covers(&gymID) {
+			return ErrOutsideGymScope
+		}
+	}
+
 	// prevent double check-in
 	existing, _ := s.repo.FindByMemberAndSession(memberID, sessionID)
 	if existing != nil {
@@ -32,17 +49,60 @@ func (s *AttendanceService) CheckIn(memberID, sessionID uuid.UUID, method string
 		SessionID:     sessionID,
 		CheckinMethod: method,
 		CheckedInAt:   time.Now(),
+		Guests:        guests,
+	}
+
+	// A booked session was paid for when it was booked
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		err := s.admissions.admit(tx, admission{
+			memberID:  memberID,
+			sessionID: sessionID,
+			at:        record.CheckedInAt,
+			source:    models.UsageCheckIn,
+			guests:    guests,
+			// Members whose renewal is unpaid get in during its grace period
+			statuses: []string{models.MembershipActive, models.MembershipPastDue},
+		})
+		if err != nil {
+			return err
+		}
+		return s.repo.WithTx(tx).Create(record)
+	})
+}
+
+// RemainingSessions returns what is left of the member's session quota for
+// the current membership period. A service limited to a gym only answers
+// for members whose home gym it is.
+func (s *AttendanceService) RemainingSessions(memberID uuid.UUID) (*SessionBalance, error) {
+	if s.gymID != nil {
+		if gymID, err := s.repo.MemberGymID(memberID); err != nil || !s.covers(gymID) {
+			return nil, ErrOutsideGymScope
+		}
 	}
+	return s.admissions.Remaining(memberID, time.Now())
+}
 
-	return s.repo.Create(record)
+// covers reports whether records of gymID are within the gym the service is
+// limited to. Records of no gym are only covered by an unlimited service.
+func (s *AttendanceService) covers(gymID *uuid.UUID) bool {
+	if s.gymID == nil {
+		return true
+	}
+	return gymID != nil && *gymID == *s.gymID
 }
 
 // ✅ Get attendance by member
-func (s *AttendanceService) GetMemberAttendance(memberID uuid.UUID) ([]models.Attendance, error) {
-	return s.repo.FindAllByMember(memberID)
+func (s *AttendanceService) GetMemberAttendance(memberID uuid.UUID, req *query.Request) (*query.Page[models.Attendance], error) {
+	if s.gymID != nil {
+		return s.repo.InGym(*s.gymID).ListByMember(memberID, req)
+	}
+	return s.repo.ListByMember(memberID, req)
 }
 
 // ✅ Get all attendance (for admin)
-func (s *AttendanceService) GetAllAttendance() ([]models.Attendance, error) {
-	return s.repo.FindAll()
+func (s *AttendanceService) GetAllAttendance(req *query.Request) (*query.Page[models.Attendance], error) {
+	if s.gymID != nil {
+		return s.repo.InGym(*s.gymID).List(req)
+	}
+	return s.repo.List(req)
 }
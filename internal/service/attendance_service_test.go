@@ -0,0 +1,33 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestAttendanceServiceCovers(t *testing.T) {
+	gym, other := uuid.New(), uuid.New()
+	unscoped := &AttendanceService{}
+	scoped := unscoped.ForGym(gym)
+
+	tests := []struct {
+		name    string
+		service *AttendanceService
+		gymID   *uuid.UUID
+		want    bool
+	}{
+		{"unscoped covers any gym", unscoped, &other, true},
+		{"unscoped covers no gym", unscoped, nil, true},
+		{"scoped covers its gym", scoped, &gym, true},
+		{"scoped refuses another gym", scoped, &other, false},
+		{"scoped refuses members without a home gym", scoped, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.service.covers(tt.gymID); got != tt.want {
+				t.Errorf("covers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,10 +5,11 @@ import (
 	"fmt"
 	"go-blog/internal/config"
 	"go-blog/internal/models"
+	"go-blog/internal/query"
 	"go-blog/repositories"
 	"go-blog/utils"
 	"log"
-
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,22 +17,67 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	accessTokenTTL  = time.Minute * 15
+	refreshTokenTTL = time.Hour * 24 * 7
+)
+
+var (
+	ErrInvalidCredentials  = errors.New("invalid credentials")
+	ErrEmailNotVerified    = errors.New("please verify your email address before logging in")
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used; the session has been revoked")
+)
+
+// ClientInfo identifies the device a refresh token is issued to
+type ClientInfo struct {
+	DeviceID  string
+	UserAgent string
+	IPAddress string
+}
+
 type AuthService struct {
-	repo repositories.UserRepository
-	db   *gorm.DB
+	repo          repositories.UserRepository
+	refreshTokens *repositories.RefreshTokenRepository
+	gyms          *repositories.GymRepository
+	households    *repositories.HouseholdRepository
+	throttle      *LoginThrottle
+	db            *gorm.DB
 }
 
-func NewAuthService(repo repositories.UserRepository, db *gorm.DB) *AuthService {
-	return &AuthService{repo: repo, db: db}
+func NewAuthService(repo repositories.UserRepository, refreshTokens *repositories.RefreshTokenRepository, gyms *repositories.GymRepository, households *repositories.HouseholdRepository, throttle *LoginThrottle, db *gorm.DB) *AuthService {
+	return &AuthService{repo: repo, refreshTokens: refreshTokens, gyms: gyms, households: households, throttle: throttle, db: db}
 }
 
+// Signup holds the optional parts of a member's registration
+type Signup struct {
+	// TrialProductID joins the member on a trial pass instead of a plan
+	TrialProductID *uuid.UUID
+	// PromoCode discounts the plan; the first period's payment is raised
+	// at registration with the discount on it
+	PromoCode string
+	// ReferralCode is the code of the member who referred them
+	ReferralCode string
+}
+
+// Register creates a user, and for members their profile and membership,
+// or a trial pass when signup names a trial product instead of a plan.
+// Members younger than their gym's guardian consent age need consent; the
+// guardian is linked to them and they join the guardian's household.
 func (s *AuthService) Register(
 	user *models.User,
 	password string,
 	planID string,
+	signup Signup,
 	startDate, endDate time.Time,
 	autoRenew bool,
+	consent *GuardianConsent,
 ) error {
+	guardian, err := s.guardianFor(s.db, user, consent)
+	if err != nil {
+		return err
+	}
+
 	// 1. Hash password
 	hashed, err := utils.HashPassword(password)
 	if err != nil {
@@ -41,109 +87,378 @@ func (s *AuthService) Register(
 	user.PasswordHash = hashed
 
 	return s.db.Transaction(func(tx *gorm.DB) error {
-		// 2. Create User
-		if err := tx.Create(user).Error; err != nil {
-			if errors.Is(err, gorm.ErrDuplicatedKey) {
-				log.Printf("WARN: Registration failed, user email %s already exists.", user.Email)
-				return fmt.Errorf("user with this email already exists")
-			}
-			log.Printf("ERROR: AuthService.Register failed to create user %s: %v", user.Email, err)
-			return fmt.Errorf("failed to register user due to database error")
+		return s.register(tx, user, planID, signup, startDate, endDate, autoRenew, consent, guardian)
+	})
+}
+
+// guardianFor checks that a minor comes with consent, and loads and checks
+// the consenting guardian. It returns nil when there is no consent.
+func (s *AuthService) guardianFor(db *gorm.DB, user *models.User, consent *GuardianConsent) (*models.User, error) {
+	if consent == nil {
+		if user.IsMinor(s.gymSettings(user).ConsentAge(), time.Now()) {
+			return nil, ErrGuardianConsentRequired
+		}
+		return nil, nil
+	}
+	guardian, err := repositories.NewUserRepository(db).GetUserByID(consent.GuardianID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: guardian not found", ErrInvalidGuardian)
+	}
+	if err := s.checkGuardian(guardian); err != nil {
+		return nil, err
+	}
+	return guardian, nil
+}
+
+// register stores a user whose password is already hashed, with their
+// member profile, membership and guardian link, inside tx. Register and the
+// member import both go through it.
+func (s *AuthService) register(
+	tx *gorm.DB,
+	user *models.User,
+	planID string,
+	signup Signup,
+	startDate, endDate time.Time,
+	autoRenew bool,
+	consent *GuardianConsent,
+	guardian *models.User,
+) error {
+	// 2. Create User
+	if err := tx.Create(user).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			log.Printf("WARN: Registration failed, user email %s already exists.", user.Email)
+			return fmt.Errorf("user with this email already exists")
 		}
+		log.Printf("ERROR: AuthService.Register failed to create user %s: %v", user.Email, err)
+		return fmt.Errorf("failed to register user due to database error")
+	}
 
-		// 3. Only create Member and Membership if user.UserType == "member"
-		if user.UserType == "member" || user.UserType == "Member" {
-			// Validate planID for members
-			if planID == "" {
-				return fmt.Errorf("plan_id is required for members")
+	// 3. Only create Member and Membership for members
+	if models.NormalizeRole(user.UserType) == models.RoleMember {
+		// Validate planID for members; a trial pass stands in for a plan
+		// while they try the gym
+		if planID == "" && signup.TrialProductID == nil {
+			return fmt.Errorf("plan_id or trial_product_id is required for members")
+		}
+		if planID != "" && signup.TrialProductID != nil {
+			return fmt.Errorf("give either plan_id or trial_product_id, not both")
+		}
+		if signup.PromoCode != "" && signup.TrialProductID != nil {
+			return fmt.Errorf("%w: promo codes apply to plans, not trial passes", ErrPromoNotUsable)
+		}
+
+		var trial *models.PassProduct
+		if signup.TrialProductID != nil {
+			product, err := passProduct(tx, *signup.TrialProductID)
+			if err != nil {
+				return err
 			}
+			if product.Kind != models.PassTrial {
+				return fmt.Errorf("%w: %s is not a trial", ErrInvalidPass, product.Name)
+			}
+			trial = product
+		}
 
+		var planUUID uuid.UUID
+		var plan models.Plan
+		if planID != "" {
 			// Convert planID string to uuid.UUID
-			planUUID, err := uuid.Parse(planID)
+			var err error
+			planUUID, err = uuid.Parse(planID)
 			if err != nil {
 				return fmt.Errorf("invalid plan_id: %v", err)
 			}
 
-			// Optional: Check if plan exists in database (uncomment if you have plan validation)
-			/*
-			var plan models.Plan
+			// Check the plan exists and is on sale; the membership records the
+			// plan version it is sold at
 			if err := tx.First(&plan, "id = ?", planUUID).Error; err != nil {
 				if errors.Is(err, gorm.ErrRecordNotFound) {
 					return fmt.Errorf("plan with id %s does not exist", planID)
 				}
 				return fmt.Errorf("failed to verify plan: %v", err)
 			}
-			*/
-
-			// Create Member
-			member := models.Member{
-				FirstName: user.FirstName,
-				LastName:  user.LastName,
-				Gender:    user.Gender,
-				UserID:    user.UserID,
-				Dob:       user.DateOfBirth,
-				EmergencyContact: datatypes.JSON([]byte(
-					fmt.Sprintf(`{"name":"%s","phone":"%s"}`, user.EmergencyContactName, user.EmergencyContactPhone),
-				)),
+			if plan.ArchivedAt != nil {
+				return fmt.Errorf("plan %s is archived and no longer on sale", plan.Title)
 			}
+		}
+
+		// Create Member
+		member := models.Member{
+			FirstName: user.FirstName,
+			LastName:  user.LastName,
+			Gender:    user.Gender,
+			UserID:    user.UserID,
+			Dob:       user.DateOfBirth,
+			EmergencyContact: datatypes.JSON([]byte(
+				fmt.Sprintf(`{"name":"%s","phone":"%s"}`, user.EmergencyContactName, user.EmergencyContactPhone),
+			)),
+		}
+
+		if err := tx.Create(&member).Error; err != nil {
+			log.Printf("ERROR: AuthService.Register failed to create member for user ID %s: %v", user.UserID, err)
+			return fmt.Errorf("failed to finalize member profile; registration rolled back")
+		}
 
-			if err := tx.Create(&member).Error; err != nil {
-				log.Printf("ERROR: AuthService.Register failed to create member for user ID %s: %v", user.UserID, err)
-				return fmt.Errorf("failed to finalize member profile; registration rolled back")
+		if signup.ReferralCode != "" {
+			if err := referMember(tx, signup.ReferralCode, member.ID); err != nil {
+				return err
+			}
+		}
+
+		if trial != nil {
+			// Trial members hold a pass until they convert it to a membership
+			pass := &models.Pass{MemberID: &member.ID, ValidFrom: startDate}
+			if err := issuePass(tx, user.UserID, trial, pass, &member.ID); err != nil {
+				log.Printf("ERROR: AuthService.Register failed to issue a trial pass for member ID %s: %v", member.ID, err)
+				return fmt.Errorf("failed to issue trial pass; registration rolled back")
+			}
+		} else {
+			var promo *models.PromoCode
+			if signup.PromoCode != "" {
+				var err error
+				if promo, err = claimPromo(tx, signup.PromoCode, member.ID, planUUID, time.Now()); err != nil {
+					return err
+				}
 			}
 
 			// Create Membership
 			membership := models.Membership{
-				MemberID:  member.ID, // <-- use Member.ID
-				PlanID:    planUUID,
-				StartDate: startDate,
-				EndDate:   endDate,
-				Status:    "active",
-				AutoRenew: autoRenew,
+				MemberID:    member.ID, // <-- use Member.ID
+				PlanID:      planUUID,
+				PlanVersion: plan.Version,
+				StartDate:   startDate,
+				EndDate:     endDate,
+				Status:      models.InitialMembershipStatus(startDate, time.Now()),
+				AutoRenew:   autoRenew,
+			}
+			if promo != nil {
+				membership.PromoCodeID = &promo.ID
 			}
 
 			if err := tx.Create(&membership).Error; err != nil {
 				log.Printf("ERROR: AuthService.Register failed to create membership for member ID %s: %v", member.ID, err)
 				return fmt.Errorf("failed to create membership; registration rolled back")
 			}
+
+			if promo != nil {
+				if err := raiseSignupPayment(tx, &membership, promo, plan.PriceCents); err != nil {
+					log.Printf("ERROR: AuthService.Register failed to raise the first payment for member ID %s: %v", member.ID, err)
+					return fmt.Errorf("failed to record the discounted payment; registration rolled back")
+				}
+			}
 		}
+	}
 
-		return nil
-	})
+	if guardian != nil {
+		households := s.households.WithTx(tx)
+		if _, err := linkGuardian(tx, households, *consent, user.UserID, "registration"); err != nil {
+			log.Printf("ERROR: AuthService.Register failed to link guardian for user ID %s: %v", user.UserID, err)
+			return fmt.Errorf("failed to record guardian consent; registration rolled back")
+		}
+		if err := joinGuardianHousehold(tx, households, guardian, user.UserID); err != nil {
+			log.Printf("ERROR: AuthService.Register failed to add user ID %s to a household: %v", user.UserID, err)
+			return fmt.Errorf("failed to join the guardian's household; registration rolled back")
+		}
+	}
+
+	return nil
 }
 
 // In your services/auth_service.go
 
-func (s *AuthService) GetAllUsers() ([]*models.User, error) {
-	return s.repo.GetAllUsers()
+func (s *AuthService) GetAllUsers(req *query.Request) (*query.Page[models.User], error) {
+	return s.repo.List(req)
+}
+
+// GetUsersByType lists users of one role. Older rows store the role in
+// lower case, so both spellings are matched.
+func (s *AuthService) GetUsersByType(userType string, req *query.Request) (*query.Page[models.User], error) {
+	req.Where("user_type", query.OpIn, userType+","+strings.ToLower(userType))
+	return s.repo.List(req)
 }
 
-// GetUsersByType retrieves users by their user type
-func (s *AuthService) GetUsersByType(userType string) ([]*models.User, error) {
-	return s.repo.GetUsersByUserType(userType)
+// GetUserByID loads a single user
+func (s *AuthService) GetUserByID(id uuid.UUID) (*models.User, error) {
+	return s.repo.GetUserByID(id)
 }
 
 
-func (s *AuthService) Login(email, password string) (*models.User, bool) {
+// Login checks the credentials of a user. ip is the client address, used to
+// throttle repeated failures; throttled attempts return a *LoginThrottledError
+// without spending a bcrypt comparison.
+func (s *AuthService) Login(email, password, ip string) (*models.User, error) {
+	now := time.Now()
+	if err := s.throttle.Check(email, ip, now); err != nil {
+		return nil, err
+	}
+
 	user, err := s.repo.GetUserByEmail(email)
 	if err != nil {
-		return nil, false
+		s.throttle.Failure(nil, email, ip, now)
+		return nil, ErrInvalidCredentials
+	}
+	if err := s.throttle.CheckLocked(user, now); err != nil {
+		return nil, err
 	}
 	if !utils.CheckPasswordHash(password, user.PasswordHash) {
-		return nil, false
+		if err := s.throttle.Failure(user, email, ip, now); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
+	s.throttle.Success(user, email)
+
+	if !user.EmailVerified && s.gymSettings(user).RequireEmailVerification {
+		return nil, ErrEmailNotVerified
 	}
-	return user, true
+	return user, nil
 }
-func (s *AuthService) GenerateTokens(user *models.User) (accessToken, refreshToken string, err error) {
-	accessToken, err = config.GenerateJWT(user.UserID.String(), user.UserType, time.Minute*15)
+
+// checkGuardian refuses guardians who are minors themselves
+func (s *AuthService) checkGuardian(guardian *models.User) error {
+	if guardian.IsMinor(s.gymSettings(guardian).ConsentAge(), time.Now()) {
+		return fmt.Errorf("%w: guardians must be adults", ErrInvalidGuardian)
+	}
+	return nil
+}
+
+// gymSettings returns the settings of the user's home gym, or defaults when
+// the user has none.
+func (s *AuthService) gymSettings(user *models.User) models.GymSettings {
+	if user.GymID == nil {
+		return models.GymSettings{}
+	}
+	gym, err := s.gyms.GetByID(user.GymID.String())
+	if err != nil {
+		log.Printf("WARN: AuthService could not load gym %s for user %s: %v", user.GymID, user.UserID, err)
+		return models.GymSettings{}
+	}
+	return gym.ParsedSettings()
+}
+// GenerateTokens issues an access token and starts a new refresh token family
+// for the given client.
+func (s *AuthService) GenerateTokens(user *models.User, client ClientInfo) (accessToken, refreshToken string, err error) {
+	accessToken, err = config.GenerateJWT(user.UserID.String(), user.UserType, config.TokenTypeAccess, accessTokenTTL)
 	if err != nil {
 		return "", "", err
 	}
 
-	refreshToken, err = config.GenerateJWT(user.UserID.String(), user.UserType, time.Hour*24*7)
+	refreshToken, err = s.issueRefreshToken(s.refreshTokens, user, uuid.New(), client)
 	if err != nil {
 		return "", "", err
 	}
 
 	return accessToken, refreshToken, nil
 }
+
+// Refresh exchanges a refresh token for a new access/refresh pair. The
+// presented token is rotated out; presenting it again revokes its family.
+func (s *AuthService) Refresh(refreshToken string, client ClientInfo) (accessToken, newRefreshToken string, err error) {
+	if _, err := config.ParseJWT(refreshToken, config.TokenTypeRefresh); err != nil {
+		return "", "", ErrInvalidRefreshToken
+	}
+
+	reused := false
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		tokens := s.refreshTokens.WithTx(tx)
+
+		stored, err := tokens.GetByHashForUpdate(utils.HashToken(refreshToken))
+		if err != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		now := time.Now()
+		switch checkRefreshToken(stored, now) {
+		case refreshInvalid:
+			return ErrInvalidRefreshToken
+		case refreshReused:
+			// Someone is replaying a token that was already exchanged: either
+			// the client or an attacker holds a stolen copy. Kill the family.
+			if err := tokens.RevokeFamily(stored.FamilyID, now); err != nil {
+				return err
+			}
+			entry := models.NewAuditLog(stored.UserID, models.AuditRefreshTokenReuse, "refresh_token_family", stored.FamilyID, map[string]interface{}{
+				"token_id":   stored.ID,
+				"device_id":  client.DeviceID,
+				"ip_address": client.IPAddress,
+				"user_agent": client.UserAgent,
+			})
+			if err := tx.Create(entry).Error; err != nil {
+				return err
+			}
+			reused = true
+			return nil
+		}
+
+		user, err := s.repo.GetUserByID(stored.UserID)
+		if err != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		if client.DeviceID == "" {
+			client.DeviceID = stored.DeviceID
+		}
+		newRefreshToken, err = s.issueRefreshToken(tokens, user, stored.FamilyID, client)
+		if err != nil {
+			return err
+		}
+		if err := tokens.MarkRotated(stored.ID, now); err != nil {
+			return err
+		}
+
+		accessToken, err = config.GenerateJWT(user.UserID.String(), user.UserType, config.TokenTypeAccess, accessTokenTTL)
+		return err
+	})
+	if err != nil {
+		return "", "", err
+	}
+	if reused {
+		log.Printf("WARN: refresh token reuse detected from %s; token family revoked", client.IPAddress)
+		return "", "", ErrRefreshTokenReused
+	}
+
+	return accessToken, newRefreshToken, nil
+}
+
+// What presenting a stored refresh token leads to
+const (
+	refreshRotate  = iota // valid: exchange it for a new pair
+	refreshInvalid        // revoked or expired
+	refreshReused         // already exchanged once: revoke its family
+)
+
+// checkRefreshToken decides what presenting stored at now leads to. A
+// revoked token is simply invalid, even when it was rotated before its
+// family was revoked, so replays after a revocation are not reported again.
+func checkRefreshToken(stored *models.RefreshToken, now time.Time) int {
+	switch {
+	case stored.RevokedAt != nil:
+		return refreshInvalid
+	case stored.RotatedAt != nil:
+		return refreshReused
+	case now.After(stored.ExpiresAt):
+		return refreshInvalid
+	}
+	return refreshRotate
+}
+
+func (s *AuthService) issueRefreshToken(tokens *repositories.RefreshTokenRepository, user *models.User, familyID uuid.UUID, client ClientInfo) (string, error) {
+	token, err := config.GenerateJWT(user.UserID.String(), user.UserType, config.TokenTypeRefresh, refreshTokenTTL)
+	if err != nil {
+		return "", err
+	}
+
+	record := &models.RefreshToken{
+		UserID:    user.UserID,
+		FamilyID:  familyID,
+		TokenHash: utils.HashToken(token),
+		DeviceID:  client.DeviceID,
+		UserAgent: client.UserAgent,
+		IPAddress: client.IPAddress,
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}
+	if err := tokens.Create(record); err != nil {
+		return "", fmt.Errorf("failed to store refresh token: %w", err)
+	}
+	return token, nil
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"go-blog/internal/models"
+)
+
+func TestCheckRefreshToken(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	earlier := now.Add(-time.Minute)
+	later := now.Add(time.Hour)
+
+	tests := []struct {
+		name  string
+		token models.RefreshToken
+		want  int
+	}{
+		{"fresh token rotates", models.RefreshToken{ExpiresAt: later}, refreshRotate},
+		{"expired token is invalid", models.RefreshToken{ExpiresAt: earlier}, refreshInvalid},
+		{"rotated token is reuse", models.RefreshToken{ExpiresAt: later, RotatedAt: &earlier}, refreshReused},
+		{"rotated and expired token is still reuse", models.RefreshToken{ExpiresAt: earlier, RotatedAt: &earlier}, refreshReused},
+		{"revoked token is invalid", models.RefreshToken{ExpiresAt: later, RevokedAt: &earlier}, refreshInvalid},
+		{"revoked family replay is not reported again", models.RefreshToken{ExpiresAt: later, RotatedAt: &earlier, RevokedAt: &earlier}, refreshInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkRefreshToken(&tt.token, now); got != tt.want {
+				t.Errorf("checkRefreshToken() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
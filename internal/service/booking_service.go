@@ -0,0 +1,131 @@
+package services
+
+import (
+	"errors"
+	"go-blog/internal/models"
+	"go-blog/internal/query"
+	"go-blog/repositories"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrBookingNotFound    = errors.New("booking not found")
+	ErrSessionNotFound    = errors.New("class session not found")
+	ErrSessionNotBookable = errors.New("class session has already started or is not scheduled")
+	ErrSessionFull        = errors.New("class session is fully booked")
+	ErrAlreadyBooked      = errors.New("member already booked this session")
+	ErrNotCancellable     = errors.New("only upcoming bookings can be cancelled")
+)
+
+// BookingService reserves places in class sessions
+type BookingService struct {
+	repo       *repositories.BookingRepository
+	admissions *AdmissionService
+	db         *gorm.DB
+}
+
+func NewBookingService(repo *repositories.BookingRepository, admissions *AdmissionService, db *gorm.DB) *BookingService {
+	return &BookingService{repo: repo, admissions: admissions, db: db}
+}
+
+// Book reserves a place for a member when their plan gives access to the
+// session, and takes a session credit from the membership covering it. actorID is whoever made the booking, the
+// member themselves, a guardian or staff.
+func (s *BookingService) Book(actorID, memberID, sessionID uuid.UUID) (*models.Booking, error) {
+	booking := &models.Booking{
+		SessionID:  sessionID,
+		MemberID:   memberID,
+		Status:     models.BookingBooked,
+		BookedByID: &actorID,
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		repo := s.repo.WithTx(tx)
+		session, err := repo.LockSession(sessionID)
+		if err != nil {
+			return ErrSessionNotFound
+		}
+		if session.Status != "scheduled" || !session.StartsAt.After(time.Now()) {
+			return ErrSessionNotBookable
+		}
+		if _, err := repo.FindBooked(memberID, sessionID); err == nil {
+			return ErrAlreadyBooked
+		}
+		booked, err := repo.CountBooked(sessionID)
+		if err != nil {
+			return err
+		}
+		if booked >= int64(session.Capacity) {
+			return ErrSessionFull
+		}
+		if err := repo.Create(booking); err != nil {
+			return err
+		}
+		return s.admissions.admit(tx, admission{
+			memberID:  memberID,
+			sessionID: sessionID,
+			at:        session.StartsAt,
+			source:    models.UsageBooking,
+			bookingID: &booking.ID,
+			// A renewal that has not started yet may cover a later session,
+			// and an unpaid one does within its grace period
+			statuses: []string{models.MembershipActive, models.MembershipPending, models.MembershipPastDue},
+		})
+	})
+	if err != nil {
+		if !isBookingError(err) {
+			log.Printf("ERROR: BookingService.Book failed for member %s, session %s: %v", memberID, sessionID, err)
+		}
+		return nil, err
+	}
+	return booking, nil
+}
+
+// Get returns a booking with its session
+func (s *BookingService) Get(id uuid.UUID) (*models.Booking, error) {
+	booking, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, ErrBookingNotFound
+	}
+	return booking, nil
+}
+
+// Cancel frees the place of a booking whose session has not started. The
+// session credit comes back unless the cancellation is late.
+func (s *BookingService) Cancel(booking *models.Booking) error {
+	now := time.Now()
+	if booking.Status != models.BookingBooked || !booking.Session.StartsAt.After(now) {
+		return ErrNotCancellable
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.repo.WithTx(tx).SetStatus(booking.ID, models.BookingCancelled); err != nil {
+			return err
+		}
+		return s.admissions.refund(tx, booking, now)
+	})
+	if err != nil {
+		log.Printf("ERROR: BookingService.Cancel failed for booking %s: %v", booking.ID, err)
+	}
+	return err
+}
+
+// ListByMember returns one page of a member's bookings
+func (s *BookingService) ListByMember(memberID uuid.UUID, req *query.Request) (*query.Page[models.Booking], error) {
+	return s.repo.ListByMember(memberID, req)
+}
+
+func isBookingError(err error) bool {
+	var denial *models.AccessDenial
+	if errors.As(err, &denial) {
+		return true
+	}
+	for _, target := range []error{ErrSessionNotFound, ErrSessionNotBookable, ErrSessionFull, ErrAlreadyBooked, ErrNoActiveMembership, ErrQuotaExhausted} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
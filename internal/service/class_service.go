@@ -2,28 +2,41 @@ package services
 
 import (
 	"go-blog/internal/models"
+	"go-blog/internal/query"
 	"go-blog/repositories"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type ClassService struct {
-	repo *repositories.ClassRepository
+	repo  *repositories.ClassRepository
+	gymID *uuid.UUID // set by ForGym
 }
 
 func NewClassService(repo *repositories.ClassRepository) *ClassService {
 	return &ClassService{repo: repo}
 }
 
+// ForGym returns a copy of the service limited to classes of one gym, for
+// API key callers.
+func (s *ClassService) ForGym(gymID uuid.UUID) *ClassService {
+	return &ClassService{repo: s.repo, gymID: &gymID}
+}
+
 // Create a new class
-func (s *ClassService) CreateClass(gymID, trainerID uuid.UUID, title, description string, capacity, durationMinutes int) (*models.Class, error) {
+func (s *ClassService) CreateClass(gymID, trainerID uuid.UUID, title, description, category string, capacity, durationMinutes int) (*models.Class, error) {
+	if s.gymID != nil && *s.gymID != gymID {
+		return nil, ErrOutsideGymScope
+	}
 	class := &models.Class{
 		ID:              uuid.New(),
 		GymID:           gymID,
 		TrainerID:       trainerID,
 		Title:           title,
 		Description:     description,
+		Category:        strings.ToLower(strings.TrimSpace(category)),
 		Capacity:        capacity,
 		DurationMinutes: durationMinutes,
 		CreatedAt:       time.Now(),
@@ -33,14 +46,19 @@ func (s *ClassService) CreateClass(gymID, trainerID uuid.UUID, title, descriptio
 	return class, err
 }
 
-// List all classes
-func (s *ClassService) ListClasses() ([]models.Class, error) {
-	var classes []models.Class
-	err := s.repo.ListAll(&classes)
-	return classes, err
+// List classes page by page
+func (s *ClassService) ListClasses(req *query.Request) (*query.Page[models.Class], error) {
+	if s.gymID != nil {
+		req.Where("gym_id", query.OpEq, s.gymID.String())
+	}
+	return s.repo.List(req)
 }
 
 // Get class by ID
 func (s *ClassService) GetClass(id uuid.UUID) (*models.Class, error) {
-	return s.repo.GetByID(id.String())
+	class, err := s.repo.GetByID(id.String())
+	if err == nil && s.gymID != nil && class.GymID != *s.gymID {
+		return nil, ErrOutsideGymScope
+	}
+	return class, err
 }
@@ -6,18 +6,26 @@ import (
 	"time"
 
 	"go-blog/internal/models"
+	"go-blog/internal/query"
 
 	"github.com/google/uuid"
 )
 
 type ClassSessionService struct {
-	repo *repositories.ClassSessionRepository
+	repo  *repositories.ClassSessionRepository
+	gymID *uuid.UUID // set by ForGym
 }
 
 func NewClassSessionService(repo *repositories.ClassSessionRepository) *ClassSessionService {
 	return &ClassSessionService{repo: repo}
 }
 
+// ForGym returns a copy of the service limited to sessions of one gym, for
+// API key callers.
+func (s *ClassSessionService) ForGym(gymID uuid.UUID) *ClassSessionService {
+	return &ClassSessionService{repo: s.repo, gymID: &gymID}
+}
+
 // Create a session
 func (s *ClassSessionService) CreateSession(classID uuid.UUID, startsAt, endsAt time.Time) (*models.ClassSession, error) {
     
@@ -25,6 +33,11 @@ func (s *ClassSessionService) CreateSession(classID uuid.UUID, startsAt, endsAt
     if classID == uuid.Nil {
         return nil, fmt.Errorf("class ID cannot be empty") // Added fmt.Errorf import might be needed
     }
+    if s.gymID != nil {
+        if gymID, err := s.repo.ClassGymID(classID); err != nil || gymID != *s.gymID {
+            return nil, ErrOutsideGymScope
+        }
+    }
 
     session := &models.ClassSession{
         // 🔑 THE CRITICAL FIX IS HERE: Assign the passed-in classID
@@ -45,14 +58,19 @@ func (s *ClassSessionService) CreateSession(classID uuid.UUID, startsAt, endsAt
 }
 
 
-// List all sessions
-func (s *ClassSessionService) ListSessions() ([]models.ClassSession, error) {
-	var sessions []models.ClassSession
-	err := s.repo.ListAll(&sessions)
-	return sessions, err
+// List sessions page by page
+func (s *ClassSessionService) ListSessions(req *query.Request) (*query.Page[models.ClassSession], error) {
+	if s.gymID != nil {
+		return s.repo.InGym(*s.gymID).List(req)
+	}
+	return s.repo.List(req)
 }
 
 // Get a session by ID
 func (s *ClassSessionService) GetSession(id uuid.UUID) (*models.ClassSession, error) {
-	return s.repo.GetByID(id.String())
+	session, err := s.repo.GetByID(id.String())
+	if err == nil && s.gymID != nil && session.Class.GymID != *s.gymID {
+		return nil, ErrOutsideGymScope
+	}
+	return session, err
 }
@@ -0,0 +1,530 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"go-blog/internal/models"
+	"go-blog/internal/query"
+	"go-blog/repositories"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidPromo    = errors.New("invalid promo code")
+	ErrPromoNotFound   = errors.New("promo code not found")
+	ErrPromoNotUsable  = errors.New("promo code cannot be used")
+	ErrInvalidReferral = errors.New("invalid referral code")
+	ErrMemberNotFound  = errors.New("member not found")
+)
+
+// PromoQuote is what a promo code takes off the price of a plan
+type PromoQuote struct {
+	Code            string    `json:"code"`
+	PlanID          uuid.UUID `json:"plan_id"`
+	PriceCents      int       `json:"price_cents"`
+	DiscountCents   int       `json:"discount_cents"`
+	AmountDueCents  int       `json:"amount_due_cents"`
+	FirstPeriodOnly bool      `json:"first_period_only"`
+}
+
+// ReferralSummary is a member's referral code with the referrals they made
+// and the account credit they hold
+type ReferralSummary struct {
+	Code        string                `json:"referral_code"`
+	CreditCents int                   `json:"credit_cents"`
+	Referrals   []models.Referral     `json:"referrals"`
+	Credits     []models.MemberCredit `json:"credits"`
+}
+
+// DiscountService manages promo codes and referrals. Discounts are applied
+// where payments are raised: at registration, renewal and plan change.
+// Referrers are rewarded when the member they referred first pays.
+type DiscountService struct {
+	repo      *repositories.PromoRepository
+	referrals *repositories.ReferralRepository
+	db        *gorm.DB
+}
+
+func NewDiscountService(repo *repositories.PromoRepository, referrals *repositories.ReferralRepository, db *gorm.DB) *DiscountService {
+	return &DiscountService{repo: repo, referrals: referrals, db: db}
+}
+
+// CreatePromo adds a promo code. Codes are stored upper case and must be
+// unique.
+func (s *DiscountService) CreatePromo(actorID uuid.UUID, promo *models.PromoCode) error {
+	if err := promo.Normalize(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPromo, err)
+	}
+	promo.Redemptions = 0
+	promo.ArchivedAt = nil
+	promo.CreatedByID = &actorID
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if len(promo.PlanIDs) > 0 {
+			var count int64
+			if err := tx.Model(&models.Plan{}).Where("id IN ?", promo.PlanIDs).Count(&count).Error; err != nil {
+				return err
+			}
+			if int(count) != len(promo.PlanIDs) {
+				return fmt.Errorf("%w: plan_ids names a plan that does not exist", ErrInvalidPromo)
+			}
+		}
+		repo := s.repo.WithTx(tx)
+		if _, err := repo.GetByCode(promo.Code); err == nil {
+			return fmt.Errorf("%w: code %s already exists", ErrInvalidPromo, promo.Code)
+		}
+		if err := repo.Create(promo); err != nil {
+			return err
+		}
+		return tx.Create(models.NewAuditLog(actorID, models.AuditPromoCreated, "promo_code", promo.ID, map[string]interface{}{
+			"code":              promo.Code,
+			"kind":              promo.Kind,
+			"percent_off":       promo.PercentOff,
+			"amount_off_cents":  promo.AmountOffCents,
+			"first_period_only": promo.FirstPeriodOnly,
+		})).Error
+	})
+	if err != nil && !isDiscountError(err) {
+		log.Printf("ERROR: DiscountService.CreatePromo failed for code %s: %v", promo.Code, err)
+	}
+	return err
+}
+
+// ListPromos returns one page of promo codes; archived ones only when asked for
+func (s *DiscountService) ListPromos(req *query.Request, includeArchived bool) (*query.Page[models.PromoCode], error) {
+	return s.repo.List(req, includeArchived)
+}
+
+// ArchivePromo stops a promo code from being redeemed. Memberships sold with
+// it keep their discount at renewal.
+func (s *DiscountService) ArchivePromo(actorID, id uuid.UUID) (*models.PromoCode, error) {
+	var promo *models.PromoCode
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		repo := s.repo.WithTx(tx)
+		var err error
+		if promo, err = repo.Lock(id); err != nil {
+			return ErrPromoNotFound
+		}
+		if promo.ArchivedAt != nil {
+			return nil
+		}
+		now := time.Now()
+		promo.ArchivedAt = &now
+		if err := repo.Update(promo); err != nil {
+			return err
+		}
+		return tx.Create(models.NewAuditLog(actorID, models.AuditPromoArchived, "promo_code", promo.ID, map[string]interface{}{
+			"code":        promo.Code,
+			"redemptions": promo.Redemptions,
+		})).Error
+	})
+	if err != nil {
+		if !isDiscountError(err) {
+			log.Printf("ERROR: DiscountService.ArchivePromo failed for promo code %s: %v", id, err)
+		}
+		return nil, err
+	}
+	return promo, nil
+}
+
+// Redemptions returns one page of the discounts a promo code gave
+func (s *DiscountService) Redemptions(id uuid.UUID, req *query.Request) (*query.Page[models.PromoRedemption], error) {
+	if _, err := s.repo.GetByID(id); err != nil {
+		return nil, ErrPromoNotFound
+	}
+	return s.repo.Redemptions(id, req)
+}
+
+// CheckPromo quotes a promo code against a plan without redeeming it, so a
+// registration form can show the discount
+func (s *DiscountService) CheckPromo(code string, planID uuid.UUID) (*PromoQuote, error) {
+	var plan models.Plan
+	if err := s.db.First(&plan, "id = ?", planID).Error; err != nil {
+		return nil, ErrPlanNotFound
+	}
+	promo, err := findPromo(s.db, code, nil, planID, time.Now(), false)
+	if err != nil {
+		if !isDiscountError(err) {
+			log.Printf("ERROR: DiscountService.CheckPromo failed for code %s: %v", code, err)
+		}
+		return nil, err
+	}
+	discount := promo.Discount(plan.PriceCents)
+	return &PromoQuote{
+		Code:            promo.Code,
+		PlanID:          plan.ID,
+		PriceCents:      plan.PriceCents,
+		DiscountCents:   discount,
+		AmountDueCents:  plan.PriceCents - discount,
+		FirstPeriodOnly: promo.FirstPeriodOnly,
+	}, nil
+}
+
+// Referrals returns a member's referral code, creating it on first use,
+// with their referrals and account credit
+func (s *DiscountService) Referrals(memberID uuid.UUID) (*ReferralSummary, error) {
+	var summary ReferralSummary
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		referrals := s.referrals.WithTx(tx)
+		member, err := referrals.LockMember(memberID)
+		if err != nil {
+			return ErrMemberNotFound
+		}
+		if member.ReferralCode == nil {
+			code, err := newReferralCode()
+			if err != nil {
+				return err
+			}
+			if err := referrals.SetCode(member.ID, code); err != nil {
+				return err
+			}
+			member.ReferralCode = &code
+		}
+		summary.Code = *member.ReferralCode
+		if summary.Referrals, err = referrals.ListByReferrer(member.ID); err != nil {
+			return err
+		}
+		if summary.CreditCents, err = referrals.Balance(member.ID); err != nil {
+			return err
+		}
+		summary.Credits, err = referrals.Credits(member.ID)
+		return err
+	})
+	if err != nil {
+		if !errors.Is(err, ErrMemberNotFound) {
+			log.Printf("ERROR: DiscountService.Referrals failed for member %s: %v", memberID, err)
+		}
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// findPromo looks up a promo code and checks it can be used on planID at
+// now. memberID is nil when the member is not known yet, which skips the
+// per member cap. With lock set the code stays locked for the rest of tx.
+func findPromo(tx *gorm.DB, code string, memberID *uuid.UUID, planID uuid.UUID, now time.Time, lock bool) (*models.PromoCode, error) {
+	repo := repositories.NewPromoRepository(tx)
+	code = strings.ToUpper(strings.TrimSpace(code))
+	find := repo.GetByCode
+	if lock {
+		find = repo.LockByCode
+	}
+	promo, err := find(code)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPromoNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if reason := promo.Unusable(planID, now); reason != "" {
+		return nil, fmt.Errorf("%w: %s", ErrPromoNotUsable, reason)
+	}
+	if memberID != nil && promo.MaxPerMember > 0 {
+		used, err := repo.CountByMember(promo.ID, *memberID)
+		if err != nil {
+			return nil, err
+		}
+		if used >= int64(promo.MaxPerMember) {
+			return nil, fmt.Errorf("%w: it has already been used the maximum number of times for this member", ErrPromoNotUsable)
+		}
+	}
+	return promo, nil
+}
+
+// claimPromo checks and locks a promo code a member is redeeming and counts
+// the use towards its cap
+func claimPromo(tx *gorm.DB, code string, memberID, planID uuid.UUID, now time.Time) (*models.PromoCode, error) {
+	promo, err := findPromo(tx, code, &memberID, planID, now, true)
+	if err != nil {
+		return nil, err
+	}
+	promo.Redemptions++
+	if err := repositories.NewPromoRepository(tx).Update(promo); err != nil {
+		return nil, err
+	}
+	return promo, nil
+}
+
+// discountPayment takes what a promo code gives off priceCents from p, then
+// pays what it can of the rest from the member's account credit. Call
+// recordDiscounts once p is stored.
+func discountPayment(tx *gorm.DB, p *models.Payment, promo *models.PromoCode, priceCents int) error {
+	if promo != nil {
+		p.DiscountCents = promo.Discount(priceCents)
+		p.PromoCodeID = &promo.ID
+		p.AmountCents -= p.DiscountCents
+	}
+	if p.AmountCents <= 0 {
+		return nil
+	}
+	referrals := repositories.NewReferralRepository(tx)
+	if _, err := referrals.LockMember(p.MemberID); err != nil {
+		return err
+	}
+	balance, err := referrals.Balance(p.MemberID)
+	if err != nil {
+		return err
+	}
+	if balance > 0 {
+		p.CreditCents = min(balance, p.AmountCents)
+		p.AmountCents -= p.CreditCents
+	}
+	return nil
+}
+
+// recordDiscounts records the promo redemption and the account credit spent
+// on a stored payment
+func recordDiscounts(tx *gorm.DB, p *models.Payment, promo *models.PromoCode, renewal bool) error {
+	if promo != nil {
+		err := repositories.NewPromoRepository(tx).CreateRedemption(&models.PromoRedemption{
+			PromoCodeID:   promo.ID,
+			MemberID:      p.MemberID,
+			MembershipID:  p.MembershipID,
+			PaymentID:     &p.ID,
+			DiscountCents: p.DiscountCents,
+			Renewal:       renewal,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if p.CreditCents > 0 {
+		return repositories.NewReferralRepository(tx).CreateCredit(&models.MemberCredit{
+			MemberID:    p.MemberID,
+			AmountCents: -p.CreditCents,
+			Reason:      models.CreditSpent,
+			PaymentID:   &p.ID,
+		})
+	}
+	return nil
+}
+
+// What a change of payment status does to the promo redemption and account
+// credit a payment holds
+const (
+	discountsKept = iota
+	discountsReleased
+	discountsReclaimed
+)
+
+// discountChange says what moving a payment from one status to another does
+// to its discounts. They are held while the payment is pending, given back
+// when it fails or is voided so they can be used again, and taken again when
+// a failed payment is paid after all.
+func discountChange(from, to string) int {
+	switch {
+	case from == models.PaymentPending && (to == models.PaymentFailed || to == models.PaymentVoid):
+		return discountsReleased
+	case from == models.PaymentFailed && to == models.PaymentPaid:
+		return discountsReclaimed
+	}
+	return discountsKept
+}
+
+// settleDiscounts releases or reclaims the discounts of a payment whose
+// status changed from from
+func settleDiscounts(tx *gorm.DB, p *models.Payment, from string) error {
+	switch discountChange(from, p.Status) {
+	case discountsReleased:
+		return releaseDiscounts(tx, p)
+	case discountsReclaimed:
+		return reclaimDiscounts(tx, p)
+	}
+	return nil
+}
+
+// releaseDiscounts undoes recordDiscounts and claimPromo for a payment that
+// will not be paid: its redemption is removed and no longer counts towards
+// the promo code's caps, and the account credit it spent is given back
+func releaseDiscounts(tx *gorm.DB, p *models.Payment) error {
+	promos := repositories.NewPromoRepository(tx)
+	redemptions, err := promos.DeleteRedemptionsOf(p.ID)
+	if err != nil {
+		return err
+	}
+	for _, redemption := range redemptions {
+		if redemption.Renewal {
+			continue
+		}
+		promo, err := promos.Lock(redemption.PromoCodeID)
+		if err != nil {
+			return err
+		}
+		promo.Redemptions = max(promo.Redemptions-1, 0)
+		if err := promos.Update(promo); err != nil {
+			return err
+		}
+	}
+	if p.CreditCents > 0 {
+		return repositories.NewReferralRepository(tx).CreateCredit(&models.MemberCredit{
+			MemberID:    p.MemberID,
+			AmountCents: p.CreditCents,
+			Reason:      models.CreditReturned,
+			PaymentID:   &p.ID,
+		})
+	}
+	return nil
+}
+
+// reclaimDiscounts records again the discounts of a failed payment that was
+// paid after all. The member paid the discounted amount, so the redemption
+// counts even when the code has since reached its cap, and the credit is
+// spent even when the balance no longer covers it.
+func reclaimDiscounts(tx *gorm.DB, p *models.Payment) error {
+	renewal := p.Method == models.PaymentMethodAutoRenewal
+	var promo *models.PromoCode
+	if p.PromoCodeID != nil {
+		promos := repositories.NewPromoRepository(tx)
+		var err error
+		if promo, err = promos.Lock(*p.PromoCodeID); err != nil {
+			return err
+		}
+		if !renewal {
+			promo.Redemptions++
+			if err := promos.Update(promo); err != nil {
+				return err
+			}
+		}
+	}
+	return recordDiscounts(tx, p, promo, renewal)
+}
+
+// raiseSignupPayment raises the payment for the first period of a
+// membership sold with a promo code, so the discount is on record from the
+// start. A period discounted to nothing is paid.
+func raiseSignupPayment(tx *gorm.DB, m *models.Membership, promo *models.PromoCode, priceCents int) error {
+	payment := &models.Payment{
+		MemberID:     m.MemberID,
+		AmountCents:  priceCents,
+		Method:       models.PaymentMethodSignup,
+		Status:       models.PaymentPending,
+		MembershipID: &m.ID,
+	}
+	if err := discountPayment(tx, payment, promo, priceCents); err != nil {
+		return err
+	}
+	if payment.AmountCents == 0 {
+		payment.Status = models.PaymentPaid
+	}
+	if err := repositories.NewPaymentRepository(tx).Create(payment); err != nil {
+		return err
+	}
+	return recordDiscounts(tx, payment, promo, false)
+}
+
+// referMember records that the member owning referralCode referred memberID
+func referMember(tx *gorm.DB, referralCode string, memberID uuid.UUID) error {
+	referrals := repositories.NewReferralRepository(tx)
+	referrer, err := referrals.MemberByCode(strings.ToUpper(strings.TrimSpace(referralCode)))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrInvalidReferral
+	}
+	if err != nil {
+		return err
+	}
+	if referrer.ID == memberID {
+		return fmt.Errorf("%w: members cannot refer themselves", ErrInvalidReferral)
+	}
+	return referrals.Create(&models.Referral{
+		ReferrerID: referrer.ID,
+		ReferredID: memberID,
+		Status:     models.ReferralPending,
+	})
+}
+
+// rewardReferral rewards whoever referred the member of a paid payment, the
+// first time one is paid: account credit, free days on the referrer's
+// current membership, or both, as the referrer's gym sets. Free days are
+// skipped when the referrer has no active membership to add them to.
+func rewardReferral(tx *gorm.DB, memberships *MembershipService, p *models.Payment) error {
+	if p.Status != models.PaymentPaid || p.AmountCents <= 0 {
+		return nil
+	}
+	referrals := repositories.NewReferralRepository(tx)
+	referral, err := referrals.LockPending(p.MemberID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	referrer, err := referrals.LockMember(referral.ReferrerID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// The referrer has left; there is nobody to reward
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var settings models.GymSettings
+	if gym, err := referrals.GymOfMember(referrer.ID); err == nil {
+		settings = gym.ParsedSettings()
+	}
+	cents, days := settings.ReferralReward()
+	metadata := map[string]interface{}{
+		"referred_id": referral.ReferredID,
+		"payment_id":  p.ID,
+	}
+
+	if days > 0 {
+		m, err := memberships.extend(tx, referrer.ID, days)
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			days = 0
+		case err != nil:
+			return err
+		default:
+			metadata["membership_id"] = m.ID
+			metadata["end_date"] = m.EndDate
+		}
+	}
+	if cents > 0 {
+		err := referrals.CreateCredit(&models.MemberCredit{
+			MemberID:    referrer.ID,
+			AmountCents: cents,
+			Reason:      models.CreditReferral,
+			ReferralID:  &referral.ID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	referral.Status = models.ReferralRewarded
+	referral.RewardCents = cents
+	referral.RewardDays = days
+	referral.PaymentID = &p.ID
+	referral.RewardedAt = &now
+	if err := referrals.Update(referral); err != nil {
+		return err
+	}
+	metadata["reward_cents"] = cents
+	metadata["reward_days"] = days
+	// The referrer is the actor of rewards nobody asked for
+	return tx.Create(models.NewAuditLog(referrer.UserID, models.AuditReferralRewarded, "referral", referral.ID, metadata)).Error
+}
+
+// newReferralCode returns an 8 character code such as "R7QXMPA2"
+func newReferralCode() (string, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return passCodeEncoding.EncodeToString(b), nil
+}
+
+func isDiscountError(err error) bool {
+	for _, target := range []error{ErrInvalidPromo, ErrPromoNotFound, ErrPromoNotUsable, ErrInvalidReferral, ErrPlanNotFound} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package services
+
+import (
+	"testing"
+
+	"go-blog/internal/models"
+)
+
+func TestDiscountChange(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		want     int
+	}{
+		{"paid keeps the discounts", models.PaymentPending, models.PaymentPaid, discountsKept},
+		{"failing gives them back", models.PaymentPending, models.PaymentFailed, discountsReleased},
+		{"voiding gives them back", models.PaymentPending, models.PaymentVoid, discountsReleased},
+		{"failed then paid takes them again", models.PaymentFailed, models.PaymentPaid, discountsReclaimed},
+		{"failed then voided has nothing left to give back", models.PaymentFailed, models.PaymentVoid, discountsKept},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := discountChange(tt.from, tt.to); got != tt.want {
+				t.Errorf("discountChange(%s, %s) = %d, want %d", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"go-blog/internal/models"
+	"go-blog/internal/query"
 
 	"github.com/google/uuid"
 )
@@ -34,11 +35,9 @@ func (s *GymService) CreateGym(name, address, phone, timezone string, openingHou
 	return gym, err
 }
 
-// List all gyms
-func (s *GymService) ListGyms() ([]models.Gym, error) {
-	var gyms []models.Gym
-	err := s.repo.ListAll(&gyms)
-	return gyms, err
+// List gyms page by page
+func (s *GymService) ListGyms(req *query.Request) (*query.Page[models.Gym], error) {
+	return s.repo.List(req)
 }
 
 // Get gym by ID
@@ -0,0 +1,397 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"go-blog/internal/models"
+	"go-blog/internal/query"
+	"go-blog/repositories"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrHouseholdNotFound       = errors.New("household not found")
+	ErrHouseholdForbidden      = errors.New("only the primary payer or staff can manage this household")
+	ErrAlreadyInHousehold      = errors.New("user already belongs to a household")
+	ErrNotInHousehold          = errors.New("user is not a member of this household")
+	ErrInvalidHouseholdArg     = errors.New("invalid household request")
+	ErrGuardianConsentRequired = errors.New("a parent or guardian must give consent for members under the minimum age")
+	ErrInvalidGuardian         = errors.New("invalid guardian")
+	ErrGuardianLinkNotFound    = errors.New("guardian link not found")
+)
+
+// GuardianConsent is a guardian's agreement to a dependent's account.
+// ConsentedBy is the guardian, or the staff member recording consent given offline.
+type GuardianConsent struct {
+	GuardianID   uuid.UUID
+	Relationship string
+	ConsentedBy  uuid.UUID
+	IP           string
+}
+
+// HouseholdActor is the caller of a household operation
+type HouseholdActor struct {
+	UserID  uuid.UUID
+	IsStaff bool
+}
+
+// HouseholdService groups families under a primary payer and keeps the
+// guardian links that let adults act for their dependents.
+type HouseholdService struct {
+	repo     *repositories.HouseholdRepository
+	users    repositories.UserRepository
+	payments *repositories.PaymentRepository
+	auth     *AuthService
+	db       *gorm.DB
+}
+
+func NewHouseholdService(repo *repositories.HouseholdRepository, users repositories.UserRepository, payments *repositories.PaymentRepository, auth *AuthService, db *gorm.DB) *HouseholdService {
+	return &HouseholdService{repo: repo, users: users, payments: payments, auth: auth, db: db}
+}
+
+// Create starts a household with the actor as its primary payer
+func (s *HouseholdService) Create(actorID uuid.UUID, name string) (*models.Household, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidHouseholdArg)
+	}
+	user, err := s.users.GetUserByID(actorID)
+	if err != nil {
+		return nil, ErrInvalidGuardian
+	}
+	if user.IsMinor(s.auth.gymSettings(user).ConsentAge(), time.Now()) {
+		return nil, fmt.Errorf("%w: minors cannot be a primary payer", ErrInvalidHouseholdArg)
+	}
+	if _, err := s.repo.MembershipOf(actorID); err == nil {
+		return nil, ErrAlreadyInHousehold
+	}
+
+	household := &models.Household{Name: name, PrimaryPayerID: actorID}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		return createHousehold(tx, s.repo.WithTx(tx), household, actorID)
+	})
+	if err != nil {
+		log.Printf("ERROR: HouseholdService.Create failed for user %s: %v", actorID, err)
+		return nil, fmt.Errorf("failed to create household")
+	}
+	return s.repo.GetByID(household.ID)
+}
+
+func (s *HouseholdService) List(req *query.Request) (*query.Page[models.Household], error) {
+	return s.repo.List(req)
+}
+
+// Get returns a household. Only its members and staff may see it.
+func (s *HouseholdService) Get(actor HouseholdActor, id uuid.UUID) (*models.Household, error) {
+	household, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, ErrHouseholdNotFound
+	}
+	if !actor.IsStaff && memberRole(household, actor.UserID) == "" {
+		return nil, ErrHouseholdNotFound
+	}
+	return household, nil
+}
+
+// Mine returns the household of a user
+func (s *HouseholdService) Mine(userID uuid.UUID) (*models.Household, error) {
+	member, err := s.repo.MembershipOf(userID)
+	if err != nil {
+		return nil, ErrHouseholdNotFound
+	}
+	return s.repo.GetByID(member.HouseholdID)
+}
+
+// AddMember puts a user into a household. Primary payers may add their own
+// dependents; staff may add anyone. Minors join as dependents and need a
+// guardian in the household.
+func (s *HouseholdService) AddMember(actor HouseholdActor, householdID, userID uuid.UUID) (*models.Household, error) {
+	household, err := s.manageable(actor, householdID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.users.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: user not found", ErrInvalidHouseholdArg)
+	}
+	if _, err := s.repo.MembershipOf(userID); err == nil {
+		return nil, ErrAlreadyInHousehold
+	}
+
+	guarded, err := s.hasGuardianIn(household, userID)
+	if err != nil {
+		return nil, err
+	}
+	role := models.HouseholdAdult
+	switch {
+	case guarded:
+		role = models.HouseholdDependent
+	case user.IsMinor(s.auth.gymSettings(user).ConsentAge(), time.Now()):
+		return nil, ErrGuardianConsentRequired
+	case !actor.IsStaff:
+		return nil, fmt.Errorf("%w: primary payers can only add their dependents", ErrHouseholdForbidden)
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.repo.WithTx(tx).AddMember(&models.HouseholdMember{HouseholdID: householdID, UserID: userID, Role: role}); err != nil {
+			return err
+		}
+		return tx.Create(models.NewAuditLog(actor.UserID, models.AuditHouseholdMemberAdded, "household", householdID, map[string]interface{}{
+			"user_id": userID,
+			"role":    role,
+		})).Error
+	})
+	if err != nil {
+		log.Printf("ERROR: HouseholdService.AddMember failed for household %s: %v", householdID, err)
+		return nil, fmt.Errorf("failed to add household member")
+	}
+	return s.repo.GetByID(householdID)
+}
+
+// RemoveMember takes a user out of a household. Adults may leave on their
+// own. The primary payer can only leave once nobody else is left, which
+// dissolves the household.
+func (s *HouseholdService) RemoveMember(actor HouseholdActor, householdID, userID uuid.UUID) error {
+	household, err := s.repo.GetByID(householdID)
+	if err != nil {
+		return ErrHouseholdNotFound
+	}
+	role := memberRole(household, userID)
+	if role == "" {
+		return ErrNotInHousehold
+	}
+	leaving := actor.UserID == userID && role == models.HouseholdAdult
+	if !leaving && !actor.IsStaff && household.PrimaryPayerID != actor.UserID {
+		return ErrHouseholdForbidden
+	}
+	dissolve := role == models.HouseholdPrimary
+	if dissolve && len(household.Members) > 1 {
+		return fmt.Errorf("%w: hand the primary payer role to another adult first", ErrInvalidHouseholdArg)
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		repo := s.repo.WithTx(tx)
+		if err := repo.RemoveMember(householdID, userID); err != nil {
+			return err
+		}
+		if dissolve {
+			if err := repo.Delete(householdID); err != nil {
+				return err
+			}
+		}
+		return tx.Create(models.NewAuditLog(actor.UserID, models.AuditHouseholdMemberRemoved, "household", householdID, map[string]interface{}{
+			"user_id":   userID,
+			"dissolved": dissolve,
+		})).Error
+	})
+}
+
+// SetPrimaryPayer hands billing for the household to another adult member
+func (s *HouseholdService) SetPrimaryPayer(actor HouseholdActor, householdID, userID uuid.UUID) (*models.Household, error) {
+	household, err := s.manageable(actor, householdID)
+	if err != nil {
+		return nil, err
+	}
+	switch memberRole(household, userID) {
+	case models.HouseholdPrimary:
+		return household, nil
+	case models.HouseholdAdult:
+	case "":
+		return nil, ErrNotInHousehold
+	default:
+		return nil, fmt.Errorf("%w: dependents cannot be the primary payer", ErrInvalidHouseholdArg)
+	}
+
+	previous := household.PrimaryPayerID
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		repo := s.repo.WithTx(tx)
+		if err := repo.SetRole(householdID, previous, models.HouseholdAdult); err != nil {
+			return err
+		}
+		if err := repo.SetRole(householdID, userID, models.HouseholdPrimary); err != nil {
+			return err
+		}
+		if err := repo.SetPrimaryPayer(householdID, userID); err != nil {
+			return err
+		}
+		return tx.Create(models.NewAuditLog(actor.UserID, models.AuditHouseholdPayerChanged, "household", householdID, map[string]interface{}{
+			"from": previous,
+			"to":   userID,
+		})).Error
+	})
+	if err != nil {
+		log.Printf("ERROR: HouseholdService.SetPrimaryPayer failed for household %s: %v", householdID, err)
+		return nil, fmt.Errorf("failed to change primary payer")
+	}
+	return s.repo.GetByID(householdID)
+}
+
+// Payments lists the payments of everyone in the household, for the
+// primary payer and staff.
+func (s *HouseholdService) Payments(actor HouseholdActor, householdID uuid.UUID, req *query.Request) (*query.Page[models.Payment], error) {
+	if _, err := s.manageable(actor, householdID); err != nil {
+		return nil, err
+	}
+	return s.payments.ListByHousehold(householdID.String(), req)
+}
+
+// LinkGuardian records consent given outside the app, e.g. on a paper form
+// at the front desk, for an existing account.
+func (s *HouseholdService) LinkGuardian(actorID uuid.UUID, consent GuardianConsent, dependentID uuid.UUID) (*models.GuardianLink, error) {
+	guardian, err := s.users.GetUserByID(consent.GuardianID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: guardian not found", ErrInvalidGuardian)
+	}
+	if _, err := s.users.GetUserByID(dependentID); err != nil {
+		return nil, fmt.Errorf("%w: dependent not found", ErrInvalidHouseholdArg)
+	}
+	if guardian.UserID == dependentID {
+		return nil, fmt.Errorf("%w: users cannot be their own guardian", ErrInvalidGuardian)
+	}
+	if err := s.auth.checkGuardian(guardian); err != nil {
+		return nil, err
+	}
+
+	consent.ConsentedBy = actorID
+	var link *models.GuardianLink
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		link, err = linkGuardian(tx, s.repo.WithTx(tx), consent, dependentID, "front_desk")
+		return err
+	})
+	if err != nil {
+		log.Printf("ERROR: HouseholdService.LinkGuardian failed for dependent %s: %v", dependentID, err)
+		return nil, fmt.Errorf("failed to link guardian")
+	}
+	return link, nil
+}
+
+// RevokeGuardianLink ends a guardian's right to act for a dependent
+func (s *HouseholdService) RevokeGuardianLink(actorID, linkID uuid.UUID) error {
+	link, err := s.repo.GetGuardianLink(linkID)
+	if err != nil || link.RevokedAt != nil {
+		return ErrGuardianLinkNotFound
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.repo.WithTx(tx).RevokeGuardianLink(linkID, time.Now()); err != nil {
+			return err
+		}
+		return tx.Create(models.NewAuditLog(actorID, models.AuditGuardianRevoked, "user", link.DependentID, map[string]interface{}{
+			"guardian_id": link.GuardianID,
+		})).Error
+	})
+}
+
+// Dependents lists the users a guardian may act for
+func (s *HouseholdService) Dependents(guardianID uuid.UUID) ([]models.GuardianLink, error) {
+	return s.repo.DependentsOf(guardianID)
+}
+
+// GuardiansOf lists the active guardian links of a dependent
+func (s *HouseholdService) GuardiansOf(dependentID uuid.UUID) ([]models.GuardianLink, error) {
+	return s.repo.GuardiansOf(dependentID)
+}
+
+func (s *HouseholdService) manageable(actor HouseholdActor, householdID uuid.UUID) (*models.Household, error) {
+	household, err := s.repo.GetByID(householdID)
+	if err != nil {
+		return nil, ErrHouseholdNotFound
+	}
+	if !actor.IsStaff && household.PrimaryPayerID != actor.UserID {
+		return nil, ErrHouseholdForbidden
+	}
+	return household, nil
+}
+
+// hasGuardianIn reports whether an adult of the household is a guardian of userID
+func (s *HouseholdService) hasGuardianIn(household *models.Household, userID uuid.UUID) (bool, error) {
+	links, err := s.repo.GuardiansOf(userID)
+	if err != nil {
+		return false, err
+	}
+	for _, link := range links {
+		if role := memberRole(household, link.GuardianID); role == models.HouseholdPrimary || role == models.HouseholdAdult {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func memberRole(household *models.Household, userID uuid.UUID) string {
+	for _, m := range household.Members {
+		if m.UserID == userID {
+			return m.Role
+		}
+	}
+	return ""
+}
+
+func createHousehold(tx *gorm.DB, repo *repositories.HouseholdRepository, household *models.Household, actorID uuid.UUID) error {
+	if err := repo.Create(household); err != nil {
+		return err
+	}
+	if err := repo.AddMember(&models.HouseholdMember{HouseholdID: household.ID, UserID: household.PrimaryPayerID, Role: models.HouseholdPrimary}); err != nil {
+		return err
+	}
+	return tx.Create(models.NewAuditLog(actorID, models.AuditHouseholdCreated, "household", household.ID, map[string]interface{}{
+		"name": household.Name,
+	})).Error
+}
+
+// linkGuardian stores a guardian link, reviving a revoked one, and writes
+// the consent to the audit log. source says where consent was given.
+func linkGuardian(tx *gorm.DB, repo *repositories.HouseholdRepository, consent GuardianConsent, dependentID uuid.UUID, source string) (*models.GuardianLink, error) {
+	link := &models.GuardianLink{
+		GuardianID:   consent.GuardianID,
+		DependentID:  dependentID,
+		Relationship: strings.TrimSpace(consent.Relationship),
+		ConsentedAt:  time.Now(),
+		ConsentedBy:  consent.ConsentedBy,
+		ConsentIP:    consent.IP,
+	}
+	if existing, err := repo.GuardianLink(consent.GuardianID, dependentID); err == nil {
+		link.ID = existing.ID
+		link.CreatedAt = existing.CreatedAt
+		if err := repo.RenewGuardianLink(link); err != nil {
+			return nil, err
+		}
+	} else if err := repo.CreateGuardianLink(link); err != nil {
+		return nil, err
+	}
+
+	err := tx.Create(models.NewAuditLog(consent.ConsentedBy, models.AuditGuardianLinked, "user", dependentID, map[string]interface{}{
+		"guardian_id":  consent.GuardianID,
+		"relationship": link.Relationship,
+		"source":       source,
+		"ip_address":   consent.IP,
+	})).Error
+	return link, err
+}
+
+// joinGuardianHousehold adds a dependent to the guardian's household,
+// starting one for the guardian if they have none.
+func joinGuardianHousehold(tx *gorm.DB, repo *repositories.HouseholdRepository, guardian *models.User, dependentID uuid.UUID) error {
+	membership, err := repo.MembershipOf(guardian.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		household := &models.Household{Name: guardian.LastName + " household", PrimaryPayerID: guardian.UserID}
+		if err := createHousehold(tx, repo, household, guardian.UserID); err != nil {
+			return err
+		}
+		membership = &models.HouseholdMember{HouseholdID: household.ID}
+	} else if err != nil {
+		return err
+	}
+
+	if err := repo.AddMember(&models.HouseholdMember{HouseholdID: membership.HouseholdID, UserID: dependentID, Role: models.HouseholdDependent}); err != nil {
+		return err
+	}
+	return tx.Create(models.NewAuditLog(guardian.UserID, models.AuditHouseholdMemberAdded, "household", membership.HouseholdID, map[string]interface{}{
+		"user_id": dependentID,
+		"role":    models.HouseholdDependent,
+	})).Error
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"errors"
+	"go-blog/internal/config"
+	"go-blog/internal/models"
+	"go-blog/repositories"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// impersonationTTL must not exceed accessTokenTTL: revocation watermarks are
+// only kept that long, and impersonation tokens are never refreshed.
+const impersonationTTL = accessTokenTTL
+
+var (
+	ErrImpersonationTargetNotFound = errors.New("user not found")
+	ErrCannotImpersonate           = errors.New("admins cannot be impersonated")
+)
+
+// Impersonation is a token that lets an admin see the app as another user
+type Impersonation struct {
+	AccessToken string       `json:"access_token"`
+	ExpiresAt   time.Time    `json:"expires_at"`
+	User        *models.User `json:"user"`
+	ActorID     uuid.UUID    `json:"impersonator_id"`
+}
+
+// ImpersonationService lets support staff view the app as a member. The
+// tokens are short-lived, read-only (enforced by AuthMiddleware) and every
+// request made with them is audited under the admin.
+type ImpersonationService struct {
+	users repositories.UserRepository
+	audit *repositories.AuditLogRepository
+}
+
+func NewImpersonationService(users repositories.UserRepository, audit *repositories.AuditLogRepository) *ImpersonationService {
+	return &ImpersonationService{users: users, audit: audit}
+}
+
+// Start mints an impersonation token for targetID on behalf of actorID
+func (s *ImpersonationService) Start(actorID, targetID uuid.UUID, reason, ip string) (*Impersonation, error) {
+	target, err := s.users.GetUserByID(targetID)
+	if err != nil {
+		return nil, ErrImpersonationTargetNotFound
+	}
+	if actorID == targetID || models.NormalizeRole(target.UserType) == models.RoleAdmin {
+		return nil, ErrCannotImpersonate
+	}
+
+	token, err := config.GenerateImpersonationJWT(target.UserID.String(), target.UserType, actorID.String(), impersonationTTL)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(impersonationTTL)
+
+	entry := models.NewAuditLog(actorID, models.AuditImpersonationStarted, "user", targetID, map[string]interface{}{
+		"reason":     strings.TrimSpace(reason),
+		"expires_at": expiresAt,
+		"ip_address": ip,
+	})
+	if err := s.audit.Create(entry); err != nil {
+		// No audit trail, no impersonation
+		log.Printf("ERROR: ImpersonationService failed to audit impersonation of %s by %s: %v", targetID, actorID, err)
+		return nil, errors.New("failed to start impersonation")
+	}
+
+	return &Impersonation{AccessToken: token, ExpiresAt: expiresAt, User: target, ActorID: actorID}, nil
+}
+
+// RecordImpersonatedRequest audits one request made while impersonating
+func (s *ImpersonationService) RecordImpersonatedRequest(actorID, userID uuid.UUID, method, path string, status int, ip string) {
+	entry := models.NewAuditLog(actorID, models.AuditImpersonatedRequest, "user", userID, map[string]interface{}{
+		"method":     method,
+		"path":       path,
+		"status":     status,
+		"ip_address": ip,
+	})
+	if err := s.audit.Create(entry); err != nil {
+		log.Printf("ERROR: ImpersonationService failed to audit request of %s as %s: %v", actorID, userID, err)
+	}
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"go-blog/internal/models"
+	"go-blog/internal/ratelimit"
+	"go-blog/repositories"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	loginIPLimit         = 20 // failed attempts per IP and window
+	loginIPWindow        = 15 * time.Minute
+	loginAccountWindow   = 15 * time.Minute
+	loginDelayAfter      = 3 // failures per account before delays start
+	loginMaxDelay        = 30 * time.Second
+	loginLockoutFailures = 5 // consecutive failures that lock the account
+	loginLockoutDuration = 15 * time.Minute
+)
+
+// Login attempt results, used as the "result" label of the attempts counter
+const (
+	loginResultSuccess   = "success"
+	loginResultFailure   = "failure"
+	loginResultThrottled = "throttled"
+	loginResultLocked    = "locked"
+)
+
+// LoginThrottledError is returned when a login attempt is refused before the
+// password is even checked.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *LoginThrottledError) Error() string {
+	if e.Locked {
+		return "account temporarily locked after too many failed login attempts"
+	}
+	return "too many login attempts, please try again later"
+}
+
+// auditWriter records lockouts and unlocks; *repositories.AuditLogRepository
+// in production
+type auditWriter interface {
+	Create(entry *models.AuditLog) error
+}
+
+// LoginThrottle protects /auth/login against credential stuffing and keeps
+// bcrypt from being used as a CPU exhaustion vector:
+//   - failures per client IP are capped with a sliding window,
+//   - failures per account add a growing delay between attempts,
+//   - consecutive failures lock the account for a while (stored on the user,
+//     so the lock holds across replicas).
+type LoginThrottle struct {
+	users      repositories.UserRepository
+	audit      auditWriter
+	perIP      *ratelimit.SlidingWindow
+	perAccount *ratelimit.SlidingWindow
+	attempts   *prometheus.CounterVec
+	lockouts   prometheus.Counter
+}
+
+func NewLoginThrottle(users repositories.UserRepository, audit *repositories.AuditLogRepository, attempts *prometheus.CounterVec, lockouts prometheus.Counter) *LoginThrottle {
+	return &LoginThrottle{
+		users:      users,
+		audit:      audit,
+		perIP:      ratelimit.NewSlidingWindow(loginIPLimit, loginIPWindow),
+		perAccount: ratelimit.NewSlidingWindow(math.MaxInt, loginAccountWindow),
+		attempts:   attempts,
+		lockouts:   lockouts,
+	}
+}
+
+// Check is called before the user is loaded. It refuses the attempt when the
+// IP is over its limit or the account is still inside its back-off delay.
+func (t *LoginThrottle) Check(email, ip string, now time.Time) error {
+	if ok, retryAfter := t.perIP.Allow(ip, now); !ok {
+		t.attempts.WithLabelValues(loginResultThrottled).Inc()
+		return &LoginThrottledError{RetryAfter: retryAfter}
+	}
+
+	key := accountKey(email)
+	failures := t.perAccount.Count(key, now)
+	if failures >= loginDelayAfter {
+		last, _ := t.perAccount.Last(key, now)
+		if wait := last.Add(backoff(failures)).Sub(now); wait > 0 {
+			t.attempts.WithLabelValues(loginResultThrottled).Inc()
+			return &LoginThrottledError{RetryAfter: wait}
+		}
+	}
+	return nil
+}
+
+// CheckLocked refuses users whose account is locked.
+func (t *LoginThrottle) CheckLocked(user *models.User, now time.Time) error {
+	if user.LockedUntil != nil && user.LockedUntil.After(now) {
+		t.attempts.WithLabelValues(loginResultLocked).Inc()
+		return &LoginThrottledError{RetryAfter: user.LockedUntil.Sub(now), Locked: true}
+	}
+	return nil
+}
+
+// Failure records a failed attempt. user is nil when the email is unknown.
+// It returns a LoginThrottledError when this failure locked the account.
+func (t *LoginThrottle) Failure(user *models.User, email, ip string, now time.Time) error {
+	t.attempts.WithLabelValues(loginResultFailure).Inc()
+	t.perIP.Record(ip, now)
+	t.perAccount.Record(accountKey(email), now)
+
+	if user == nil {
+		return nil
+	}
+
+	// A lock that has run out starts a fresh count, so one more wrong
+	// password does not lock the account again
+	if user.LockedUntil != nil && !user.LockedUntil.After(now) {
+		if err := t.users.ResetFailedLogins(user.UserID); err != nil {
+			log.Printf("ERROR: LoginThrottle failed to reset failed logins for %s: %v", user.UserID, err)
+			return nil
+		}
+		user.LockedUntil = nil
+		user.FailedLoginAttempts = 0
+	}
+
+	count, err := t.users.IncrementFailedLogins(user.UserID)
+	if err != nil {
+		log.Printf("ERROR: LoginThrottle failed to count failed login for %s: %v", user.UserID, err)
+		return nil
+	}
+	if count < loginLockoutFailures {
+		return nil
+	}
+
+	until := now.Add(loginLockoutDuration)
+	if err := t.users.LockUntil(user.UserID, until); err != nil {
+		log.Printf("ERROR: LoginThrottle failed to lock user %s: %v", user.UserID, err)
+		return nil
+	}
+	t.lockouts.Inc()
+
+	entry := models.NewAuditLog(user.UserID, models.AuditAccountLocked, "user", user.UserID, map[string]interface{}{
+		"failed_attempts": count,
+		"locked_until":    until,
+		"ip_address":      ip,
+	})
+	if err := t.audit.Create(entry); err != nil {
+		log.Printf("ERROR: LoginThrottle failed to audit lockout of %s: %v", user.UserID, err)
+	}
+	return &LoginThrottledError{RetryAfter: loginLockoutDuration, Locked: true}
+}
+
+// Success clears the failure history of the account.
+func (t *LoginThrottle) Success(user *models.User, email string) {
+	t.attempts.WithLabelValues(loginResultSuccess).Inc()
+	t.perAccount.Reset(accountKey(email))
+
+	if user.FailedLoginAttempts > 0 || user.LockedUntil != nil {
+		if err := t.users.ResetFailedLogins(user.UserID); err != nil {
+			log.Printf("ERROR: LoginThrottle failed to reset failed logins for %s: %v", user.UserID, err)
+		}
+	}
+}
+
+// Unlock lifts a lockout on behalf of an admin.
+func (t *LoginThrottle) Unlock(actorID, userID uuid.UUID) error {
+	user, err := t.users.GetUserByID(userID)
+	if err != nil {
+		return fmt.Errorf("user not found")
+	}
+	if err := t.users.ResetFailedLogins(userID); err != nil {
+		return err
+	}
+	t.perAccount.Reset(accountKey(user.Email))
+
+	return t.audit.Create(models.NewAuditLog(actorID, models.AuditAccountUnlocked, "user", userID, nil))
+}
+
+// Run periodically drops idle entries from the in-memory windows.
+func (t *LoginThrottle) Run(ctx context.Context) error {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			t.perIP.Cleanup(now)
+			t.perAccount.Cleanup(now)
+		}
+	}
+}
+
+// backoff doubles the delay with every failure past loginDelayAfter: 1s, 2s, 4s, ...
+func backoff(failures int) time.Duration {
+	delay := time.Second << uint(failures-loginDelayAfter)
+	if delay <= 0 || delay > loginMaxDelay {
+		return loginMaxDelay
+	}
+	return delay
+}
+
+func accountKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
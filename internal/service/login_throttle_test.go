@@ -0,0 +1,214 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go-blog/internal/models"
+	"go-blog/internal/ratelimit"
+	"go-blog/repositories"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fakeUsers keeps the lockout columns of users in memory
+type fakeUsers struct {
+	repositories.UserRepository
+	failed map[uuid.UUID]int
+	locked map[uuid.UUID]time.Time
+}
+
+func (f *fakeUsers) IncrementFailedLogins(id uuid.UUID) (int, error) {
+	f.failed[id]++
+	return f.failed[id], nil
+}
+
+func (f *fakeUsers) LockUntil(id uuid.UUID, until time.Time) error {
+	f.locked[id] = until
+	return nil
+}
+
+func (f *fakeUsers) ResetFailedLogins(id uuid.UUID) error {
+	delete(f.failed, id)
+	delete(f.locked, id)
+	return nil
+}
+
+type fakeAudit struct {
+	entries []*models.AuditLog
+}
+
+func (f *fakeAudit) Create(entry *models.AuditLog) error {
+	f.entries = append(f.entries, entry)
+	return nil
+}
+
+func newTestThrottle() (*LoginThrottle, *fakeUsers, *fakeAudit) {
+	users := &fakeUsers{failed: map[uuid.UUID]int{}, locked: map[uuid.UUID]time.Time{}}
+	audit := &fakeAudit{}
+	return &LoginThrottle{
+		users:      users,
+		audit:      audit,
+		perIP:      ratelimit.NewSlidingWindow(loginIPLimit, loginIPWindow),
+		perAccount: ratelimit.NewSlidingWindow(1<<30, loginAccountWindow),
+		attempts:   prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_login_attempts"}, []string{"result"}),
+		lockouts:   prometheus.NewCounter(prometheus.CounterOpts{Name: "test_login_lockouts"}),
+	}, users, audit
+}
+
+func TestLoginThrottleFailure(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	expired := now.Add(-time.Minute)
+
+	tests := []struct {
+		name        string
+		prior       int        // failures already counted on the user
+		lockedUntil *time.Time // lock already on the user
+		failures    int
+		wantLocked  bool // whether the last failure locked the account
+		wantCount   int
+	}{
+		{"below the limit", 0, nil, loginLockoutFailures - 1, false, loginLockoutFailures - 1},
+		{"reaching the limit locks", 0, nil, loginLockoutFailures, true, loginLockoutFailures},
+		{"one more after earlier failures locks", loginLockoutFailures - 1, nil, 1, true, loginLockoutFailures},
+		{"expired lock starts a fresh count", loginLockoutFailures, &expired, 1, false, 1},
+		{"expired lock locks again only at the limit", loginLockoutFailures, &expired, loginLockoutFailures, true, loginLockoutFailures},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttle, users, audit := newTestThrottle()
+			user := &models.User{UserID: uuid.New(), Email: "a@example.com", FailedLoginAttempts: tt.prior, LockedUntil: tt.lockedUntil}
+			users.failed[user.UserID] = tt.prior
+			if tt.lockedUntil != nil {
+				users.locked[user.UserID] = *tt.lockedUntil
+			}
+
+			var err error
+			for i := 0; i < tt.failures; i++ {
+				err = throttle.Failure(user, user.Email, "10.0.0.1", now)
+			}
+
+			var throttled *LoginThrottledError
+			if locked := errors.As(err, &throttled) && throttled.Locked; locked != tt.wantLocked {
+				t.Fatalf("locked = %v, want %v (err %v)", locked, tt.wantLocked, err)
+			}
+			if got := users.failed[user.UserID]; got != tt.wantCount {
+				t.Errorf("failed logins = %d, want %d", got, tt.wantCount)
+			}
+			if tt.wantLocked {
+				if until := users.locked[user.UserID]; !until.Equal(now.Add(loginLockoutDuration)) {
+					t.Errorf("locked until %v, want %v", until, now.Add(loginLockoutDuration))
+				}
+				if len(audit.entries) != 1 || audit.entries[0].ActionType != models.AuditAccountLocked {
+					t.Errorf("want one %s audit entry, got %d entries", models.AuditAccountLocked, len(audit.entries))
+				}
+			}
+		})
+	}
+}
+
+func TestLoginThrottleCheckLocked(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	future := now.Add(10 * time.Minute)
+	past := now.Add(-time.Second)
+
+	tests := []struct {
+		name        string
+		lockedUntil *time.Time
+		wantErr     bool
+	}{
+		{"never locked", nil, false},
+		{"lock in force", &future, true},
+		{"lock has run out", &past, false},
+		{"lock ends now", &now, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttle, _, _ := newTestThrottle()
+			err := throttle.CheckLocked(&models.User{LockedUntil: tt.lockedUntil}, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckLocked() error = %v, want error %v", err, tt.wantErr)
+			}
+			var throttled *LoginThrottledError
+			if tt.wantErr && (!errors.As(err, &throttled) || throttled.RetryAfter != tt.lockedUntil.Sub(now)) {
+				t.Errorf("want RetryAfter %v, got %v", tt.lockedUntil.Sub(now), err)
+			}
+		})
+	}
+}
+
+func TestLoginThrottleCheck(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		failures  int           // failures recorded for the account, one second apart
+		fromIPs   int           // distinct IPs they came from
+		after     time.Duration // time since the last failure when checking
+		wantRetry time.Duration // zero when the attempt is allowed
+	}{
+		{"no failures", 0, 1, 0, 0},
+		{"failures below the delay threshold", loginDelayAfter - 1, loginDelayAfter - 1, 0, 0},
+		{"first delay", loginDelayAfter, loginDelayAfter, 0, time.Second},
+		{"delay doubles", loginDelayAfter + 2, loginDelayAfter + 2, 0, 4 * time.Second},
+		{"delay has passed", loginDelayAfter + 2, loginDelayAfter + 2, 5 * time.Second, 0},
+		{"ip over its limit", loginIPLimit, 1, time.Hour - loginIPWindow, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttle, _, _ := newTestThrottle()
+			start := now.Add(-time.Duration(tt.failures) * time.Second)
+			for i := 0; i < tt.failures; i++ {
+				ip := fmt.Sprintf("10.0.0.%d", i%tt.fromIPs)
+				throttle.Failure(nil, "a@example.com", ip, start.Add(time.Duration(i+1)*time.Second))
+			}
+
+			err := throttle.Check("A@example.com ", "10.0.0.200", now.Add(tt.after))
+			var throttled *LoginThrottledError
+			switch {
+			case tt.wantRetry == 0 && err != nil:
+				t.Fatalf("Check() = %v, want allowed", err)
+			case tt.wantRetry != 0 && (!errors.As(err, &throttled) || throttled.RetryAfter != tt.wantRetry):
+				t.Fatalf("Check() = %v, want retry after %v", err, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestLoginThrottleIPLimit(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	throttle, _, _ := newTestThrottle()
+	for i := 0; i < loginIPLimit; i++ {
+		throttle.Failure(nil, fmt.Sprintf("user%d@example.com", i), "10.0.0.1", now)
+	}
+	if err := throttle.Check("someone@example.com", "10.0.0.1", now); err == nil {
+		t.Fatal("Check() allowed an IP over its limit")
+	}
+	if err := throttle.Check("someone@example.com", "10.0.0.2", now); err != nil {
+		t.Fatalf("Check() refused another IP: %v", err)
+	}
+	if err := throttle.Check("someone@example.com", "10.0.0.1", now.Add(loginIPWindow+time.Second)); err != nil {
+		t.Fatalf("Check() still refused the IP after its window: %v", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{loginDelayAfter, time.Second},
+		{loginDelayAfter + 1, 2 * time.Second},
+		{loginDelayAfter + 4, 16 * time.Second},
+		{loginDelayAfter + 5, loginMaxDelay},
+		{loginDelayAfter + 100, loginMaxDelay},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.failures); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,497 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"go-blog/internal/importer"
+	"go-blog/internal/models"
+	"go-blog/repositories"
+	"go-blog/utils"
+	"io"
+	"log"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Password modes of a member import
+const (
+	// ImportPasswordGenerate sets a random password and returns it in the report
+	ImportPasswordGenerate = "generate"
+	// ImportPasswordInvite emails each member a link to choose a password
+	ImportPasswordInvite = "invite"
+)
+
+// Row statuses of an import report
+const (
+	ImportRowValid   = "valid" // dry run only: the row would be imported
+	ImportRowCreated = "created"
+	ImportRowFailed  = "failed"
+)
+
+const (
+	defaultImportBatch = 100
+	maxImportBatch     = 500
+)
+
+var ErrInvalidImport = errors.New("invalid import")
+
+// errDryRun rolls back a dry-run batch after every row went through
+var errDryRun = errors.New("dry run")
+
+// ImportOptions controls a member import
+type ImportOptions struct {
+	ActorID      uuid.UUID
+	GymID        *uuid.UUID // home gym of every imported user
+	DryRun       bool
+	PasswordMode string
+	BatchSize    int
+	Filename     string
+}
+
+// ImportRowResult is the outcome of one spreadsheet row
+type ImportRowResult struct {
+	Line     int        `json:"line"`
+	Email    string     `json:"email"`
+	Status   string     `json:"status"`
+	Errors   []string   `json:"errors,omitempty"`
+	UserID   *uuid.UUID `json:"user_id,omitempty"`
+	Password string     `json:"password,omitempty"` // generated passwords, shown only once
+}
+
+// ImportReport sums up an import row by row
+type ImportReport struct {
+	ID      uuid.UUID         `json:"id"`
+	DryRun  bool              `json:"dry_run"`
+	Total   int               `json:"total"`
+	Valid   int               `json:"valid"`
+	Created int               `json:"created"`
+	Failed  int               `json:"failed"`
+	Rows    []ImportRowResult `json:"rows"`
+}
+
+// importCandidate is a row that passed validation, ready to register
+type importCandidate struct {
+	result        *ImportRowResult
+	user          *models.User
+	password      string
+	planID        string
+	start, end    time.Time
+	autoRenew     bool
+	guardianEmail string
+	relationship  string
+}
+
+// MemberImportService creates members in bulk from spreadsheets exported
+// by other gym software. Rows are registered through the same code as
+// AuthService.Register, in one transaction per batch with a savepoint per
+// row, so a bad row does not take its batch down with it.
+type MemberImportService struct {
+	auth     *AuthService
+	accounts *AccountService
+	plans    *repositories.PlanRepository
+	gyms     *repositories.GymRepository
+	db       *gorm.DB
+}
+
+func NewMemberImportService(auth *AuthService, accounts *AccountService, plans *repositories.PlanRepository, gyms *repositories.GymRepository, db *gorm.DB) *MemberImportService {
+	return &MemberImportService{auth: auth, accounts: accounts, plans: plans, gyms: gyms, db: db}
+}
+
+// Import validates and registers the rows. With DryRun every row still runs
+// through registration, but nothing is committed and no email is sent.
+func (s *MemberImportService) Import(rows []importer.Row, opts ImportOptions) (*ImportReport, error) {
+	gymName, err := s.checkOptions(&opts)
+	if err != nil {
+		return nil, err
+	}
+	plans, err := s.planIndex()
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.existingEmails(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{ID: uuid.New(), DryRun: opts.DryRun, Total: len(rows), Rows: make([]ImportRowResult, len(rows))}
+	seen := make(map[string]int, len(rows))
+	var candidates []*importCandidate
+	for i, row := range rows {
+		result := &report.Rows[i]
+		*result = ImportRowResult{Line: row.Line, Email: row.Get("email")}
+		candidate := s.parseRow(row, result, plans, opts)
+
+		email := strings.ToLower(result.Email)
+		if line, dup := seen[email]; dup && email != "" {
+			result.Errors = append(result.Errors, fmt.Sprintf("email is also used on line %d", line))
+		} else if email != "" {
+			seen[email] = row.Line
+		}
+		if existing[email] {
+			result.Errors = append(result.Errors, "a user with this email already exists")
+		}
+		if len(result.Errors) > 0 {
+			result.Status = ImportRowFailed
+			continue
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	if err := s.setPasswords(candidates, opts); err != nil {
+		return nil, err
+	}
+	if opts.DryRun && len(candidates) > 0 {
+		// One transaction lets dependents find guardians listed in an
+		// earlier batch, which a real run commits before getting to them
+		opts.BatchSize = len(candidates)
+	}
+	for start := 0; start < len(candidates); start += opts.BatchSize {
+		end := start + opts.BatchSize
+		if end > len(candidates) {
+			end = len(candidates)
+		}
+		s.runBatch(report, candidates[start:end], opts)
+	}
+
+	for _, row := range report.Rows {
+		switch row.Status {
+		case ImportRowValid:
+			report.Valid++
+		case ImportRowCreated:
+			report.Created++
+		default:
+			report.Failed++
+		}
+	}
+
+	if !opts.DryRun && opts.PasswordMode == ImportPasswordInvite {
+		for _, c := range candidates {
+			if c.result.Status != ImportRowCreated {
+				continue
+			}
+			if err := s.accounts.SendInvite(c.user, gymName); err != nil {
+				log.Printf("ERROR: member import %s could not send invite to %s: %v", report.ID, c.user.Email, err)
+				c.result.Errors = append(c.result.Errors, "created, but the invite email could not be sent")
+			}
+		}
+	}
+	return report, nil
+}
+
+func (s *MemberImportService) checkOptions(opts *ImportOptions) (string, error) {
+	switch opts.PasswordMode {
+	case "":
+		opts.PasswordMode = ImportPasswordInvite
+	case ImportPasswordGenerate, ImportPasswordInvite:
+	default:
+		return "", fmt.Errorf("%w: password_mode must be %q or %q", ErrInvalidImport, ImportPasswordGenerate, ImportPasswordInvite)
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultImportBatch
+	}
+	if opts.BatchSize > maxImportBatch {
+		return "", fmt.Errorf("%w: batch_size may be at most %d", ErrInvalidImport, maxImportBatch)
+	}
+
+	gymName := "Your gym"
+	if opts.GymID != nil {
+		gym, err := s.gyms.GetByID(opts.GymID.String())
+		if err != nil {
+			return "", fmt.Errorf("%w: gym not found", ErrInvalidImport)
+		}
+		gymName = gym.Name
+	}
+	return gymName, nil
+}
+
+// planIndex maps plan IDs and lower-cased titles to plans. Titles used by
+// more than one plan map to nil, so rows must use the ID instead.
+func (s *MemberImportService) planIndex() (map[string]*models.Plan, error) {
+	plans, err := s.plans.All()
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]*models.Plan, 2*len(plans))
+	for i := range plans {
+		plan := &plans[i]
+		index[plan.ID.String()] = plan
+		title := strings.ToLower(strings.TrimSpace(plan.Title))
+		if _, taken := index[title]; taken {
+			index[title] = nil
+		} else {
+			index[title] = plan
+		}
+	}
+	return index, nil
+}
+
+func (s *MemberImportService) existingEmails(rows []importer.Row) (map[string]bool, error) {
+	emails := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if email := row.Get("email"); email != "" {
+			emails = append(emails, strings.ToLower(email))
+		}
+	}
+	existing := make(map[string]bool)
+	if len(emails) == 0 {
+		return existing, nil
+	}
+	var found []string
+	if err := s.db.Model(&models.User{}).Where("LOWER(email) IN ?", emails).Pluck("LOWER(email)", &found).Error; err != nil {
+		return nil, err
+	}
+	for _, email := range found {
+		existing[email] = true
+	}
+	return existing, nil
+}
+
+// parseRow maps the cells of a row onto a user and membership. Problems are
+// collected on the result rather than stopping at the first one.
+func (s *MemberImportService) parseRow(row importer.Row, result *ImportRowResult, plans map[string]*models.Plan, opts ImportOptions) *importCandidate {
+	c := &importCandidate{result: result}
+	fail := func(format string, args ...interface{}) {
+		result.Errors = append(result.Errors, fmt.Sprintf(format, args...))
+	}
+
+	user := &models.User{
+		FirstName:             row.Get("first_name"),
+		LastName:              row.Get("last_name"),
+		Email:                 row.Get("email"),
+		PhoneNumber:           row.Get("phone_number"),
+		Gender:                row.Get("gender"),
+		FitnessGoals:          row.Get("fitness_goals"),
+		EmergencyContactName:  row.Get("emergency_contact_name"),
+		EmergencyContactPhone: row.Get("emergency_contact_phone"),
+		MembershipType:        row.Get("membership_type"),
+		UserType:              models.RoleMember,
+		GymID:                 opts.GymID,
+	}
+	c.user = user
+
+	if user.FirstName == "" {
+		fail("first_name is required")
+	} else if len([]rune(user.FirstName)) > 50 {
+		fail("first_name is longer than 50 characters")
+	}
+	if user.LastName == "" {
+		fail("last_name is required")
+	} else if len([]rune(user.LastName)) > 50 {
+		fail("last_name is longer than 50 characters")
+	}
+	if user.Email == "" {
+		fail("email is required")
+	} else if addr, err := mail.ParseAddress(user.Email); err != nil || addr.Address != user.Email || len(user.Email) > 100 {
+		fail("email %q is not a valid address", user.Email)
+	}
+	if len(user.PhoneNumber) > 15 {
+		fail("phone_number is longer than 15 characters")
+	}
+	if len(user.EmergencyContactPhone) > 15 {
+		fail("emergency_contact_phone is longer than 15 characters")
+	}
+	if raw := row.Get("date_of_birth"); raw != "" {
+		if dob, err := parseImportDate(raw); err != nil {
+			fail("date_of_birth: %v", err)
+		} else if dob.After(time.Now()) {
+			fail("date_of_birth is in the future")
+		} else {
+			user.DateOfBirth = &dob
+		}
+	}
+
+	planRef := row.Get("plan")
+	plan, known := plans[strings.ToLower(planRef)]
+	switch {
+	case planRef == "":
+		fail("plan is required")
+	case !known:
+		fail("plan %q does not exist", planRef)
+	case plan == nil:
+		fail("plan title %q is used by several plans; use the plan ID", planRef)
+	default:
+		c.planID = plan.ID.String()
+		if user.MembershipType == "" {
+			user.MembershipType = plan.Title
+		}
+	}
+
+	c.start = time.Now()
+	if raw := row.Get("membership_start"); raw != "" {
+		if start, err := parseImportDate(raw); err != nil {
+			fail("membership_start: %v", err)
+		} else {
+			c.start = start
+		}
+	}
+	if raw := row.Get("membership_end"); raw != "" {
+		if end, err := parseImportDate(raw); err != nil {
+			fail("membership_end: %v", err)
+		} else {
+			c.end = end
+		}
+	} else if plan != nil {
+		c.end = plan.PeriodEnd(c.start)
+	}
+	if !c.end.IsZero() && !c.end.After(c.start) {
+		fail("membership_end must be after membership_start")
+	}
+
+	c.autoRenew = true
+	if raw := row.Get("auto_renew"); raw != "" {
+		if v, err := parseImportBool(raw); err != nil {
+			fail("auto_renew: %v", err)
+		} else {
+			c.autoRenew = v
+		}
+	}
+
+	c.guardianEmail = row.Get("guardian_email")
+	c.relationship = row.Get("guardian_relationship")
+	return c
+}
+
+// setPasswords gives every candidate a password: a readable one to hand
+// out, or an unusable one replaced through the invite link.
+func (s *MemberImportService) setPasswords(candidates []*importCandidate, opts ImportOptions) error {
+	for _, c := range candidates {
+		if opts.DryRun {
+			// Nothing is committed, so skip the bcrypt work
+			c.user.PasswordHash = "dry-run"
+			continue
+		}
+		n := 32
+		if opts.PasswordMode == ImportPasswordGenerate {
+			n = 9
+		}
+		password, err := utils.GenerateRandomToken(n)
+		if err != nil {
+			return err
+		}
+		hashed, err := utils.HashPassword(password)
+		if err != nil {
+			return fmt.Errorf("failed to process password")
+		}
+		c.user.PasswordHash = hashed
+		if opts.PasswordMode == ImportPasswordGenerate {
+			c.password = password
+		}
+	}
+	return nil
+}
+
+// runBatch registers a batch of candidates in one transaction, each in its
+// own savepoint.
+func (s *MemberImportService) runBatch(report *ImportReport, batch []*importCandidate, opts ImportOptions) {
+	created := 0
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for _, c := range batch {
+			err := tx.Transaction(func(sp *gorm.DB) error {
+				return s.registerRow(sp, c, opts)
+			})
+			if err != nil {
+				c.result.Status = ImportRowFailed
+				c.result.Errors = append(c.result.Errors, err.Error())
+				continue
+			}
+			c.result.Status = ImportRowCreated
+			c.result.UserID = &c.user.UserID
+			created++
+		}
+		if opts.DryRun {
+			return errDryRun
+		}
+		return tx.Create(models.NewAuditLog(opts.ActorID, models.AuditMembersImported, "member_import", report.ID, map[string]interface{}{
+			"file":    opts.Filename,
+			"rows":    len(batch),
+			"created": created,
+		})).Error
+	})
+
+	for _, c := range batch {
+		switch {
+		case errors.Is(err, errDryRun) && c.result.Status == ImportRowCreated:
+			c.result.Status = ImportRowValid
+			c.result.UserID = nil
+		case err != nil && !errors.Is(err, errDryRun) && c.result.Status == ImportRowCreated:
+			c.result.Status = ImportRowFailed
+			c.result.UserID = nil
+			c.result.Errors = append(c.result.Errors, "the batch could not be committed")
+		case c.result.Status == ImportRowCreated:
+			c.result.Password = c.password
+		}
+	}
+	if err != nil && !errors.Is(err, errDryRun) {
+		log.Printf("ERROR: member import %s failed to commit a batch: %v", report.ID, err)
+	}
+}
+
+func (s *MemberImportService) registerRow(tx *gorm.DB, c *importCandidate, opts ImportOptions) error {
+	var consent *GuardianConsent
+	if c.guardianEmail != "" {
+		var guardian models.User
+		if err := tx.Select("user_id").Where("LOWER(email) = LOWER(?)", c.guardianEmail).First(&guardian).Error; err != nil {
+			return fmt.Errorf("%w: no user with email %s; list guardians before their dependents", ErrInvalidGuardian, c.guardianEmail)
+		}
+		consent = &GuardianConsent{GuardianID: guardian.UserID, Relationship: c.relationship, ConsentedBy: opts.ActorID}
+	}
+	guardian, err := s.auth.guardianFor(tx, c.user, consent)
+	if err != nil {
+		return err
+	}
+	return s.auth.register(tx, c.user, c.planID, Signup{}, c.start, c.end, c.autoRenew, consent, guardian)
+}
+
+// WriteCSV writes the report as CSV, one line per spreadsheet row
+func (r *ImportReport) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"line", "email", "status", "user_id", "password", "errors"}); err != nil {
+		return err
+	}
+	for _, row := range r.Rows {
+		userID := ""
+		if row.UserID != nil {
+			userID = row.UserID.String()
+		}
+		record := []string{strconv.Itoa(row.Line), row.Email, row.Status, userID, row.Password, strings.Join(row.Errors, "; ")}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// importDateLayouts are the date formats accepted in spreadsheets
+// Day-first and month-first dates with slashes are ambiguous and refused.
+var importDateLayouts = []string{"2006-01-02", "2006/01/02", "02.01.2006", time.RFC3339}
+
+// parseImportDate reads a date cell. Excel dates arrive as serial numbers.
+func parseImportDate(raw string) (time.Time, error) {
+	if serial, err := strconv.ParseFloat(raw, 64); err == nil && serial > 0 && serial < 100000 {
+		// Excel counts days from 1899-12-30, leap year bug included
+		return time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(serial)), nil
+	}
+	for _, layout := range importDateLayouts {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date, use YYYY-MM-DD", raw)
+}
+
+func parseImportBool(raw string) (bool, error) {
+	switch strings.ToLower(raw) {
+	case "1", "true", "yes", "y":
+		return true, nil
+	case "0", "false", "no", "n":
+		return false, nil
+	}
+	return false, fmt.Errorf("%q is not yes or no", raw)
+}
@@ -1,15 +1,29 @@
 package services
 
 import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
 	"go-blog/internal/models"
+	"go-blog/internal/query"
 	"go-blog/repositories"
 
 	"github.com/google/uuid"
 )
 
+const (
+	minSearchTermLen     = 2
+	defaultSearchResults = 20
+	maxSearchResults     = 50
+)
+
+var ErrSearchTermTooShort = fmt.Errorf("search term must be at least %d characters", minSearchTermLen)
+
 type MemberService interface {
 	CreateMember(member *models.Member) error
-	GetAllMembers() ([]models.Member, error)
+	GetAllMembers(req *query.Request) (*query.Page[models.Member], error)
+	SearchMembers(term string, limit int) ([]repositories.MemberSearchResult, error)
 	GetMemberByID(id uuid.UUID) (*models.Member, error)
 	UpdateMember(member *models.Member) error
 	DeleteMember(id uuid.UUID) error
@@ -27,8 +41,25 @@ func (s *memberService) CreateMember(member *models.Member) error {
 	return s.repo.Create(member)
 }
 
-func (s *memberService) GetAllMembers() ([]models.Member, error) {
-	return s.repo.GetAll()
+func (s *memberService) GetAllMembers(req *query.Request) (*query.Page[models.Member], error) {
+	return s.repo.List(req)
+}
+
+// SearchMembers ranks members by how well name, email or phone match term
+func (s *memberService) SearchMembers(term string, limit int) ([]repositories.MemberSearchResult, error) {
+	term = strings.TrimSpace(term)
+	if utf8.RuneCountInString(term) < minSearchTermLen {
+		return nil, ErrSearchTermTooShort
+	}
+	if limit < 1 || limit > maxSearchResults {
+		limit = defaultSearchResults
+	}
+
+	results, err := s.repo.Search(term, limit)
+	if results == nil {
+		results = []repositories.MemberSearchResult{}
+	}
+	return results, err
 }
 
 func (s *memberService) GetMemberByID(id uuid.UUID) (*models.Member, error) {
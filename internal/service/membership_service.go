@@ -1,26 +1,756 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"go-blog/internal/models"
+	"go-blog/internal/query"
 	"go-blog/repositories"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMembershipNotFound = errors.New("membership not found")
+	ErrInvalidMembership  = errors.New("invalid membership")
+	ErrInvalidTransition  = errors.New("membership cannot change to that status")
+	ErrInvalidReason      = errors.New("unknown reason code")
+	ErrMembershipClosed   = errors.New("membership is cancelled or expired")
+	ErrNotDeletable       = errors.New("only pending memberships can be deleted; cancel it instead")
+	ErrNotChangeable      = errors.New("only active memberships can change plan")
+)
+
+const (
+	// MaxFreezeDays caps a single freeze
+	MaxFreezeDays = 180
+
+	lifecycleInterval = 15 * time.Minute
+	lifecycleBatch    = 500
 )
 
+// MembershipUpdate holds the fields staff may change directly. Status only
+// changes through Freeze, Unfreeze and Cancel.
+type MembershipUpdate struct {
+	AutoRenew       *bool
+	PaymentMethodID *string
+	EndDate         *time.Time
+}
+
+// MembershipService moves memberships through their lifecycle:
+// pending → active → frozen → active, and on to cancelled or expired.
+// Renewals whose payment is late are past due until paid or cancelled.
 type MembershipService struct {
-	repo *repositories.MembershipRepository
+	repo       *repositories.MembershipRepository
+	payments   *repositories.PaymentRepository
+	households *repositories.HouseholdRepository
+	db         *gorm.DB
 }
 
-func NewMembershipService(repo *repositories.MembershipRepository) *MembershipService {
-	return &MembershipService{repo: repo}
+func NewMembershipService(repo *repositories.MembershipRepository, payments *repositories.PaymentRepository, households *repositories.HouseholdRepository, db *gorm.DB) *MembershipService {
+	return &MembershipService{repo: repo, payments: payments, households: households, db: db}
 }
 
-func (s *MembershipService) CreateMembership(m *models.Membership) error {
-    // Ensure you create the UUID on the model if you are not relying on the database default
-    // If your GORM model has `gorm:"default:gen_random_uuid()"` and you are using postgres, 
-    // you can rely on the database. If not, consider setting it here:
-    // m.ID = uuid.New()
-	return s.repo.Create(m)
+// CreateMembership adds a membership. Its status follows from the start date
+// unless pending or active is asked for explicitly.
+func (s *MembershipService) CreateMembership(actorID uuid.UUID, m *models.Membership) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return s.create(tx, actorID, m)
+	})
 }
 
-func (s *MembershipService) GetByMember(memberID string) ([]models.Membership, error) {
-	return s.repo.GetByMember(memberID)
-}
\ No newline at end of file
+// create adds a membership inside tx. A membership without an end date runs
+// for one period of its plan.
+func (s *MembershipService) create(tx *gorm.DB, actorID uuid.UUID, m *models.Membership) error {
+	switch m.Status {
+	case "":
+		m.Status = models.InitialMembershipStatus(m.StartDate, time.Now())
+	case models.MembershipPending, models.MembershipActive:
+	default:
+		return fmt.Errorf("%w: new memberships are pending or active", ErrInvalidMembership)
+	}
+
+	var plan models.Plan
+	if err := tx.First(&plan, "id = ?", m.PlanID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: plan does not exist", ErrInvalidMembership)
+		}
+		return err
+	}
+	if plan.ArchivedAt != nil {
+		return ErrPlanArchived
+	}
+	m.PlanVersion = plan.Version
+	if m.EndDate.IsZero() {
+		m.EndDate = plan.PeriodEnd(m.StartDate)
+	}
+	if !m.EndDate.After(m.StartDate) {
+		return fmt.Errorf("%w: end_date must be after start_date", ErrInvalidMembership)
+	}
+
+	if err := s.repo.WithTx(tx).Create(m); err != nil {
+		return err
+	}
+	return tx.Create(models.NewAuditLog(actorID, models.AuditMembershipCreated, "membership", m.ID, map[string]interface{}{
+		"member_id":    m.MemberID,
+		"plan_id":      m.PlanID,
+		"plan_version": m.PlanVersion,
+		"status":       m.Status,
+	})).Error
+}
+
+func (s *MembershipService) GetByMember(memberID string, req *query.Request) (*query.Page[models.Membership], error) {
+	return s.repo.ListByMember(memberID, req)
+}
+
+// Get returns a membership with its plan
+func (s *MembershipService) Get(id uuid.UUID) (*models.Membership, error) {
+	m, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, ErrMembershipNotFound
+	}
+	return m, nil
+}
+
+// Update changes the renewal settings or end date of an open membership
+func (s *MembershipService) Update(actorID, id uuid.UUID, input MembershipUpdate) (*models.Membership, error) {
+	err := s.change(id, func(tx *gorm.DB, m *models.Membership) error {
+		if isFinal(m.Status) {
+			return ErrMembershipClosed
+		}
+		fields := map[string]interface{}{}
+		if input.AutoRenew != nil {
+			m.AutoRenew = *input.AutoRenew
+			fields["auto_renew"] = m.AutoRenew
+		}
+		if input.PaymentMethodID != nil {
+			m.PaymentMethodID = input.PaymentMethodID
+			fields["payment_method_id"] = *m.PaymentMethodID
+		}
+		if input.EndDate != nil {
+			if !input.EndDate.After(m.StartDate) {
+				return fmt.Errorf("%w: end_date must be after start_date", ErrInvalidMembership)
+			}
+			fields["end_date"] = map[string]time.Time{"from": m.EndDate, "to": *input.EndDate}
+			m.EndDate = *input.EndDate
+		}
+		if err := s.repo.WithTx(tx).Update(m); err != nil {
+			return err
+		}
+		if err := tx.Create(models.NewAuditLog(actorID, models.AuditMembershipUpdated, "membership", m.ID, fields)).Error; err != nil {
+			return err
+		}
+		if !m.AutoRenew {
+			return s.dropRenewal(tx, actorID, m, models.ReasonStaffDecision)
+		}
+		return s.realignRenewal(tx, m)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(id)
+}
+
+// Delete removes a membership that has not started yet. Anything else keeps
+// its history and has to be cancelled.
+func (s *MembershipService) Delete(actorID, id uuid.UUID) error {
+	return s.change(id, func(tx *gorm.DB, m *models.Membership) error {
+		if m.Status != models.MembershipPending {
+			return ErrNotDeletable
+		}
+		if err := voidPendingPayments(tx, m.ID); err != nil {
+			return err
+		}
+		if err := s.repo.WithTx(tx).Delete(m.ID.String()); err != nil {
+			return err
+		}
+		return tx.Create(models.NewAuditLog(actorID, models.AuditMembershipDeleted, "membership", m.ID, map[string]interface{}{
+			"member_id": m.MemberID,
+			"plan_id":   m.PlanID,
+		})).Error
+	})
+}
+
+// Freeze pauses an active membership for a number of days and pushes its
+// end date back by as much.
+func (s *MembershipService) Freeze(actorID, id uuid.UUID, days int, reason string) (*models.Membership, error) {
+	if days < 1 || days > MaxFreezeDays {
+		return nil, fmt.Errorf("%w: a freeze lasts 1 to %d days", ErrInvalidMembership, MaxFreezeDays)
+	}
+	if !models.MembershipReasons[reason] {
+		return nil, ErrInvalidReason
+	}
+	err := s.change(id, func(tx *gorm.DB, m *models.Membership) error {
+		until := time.Now().AddDate(0, 0, days)
+		m.FrozenUntil = &until
+		m.EndDate = m.EndDate.AddDate(0, 0, days)
+		if m.CancelAt != nil {
+			m.CancelAt = &m.EndDate
+		}
+		err := s.transition(tx, actorID, m, models.MembershipFrozen, reason, map[string]interface{}{
+			"days":         days,
+			"frozen_until": until,
+			"end_date":     m.EndDate,
+		})
+		if err != nil {
+			return err
+		}
+		return s.realignRenewal(tx, m)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(id)
+}
+
+// Unfreeze ends a freeze early. The days not used are taken back off the
+// end date.
+func (s *MembershipService) Unfreeze(actorID, id uuid.UUID, reason string) (*models.Membership, error) {
+	if !models.MembershipReasons[reason] {
+		return nil, ErrInvalidReason
+	}
+	err := s.change(id, func(tx *gorm.DB, m *models.Membership) error {
+		return s.unfreeze(tx, actorID, m, reason, time.Now())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(id)
+}
+
+// Cancel ends a membership now, or at the end of its current period when
+// atPeriodEnd is set. Either way it will not renew.
+func (s *MembershipService) Cancel(actorID, id uuid.UUID, atPeriodEnd bool, reason string) (*models.Membership, error) {
+	if !models.MembershipReasons[reason] {
+		return nil, ErrInvalidReason
+	}
+	err := s.change(id, func(tx *gorm.DB, m *models.Membership) error {
+		if !models.CanTransition(m.Status, models.MembershipCancelled) {
+			return ErrInvalidTransition
+		}
+		m.AutoRenew = false
+
+		if atPeriodEnd && m.Status != models.MembershipPending {
+			m.CancelAt = &m.EndDate
+			m.StatusReason = reason
+			if err := s.repo.WithTx(tx).Update(m); err != nil {
+				return err
+			}
+			err := tx.Create(models.NewAuditLog(actorID, models.AuditMembershipCancelScheduled, "membership", m.ID, map[string]interface{}{
+				"reason":    reason,
+				"cancel_at": m.EndDate,
+			})).Error
+			if err != nil {
+				return err
+			}
+			return s.dropRenewal(tx, actorID, m, reason)
+		}
+
+		now := time.Now()
+		if m.Status != models.MembershipPending && m.EndDate.After(now) {
+			m.EndDate = now
+		}
+		m.CancelAt = nil
+		m.FrozenUntil = nil
+		m.GraceUntil = nil
+		err := s.transition(tx, actorID, m, models.MembershipCancelled, reason, map[string]interface{}{
+			"end_date": m.EndDate,
+		})
+		if err != nil {
+			return err
+		}
+		if err := voidPendingPayments(tx, m.ID); err != nil {
+			return err
+		}
+		return s.dropRenewal(tx, actorID, m, reason)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(id)
+}
+
+// PlanChangeQuote is what moving a membership to another plan costs. The
+// unused days of the current period are credited from what was charged for
+// it, the price it was sold at less any promo discount. A promo code
+// discounts the new plan's price, and account credit pays what it can of the
+// rest.
+type PlanChangeQuote struct {
+	MembershipID       uuid.UUID `json:"membership_id"`
+	CurrentPlanID      uuid.UUID `json:"current_plan_id"`
+	NewPlanID          uuid.UUID `json:"new_plan_id"`
+	PaidCents          int       `json:"paid_cents"`
+	PeriodDays         int       `json:"period_days"`
+	UnusedDays         int       `json:"unused_days"`
+	CreditCents        int       `json:"credit_cents"`
+	PriceCents         int       `json:"price_cents"`
+	PromoCode          string    `json:"promo_code,omitempty"`
+	DiscountCents      int       `json:"discount_cents"`
+	AccountCreditCents int       `json:"account_credit_cents"`
+	AmountDueCents     int       `json:"amount_due_cents"` // negative when the member is owed a refund
+	StartDate          time.Time `json:"start_date"`
+	EndDate            time.Time `json:"end_date"`
+}
+
+// PlanChange is a completed plan change: the new membership and the
+// payment settling the difference
+type PlanChange struct {
+	Quote      PlanChangeQuote    `json:"quote"`
+	Membership *models.Membership `json:"membership"`
+	Payment    *models.Payment    `json:"payment"`
+}
+
+// PreviewPlanChange quotes moving a membership to another plan now, with an
+// optional promo code, without changing anything
+func (s *MembershipService) PreviewPlanChange(id, planID uuid.UUID, promoCode string) (*PlanChangeQuote, error) {
+	m, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, ErrMembershipNotFound
+	}
+	now := time.Now()
+	var promo *models.PromoCode
+	if promoCode != "" {
+		if promo, err = findPromo(s.db, promoCode, &m.MemberID, planID, now, false); err != nil {
+			if !isDiscountError(err) {
+				log.Printf("ERROR: MembershipService failed to look up promo code %s: %v", promoCode, err)
+			}
+			return nil, err
+		}
+	}
+	quote, _, err := s.quotePlanChange(s.db, m, planID, promo, now)
+	if err != nil {
+		if !isMembershipError(err) {
+			log.Printf("ERROR: MembershipService failed to quote plan change for membership %s: %v", id, err)
+		}
+		return nil, err
+	}
+	return quote, nil
+}
+
+// ChangePlan moves an active membership to another plan. The current
+// membership ends now, a new one on the other plan starts in its place, and
+// a payment for the amount due, or a negative one for the refund owed, is
+// raised against it. A pending renewal of the old membership is dropped.
+// A promo code, if given, is redeemed on the new membership.
+func (s *MembershipService) ChangePlan(actorID, id, planID uuid.UUID, promoCode string) (*PlanChange, error) {
+	var result PlanChange
+	err := s.change(id, func(tx *gorm.DB, m *models.Membership) error {
+		now := time.Now()
+		var promo *models.PromoCode
+		if promoCode != "" {
+			var err error
+			if promo, err = claimPromo(tx, promoCode, m.MemberID, planID, now); err != nil {
+				return err
+			}
+		}
+		quote, plan, err := s.quotePlanChange(tx, m, planID, promo, now)
+		if err != nil {
+			return err
+		}
+
+		next := &models.Membership{
+			MemberID:        m.MemberID,
+			PlanID:          plan.ID,
+			PlanVersion:     plan.Version,
+			StartDate:       quote.StartDate,
+			EndDate:         quote.EndDate,
+			Status:          models.MembershipActive,
+			AutoRenew:       m.AutoRenew && m.CancelAt == nil,
+			PaymentMethodID: m.PaymentMethodID,
+			ChangedFromID:   &m.ID,
+		}
+		if promo != nil {
+			next.PromoCodeID = &promo.ID
+		}
+		if err := s.repo.WithTx(tx).Create(next); err != nil {
+			return err
+		}
+
+		payment := &models.Payment{
+			MemberID:     m.MemberID,
+			AmountCents:  quote.PriceCents - quote.CreditCents,
+			Method:       models.PaymentMethodPlanChange,
+			Status:       models.PaymentPending,
+			MembershipID: &next.ID,
+		}
+		if err := discountPayment(tx, payment, promo, quote.PriceCents); err != nil {
+			return err
+		}
+		// The balance may have moved since the quote read it
+		quote.AccountCreditCents = payment.CreditCents
+		quote.AmountDueCents = payment.AmountCents
+		if payment.AmountCents == 0 {
+			payment.Status = models.PaymentPaid
+		}
+		if m.PaymentMethodID != nil {
+			payment.Reference = *m.PaymentMethodID
+		}
+		if payer, err := s.households.WithTx(tx).PrimaryPayerOfMember(m.MemberID); err == nil {
+			payment.PayerID = &payer
+		}
+		if err := s.payments.WithTx(tx).Create(payment); err != nil {
+			return err
+		}
+		if err := recordDiscounts(tx, payment, promo, false); err != nil {
+			return err
+		}
+
+		if err := voidPendingPayments(tx, m.ID); err != nil {
+			return err
+		}
+		if err := s.dropRenewal(tx, actorID, m, models.ReasonPlanChanged); err != nil {
+			return err
+		}
+		m.EndDate = now
+		m.AutoRenew = false
+		m.CancelAt = nil
+		err = s.transition(tx, actorID, m, models.MembershipCancelled, models.ReasonPlanChanged, map[string]interface{}{
+			"end_date":   m.EndDate,
+			"changed_to": next.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = tx.Create(models.NewAuditLog(actorID, models.AuditMembershipPlanChanged, "membership", next.ID, map[string]interface{}{
+			"changed_from":     m.ID,
+			"from_plan_id":     quote.CurrentPlanID,
+			"to_plan_id":       quote.NewPlanID,
+			"credit_cents":     quote.CreditCents,
+			"discount_cents":   quote.DiscountCents,
+			"amount_due_cents": quote.AmountDueCents,
+			"payment_id":       payment.ID,
+		})).Error
+		if err != nil {
+			return err
+		}
+		result = PlanChange{Quote: *quote, Membership: next, Payment: payment}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Membership, err = s.repo.GetByID(result.Membership.ID); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// quotePlanChange prices moving m to another plan at now. Credit covers the
+// whole days left in the current period; promo is nil without a promo code.
+func (s *MembershipService) quotePlanChange(tx *gorm.DB, m *models.Membership, planID uuid.UUID, promo *models.PromoCode, now time.Time) (*PlanChangeQuote, *models.Plan, error) {
+	if m.Status != models.MembershipActive {
+		return nil, nil, ErrNotChangeable
+	}
+	if planID == m.PlanID {
+		return nil, nil, fmt.Errorf("%w: membership is already on this plan", ErrInvalidMembership)
+	}
+	var plan models.Plan
+	if err := tx.First(&plan, "id = ?", planID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, fmt.Errorf("%w: plan does not exist", ErrInvalidMembership)
+		}
+		return nil, nil, err
+	}
+	if plan.ArchivedAt != nil {
+		return nil, nil, ErrPlanArchived
+	}
+	var version models.PlanVersion
+	if err := tx.First(&version, "plan_id = ? AND version = ?", m.PlanID, m.PlanVersion).Error; err != nil {
+		return nil, nil, err
+	}
+	discount, err := s.payments.WithTx(tx).DiscountFor(m.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	paid := max(version.PriceCents-discount, 0)
+
+	periodDays := max(wholeDays(m.StartDate, m.EndDate), 1)
+	balance, err := repositories.NewReferralRepository(tx).Balance(m.MemberID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	quote := &PlanChangeQuote{
+		MembershipID:  m.ID,
+		CurrentPlanID: m.PlanID,
+		NewPlanID:     plan.ID,
+		PaidCents:     paid,
+		PeriodDays:    periodDays,
+		UnusedDays:    min(max(wholeDays(now, m.EndDate), 0), periodDays),
+		PriceCents:    plan.PriceCents,
+		StartDate:     now,
+		EndDate:       plan.PeriodEnd(now),
+	}
+	quote.price(promo, balance)
+	return quote, &plan, nil
+}
+
+// price works out the credit and the amount due of a quote whose period,
+// paid amount and new price are set: the prorated credit and the promo
+// discount come off the new price, then account credit up to balanceCents
+// pays what it can of the rest
+func (q *PlanChangeQuote) price(promo *models.PromoCode, balanceCents int) {
+	q.CreditCents = prorate(q.PaidCents, q.UnusedDays, q.PeriodDays)
+	q.AmountDueCents = q.PriceCents - q.CreditCents
+	if promo != nil {
+		q.PromoCode = promo.Code
+		q.DiscountCents = promo.Discount(q.PriceCents)
+		q.AmountDueCents -= q.DiscountCents
+	}
+	if q.AmountDueCents > 0 && balanceCents > 0 {
+		q.AccountCreditCents = min(balanceCents, q.AmountDueCents)
+		q.AmountDueCents -= q.AccountCreditCents
+	}
+}
+
+// prorate is the share of paidCents that unusedDays of a periodDays period
+// are worth, never more than paidCents
+func prorate(paidCents, unusedDays, periodDays int) int {
+	if paidCents <= 0 || unusedDays <= 0 || periodDays <= 0 {
+		return 0
+	}
+	return min(paidCents*unusedDays/periodDays, paidCents)
+}
+
+func wholeDays(from, to time.Time) int {
+	return int(to.Sub(from).Hours() / 24)
+}
+
+// RunLifecycle applies date-driven status changes until ctx is cancelled:
+// pending memberships start, freezes end, and memberships past their
+// cancellation or end date close.
+func (s *MembershipService) RunLifecycle(ctx context.Context) error {
+	ticker := time.NewTicker(lifecycleInterval)
+	defer ticker.Stop()
+	for {
+		if n, err := s.ApplyDue(time.Now()); err != nil {
+			log.Printf("ERROR: MembershipService lifecycle run failed after %d changes: %v", n, err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// ApplyDue makes the status changes that are due at now and returns how many
+// memberships changed status. A membership that fails is logged and left
+// for the next run; the others still change.
+func (s *MembershipService) ApplyDue(now time.Time) (int, error) {
+	ids, err := s.repo.DueForTransition(now, lifecycleBatch)
+	if err != nil {
+		return 0, err
+	}
+	changed := 0
+	for _, id := range ids {
+		var from, to string
+		err := s.change(id, func(tx *gorm.DB, m *models.Membership) error {
+			from = m.Status
+			defer func() { to = m.Status }()
+			// The member is the actor of changes nobody asked for
+			actorID := m.Member.UserID
+			switch {
+			case m.Status == models.MembershipPending && !m.StartDate.After(now):
+				// Renewals only start once their payment is in
+				if m.RenewedFromID != nil {
+					paid, err := s.repo.WithTx(tx).IsPaid(m.ID)
+					if err != nil {
+						return err
+					}
+					if !paid {
+						grace := m.StartDate.Add(RenewalGracePeriod)
+						m.GraceUntil = &grace
+						return s.automatic(tx, actorID, m, models.MembershipPastDue, models.ReasonPaymentOverdue)
+					}
+				}
+				return s.automatic(tx, actorID, m, models.MembershipActive, models.ReasonStarted)
+			case m.Status == models.MembershipFrozen && m.FrozenUntil != nil && !m.FrozenUntil.After(now):
+				return s.unfreeze(tx, actorID, m, models.ReasonFreezeEnded, now)
+			case m.Status == models.MembershipActive && m.CancelAt != nil && !m.CancelAt.After(now):
+				m.CancelAt = nil
+				return s.automatic(tx, actorID, m, models.MembershipCancelled, models.ReasonPeriodEnded)
+			case m.Status == models.MembershipActive && m.EndDate.Before(now):
+				return s.automatic(tx, actorID, m, models.MembershipExpired, models.ReasonPeriodEnded)
+			case m.Status == models.MembershipPastDue && m.GraceUntil != nil && !m.GraceUntil.After(now):
+				m.AutoRenew = false
+				m.GraceUntil = nil
+				if err := voidPendingPayments(tx, m.ID); err != nil {
+					return err
+				}
+				return s.automatic(tx, actorID, m, models.MembershipCancelled, models.ReasonNonPayment)
+			case m.Status == models.MembershipPastDue && m.EndDate.Before(now):
+				return s.automatic(tx, actorID, m, models.MembershipExpired, models.ReasonPeriodEnded)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("ERROR: MembershipService failed to apply due status change to membership %s: %v", id, err)
+			continue
+		}
+		if to != from {
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+// change runs fn on a locked membership inside a transaction
+func (s *MembershipService) change(id uuid.UUID, fn func(tx *gorm.DB, m *models.Membership) error) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		m, err := s.repo.WithTx(tx).Lock(id)
+		if err != nil {
+			return ErrMembershipNotFound
+		}
+		return fn(tx, m)
+	})
+	if err != nil && !isMembershipError(err) {
+		log.Printf("ERROR: MembershipService failed to change membership %s: %v", id, err)
+	}
+	return err
+}
+
+func (s *MembershipService) unfreeze(tx *gorm.DB, actorID uuid.UUID, m *models.Membership, reason string, now time.Time) error {
+	if m.Status != models.MembershipFrozen || m.FrozenUntil == nil {
+		return ErrInvalidTransition
+	}
+	unused := 0
+	if m.FrozenUntil.After(now) {
+		unused = int(m.FrozenUntil.Sub(now).Hours() / 24)
+	}
+	m.EndDate = m.EndDate.AddDate(0, 0, -unused)
+	if m.CancelAt != nil {
+		m.CancelAt = &m.EndDate
+	}
+	m.FrozenUntil = nil
+	err := s.transition(tx, actorID, m, models.MembershipActive, reason, map[string]interface{}{
+		"unused_days": unused,
+		"end_date":    m.EndDate,
+		"automatic":   reason == models.ReasonFreezeEnded,
+	})
+	if err != nil {
+		return err
+	}
+	return s.realignRenewal(tx, m)
+}
+
+// settle applies the outcome of a payment to the membership it pays for:
+// a past due membership becomes active when paid, and an active one falls
+// past due with a grace period when its payment fails.
+func (s *MembershipService) settle(tx *gorm.DB, actorID, id uuid.UUID, status string) error {
+	m, err := s.repo.WithTx(tx).Lock(id)
+	if err != nil {
+		return ErrMembershipNotFound
+	}
+	switch {
+	case status == models.PaymentPaid && m.Status == models.MembershipPastDue:
+		m.GraceUntil = nil
+		return s.transition(tx, actorID, m, models.MembershipActive, models.ReasonPaymentReceived, nil)
+	case status == models.PaymentFailed && m.Status == models.MembershipActive:
+		grace := time.Now().Add(RenewalGracePeriod)
+		m.GraceUntil = &grace
+		return s.transition(tx, actorID, m, models.MembershipPastDue, models.ReasonPaymentFailed, map[string]interface{}{
+			"grace_until": grace,
+		})
+	}
+	return nil
+}
+
+// extend adds free days to the active membership of a member that ends
+// last, pushing its renewal back by as much. It returns
+// gorm.ErrRecordNotFound when the member has no active membership.
+func (s *MembershipService) extend(tx *gorm.DB, memberID uuid.UUID, days int) (*models.Membership, error) {
+	m, err := s.repo.WithTx(tx).LockCurrent(memberID)
+	if err != nil {
+		return nil, err
+	}
+	m.EndDate = m.EndDate.AddDate(0, 0, days)
+	if m.CancelAt != nil {
+		m.CancelAt = &m.EndDate
+	}
+	if err := s.repo.WithTx(tx).Update(m); err != nil {
+		return nil, err
+	}
+	return m, s.realignRenewal(tx, m)
+}
+
+// realignRenewal moves a renewal that has not started to follow the new end
+// date of the membership it renews.
+func (s *MembershipService) realignRenewal(tx *gorm.DB, m *models.Membership) error {
+	renewal, err := s.repo.WithTx(tx).RenewalOf(m.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if renewal.Status != models.MembershipPending || renewal.StartDate.Equal(m.EndDate) {
+		return nil
+	}
+	renewal.StartDate = m.EndDate
+	renewal.EndDate = renewal.Plan.PeriodEnd(m.EndDate)
+	return s.repo.WithTx(tx).Update(renewal)
+}
+
+// dropRenewal cancels a renewal that has not started and voids its payment
+func (s *MembershipService) dropRenewal(tx *gorm.DB, actorID uuid.UUID, m *models.Membership, reason string) error {
+	renewal, err := s.repo.WithTx(tx).RenewalOf(m.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if renewal.Status != models.MembershipPending {
+		return nil
+	}
+	renewal.AutoRenew = false
+	if err := voidPendingPayments(tx, renewal.ID); err != nil {
+		return err
+	}
+	return s.transition(tx, actorID, renewal, models.MembershipCancelled, reason, map[string]interface{}{
+		"renewal_of": m.ID,
+	})
+}
+
+func (s *MembershipService) automatic(tx *gorm.DB, actorID uuid.UUID, m *models.Membership, to, reason string) error {
+	return s.transition(tx, actorID, m, to, reason, map[string]interface{}{"automatic": true})
+}
+
+// transition validates and saves a status change and records it in the
+// audit log.
+func (s *MembershipService) transition(tx *gorm.DB, actorID uuid.UUID, m *models.Membership, to, reason string, metadata map[string]interface{}) error {
+	from := m.Status
+	if !models.CanTransition(from, to) {
+		return ErrInvalidTransition
+	}
+	m.Status = to
+	m.StatusReason = reason
+	if err := s.repo.WithTx(tx).Update(m); err != nil {
+		return err
+	}
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	metadata["from"] = from
+	metadata["to"] = to
+	metadata["reason"] = reason
+	return tx.Create(models.NewAuditLog(actorID, models.AuditMembershipStatusChanged, "membership", m.ID, metadata)).Error
+}
+
+func isFinal(status string) bool {
+	return status == models.MembershipCancelled || status == models.MembershipExpired
+}
+
+func isMembershipError(err error) bool {
+	for _, target := range []error{ErrMembershipNotFound, ErrInvalidMembership, ErrInvalidTransition, ErrMembershipClosed, ErrNotDeletable, ErrNotChangeable, ErrPlanArchived, ErrPromoNotFound, ErrPromoNotUsable} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
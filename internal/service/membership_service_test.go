@@ -0,0 +1,82 @@
+package services
+
+import (
+	"testing"
+
+	"go-blog/internal/models"
+)
+
+func TestProrate(t *testing.T) {
+	tests := []struct {
+		name       string
+		paid       int
+		unusedDays int
+		periodDays int
+		want       int
+	}{
+		{"half the period unused", 3000, 15, 30, 1500},
+		{"rounds down", 1000, 1, 3, 333},
+		{"whole period unused", 3000, 30, 30, 3000},
+		{"never more than was paid", 3000, 31, 30, 3000},
+		{"nothing paid", 0, 15, 30, 0},
+		{"fully discounted period", -500, 15, 30, 0},
+		{"no unused days", 3000, 0, 30, 0},
+		{"empty period", 3000, 15, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := prorate(tt.paid, tt.unusedDays, tt.periodDays); got != tt.want {
+				t.Errorf("prorate(%d, %d, %d) = %d, want %d", tt.paid, tt.unusedDays, tt.periodDays, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanChangeQuotePrice(t *testing.T) {
+	percent := func(off int) *models.PromoCode {
+		return &models.PromoCode{Code: "SAVE", Kind: models.PromoPercent, PercentOff: off}
+	}
+	fixed := func(cents int) *models.PromoCode {
+		return &models.PromoCode{Code: "SAVE", Kind: models.PromoFixed, AmountOffCents: cents}
+	}
+
+	tests := []struct {
+		name         string
+		paid         int // charged for the current period, after its discount
+		unusedDays   int
+		price        int // of the new plan
+		promo        *models.PromoCode
+		balance      int
+		wantCredit   int
+		wantDiscount int
+		wantAccount  int
+		wantDue      int
+	}{
+		{"upgrade", 3000, 15, 5000, nil, 0, 1500, 0, 0, 3500},
+		{"downgrade is owed a refund", 3000, 30, 1000, nil, 0, 3000, 0, 0, -2000},
+		{"credit from a discounted period", 1500, 15, 5000, nil, 0, 750, 0, 0, 4250},
+		{"no credit from a free period", 0, 30, 5000, nil, 0, 0, 0, 0, 5000},
+		{"percent promo on the new price", 3000, 15, 5000, percent(10), 0, 1500, 500, 0, 3000},
+		{"full promo takes the whole price", 0, 15, 5000, percent(100), 0, 0, 5000, 0, 0},
+		{"fixed promo capped at the price", 0, 15, 2000, fixed(3000), 0, 0, 2000, 0, 0},
+		{"account credit pays part", 3000, 15, 5000, nil, 1000, 1500, 0, 1000, 2500},
+		{"account credit pays all", 3000, 15, 5000, nil, 9000, 1500, 0, 3500, 0},
+		{"account credit unused on a refund", 3000, 30, 1000, nil, 9000, 3000, 0, 0, -2000},
+		{"promo then account credit", 3000, 15, 5000, percent(50), 1000, 1500, 2500, 1000, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &PlanChangeQuote{PaidCents: tt.paid, PeriodDays: 30, UnusedDays: tt.unusedDays, PriceCents: tt.price}
+			q.price(tt.promo, tt.balance)
+			if q.CreditCents != tt.wantCredit || q.DiscountCents != tt.wantDiscount ||
+				q.AccountCreditCents != tt.wantAccount || q.AmountDueCents != tt.wantDue {
+				t.Errorf("credit %d, discount %d, account credit %d, due %d; want %d, %d, %d, %d",
+					q.CreditCents, q.DiscountCents, q.AccountCreditCents, q.AmountDueCents,
+					tt.wantCredit, tt.wantDiscount, tt.wantAccount, tt.wantDue)
+			}
+			if tt.promo != nil && q.PromoCode != tt.promo.Code {
+				t.Errorf("PromoCode = %q, want %q", q.PromoCode, tt.promo.Code)
+			}
+		})
+	}
+}
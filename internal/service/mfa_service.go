@@ -0,0 +1,368 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"go-blog/internal/config"
+	"go-blog/internal/models"
+	"go-blog/repositories"
+	"go-blog/utils"
+	"image/png"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	mfaChallengeTTL     = 5 * time.Minute
+	mfaEnrollmentTTL    = 10 * time.Minute
+	totpPeriod          = 30
+	recoveryCodeCount   = 10
+	recoveryCodeByteLen = 7 // 56 bits, rendered as 12 base32 characters
+)
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnrolled    = errors.New("start two-factor enrollment first")
+	ErrMFANotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrMFARequired       = errors.New("two-factor authentication is required for your account")
+	ErrInvalidMFACode    = errors.New("invalid authentication code")
+	ErrInvalidMFAToken   = errors.New("invalid or expired MFA token")
+)
+
+// MFAEnrollment is what a user needs to add the account to an authenticator app.
+type MFAEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURL string `json:"otpauth_url"`
+}
+
+// MFAService implements TOTP (RFC 6238) two-factor authentication with
+// single-use recovery codes.
+type MFAService struct {
+	users         repositories.UserRepository
+	recoveryCodes *repositories.RecoveryCodeRepository
+	audit         *repositories.AuditLogRepository
+	throttle      *LoginThrottle
+	auth          *AuthService
+	revocations   *TokenRevocationService
+	issuer        string
+}
+
+func NewMFAService(users repositories.UserRepository, recoveryCodes *repositories.RecoveryCodeRepository, audit *repositories.AuditLogRepository, throttle *LoginThrottle, auth *AuthService, revocations *TokenRevocationService) *MFAService {
+	issuer := os.Getenv("MFA_ISSUER")
+	if issuer == "" {
+		issuer = "Gym"
+	}
+	return &MFAService{users: users, recoveryCodes: recoveryCodes, audit: audit, throttle: throttle, auth: auth, revocations: revocations, issuer: issuer}
+}
+
+// EnrollmentRequired reports whether user must set up 2FA before logging in.
+func (s *MFAService) EnrollmentRequired(user *models.User) bool {
+	return !user.TOTPEnabled && s.required(user)
+}
+
+func (s *MFAService) required(user *models.User) bool {
+	return models.NormalizeRole(user.UserType) != models.RoleMember && s.auth.gymSettings(user).RequireStaff2FA
+}
+
+// IssueChallenge returns the short-lived token that /auth/login/mfa exchanges
+// for real tokens once the code is verified.
+func (s *MFAService) IssueChallenge(user *models.User) (string, error) {
+	return config.GenerateJWT(user.UserID.String(), user.UserType, config.TokenTypeMFA, mfaChallengeTTL)
+}
+
+// IssueEnrollmentToken returns a token that only allows 2FA enrollment.
+func (s *MFAService) IssueEnrollmentToken(user *models.User) (string, error) {
+	return config.GenerateJWT(user.UserID.String(), user.UserType, config.TokenTypeMFAEnroll, mfaEnrollmentTTL)
+}
+
+// StartEnrollment generates a new secret for the user. 2FA stays disabled
+// until ConfirmEnrollment sees a valid code.
+func (s *MFAService) StartEnrollment(userID uuid.UUID) (*MFAEnrollment, error) {
+	user, err := s.users.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if user.TOTPEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: s.issuer, AccountName: user.Email, Period: totpPeriod})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.users.UpdateFields(userID, map[string]interface{}{"totp_secret": key.Secret(), "totp_last_step": 0}); err != nil {
+		return nil, err
+	}
+
+	return &MFAEnrollment{Secret: key.Secret(), OTPAuthURL: key.URL()}, nil
+}
+
+// QRCode renders the provisioning URI of a pending enrollment as a PNG.
+// Once 2FA is enabled the secret is never shown again.
+func (s *MFAService) QRCode(userID uuid.UUID, size int) ([]byte, error) {
+	user, err := s.users.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if user.TOTPEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrMFANotEnrolled
+	}
+
+	key, err := s.provisioningKey(user)
+	if err != nil {
+		return nil, err
+	}
+	img, err := key.Image(size, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ConfirmEnrollment enables 2FA once the user proves their app produces
+// valid codes, and returns a fresh set of recovery codes.
+func (s *MFAService) ConfirmEnrollment(userID uuid.UUID, code string) ([]string, error) {
+	user, err := s.users.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if user.TOTPEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrMFANotEnrolled
+	}
+	if err := s.verifyTOTP(user, code); err != nil {
+		return nil, err
+	}
+
+	if err := s.users.UpdateFields(userID, map[string]interface{}{"totp_enabled": true}); err != nil {
+		return nil, err
+	}
+	codes, err := s.RegenerateRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordAudit(userID, models.AuditMFAEnabled, nil)
+	return codes, nil
+}
+
+// VerifyChallenge completes a two-step login. Exactly one of code and
+// recoveryCode is expected. Wrong codes count as failed logins. A challenge
+// is used up once it has been verified.
+func (s *MFAService) VerifyChallenge(mfaToken, code, recoveryCode, ip string) (*models.User, error) {
+	claims, err := config.ParseJWT(mfaToken, config.TokenTypeMFA)
+	if err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+	userID, err := uuid.Parse(fmt.Sprint(claims["user_id"]))
+	if err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+	jti, _ := claims["jti"].(string)
+	issuedAt, err := claims.GetIssuedAt()
+	if err != nil || issuedAt == nil || jti == "" || s.revocations.IsRevoked(jti, userID.String(), issuedAt.Time) {
+		return nil, ErrInvalidMFAToken
+	}
+	user, err := s.users.GetUserByID(userID)
+	if err != nil || !user.TOTPEnabled {
+		return nil, ErrInvalidMFAToken
+	}
+
+	now := time.Now()
+	if err := s.throttle.Check(user.Email, ip, now); err != nil {
+		return nil, err
+	}
+	if err := s.throttle.CheckLocked(user, now); err != nil {
+		return nil, err
+	}
+
+	if recoveryCode != "" {
+		err = s.useRecoveryCode(user, recoveryCode)
+	} else {
+		err = s.verifyTOTP(user, code)
+	}
+	if err != nil {
+		if lockErr := s.throttle.Failure(user, user.Email, ip, now); lockErr != nil {
+			return nil, lockErr
+		}
+		return nil, err
+	}
+
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return nil, ErrInvalidMFAToken
+	}
+	unused, err := s.revocations.Consume(userID, jti, expiresAt.Time)
+	if err != nil {
+		log.Printf("ERROR: MFAService failed to use up the challenge of user %s: %v", userID, err)
+		return nil, err
+	}
+	if !unused {
+		return nil, ErrInvalidMFAToken
+	}
+
+	s.throttle.Success(user, user.Email)
+	return user, nil
+}
+
+// Disable turns 2FA off after checking a current code. Users whose gym
+// requires 2FA cannot turn it off.
+func (s *MFAService) Disable(userID uuid.UUID, code string) error {
+	user, err := s.users.GetUserByID(userID)
+	if err != nil {
+		return fmt.Errorf("user not found")
+	}
+	if !user.TOTPEnabled {
+		return ErrMFANotEnabled
+	}
+	if s.required(user) {
+		return ErrMFARequired
+	}
+	if err := s.verifyTOTP(user, code); err != nil {
+		return err
+	}
+
+	err = s.users.UpdateFields(userID, map[string]interface{}{
+		"totp_enabled":   false,
+		"totp_secret":    "",
+		"totp_last_step": 0,
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.recoveryCodes.Replace(userID, nil); err != nil {
+		return err
+	}
+
+	s.recordAudit(userID, models.AuditMFADisabled, nil)
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of the user. The clear
+// text codes are only ever returned here.
+func (s *MFAService) RegenerateRecoveryCodes(userID uuid.UUID) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	records := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		records[i] = models.RecoveryCode{UserID: userID, CodeHash: utils.HashToken(normalizeRecoveryCode(code))}
+	}
+
+	if err := s.recoveryCodes.Replace(userID, records); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// VerifyCode checks a TOTP code for an enabled user, e.g. before regenerating
+// recovery codes.
+func (s *MFAService) VerifyCode(userID uuid.UUID, code string) error {
+	user, err := s.users.GetUserByID(userID)
+	if err != nil {
+		return fmt.Errorf("user not found")
+	}
+	if !user.TOTPEnabled {
+		return ErrMFANotEnabled
+	}
+	return s.verifyTOTP(user, code)
+}
+
+// verifyTOTP accepts codes from the previous, current and next 30s step, but
+// never a step at or before the last accepted one, so a code cannot be replayed.
+func (s *MFAService) verifyTOTP(user *models.User, code string) error {
+	code = strings.TrimSpace(code)
+	current := time.Now().Unix() / totpPeriod
+
+	for _, step := range []int64{current - 1, current, current + 1} {
+		if step <= user.TOTPLastStep {
+			continue
+		}
+		expected, err := totp.GenerateCodeCustom(user.TOTPSecret, time.Unix(step*totpPeriod, 0), totp.ValidateOpts{
+			Period:    totpPeriod,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return err
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			user.TOTPLastStep = step
+			return s.users.UpdateFields(user.UserID, map[string]interface{}{"totp_last_step": step})
+		}
+	}
+	return ErrInvalidMFACode
+}
+
+func (s *MFAService) useRecoveryCode(user *models.User, code string) error {
+	ok, err := s.recoveryCodes.Consume(user.UserID, utils.HashToken(normalizeRecoveryCode(code)), time.Now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	remaining, _ := s.recoveryCodes.CountUnused(user.UserID)
+	s.recordAudit(user.UserID, models.AuditRecoveryCodeUsed, map[string]interface{}{"remaining": remaining})
+	return nil
+}
+
+// provisioningKey rebuilds the otpauth key from the stored secret.
+func (s *MFAService) provisioningKey(user *models.User) (*otp.Key, error) {
+	secret, err := recoveryEncoding.DecodeString(strings.ToUpper(user.TOTPSecret))
+	if err != nil {
+		return nil, err
+	}
+	return totp.Generate(totp.GenerateOpts{
+		Issuer:      s.issuer,
+		AccountName: user.Email,
+		Period:      totpPeriod,
+		Secret:      secret,
+	})
+}
+
+func (s *MFAService) recordAudit(userID uuid.UUID, action string, metadata map[string]interface{}) {
+	if err := s.audit.Create(models.NewAuditLog(userID, action, "user", userID, metadata)); err != nil {
+		log.Printf("ERROR: MFAService failed to audit %s for %s: %v", action, userID, err)
+	}
+}
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newRecoveryCode returns a code like "abcd-efgh-ijkl"
+func newRecoveryCode() (string, error) {
+	b := make([]byte, recoveryCodeByteLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	raw := strings.ToLower(recoveryEncoding.EncodeToString(b))[:12]
+	return raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
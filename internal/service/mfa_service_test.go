@@ -0,0 +1,37 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go-blog/internal/config"
+	"go-blog/internal/keyring"
+	"go-blog/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestVerifyChallengeRefusesUsedChallenge(t *testing.T) {
+	config.Keys = keyring.New()
+	if err := config.Keys.SetSigningKey(keyring.NewHMACKey([]byte("test-secret"))); err != nil {
+		t.Fatal(err)
+	}
+	revocations := &TokenRevocationService{revoked: map[string]time.Time{}, watermarks: map[uuid.UUID]time.Time{}}
+	mfa := &MFAService{revocations: revocations}
+
+	challenge, err := mfa.IssueChallenge(&models.User{UserID: uuid.New(), UserType: models.RoleMember})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := config.ParseJWT(challenge, config.TokenTypeMFA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// what Consume leaves behind after the first successful verification
+	revocations.revoked[claims["jti"].(string)] = time.Now().Add(mfaChallengeTTL)
+
+	if _, err := mfa.VerifyChallenge(challenge, "123456", "", "10.0.0.1"); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Fatalf("VerifyChallenge() = %v, want %v", err, ErrInvalidMFAToken)
+	}
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-blog/internal/config"
+	"go-blog/internal/models"
+	"go-blog/internal/oidc"
+	"go-blog/repositories"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// oidcFlowTTL is how long a user may take at the identity provider
+const oidcFlowTTL = 10 * time.Minute
+
+var (
+	ErrUnknownOIDCProvider  = errors.New("unknown login provider")
+	ErrInvalidOIDCState     = errors.New("login session expired or invalid, please try again")
+	ErrOIDCEmailNotVerified = errors.New("the provider did not confirm your email address")
+	ErrOIDCDomainNotAllowed = errors.New("this email domain may not sign in with this provider")
+	ErrInvalidReturnTo      = errors.New("return_to must point to the app")
+)
+
+// OIDCProviderInfo is what the login page needs to show a provider button
+type OIDCProviderInfo struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	LoginURL    string `json:"login_url"`
+}
+
+// OIDCLogin is the outcome of a completed provider login
+type OIDCLogin struct {
+	User     *models.User
+	ReturnTo string
+	Created  bool // the user was provisioned by this login
+}
+
+// OIDCService signs users in through external OpenID Connect providers.
+// Provider accounts are linked to users by verified email; unknown emails
+// get a new member account.
+type OIDCService struct {
+	providers  map[string]*oidc.Provider
+	identities *repositories.UserIdentityRepository
+	audit      *repositories.AuditLogRepository
+	throttle   *LoginThrottle
+	db         *gorm.DB
+}
+
+func NewOIDCService(providers map[string]*oidc.Provider, identities *repositories.UserIdentityRepository, audit *repositories.AuditLogRepository, throttle *LoginThrottle, db *gorm.DB) *OIDCService {
+	return &OIDCService{providers: providers, identities: identities, audit: audit, throttle: throttle, db: db}
+}
+
+// Providers lists the configured providers by name
+func (s *OIDCService) Providers() []OIDCProviderInfo {
+	infos := make([]OIDCProviderInfo, 0, len(s.providers))
+	for name, p := range s.providers {
+		infos = append(infos, OIDCProviderInfo{Name: name, DisplayName: p.DisplayName, LoginURL: "/auth/oidc/" + name + "/login"})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Begin starts a login. It returns the provider URL to redirect to and a
+// signed flow token that must come back with the callback, normally in a
+// cookie. returnTo is optional and must point to APP_BASE_URL; loginHint is
+// passed on to the provider.
+func (s *OIDCService) Begin(ctx context.Context, providerName, returnTo, loginHint string) (string, string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", "", ErrUnknownOIDCProvider
+	}
+	if returnTo != "" && !validReturnTo(returnTo) {
+		return "", "", ErrInvalidReturnTo
+	}
+
+	state, err := oidc.RandomString(24)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := oidc.RandomString(24)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := oidc.RandomString(48)
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, oidc.CodeChallenge(verifier), loginHint)
+	if err != nil {
+		log.Printf("ERROR: OIDCService.Begin failed for provider %s: %v", providerName, err)
+		return "", "", fmt.Errorf("login provider is unavailable")
+	}
+
+	now := time.Now()
+	flowToken, err := config.Keys.Sign(jwt.MapClaims{
+		"typ":       config.TokenTypeOIDCState,
+		"provider":  providerName,
+		"state":     state,
+		"nonce":     nonce,
+		"verifier":  verifier,
+		"return_to": returnTo,
+		"iat":       now.Unix(),
+		"exp":       now.Add(oidcFlowTTL).Unix(),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return authURL, flowToken, nil
+}
+
+// ReturnTo reads the return URL of a flow token without completing the
+// login, so errors can be sent back to the app.
+func (s *OIDCService) ReturnTo(flowToken string) string {
+	claims, err := config.ParseJWT(flowToken, config.TokenTypeOIDCState)
+	if err != nil {
+		return ""
+	}
+	returnTo, _ := claims["return_to"].(string)
+	return returnTo
+}
+
+// Complete handles the provider callback: it checks state, redeems the code
+// and returns the linked, or newly provisioned, user.
+func (s *OIDCService) Complete(ctx context.Context, providerName, flowToken, state, code string) (*OIDCLogin, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownOIDCProvider
+	}
+
+	flow, err := config.ParseJWT(flowToken, config.TokenTypeOIDCState)
+	if err != nil {
+		return nil, ErrInvalidOIDCState
+	}
+	expectedState, _ := flow["state"].(string)
+	if flow["provider"] != providerName || state == "" || state != expectedState {
+		return nil, ErrInvalidOIDCState
+	}
+	nonce, _ := flow["nonce"].(string)
+	verifier, _ := flow["verifier"].(string)
+	returnTo, _ := flow["return_to"].(string)
+
+	claims, err := provider.Exchange(ctx, code, verifier, nonce)
+	if err != nil {
+		log.Printf("WARN: OIDC login with %s failed: %v", providerName, err)
+		return nil, ErrInvalidOIDCState
+	}
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, ErrOIDCEmailNotVerified
+	}
+	if !provider.EmailAllowed(claims.Email) {
+		return nil, ErrOIDCDomainNotAllowed
+	}
+
+	login, err := s.resolveUser(provider, claims)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.throttle.CheckLocked(login.User, time.Now()); err != nil {
+		return nil, err
+	}
+	login.ReturnTo = returnTo
+	return login, nil
+}
+
+// resolveUser finds the user of a provider account. Known accounts map
+// directly; otherwise the account is linked to the user with the same email,
+// or a new member is created.
+func (s *OIDCService) resolveUser(provider *oidc.Provider, claims *oidc.Claims) (*OIDCLogin, error) {
+	now := time.Now()
+
+	identity, err := s.identities.GetBySubject(provider.Name, claims.Subject)
+	if err == nil {
+		if err := s.identities.TouchLogin(identity.ID, claims.Email, now); err != nil {
+			log.Printf("ERROR: OIDCService failed to record login of identity %s: %v", identity.ID, err)
+		}
+		return &OIDCLogin{User: &identity.User}, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	login := &OIDCLogin{}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		identities := s.identities.WithTx(tx)
+
+		user, err := identities.FindUserByEmail(claims.Email)
+		switch {
+		case err == nil:
+			// The provider vouched for the address, which is as good as our
+			// own verification mail
+			if !user.EmailVerified {
+				if err := tx.Model(user).Updates(map[string]interface{}{"email_verified": true, "email_verified_at": now}).Error; err != nil {
+					return err
+				}
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			user, err = provisionUser(tx, provider, claims, now)
+			if err != nil {
+				return err
+			}
+			login.Created = true
+		default:
+			return err
+		}
+
+		identity := &models.UserIdentity{
+			UserID:      user.UserID,
+			Provider:    provider.Name,
+			Subject:     claims.Subject,
+			Email:       claims.Email,
+			LastLoginAt: &now,
+		}
+		if err := identities.Create(identity); err != nil {
+			return err
+		}
+
+		action := models.AuditIdentityLinked
+		if login.Created {
+			action = models.AuditUserProvisioned
+		}
+		if err := tx.Create(models.NewAuditLog(user.UserID, action, "user", user.UserID, map[string]interface{}{
+			"provider": provider.Name,
+			"email":    claims.Email,
+		})).Error; err != nil {
+			return err
+		}
+
+		login.User = user
+		return nil
+	})
+	if err != nil {
+		log.Printf("ERROR: OIDCService failed to link %s account of %s: %v", provider.Name, claims.Email, err)
+		return nil, fmt.Errorf("failed to sign in with %s", provider.DisplayName)
+	}
+	return login, nil
+}
+
+// provisionUser creates a member account for a first-time provider login.
+// It has no password; the user can set one through /auth/forgot-password.
+func provisionUser(tx *gorm.DB, provider *oidc.Provider, claims *oidc.Claims, now time.Time) (*models.User, error) {
+	firstName, lastName := claims.GivenName, claims.FamilyName
+	if firstName == "" && lastName == "" {
+		firstName, lastName, _ = strings.Cut(strings.TrimSpace(claims.Name), " ")
+	}
+	if firstName == "" {
+		firstName, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	user := &models.User{
+		FirstName:       truncateRunes(firstName, 50),
+		LastName:        truncateRunes(lastName, 50),
+		Email:           claims.Email,
+		UserType:        models.RoleMember,
+		EmailVerified:   true,
+		EmailVerifiedAt: &now,
+	}
+	if provider.GymID != "" {
+		if gymID, err := uuid.Parse(provider.GymID); err == nil {
+			user.GymID = &gymID
+		}
+	}
+	if err := tx.Create(user).Error; err != nil {
+		return nil, err
+	}
+
+	member := &models.Member{
+		FirstName:        user.FirstName,
+		LastName:         user.LastName,
+		UserID:           user.UserID,
+		EmergencyContact: datatypes.JSON([]byte(`{}`)),
+	}
+	if err := tx.Create(member).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// validReturnTo only allows redirects back into the app, so the callback
+// cannot be used to hand tokens to another site.
+func validReturnTo(returnTo string) bool {
+	target, err := url.Parse(returnTo)
+	if err != nil {
+		return false
+	}
+	app, err := url.Parse(config.AppBaseURL())
+	if err != nil {
+		return false
+	}
+	return target.Scheme == app.Scheme && target.Host == app.Host && target.User == nil
+}
+
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) > max {
+		return string(runes[:max])
+	}
+	return s
+}
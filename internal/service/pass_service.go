@@ -0,0 +1,496 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"go-blog/internal/models"
+	"go-blog/internal/query"
+	"go-blog/repositories"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPassNotFound        = errors.New("pass not found")
+	ErrPassProductNotFound = errors.New("pass product not found")
+	ErrInvalidPass         = errors.New("invalid pass")
+	ErrPassNotUsable       = errors.New("pass cannot be used")
+	ErrPassAlreadyUsed     = errors.New("pass already checked in for this session")
+)
+
+// PassSale is a single visit or trial sold to a member or a walk-in
+type PassSale struct {
+	ProductID     uuid.UUID
+	MemberID      *uuid.UUID // nil for walk-ins without an account
+	HolderName    string
+	HolderEmail   string
+	HolderPhone   string
+	PaymentMethod string // how it was paid at the desk; empty leaves it to pay
+	ValidFrom     *time.Time
+}
+
+// PassHolder names the guest of a guest pass
+type PassHolder struct {
+	Name  string
+	Email string
+	Phone string
+}
+
+// TrialConversion is the membership a trial pass becomes
+type TrialConversion struct {
+	PlanID    uuid.UUID
+	MemberID  *uuid.UUID // the account a walk-in registered; defaults to the holder
+	StartDate *time.Time
+	AutoRenew bool
+}
+
+// PassService sells and redeems passes: single visits and trials for
+// walk-ins, and guest passes members issue within their plan's guest
+// allowance. Redemptions are recorded as PassVisits.
+type PassService struct {
+	repo        *repositories.PassRepository
+	attendance  *repositories.AttendanceRepository
+	payments    *repositories.PaymentRepository
+	admissions  *AdmissionService
+	memberships *MembershipService
+	db          *gorm.DB
+	gymID       *uuid.UUID // set by ForGym
+}
+
+func NewPassService(
+	repo *repositories.PassRepository,
+	attendance *repositories.AttendanceRepository,
+	payments *repositories.PaymentRepository,
+	admissions *AdmissionService,
+	memberships *MembershipService,
+	db *gorm.DB,
+) *PassService {
+	return &PassService{
+		repo:        repo,
+		attendance:  attendance,
+		payments:    payments,
+		admissions:  admissions,
+		memberships: memberships,
+		db:          db,
+	}
+}
+
+// ForGym returns a copy of the service that only redeems passes at one gym,
+// for API key callers such as check-in kiosks.
+func (s *PassService) ForGym(gymID uuid.UUID) *PassService {
+	scoped := *s
+	scoped.gymID = &gymID
+	return &scoped
+}
+
+// CreateProduct adds a pass product after filling in the defaults of its kind
+func (s *PassService) CreateProduct(product *models.PassProduct) error {
+	product.Name = strings.TrimSpace(product.Name)
+	if err := product.Normalize(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPass, err)
+	}
+	product.ArchivedAt = nil
+	return s.repo.CreateProduct(product)
+}
+
+// ListProducts returns one page of the pass products on sale, or of all
+func (s *PassService) ListProducts(req *query.Request, includeArchived bool) (*query.Page[models.PassProduct], error) {
+	return s.repo.ListProducts(req, includeArchived)
+}
+
+// ArchiveProduct takes a pass product off sale; passes already sold stay
+// valid
+func (s *PassService) ArchiveProduct(id uuid.UUID) (*models.PassProduct, error) {
+	product, err := s.repo.GetProduct(id)
+	if err != nil {
+		return nil, ErrPassProductNotFound
+	}
+	if product.ArchivedAt == nil {
+		now := time.Now()
+		product.ArchivedAt = &now
+		if err := s.repo.UpdateProduct(product); err != nil {
+			return nil, err
+		}
+	}
+	return product, nil
+}
+
+// Sell issues a single visit or trial pass. Members are charged through a
+// payment; walk-in sales are recorded on the pass.
+func (s *PassService) Sell(actorID uuid.UUID, sale PassSale) (*models.Pass, error) {
+	if sale.MemberID == nil && strings.TrimSpace(sale.HolderName) == "" {
+		return nil, fmt.Errorf("%w: member_id or holder_name is required", ErrInvalidPass)
+	}
+	pass := &models.Pass{
+		MemberID:      sale.MemberID,
+		HolderName:    strings.TrimSpace(sale.HolderName),
+		HolderEmail:   strings.TrimSpace(sale.HolderEmail),
+		HolderPhone:   strings.TrimSpace(sale.HolderPhone),
+		PaymentMethod: sale.PaymentMethod,
+		IssuedByID:    &actorID,
+	}
+	if sale.ValidFrom != nil {
+		pass.ValidFrom = *sale.ValidFrom
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		product, err := passProduct(tx, sale.ProductID)
+		if err != nil {
+			return err
+		}
+		if product.Kind == models.PassGuest {
+			return fmt.Errorf("%w: guest passes are issued by the sponsoring member", ErrInvalidPass)
+		}
+		return issuePass(tx, actorID, product, pass, sale.MemberID)
+	})
+	if err != nil {
+		if !isPassError(err) {
+			log.Printf("ERROR: PassService.Sell failed for product %s: %v", sale.ProductID, err)
+		}
+		return nil, err
+	}
+	return s.repo.GetByID(pass.ID)
+}
+
+// IssueGuestPass issues a guest pass sponsored by a member. Each pass takes
+// one place of the guest allowance of the sponsor's plan for the period.
+func (s *PassService) IssueGuestPass(actorID, sponsorID, productID uuid.UUID, guest PassHolder) (*models.Pass, error) {
+	if strings.TrimSpace(guest.Name) == "" {
+		return nil, fmt.Errorf("%w: the guest's name is required", ErrInvalidPass)
+	}
+	pass := &models.Pass{
+		HolderName:  strings.TrimSpace(guest.Name),
+		HolderEmail: strings.TrimSpace(guest.Email),
+		HolderPhone: strings.TrimSpace(guest.Phone),
+		SponsorID:   &sponsorID,
+		IssuedByID:  &actorID,
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		product, err := passProduct(tx, productID)
+		if err != nil {
+			return err
+		}
+		if product.Kind != models.PassGuest {
+			return fmt.Errorf("%w: %s is not a guest pass", ErrInvalidPass, product.Name)
+		}
+		if err := s.admissions.admitGuests(tx, sponsorID, time.Now(), 1); err != nil {
+			return err
+		}
+		return issuePass(tx, actorID, product, pass, &sponsorID)
+	})
+	if err != nil {
+		if !isPassError(err) {
+			log.Printf("ERROR: PassService.IssueGuestPass failed for member %s: %v", sponsorID, err)
+		}
+		return nil, err
+	}
+	return s.repo.GetByID(pass.ID)
+}
+
+// Redeem checks a pass in at a gym, for a class session or for open gym
+// when sessionID is nil. Holders with an account also get an attendance
+// record for the session, so their history carries over if they join.
+func (s *PassService) Redeem(actorID *uuid.UUID, code string, gymID, sessionID *uuid.UUID) (*models.PassVisit, error) {
+	var visit *models.PassVisit
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		repo := s.repo.WithTx(tx)
+		pass, err := repo.LockByCode(normalizePassCode(code))
+		if err != nil {
+			return ErrPassNotFound
+		}
+		now := time.Now()
+		if reason := pass.Unusable(now); reason != "" {
+			return fmt.Errorf("%w: %s", ErrPassNotUsable, reason)
+		}
+
+		if sessionID != nil {
+			sessionGym, err := s.attendance.WithTx(tx).SessionGymID(*sessionID)
+			if err != nil {
+				return ErrSessionNotFound
+			}
+			gymID = &sessionGym
+		}
+		if gymID == nil {
+			return fmt.Errorf("%w: gym_id or session_id is required", ErrInvalidPass)
+		}
+		if s.gymID != nil && *gymID != *s.gymID {
+			return ErrOutsideGymScope
+		}
+		if pass.Product.GymID != nil && *pass.Product.GymID != *gymID {
+			return fmt.Errorf("%w: this pass is for another gym", ErrPassNotUsable)
+		}
+		if sessionID != nil {
+			used, err := repo.VisitedSession(pass.ID, *sessionID)
+			if err != nil {
+				return err
+			}
+			if used {
+				return ErrPassAlreadyUsed
+			}
+		}
+
+		if pass.VisitsLeft != nil {
+			left := *pass.VisitsLeft - 1
+			pass.VisitsLeft = &left
+			if err := repo.Update(pass); err != nil {
+				return err
+			}
+		}
+		visit = &models.PassVisit{
+			PassID:        pass.ID,
+			GymID:         *gymID,
+			SessionID:     sessionID,
+			CheckedInByID: actorID,
+			VisitedAt:     now,
+		}
+		if err := repo.CreateVisit(visit); err != nil {
+			return err
+		}
+
+		if pass.MemberID == nil || sessionID == nil {
+			return nil
+		}
+		attendance := s.attendance.WithTx(tx)
+		if existing, _ := attendance.FindByMemberAndSession(*pass.MemberID, *sessionID); existing != nil {
+			return nil
+		}
+		return attendance.Create(&models.Attendance{
+			ID:            uuid.New(),
+			MemberID:      *pass.MemberID,
+			SessionID:     *sessionID,
+			CheckinMethod: "pass",
+			CheckedInAt:   now,
+		})
+	})
+	if err != nil {
+		if !isPassError(err) {
+			log.Printf("ERROR: PassService.Redeem failed: %v", err)
+		}
+		return nil, err
+	}
+	return visit, nil
+}
+
+// ConvertTrial turns a trial pass into a membership. The pass and its
+// visits stay, marked converted and linked to the membership. A walk-in
+// has to register first; their new member profile becomes the holder.
+func (s *PassService) ConvertTrial(actorID, id uuid.UUID, input TrialConversion) (*models.Membership, error) {
+	var membership *models.Membership
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		repo := s.repo.WithTx(tx)
+		pass, err := repo.Lock(id)
+		if err != nil {
+			return ErrPassNotFound
+		}
+		if pass.Product.Kind != models.PassTrial {
+			return fmt.Errorf("%w: only trials convert to a membership", ErrInvalidPass)
+		}
+		if pass.Status != models.PassActive {
+			return fmt.Errorf("%w: this trial is %s", ErrPassNotUsable, pass.Status)
+		}
+		memberID := pass.MemberID
+		if input.MemberID != nil {
+			if memberID != nil && *memberID != *input.MemberID {
+				return fmt.Errorf("%w: the trial belongs to another member", ErrInvalidPass)
+			}
+			memberID = input.MemberID
+		}
+		if memberID == nil {
+			return fmt.Errorf("%w: member_id is required; register the walk-in first", ErrInvalidPass)
+		}
+
+		start := time.Now()
+		if input.StartDate != nil {
+			start = *input.StartDate
+		}
+		membership = &models.Membership{
+			MemberID:  *memberID,
+			PlanID:    input.PlanID,
+			StartDate: start,
+			AutoRenew: input.AutoRenew,
+		}
+		if err := s.memberships.create(tx, actorID, membership); err != nil {
+			return err
+		}
+
+		pass.MemberID = memberID
+		pass.MembershipID = &membership.ID
+		pass.Status = models.PassConverted
+		if err := repo.Update(pass); err != nil {
+			return err
+		}
+		return tx.Create(models.NewAuditLog(actorID, models.AuditPassConverted, "pass", pass.ID, map[string]interface{}{
+			"member_id":     *memberID,
+			"membership_id": membership.ID,
+			"plan_id":       input.PlanID,
+		})).Error
+	})
+	if err != nil {
+		if !isPassError(err) && !isMembershipError(err) {
+			log.Printf("ERROR: PassService.ConvertTrial failed for pass %s: %v", id, err)
+		}
+		return nil, err
+	}
+	return s.memberships.Get(membership.ID)
+}
+
+// Void cancels a pass that is still active, and any payment still pending
+// for it
+func (s *PassService) Void(actorID, id uuid.UUID) (*models.Pass, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		repo := s.repo.WithTx(tx)
+		pass, err := repo.Lock(id)
+		if err != nil {
+			return ErrPassNotFound
+		}
+		if pass.Status != models.PassActive {
+			return fmt.Errorf("%w: this pass is %s", ErrPassNotUsable, pass.Status)
+		}
+		pass.Status = models.PassVoid
+		if err := repo.Update(pass); err != nil {
+			return err
+		}
+		if pass.PaymentID != nil {
+			payments := s.payments.WithTx(tx)
+			payment, err := payments.Lock(*pass.PaymentID)
+			if err != nil {
+				return err
+			}
+			if payment.Status == models.PaymentPending {
+				if err := payments.SetStatus(payment.ID, models.PaymentVoid); err != nil {
+					return err
+				}
+				if err := releaseDiscounts(tx, payment); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Create(models.NewAuditLog(actorID, models.AuditPassVoided, "pass", pass.ID, nil)).Error
+	})
+	if err != nil {
+		if !isPassError(err) {
+			log.Printf("ERROR: PassService.Void failed for pass %s: %v", id, err)
+		}
+		return nil, err
+	}
+	return s.repo.GetByID(id)
+}
+
+// Get returns a pass with its product and visits
+func (s *PassService) Get(id uuid.UUID) (*models.Pass, error) {
+	pass, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, ErrPassNotFound
+	}
+	return pass, nil
+}
+
+// List returns one page of all passes
+func (s *PassService) List(req *query.Request) (*query.Page[models.Pass], error) {
+	return s.repo.List(req)
+}
+
+// ListByMember returns one page of the passes a member holds or sponsored
+func (s *PassService) ListByMember(memberID uuid.UUID, req *query.Request) (*query.Page[models.Pass], error) {
+	return s.repo.ListByMember(memberID, req)
+}
+
+// passProduct loads a pass product that is on sale
+func passProduct(tx *gorm.DB, id uuid.UUID) (*models.PassProduct, error) {
+	product, err := repositories.NewPassRepository(tx).GetProduct(id)
+	if err != nil {
+		return nil, ErrPassProductNotFound
+	}
+	if product.ArchivedAt != nil {
+		return nil, fmt.Errorf("%w: %s is no longer on sale", ErrInvalidPass, product.Name)
+	}
+	return product, nil
+}
+
+// issuePass stores a pass of product inside tx: it gets a code, its validity
+// and visits from the product, and a payment when there is a member to
+// charge. Passes paid at the desk have a paid payment; others are pending.
+func issuePass(tx *gorm.DB, actorID uuid.UUID, product *models.PassProduct, pass *models.Pass, payerID *uuid.UUID) error {
+	code, err := newPassCode()
+	if err != nil {
+		return err
+	}
+	pass.Code = code
+	pass.ProductID = product.ID
+	pass.Status = models.PassActive
+	if pass.ValidFrom.IsZero() {
+		pass.ValidFrom = time.Now()
+	}
+	pass.ValidUntil = pass.ValidFrom.AddDate(0, 0, product.ValidDays)
+	if product.Visits != nil {
+		visits := *product.Visits
+		pass.VisitsLeft = &visits
+	}
+	pass.PriceCents = product.PriceCents
+
+	if payerID != nil && product.PriceCents > 0 {
+		payment := &models.Payment{
+			MemberID:    *payerID,
+			AmountCents: product.PriceCents,
+			Method:      pass.PaymentMethod,
+			Status:      models.PaymentPaid,
+			Reference:   "pass " + code,
+		}
+		if payment.Method == "" {
+			payment.Method = models.PaymentMethodPass
+			payment.Status = models.PaymentPending
+		}
+		if payer, err := repositories.NewHouseholdRepository(tx).PrimaryPayerOfMember(*payerID); err == nil {
+			payment.PayerID = &payer
+		}
+		if err := repositories.NewPaymentRepository(tx).Create(payment); err != nil {
+			return err
+		}
+		pass.PaymentID = &payment.ID
+	}
+
+	if err := repositories.NewPassRepository(tx).Create(pass); err != nil {
+		return err
+	}
+	return tx.Create(models.NewAuditLog(actorID, models.AuditPassIssued, "pass", pass.ID, map[string]interface{}{
+		"product_id":  product.ID,
+		"kind":        product.Kind,
+		"member_id":   pass.MemberID,
+		"sponsor_id":  pass.SponsorID,
+		"price_cents": pass.PriceCents,
+	})).Error
+}
+
+var passCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newPassCode returns an 8 character code such as "K7QXMPA2"
+func newPassCode() (string, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return passCodeEncoding.EncodeToString(b), nil
+}
+
+// normalizePassCode accepts codes typed in lower case or with separators
+func normalizePassCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+func isPassError(err error) bool {
+	var denial *models.AccessDenial
+	if errors.As(err, &denial) {
+		return true
+	}
+	for _, target := range []error{ErrPassNotFound, ErrPassProductNotFound, ErrInvalidPass, ErrPassNotUsable, ErrPassAlreadyUsed, ErrNoActiveMembership, ErrSessionNotFound, ErrOutsideGymScope} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,25 +1,128 @@
 package services
 
 import (
+	"errors"
+	"fmt"
 	"go-blog/internal/models"
+	"go-blog/internal/query"
 	"go-blog/repositories"
+	"log"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPaymentNotFound      = errors.New("payment not found")
+	ErrInvalidPaymentStatus = errors.New("payment status must be pending, paid, failed or void")
+	ErrPaymentStatusChange  = errors.New("payment cannot change to that status")
 )
 
 type PaymentService struct {
-	repo *repositories.PaymentRepository
+	repo        *repositories.PaymentRepository
+	households  *repositories.HouseholdRepository
+	memberships *MembershipService
+	db          *gorm.DB
 }
 
-func NewPaymentService(repo *repositories.PaymentRepository) *PaymentService {
-	return &PaymentService{repo: repo}
+func NewPaymentService(repo *repositories.PaymentRepository, households *repositories.HouseholdRepository, memberships *MembershipService, db *gorm.DB) *PaymentService {
+	return &PaymentService{repo: repo, households: households, memberships: memberships, db: db}
 }
 
+// RecordPayment stores a payment. Unless a payer is given, members of a
+// household are billed to its primary payer. A payment recorded as paid
+// may earn whoever referred the member their reward.
 func (s *PaymentService) RecordPayment(p *models.Payment) error {
-	return s.repo.Create(p)
+	if p.PayerID == nil {
+		if payer, err := s.households.PrimaryPayerOfMember(p.MemberID); err == nil {
+			p.PayerID = &payer
+		}
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.repo.WithTx(tx).Create(p); err != nil {
+			return err
+		}
+		return rewardReferral(tx, s.memberships, p)
+	})
+}
+
+func (s *PaymentService) GetPayments(memberID string, req *query.Request) (*query.Page[models.Payment], error) {
+	return s.repo.ListByMember(memberID, req)
+}
+func (s *PaymentService) GetAllPayments(req *query.Request) (*query.Page[models.Payment], error) {
+	return s.repo.List(req)
 }
 
-func (s *PaymentService) GetPayments(memberID string) ([]models.Payment, error) {
-	return s.repo.GetByMember(memberID)
+// SetStatus records the outcome of a payment. Only the changes
+// models.CanChangePaymentStatus allows are made, so a payment is settled
+// once. The promo redemption and account credit of a payment are given back
+// when it fails or is voided, payments for a membership period move it out
+// of, or into, past due, and a member's first paid payment rewards whoever
+// referred them.
+func (s *PaymentService) SetStatus(actorID, id uuid.UUID, status string) (*models.Payment, error) {
+	switch status {
+	case models.PaymentPending, models.PaymentPaid, models.PaymentFailed, models.PaymentVoid:
+	default:
+		return nil, ErrInvalidPaymentStatus
+	}
+
+	var payment *models.Payment
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		repo := s.repo.WithTx(tx)
+		p, err := repo.Lock(id)
+		if err != nil {
+			return ErrPaymentNotFound
+		}
+		payment = p
+		if p.Status == status {
+			return nil
+		}
+		if !models.CanChangePaymentStatus(p.Status, status) {
+			return fmt.Errorf("%w: %s to %s", ErrPaymentStatusChange, p.Status, status)
+		}
+		from := p.Status
+		p.Status = status
+		if err := repo.SetStatus(id, status); err != nil {
+			return err
+		}
+		err = tx.Create(models.NewAuditLog(actorID, models.AuditPaymentStatusChanged, "payment", id, map[string]interface{}{
+			"from": from,
+			"to":   status,
+		})).Error
+		if err != nil {
+			return err
+		}
+		if err := settleDiscounts(tx, p, from); err != nil {
+			return err
+		}
+		if err := rewardReferral(tx, s.memberships, p); err != nil {
+			return err
+		}
+		if p.MembershipID == nil {
+			return nil
+		}
+		return s.memberships.settle(tx, actorID, *p.MembershipID, status)
+	})
+	if err != nil {
+		if !errors.Is(err, ErrPaymentNotFound) && !errors.Is(err, ErrPaymentStatusChange) {
+			log.Printf("ERROR: PaymentService.SetStatus failed for payment %s: %v", id, err)
+		}
+		return nil, err
+	}
+	return payment, nil
 }
-func (s *PaymentService) GetAllPayments() ([]models.Payment, error) {
-	return s.repo.GetAll()
+
+// voidPendingPayments voids the unpaid payments raised for a membership and
+// gives back the discounts they held
+func voidPendingPayments(tx *gorm.DB, membershipID uuid.UUID) error {
+	voided, err := repositories.NewPaymentRepository(tx).VoidPending(membershipID)
+	if err != nil {
+		return err
+	}
+	for i := range voided {
+		if err := releaseDiscounts(tx, &voided[i]); err != nil {
+			return err
+		}
+	}
+	return nil
 }
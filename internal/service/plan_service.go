@@ -1,22 +1,260 @@
 package services
 
 import (
+	"errors"
+	"fmt"
 	"go-blog/internal/models"
+	"go-blog/internal/query"
 	"go-blog/repositories"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidPlan  = errors.New("invalid plan")
+	ErrPlanNotFound = errors.New("plan not found")
+	ErrPlanArchived = errors.New("plan is archived and no longer on sale")
+	ErrPlanInUse    = errors.New("plan has memberships; archive it instead")
 )
 
+// PlanUpdate holds the plan fields to change. A new price or billing cycle
+// starts a new plan version.
+type PlanUpdate struct {
+	Title        *string
+	Description  *string
+	PriceCents   *int
+	BillingCycle *string
+	NumSessions  *int
+	Unlimited    bool // clears NumSessions
+	Access       *models.AccessPolicy
+}
+
 type PlanService struct {
-	repo *repositories.PlanRepository
+	repo        *repositories.PlanRepository
+	memberships *repositories.MembershipRepository
+	db          *gorm.DB
+}
+
+func NewPlanService(repo *repositories.PlanRepository, memberships *repositories.MembershipRepository, db *gorm.DB) *PlanService {
+	return &PlanService{repo: repo, memberships: memberships, db: db}
+}
+
+// CreatePlan stores a plan and its first version after checking its access
+// policy
+func (s *PlanService) CreatePlan(actorID uuid.UUID, plan *models.Plan) error {
+	if err := validatePlan(plan); err != nil {
+		return err
+	}
+	plan.Version = 1
+	plan.ArchivedAt = nil
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		repo := s.repo.WithTx(tx)
+		if err := repo.Create(plan); err != nil {
+			return err
+		}
+		version := plan.Snapshot()
+		version.CreatedByID = &actorID
+		if err := repo.CreateVersion(&version); err != nil {
+			return err
+		}
+		return tx.Create(models.NewAuditLog(actorID, models.AuditPlanCreated, "plan", plan.ID, map[string]interface{}{
+			"title":         plan.Title,
+			"price_cents":   plan.PriceCents,
+			"billing_cycle": plan.BillingCycle,
+		})).Error
+	})
+}
+
+// GetPlans returns one page of plans on sale, or of all plans
+func (s *PlanService) GetPlans(req *query.Request, includeArchived bool) (*query.Page[models.Plan], error) {
+	return s.repo.List(req, includeArchived)
+}
+
+// GetPlan returns a plan, archived or not
+func (s *PlanService) GetPlan(id uuid.UUID) (*models.Plan, error) {
+	plan, err := s.repo.GetByID(id.String())
+	if err != nil {
+		return nil, ErrPlanNotFound
+	}
+	return plan, nil
+}
+
+// UpdatePlan changes a plan. A different price or billing cycle becomes a
+// new version; memberships already sold keep the version they were sold at
+// until they renew.
+func (s *PlanService) UpdatePlan(actorID, id uuid.UUID, input PlanUpdate) (*models.Plan, error) {
+	var plan *models.Plan
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		repo := s.repo.WithTx(tx)
+		var err error
+		if plan, err = repo.Lock(id); err != nil {
+			return ErrPlanNotFound
+		}
+
+		previous := plan.Snapshot()
+		fields := map[string]interface{}{}
+		if input.Title != nil {
+			plan.Title = strings.TrimSpace(*input.Title)
+			fields["title"] = plan.Title
+		}
+		if input.Description != nil {
+			plan.Description = *input.Description
+			fields["description"] = plan.Description
+		}
+		if input.PriceCents != nil {
+			plan.PriceCents = *input.PriceCents
+		}
+		if input.BillingCycle != nil {
+			plan.BillingCycle = *input.BillingCycle
+		}
+		if input.Unlimited {
+			plan.NumSessions = nil
+			fields["num_sessions"] = nil
+		} else if input.NumSessions != nil {
+			plan.NumSessions = input.NumSessions
+			fields["num_sessions"] = *plan.NumSessions
+		}
+		if input.Access != nil {
+			plan.Access = *input.Access
+			fields["access"] = plan.Access
+		}
+		if err := validatePlan(plan); err != nil {
+			return err
+		}
+
+		if plan.PriceCents != previous.PriceCents || plan.BillingCycle != previous.BillingCycle {
+			plan.Version++
+			version := plan.Snapshot()
+			version.CreatedByID = &actorID
+			if err := repo.CreateVersion(&version); err != nil {
+				return err
+			}
+			fields["version"] = map[string]interface{}{
+				"from":          previous.Version,
+				"to":            plan.Version,
+				"price_cents":   plan.PriceCents,
+				"billing_cycle": plan.BillingCycle,
+			}
+		}
+		if err := repo.Update(plan); err != nil {
+			return err
+		}
+		return tx.Create(models.NewAuditLog(actorID, models.AuditPlanUpdated, "plan", plan.ID, fields)).Error
+	})
+	if err != nil {
+		if !isPlanError(err) {
+			log.Printf("ERROR: PlanService.UpdatePlan failed for plan %s: %v", id, err)
+		}
+		return nil, err
+	}
+	return plan, nil
+}
+
+// Archive takes a plan off sale. Its memberships stay valid and renew.
+func (s *PlanService) Archive(actorID, id uuid.UUID) (*models.Plan, error) {
+	now := time.Now()
+	return s.setArchived(actorID, id, &now, models.AuditPlanArchived)
+}
+
+// Unarchive puts an archived plan back on sale
+func (s *PlanService) Unarchive(actorID, id uuid.UUID) (*models.Plan, error) {
+	return s.setArchived(actorID, id, nil, models.AuditPlanUnarchived)
+}
+
+func (s *PlanService) setArchived(actorID, id uuid.UUID, at *time.Time, action string) (*models.Plan, error) {
+	var plan *models.Plan
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		repo := s.repo.WithTx(tx)
+		var err error
+		if plan, err = repo.Lock(id); err != nil {
+			return ErrPlanNotFound
+		}
+		plan.ArchivedAt = at
+		if err := repo.Update(plan); err != nil {
+			return err
+		}
+		return tx.Create(models.NewAuditLog(actorID, action, "plan", plan.ID, nil)).Error
+	})
+	if err != nil {
+		if !isPlanError(err) {
+			log.Printf("ERROR: PlanService failed to archive or unarchive plan %s: %v", id, err)
+		}
+		return nil, err
+	}
+	return plan, nil
+}
+
+// DeletePlan removes a plan that was never sold. Plans with memberships
+// can only be archived, so no membership loses its plan.
+func (s *PlanService) DeletePlan(actorID, id uuid.UUID) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		repo := s.repo.WithTx(tx)
+		plan, err := repo.Lock(id)
+		if err != nil {
+			return ErrPlanNotFound
+		}
+		count, err := repo.CountMemberships(id)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrPlanInUse
+		}
+		if err := repo.Delete(id.String()); err != nil {
+			return err
+		}
+		return tx.Create(models.NewAuditLog(actorID, models.AuditPlanDeleted, "plan", id, map[string]interface{}{
+			"title": plan.Title,
+		})).Error
+	})
+	if err != nil && !isPlanError(err) {
+		log.Printf("ERROR: PlanService.DeletePlan failed for plan %s: %v", id, err)
+	}
+	return err
+}
+
+// Versions lists the versions of a plan with how many memberships are on each
+func (s *PlanService) Versions(id uuid.UUID) ([]repositories.PlanVersionSummary, error) {
+	if _, err := s.GetPlan(id); err != nil {
+		return nil, err
+	}
+	return s.repo.Versions(id)
 }
 
-func NewPlanService(repo *repositories.PlanRepository) *PlanService {
-	return &PlanService{repo: repo}
+// Memberships returns one page of the memberships sold on a plan; filter by
+// plan_version to see one version
+func (s *PlanService) Memberships(id uuid.UUID, req *query.Request) (*query.Page[models.Membership], error) {
+	if _, err := s.GetPlan(id); err != nil {
+		return nil, err
+	}
+	return s.memberships.ListByPlan(id, req)
 }
 
-func (s *PlanService) CreatePlan(plan *models.Plan) error {
-	return s.repo.Create(plan)
+func validatePlan(plan *models.Plan) error {
+	if strings.TrimSpace(plan.Title) == "" {
+		return fmt.Errorf("%w: title is required", ErrInvalidPlan)
+	}
+	if plan.PriceCents < 0 {
+		return fmt.Errorf("%w: price_cents cannot be negative", ErrInvalidPlan)
+	}
+	if plan.NumSessions != nil && *plan.NumSessions < 0 {
+		return fmt.Errorf("%w: num_sessions cannot be negative", ErrInvalidPlan)
+	}
+	if err := plan.Access.Validate(); err != nil {
+		return fmt.Errorf("%w: access: %v", ErrInvalidPlan, err)
+	}
+	return nil
 }
 
-func (s *PlanService) GetPlans() ([]models.Plan, error) {
-	return s.repo.GetAll()
+func isPlanError(err error) bool {
+	for _, target := range []error{ErrInvalidPlan, ErrPlanNotFound, ErrPlanInUse} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,338 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-blog/internal/models"
+	"go-blog/internal/query"
+	"go-blog/internal/storage"
+	"go-blog/repositories"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrErasureRequestNotFound = errors.New("erasure request not found")
+	ErrErasureAlreadyPending  = errors.New("an erasure request for this user is already pending")
+	ErrErasureNotPending      = errors.New("erasure request was already reviewed")
+	ErrErasureSelfReview      = errors.New("an erasure request must be reviewed by another admin")
+)
+
+// DataExport is the personal data of one user, as handed out on request.
+// Secrets such as password hashes and 2FA seeds are never included.
+type DataExport struct {
+	GeneratedAt   time.Time             `json:"generated_at"`
+	User          *models.User          `json:"user"`
+	Member        *ExportedMember       `json:"member,omitempty"`
+	Memberships   []ExportedMembership  `json:"memberships"`
+	Payments      []ExportedPayment     `json:"payments"`
+	Bookings      []ExportedVisit       `json:"bookings"`
+	Attendance    []ExportedVisit       `json:"attendance"`
+	Notifications []models.Notification `json:"notifications"`
+	Identities    []models.UserIdentity `json:"linked_accounts"`
+
+	photo []byte // the profile picture, only added to ZIP archives
+}
+
+type ExportedMember struct {
+	ID               uuid.UUID       `json:"id"`
+	FirstName        string          `json:"first_name"`
+	LastName         string          `json:"last_name"`
+	DateOfBirth      *time.Time      `json:"date_of_birth"`
+	Gender           string          `json:"gender"`
+	EmergencyContact json.RawMessage `json:"emergency_contact"`
+	Notes            string          `json:"notes"`
+	CreatedAt        time.Time       `json:"created_at"`
+}
+
+type ExportedMembership struct {
+	ID        uuid.UUID `json:"id"`
+	Plan      string    `json:"plan"`
+	StartDate time.Time `json:"start_date"`
+	EndDate   time.Time `json:"end_date"`
+	Status    string    `json:"status"`
+	AutoRenew bool      `json:"auto_renew"`
+}
+
+type ExportedPayment struct {
+	ID          uuid.UUID `json:"id"`
+	AmountCents int       `json:"amount_cents"`
+	Currency    string    `json:"currency"`
+	Method      string    `json:"method"`
+	Status      string    `json:"status"`
+	Reference   string    `json:"reference"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ExportedVisit is a booking or a check-in
+type ExportedVisit struct {
+	Class    string    `json:"class"`
+	StartsAt time.Time `json:"starts_at"`
+	Status   string    `json:"status,omitempty"`
+	Method   string    `json:"method,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// PrivacyService hands users their data and erases it on request. Erasure
+// anonymizes instead of deleting, so financial records survive for the
+// legally required retention period.
+type PrivacyService struct {
+	repo        *repositories.PrivacyRepository
+	photos      *ProfilePhotoService
+	blobs       storage.BlobStore
+	revocations *TokenRevocationService
+	audit       *repositories.AuditLogRepository
+	db          *gorm.DB
+}
+
+func NewPrivacyService(repo *repositories.PrivacyRepository, photos *ProfilePhotoService, blobs storage.BlobStore, revocations *TokenRevocationService, audit *repositories.AuditLogRepository, db *gorm.DB) *PrivacyService {
+	return &PrivacyService{repo: repo, photos: photos, blobs: blobs, revocations: revocations, audit: audit, db: db}
+}
+
+// Export gathers the data of userID on behalf of actorID, who is the user
+// themselves or an admin.
+func (s *PrivacyService) Export(ctx context.Context, actorID, userID uuid.UUID, format string) (*DataExport, error) {
+	data, err := s.repo.CollectPersonalData(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrProfileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	export := &DataExport{
+		GeneratedAt:   time.Now(),
+		User:          &data.User,
+		Memberships:   []ExportedMembership{},
+		Payments:      []ExportedPayment{},
+		Bookings:      []ExportedVisit{},
+		Attendance:    []ExportedVisit{},
+		Notifications: data.Notifications,
+		Identities:    data.Identities,
+	}
+	if m := data.Member; m != nil {
+		export.Member = &ExportedMember{
+			ID: m.ID, FirstName: m.FirstName, LastName: m.LastName, DateOfBirth: m.Dob, Gender: m.Gender,
+			EmergencyContact: json.RawMessage(m.EmergencyContact), Notes: m.Notes, CreatedAt: m.CreatedAt,
+		}
+		if len(m.EmergencyContact) == 0 {
+			export.Member.EmergencyContact = json.RawMessage("{}")
+		}
+	}
+	for _, ms := range data.Memberships {
+		export.Memberships = append(export.Memberships, ExportedMembership{
+			ID: ms.ID, Plan: ms.Plan.Title, StartDate: ms.StartDate, EndDate: ms.EndDate, Status: ms.Status, AutoRenew: ms.AutoRenew,
+		})
+	}
+	for _, p := range data.Payments {
+		export.Payments = append(export.Payments, ExportedPayment{
+			ID: p.ID, AmountCents: p.AmountCents, Currency: p.Currency, Method: p.Method, Status: p.Status, Reference: p.Reference, CreatedAt: p.CreatedAt,
+		})
+	}
+	for _, b := range data.Bookings {
+		export.Bookings = append(export.Bookings, ExportedVisit{
+			Class: b.Session.Class.Title, StartsAt: b.Session.StartsAt, Status: b.Status, At: b.CreatedAt,
+		})
+	}
+	for _, a := range data.Attendance {
+		export.Attendance = append(export.Attendance, ExportedVisit{
+			Class: a.Session.Class.Title, StartsAt: a.Session.StartsAt, Method: a.CheckinMethod, At: a.CheckedInAt,
+		})
+	}
+	if export.Notifications == nil {
+		export.Notifications = []models.Notification{}
+	}
+	if export.Identities == nil {
+		export.Identities = []models.UserIdentity{}
+	}
+
+	if format == "zip" && data.User.ProfilePictureKey != "" {
+		photo, err := s.blobs.Get(ctx, photoKey(data.User.ProfilePictureKey))
+		if err != nil {
+			log.Printf("WARN: PrivacyService could not add profile picture of %s to export: %v", userID, err)
+		}
+		export.photo = photo
+	}
+
+	entry := models.NewAuditLog(actorID, models.AuditDataExported, "user", userID, map[string]interface{}{"format": format})
+	if err := s.audit.Create(entry); err != nil {
+		log.Printf("ERROR: PrivacyService failed to audit export of %s: %v", userID, err)
+	}
+	return export, nil
+}
+
+// WriteZip writes the export as a ZIP archive with one JSON file per section
+// and the profile picture, if any.
+func (e *DataExport) WriteZip(w io.Writer) error {
+	archive := zip.NewWriter(w)
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"export.json", e},
+		{"profile.json", map[string]interface{}{"user": e.User, "member": e.Member}},
+		{"memberships.json", e.Memberships},
+		{"payments.json", e.Payments},
+		{"bookings.json", e.Bookings},
+		{"attendance.json", e.Attendance},
+		{"notifications.json", e.Notifications},
+		{"linked_accounts.json", e.Identities},
+	}
+	for _, f := range files {
+		out, err := archive.Create(f.name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.data); err != nil {
+			return err
+		}
+	}
+	if len(e.photo) > 0 {
+		out, err := archive.Create("profile-picture.jpg")
+		if err != nil {
+			return err
+		}
+		if _, err := out.Write(e.photo); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// RequestErasure files an erasure request for userID. actorID is the user
+// themselves or an admin acting on a request received elsewhere.
+func (s *PrivacyService) RequestErasure(actorID, userID uuid.UUID, reason string) (*models.ErasureRequest, error) {
+	pending, err := s.repo.HasPendingErasure(userID)
+	if err != nil {
+		return nil, err
+	}
+	if pending {
+		return nil, ErrErasureAlreadyPending
+	}
+
+	request := &models.ErasureRequest{
+		UserID:        userID,
+		RequestedByID: actorID,
+		Reason:        strings.TrimSpace(reason),
+		Status:        models.ErasurePending,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.repo.WithTx(tx).CreateErasureRequest(request); err != nil {
+			return err
+		}
+		return tx.Create(models.NewAuditLog(actorID, models.AuditErasureRequested, "user", userID, map[string]interface{}{
+			"erasure_request_id": request.ID,
+		})).Error
+	})
+	if err != nil {
+		log.Printf("ERROR: PrivacyService failed to create erasure request for %s: %v", userID, err)
+		return nil, fmt.Errorf("failed to create erasure request")
+	}
+	return request, nil
+}
+
+func (s *PrivacyService) ListErasureRequests(req *query.Request) (*query.Page[models.ErasureRequest], error) {
+	return s.repo.ListErasureRequests(req)
+}
+
+func (s *PrivacyService) ErasureRequestsOf(userID uuid.UUID) ([]models.ErasureRequest, error) {
+	return s.repo.ErasureRequestsOf(userID)
+}
+
+// Approve carries out a pending erasure request. Admins cannot approve
+// requests they filed or that concern themselves.
+func (s *PrivacyService) Approve(ctx context.Context, adminID, requestID uuid.UUID, note string) (*models.ErasureRequest, error) {
+	request, err := s.reviewable(adminID, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Anonymize clears the picture key, so read it first
+	pictureKey, err := s.repo.ProfilePictureKey(request.UserID)
+	if err != nil {
+		return nil, ErrProfileNotFound
+	}
+
+	now := time.Now()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		repo := s.repo.WithTx(tx)
+		ok, err := repo.ReviewErasureRequest(requestID, adminID, models.ErasureCompleted, note, now)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrErasureNotPending
+		}
+		if err := repo.Anonymize(request.UserID, now); err != nil {
+			return err
+		}
+		return tx.Create(models.NewAuditLog(adminID, models.AuditErasureCompleted, "user", request.UserID, map[string]interface{}{
+			"erasure_request_id": requestID,
+		})).Error
+	})
+	if errors.Is(err, ErrErasureNotPending) {
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("ERROR: PrivacyService failed to erase user %s: %v", request.UserID, err)
+		return nil, fmt.Errorf("failed to erase personal data")
+	}
+
+	if pictureKey != "" {
+		s.photos.deleteBlobs(ctx, pictureKey)
+	}
+	if err := s.revocations.LogoutAll(adminID, request.UserID, "erased"); err != nil {
+		log.Printf("ERROR: PrivacyService failed to end sessions of erased user %s: %v", request.UserID, err)
+	}
+	return s.repo.GetErasureRequest(requestID)
+}
+
+// Reject closes a pending erasure request without erasing anything
+func (s *PrivacyService) Reject(adminID, requestID uuid.UUID, note string) (*models.ErasureRequest, error) {
+	request, err := s.reviewable(adminID, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		ok, err := s.repo.WithTx(tx).ReviewErasureRequest(requestID, adminID, models.ErasureRejected, note, time.Now())
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrErasureNotPending
+		}
+		return tx.Create(models.NewAuditLog(adminID, models.AuditErasureRejected, "user", request.UserID, map[string]interface{}{
+			"erasure_request_id": requestID,
+			"note":               note,
+		})).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetErasureRequest(requestID)
+}
+
+func (s *PrivacyService) reviewable(adminID, requestID uuid.UUID) (*models.ErasureRequest, error) {
+	request, err := s.repo.GetErasureRequest(requestID)
+	if err != nil {
+		return nil, ErrErasureRequestNotFound
+	}
+	if request.Status != models.ErasurePending {
+		return nil, ErrErasureNotPending
+	}
+	if adminID == request.UserID || adminID == request.RequestedByID {
+		return nil, ErrErasureSelfReview
+	}
+	return request, nil
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-blog/internal/imaging"
+	"go-blog/internal/models"
+	"go-blog/internal/storage"
+	"go-blog/repositories"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+const (
+	MaxProfilePhotoBytes  = 5 << 20
+	maxProfilePhotoPixels = 40_000_000
+	profilePhotoSize      = 512
+	profileThumbnailSize  = 128
+	profilePhotoQuality   = 85
+)
+
+var (
+	ErrPhotoTooLarge        = fmt.Errorf("photo must be at most %d MB", MaxProfilePhotoBytes>>20)
+	ErrUnsupportedPhotoType = errors.New("photo must be a JPEG, PNG or WebP image")
+)
+
+var allowedPhotoTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+// ProfilePhotoService turns uploaded pictures into square, metadata-free
+// JPEGs and keeps them in the BlobStore.
+type ProfilePhotoService struct {
+	profiles *repositories.ProfileRepository
+	blobs    storage.BlobStore
+}
+
+func NewProfilePhotoService(profiles *repositories.ProfileRepository, blobs storage.BlobStore) *ProfilePhotoService {
+	return &ProfilePhotoService{profiles: profiles, blobs: blobs}
+}
+
+// Upload replaces the profile picture of userID. declaredType is the
+// Content-Type sent by the client; the bytes must agree with it.
+func (s *ProfilePhotoService) Upload(ctx context.Context, userID uuid.UUID, data []byte, declaredType string) (*models.User, error) {
+	if len(data) > MaxProfilePhotoBytes {
+		return nil, ErrPhotoTooLarge
+	}
+	sniffed := http.DetectContentType(data)
+	if !allowedPhotoTypes[sniffed] || (declaredType != "" && !allowedPhotoTypes[declaredType]) {
+		return nil, ErrUnsupportedPhotoType
+	}
+
+	user, err := s.profiles.GetUser(userID)
+	if err != nil {
+		return nil, ErrProfileNotFound
+	}
+
+	img, err := imaging.Decode(data, maxProfilePhotoPixels)
+	if err != nil {
+		if errors.Is(err, imaging.ErrTooLarge) {
+			return nil, err
+		}
+		return nil, ErrUnsupportedPhotoType
+	}
+
+	photo, err := imaging.EncodeJPEG(imaging.SquareThumbnail(img, profilePhotoSize), profilePhotoQuality)
+	if err != nil {
+		return nil, err
+	}
+	thumbnail, err := imaging.EncodeJPEG(imaging.SquareThumbnail(img, profileThumbnailSize), profilePhotoQuality)
+	if err != nil {
+		return nil, err
+	}
+
+	// A fresh key per upload keeps caches from serving the old picture
+	key := fmt.Sprintf("profile-pictures/%s/%s", userID, uuid.New())
+	if err := s.blobs.Put(ctx, photoKey(key), photo, "image/jpeg"); err != nil {
+		return nil, err
+	}
+	if err := s.blobs.Put(ctx, thumbnailKey(key), thumbnail, "image/jpeg"); err != nil {
+		s.deleteBlobs(ctx, key)
+		return nil, err
+	}
+
+	err = s.profiles.UpdateUser(userID, map[string]interface{}{
+		"profile_picture_url":   s.blobs.URL(photoKey(key)),
+		"profile_thumbnail_url": s.blobs.URL(thumbnailKey(key)),
+		"profile_picture_key":   key,
+	})
+	if err != nil {
+		s.deleteBlobs(ctx, key)
+		return nil, err
+	}
+
+	if user.ProfilePictureKey != "" {
+		s.deleteBlobs(ctx, user.ProfilePictureKey)
+	}
+	return s.profiles.GetUser(userID)
+}
+
+// Remove deletes the profile picture of userID.
+func (s *ProfilePhotoService) Remove(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.profiles.GetUser(userID)
+	if err != nil {
+		return ErrProfileNotFound
+	}
+
+	err = s.profiles.UpdateUser(userID, map[string]interface{}{
+		"profile_picture_url":   "",
+		"profile_thumbnail_url": "",
+		"profile_picture_key":   "",
+	})
+	if err != nil {
+		return err
+	}
+
+	if user.ProfilePictureKey != "" {
+		s.deleteBlobs(ctx, user.ProfilePictureKey)
+	}
+	return nil
+}
+
+// deleteBlobs removes both sizes of a picture. Failures only leave orphaned
+// files behind, so they are logged rather than returned.
+func (s *ProfilePhotoService) deleteBlobs(ctx context.Context, key string) {
+	for _, k := range []string{photoKey(key), thumbnailKey(key)} {
+		if err := s.blobs.Delete(ctx, k); err != nil {
+			log.Printf("WARN: ProfilePhotoService failed to delete %s: %v", k, err)
+		}
+	}
+}
+
+func photoKey(key string) string {
+	return fmt.Sprintf("%s_%d.jpg", key, profilePhotoSize)
+}
+
+func thumbnailKey(key string) string {
+	return fmt.Sprintf("%s_%d.jpg", key, profileThumbnailSize)
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-blog/internal/models"
+	"go-blog/repositories"
+	"go-blog/utils"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const (
+	overviewUpcomingLimit   = 10
+	overviewAttendanceLimit = 10
+)
+
+var (
+	ErrProfileNotFound   = errors.New("profile not found")
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+	ErrSamePassword      = errors.New("new password must differ from the current one")
+)
+
+// ProfileUpdate holds the fields a user may change about themselves. Nil
+// fields are left untouched.
+type ProfileUpdate struct {
+	PhoneNumber           *string `json:"phone_number" binding:"omitempty,max=15"`
+	FitnessGoals          *string `json:"fitness_goals"`
+	EmergencyContactName  *string `json:"emergency_contact_name" binding:"omitempty,max=100"`
+	EmergencyContactPhone *string `json:"emergency_contact_phone" binding:"omitempty,max=15"`
+	Gender                *string `json:"gender" binding:"omitempty,max=20"`
+}
+
+// emergencyContact is the shape of Member.EmergencyContact
+type emergencyContact struct {
+	Name  string `json:"name"`
+	Phone string `json:"phone"`
+}
+
+// MemberOverview combines what a member usually checks on their dashboard
+type MemberOverview struct {
+	ActiveMembership *models.Membership  `json:"active_membership"`
+	UpcomingBookings []models.Booking    `json:"upcoming_bookings"`
+	RecentAttendance []models.Attendance `json:"recent_attendance"`
+}
+
+// ProfileService backs the /me endpoints
+type ProfileService struct {
+	repo        *repositories.ProfileRepository
+	revocations *TokenRevocationService
+	db          *gorm.DB
+}
+
+func NewProfileService(repo *repositories.ProfileRepository, revocations *TokenRevocationService, db *gorm.DB) *ProfileService {
+	return &ProfileService{repo: repo, revocations: revocations, db: db}
+}
+
+// GetProfile returns the user with their member profile preloaded
+func (s *ProfileService) GetProfile(userID uuid.UUID) (*models.User, error) {
+	user, err := s.repo.GetUser(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrProfileNotFound
+	}
+	return user, err
+}
+
+// UpdateProfile applies the update to the user and keeps the linked member
+// profile (gender, emergency contact) in sync.
+func (s *ProfileService) UpdateProfile(userID uuid.UUID, update ProfileUpdate) (*models.User, error) {
+	user, err := s.GetProfile(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	userFields := map[string]interface{}{}
+	memberFields := map[string]interface{}{}
+
+	if update.PhoneNumber != nil {
+		userFields["phone_number"] = *update.PhoneNumber
+	}
+	if update.FitnessGoals != nil {
+		userFields["fitness_goals"] = *update.FitnessGoals
+	}
+	if update.Gender != nil {
+		userFields["gender"] = *update.Gender
+		memberFields["gender"] = *update.Gender
+	}
+	if update.EmergencyContactName != nil || update.EmergencyContactPhone != nil {
+		contact := emergencyContact{Name: user.EmergencyContactName, Phone: user.EmergencyContactPhone}
+		if update.EmergencyContactName != nil {
+			contact.Name = *update.EmergencyContactName
+		}
+		if update.EmergencyContactPhone != nil {
+			contact.Phone = *update.EmergencyContactPhone
+		}
+		userFields["emergency_contact_name"] = contact.Name
+		userFields["emergency_contact_phone"] = contact.Phone
+
+		raw, err := json.Marshal(contact)
+		if err != nil {
+			return nil, err
+		}
+		memberFields["emergency_contact"] = datatypes.JSON(raw)
+	}
+
+	if len(userFields) == 0 {
+		return user, nil
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		repo := s.repo.WithTx(tx)
+		if err := repo.UpdateUser(userID, userFields); err != nil {
+			return err
+		}
+		if user.Member == nil || len(memberFields) == 0 {
+			return nil
+		}
+		return repo.UpdateMember(user.Member.ID, memberFields)
+	})
+	if err != nil {
+		log.Printf("ERROR: ProfileService.UpdateProfile failed for user %s: %v", userID, err)
+		return nil, fmt.Errorf("failed to update profile")
+	}
+
+	return s.GetProfile(userID)
+}
+
+// ChangePassword replaces the password after checking the current one and
+// signs the user out everywhere, including the calling session.
+func (s *ProfileService) ChangePassword(userID uuid.UUID, currentPassword, newPassword string) error {
+	user, err := s.GetProfile(userID)
+	if err != nil {
+		return err
+	}
+	if !utils.CheckPasswordHash(currentPassword, user.PasswordHash) {
+		return ErrIncorrectPassword
+	}
+	if len(newPassword) < minPasswordLen {
+		return ErrWeakPassword
+	}
+	if currentPassword == newPassword {
+		return ErrSamePassword
+	}
+
+	hashed, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to process password")
+	}
+	if err := s.repo.UpdateUser(userID, map[string]interface{}{"password_hash": hashed}); err != nil {
+		log.Printf("ERROR: ProfileService.ChangePassword failed for user %s: %v", userID, err)
+		return fmt.Errorf("failed to change password")
+	}
+
+	return s.revocations.LogoutAll(userID, userID, "password_changed")
+}
+
+// Overview returns the active membership, upcoming bookings and recent
+// attendance of the user's member profile.
+func (s *ProfileService) Overview(userID uuid.UUID) (*MemberOverview, error) {
+	member, err := s.repo.GetMemberByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	overview := &MemberOverview{
+		UpcomingBookings: []models.Booking{},
+		RecentAttendance: []models.Attendance{},
+	}
+	if member == nil {
+		return overview, nil
+	}
+
+	now := time.Now()
+	if overview.ActiveMembership, err = s.repo.ActiveMembership(member.ID, now); err != nil {
+		return nil, err
+	}
+	if overview.UpcomingBookings, err = s.repo.UpcomingBookings(member.ID, now, overviewUpcomingLimit); err != nil {
+		return nil, err
+	}
+	if overview.RecentAttendance, err = s.repo.RecentAttendance(member.ID, overviewAttendanceLimit); err != nil {
+		return nil, err
+	}
+	return overview, nil
+}
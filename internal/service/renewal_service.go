@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"go-blog/internal/models"
+	"go-blog/repositories"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// RenewalGracePeriod is how long a renewal may stay unpaid after it
+	// starts before it is cancelled
+	RenewalGracePeriod = 7 * 24 * time.Hour
+
+	// renewalLead is how long before the end of a period its renewal and
+	// payment are raised
+	renewalLead     = 3 * 24 * time.Hour
+	renewalInterval = 10 * time.Minute
+	renewalBatch    = 100
+)
+
+// RenewalService raises the next period and its payment for memberships
+// that renew automatically. Each membership is renewed at most once: rows
+// are claimed with SKIP LOCKED so replicas share the work, and the unique
+// renewed_from_id makes a second renewal of the same period fail.
+type RenewalService struct {
+	memberships *repositories.MembershipRepository
+	payments    *repositories.PaymentRepository
+	households  *repositories.HouseholdRepository
+	db          *gorm.DB
+}
+
+func NewRenewalService(memberships *repositories.MembershipRepository, payments *repositories.PaymentRepository, households *repositories.HouseholdRepository, db *gorm.DB) *RenewalService {
+	return &RenewalService{memberships: memberships, payments: payments, households: households, db: db}
+}
+
+// Run renews due memberships until ctx is cancelled
+func (s *RenewalService) Run(ctx context.Context) error {
+	ticker := time.NewTicker(renewalInterval)
+	defer ticker.Stop()
+	for {
+		if n, err := s.RenewDue(time.Now()); err != nil {
+			log.Printf("ERROR: RenewalService run failed after %d renewals: %v", n, err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// RenewDue renews every membership ending within the renewal lead time of
+// now and returns how many were renewed.
+func (s *RenewalService) RenewDue(now time.Time) (int, error) {
+	total := 0
+	for {
+		claimed, renewed, err := s.renewBatch(now)
+		total += renewed
+		// Stop when the queue is drained, or when a full batch only failed
+		// and would be claimed again
+		if err != nil || claimed < renewalBatch || renewed == 0 {
+			return total, err
+		}
+	}
+}
+
+func (s *RenewalService) renewBatch(now time.Time) (claimed, renewed int, err error) {
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		due, err := s.memberships.WithTx(tx).ClaimForRenewal(now.Add(renewalLead), renewalBatch)
+		if err != nil {
+			return err
+		}
+		claimed = len(due)
+		for i := range due {
+			m := &due[i]
+			// A savepoint per membership so one failure does not undo the batch
+			if err := tx.Transaction(func(tx *gorm.DB) error { return s.renew(tx, m) }); err != nil {
+				log.Printf("ERROR: RenewalService failed to renew membership %s: %v", m.ID, err)
+				continue
+			}
+			renewed++
+		}
+		return nil
+	})
+	return claimed, renewed, err
+}
+
+// renew creates the period following m and a pending payment for it. A
+// recurring promo code m was sold with discounts the renewal too, and the
+// member's account credit pays what it can.
+func (s *RenewalService) renew(tx *gorm.DB, m *models.Membership) error {
+	var promo *models.PromoCode
+	if m.PromoCodeID != nil {
+		p, err := repositories.NewPromoRepository(tx).GetByID(*m.PromoCodeID)
+		if err != nil {
+			return err
+		}
+		if !p.FirstPeriodOnly {
+			promo = p
+		}
+	}
+
+	next := &models.Membership{
+		MemberID:        m.MemberID,
+		PlanID:          m.PlanID,
+		PlanVersion:     m.Plan.Version,
+		StartDate:       m.EndDate,
+		EndDate:         m.Plan.PeriodEnd(m.EndDate),
+		Status:          models.MembershipPending,
+		AutoRenew:       true,
+		PaymentMethodID: m.PaymentMethodID,
+		RenewedFromID:   &m.ID,
+	}
+	if promo != nil {
+		next.PromoCodeID = &promo.ID
+	}
+	if err := s.memberships.WithTx(tx).Create(next); err != nil {
+		return err
+	}
+
+	payment := &models.Payment{
+		MemberID:     m.MemberID,
+		AmountCents:  m.Plan.PriceCents,
+		Method:       models.PaymentMethodAutoRenewal,
+		Status:       models.PaymentPending,
+		MembershipID: &next.ID,
+	}
+	if m.PaymentMethodID != nil {
+		payment.Reference = *m.PaymentMethodID
+	}
+	if payer, err := s.households.WithTx(tx).PrimaryPayerOfMember(m.MemberID); err == nil {
+		payment.PayerID = &payer
+	}
+	if err := discountPayment(tx, payment, promo, m.Plan.PriceCents); err != nil {
+		return err
+	}
+	if payment.AmountCents == 0 {
+		payment.Status = models.PaymentPaid
+	}
+	if err := s.payments.WithTx(tx).Create(payment); err != nil {
+		return err
+	}
+	if err := recordDiscounts(tx, payment, promo, true); err != nil {
+		return err
+	}
+
+	// The member is the actor of renewals nobody asked for
+	return tx.Create(models.NewAuditLog(m.Member.UserID, models.AuditMembershipRenewed, "membership", m.ID, map[string]interface{}{
+		"renewal_id":     next.ID,
+		"payment_id":     payment.ID,
+		"start_date":     next.StartDate,
+		"end_date":       next.EndDate,
+		"amount_cents":   payment.AmountCents,
+		"discount_cents": payment.DiscountCents,
+		"credit_cents":   payment.CreditCents,
+	})).Error
+}
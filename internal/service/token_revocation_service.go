@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"go-blog/internal/models"
+	"go-blog/repositories"
+	"go-blog/utils"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// revocationSyncInterval bounds how long a revocation made on one replica
+// takes to reach the others.
+const revocationSyncInterval = 15 * time.Second
+
+// TokenRevocationService records revoked tokens in the database and answers
+// "is this token revoked?" from an in-process snapshot, so AuthMiddleware never
+// hits the database. The snapshot is reloaded every revocationSyncInterval.
+type TokenRevocationService struct {
+	repo          *repositories.TokenRevocationRepository
+	refreshTokens *repositories.RefreshTokenRepository
+	db            *gorm.DB
+
+	mu         sync.RWMutex
+	revoked    map[string]time.Time    // jti -> token expiry
+	watermarks map[uuid.UUID]time.Time // user -> tokens issued before are revoked
+}
+
+func NewTokenRevocationService(repo *repositories.TokenRevocationRepository, refreshTokens *repositories.RefreshTokenRepository, db *gorm.DB) *TokenRevocationService {
+	return &TokenRevocationService{
+		repo:          repo,
+		refreshTokens: refreshTokens,
+		db:            db,
+		revoked:       make(map[string]time.Time),
+		watermarks:    make(map[uuid.UUID]time.Time),
+	}
+}
+
+// IsRevoked reports whether a token with the given jti, subject and issue time
+// has been revoked.
+func (s *TokenRevocationService) IsRevoked(jti string, userID string, issuedAt time.Time) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.revoked[jti]; ok {
+		return true
+	}
+	if id, err := uuid.Parse(userID); err == nil {
+		if before, ok := s.watermarks[id]; ok && issuedAt.Before(before) {
+			return true
+		}
+	}
+	return false
+}
+
+// Logout revokes one access token and, when given, the refresh token family
+// of the same session.
+func (s *TokenRevocationService) Logout(userID uuid.UUID, jti string, expiresAt time.Time, refreshToken string) error {
+	now := time.Now()
+	if err := s.repo.RevokeToken(&models.RevokedToken{JTI: jti, UserID: userID, ExpiresAt: expiresAt, RevokedAt: now}); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.revoked[jti] = expiresAt
+	s.mu.Unlock()
+
+	if refreshToken != "" {
+		stored, err := s.refreshTokens.GetByHash(utils.HashToken(refreshToken))
+		if err == nil && stored.UserID == userID {
+			return s.refreshTokens.RevokeFamily(stored.FamilyID, now)
+		}
+	}
+	return nil
+}
+
+// Consume revokes a single-use token, such as an MFA challenge, and reports
+// whether it was still unused. Of concurrent calls for one token only one
+// gets true.
+func (s *TokenRevocationService) Consume(userID uuid.UUID, jti string, expiresAt time.Time) (bool, error) {
+	claimed, err := s.repo.ClaimToken(&models.RevokedToken{JTI: jti, UserID: userID, ExpiresAt: expiresAt, RevokedAt: time.Now()})
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	s.revoked[jti] = expiresAt
+	s.mu.Unlock()
+	return claimed, nil
+}
+
+// LogoutAll revokes every token issued to userID so far. actorID is the user
+// that asked for it, which differs from userID when an admin ends the
+// sessions of someone else.
+func (s *TokenRevocationService) LogoutAll(actorID, userID uuid.UUID, reason string) error {
+	now := time.Now()
+	before := watermarkAt(now)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := repositories.NewTokenRevocationRepository(tx).SetWatermark(userID, before); err != nil {
+			return err
+		}
+		if err := s.refreshTokens.WithTx(tx).RevokeAllForUser(userID, now); err != nil {
+			return err
+		}
+		return tx.Create(models.NewAuditLog(actorID, models.AuditSessionsRevoked, "user", userID, map[string]interface{}{
+			"reason": reason,
+		})).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.watermarks[userID] = before
+	s.mu.Unlock()
+	return nil
+}
+
+// watermarkAt is the cut-off LogoutAll stores at now. iat has second
+// precision, so the cut-off is the start of the current second: tokens issued
+// in that second stay valid, which keeps the pair a password change hands
+// back usable.
+func watermarkAt(now time.Time) time.Time {
+	return now.Truncate(time.Second)
+}
+
+// Sync reloads the snapshot from the database.
+func (s *TokenRevocationService) Sync() error {
+	now := time.Now()
+
+	tokens, err := s.repo.ListRevokedTokens(now)
+	if err != nil {
+		return err
+	}
+	// Access tokens older than their TTL have expired, so only recent
+	// watermarks can still reject anything.
+	watermarks, err := s.repo.ListWatermarksSince(now.Add(-accessTokenTTL))
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Entries revoked locally while the queries ran may be missing from the
+	// result, so merge instead of replacing and only drop what has expired.
+	revoked := make(map[string]time.Time, len(tokens))
+	for jti, expiresAt := range s.revoked {
+		if expiresAt.After(now) {
+			revoked[jti] = expiresAt
+		}
+	}
+	for _, t := range tokens {
+		revoked[t.JTI] = t.ExpiresAt
+	}
+
+	marks := make(map[uuid.UUID]time.Time, len(watermarks))
+	for userID, before := range s.watermarks {
+		if before.After(now.Add(-accessTokenTTL)) {
+			marks[userID] = before
+		}
+	}
+	for _, w := range watermarks {
+		if w.RevokedBefore.After(marks[w.UserID]) {
+			marks[w.UserID] = w.RevokedBefore
+		}
+	}
+
+	s.revoked = revoked
+	s.watermarks = marks
+	return nil
+}
+
+// Run keeps the snapshot fresh and prunes expired rows until ctx is cancelled.
+func (s *TokenRevocationService) Run(ctx context.Context) error {
+	if err := s.Sync(); err != nil {
+		log.Printf("ERROR: TokenRevocationService initial sync failed: %v", err)
+	}
+
+	ticker := time.NewTicker(revocationSyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := s.repo.DeleteExpired(time.Now()); err != nil {
+				log.Printf("ERROR: TokenRevocationService failed to prune expired tokens: %v", err)
+			}
+			if err := s.Sync(); err != nil {
+				log.Printf("ERROR: TokenRevocationService sync failed: %v", err)
+			}
+		}
+	}
+}
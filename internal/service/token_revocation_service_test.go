@@ -0,0 +1,69 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-blog/internal/config"
+	"go-blog/internal/keyring"
+	"go-blog/middlewares"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// TestLogoutAllKeepsNewTokens replays a password change: every session is
+// ended and a new access token is issued straight away, which must still be
+// accepted by AuthMiddleware.
+func TestLogoutAllKeepsNewTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config.Keys = keyring.New()
+	if err := config.Keys.SetSigningKey(keyring.NewHMACKey([]byte("test-secret"))); err != nil {
+		t.Fatal(err)
+	}
+	revocations := &TokenRevocationService{revoked: map[string]time.Time{}, watermarks: map[uuid.UUID]time.Time{}}
+	middlewares.SetRevocationChecker(revocations)
+	defer middlewares.SetRevocationChecker(nil)
+
+	router := gin.New()
+	router.GET("/me", middlewares.AuthMiddleware(), func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+	call := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	userID := uuid.New()
+	now := time.Now()
+	oldToken, err := config.Keys.Sign(jwt.MapClaims{
+		"user_id": userID.String(),
+		"typ":     config.TokenTypeAccess,
+		"jti":     uuid.New().String(),
+		"iat":     now.Add(-time.Second).Unix(),
+		"exp":     now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the in-memory half of LogoutAll
+	revocations.watermarks[userID] = watermarkAt(now)
+	newToken, err := config.GenerateJWT(userID.String(), "member", config.TokenTypeAccess, accessTokenTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code := call(newToken); code != http.StatusOK {
+		t.Errorf("token issued after the password change: status %d, want %d", code, http.StatusOK)
+	}
+	if code := call(oldToken); code != http.StatusUnauthorized {
+		t.Errorf("token issued before the password change: status %d, want %d", code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,60 @@
+// Package storage keeps uploaded files (profile pictures, exports) behind a
+// BlobStore so the backend can be swapped between local disk and S3.
+package storage
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+)
+
+// ErrNotFound is returned by Get when the key does not exist.
+var ErrNotFound = errors.New("storage: object not found")
+
+// BlobStore stores opaque objects under slash-separated keys.
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+	// URL returns where clients can fetch the object.
+	URL(key string) string
+}
+
+// NewFromEnv picks a BlobStore based on STORAGE_DRIVER:
+//
+//	local  (default) files under STORAGE_LOCAL_DIR, served by the API at /uploads
+//	s3     S3_ENDPOINT, S3_REGION, S3_BUCKET, S3_ACCESS_KEY, S3_SECRET_KEY,
+//	       S3_PUBLIC_URL (optional, e.g. a CDN), S3_PATH_STYLE (true for MinIO)
+//
+// STORAGE_PUBLIC_URL overrides the URL prefix of local files.
+func NewFromEnv() BlobStore {
+	switch strings.ToLower(os.Getenv("STORAGE_DRIVER")) {
+	case "s3":
+		return &S3Store{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    envOr("S3_REGION", "us-east-1"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			PublicURL: os.Getenv("S3_PUBLIC_URL"),
+			PathStyle: os.Getenv("S3_PATH_STYLE") == "true",
+		}
+	case "", "local":
+		return &LocalStore{
+			Dir:       envOr("STORAGE_LOCAL_DIR", "uploads"),
+			URLPrefix: envOr("STORAGE_PUBLIC_URL", LocalURLPath),
+		}
+	default:
+		log.Printf("WARN: unknown STORAGE_DRIVER %q, storing files locally", os.Getenv("STORAGE_DRIVER"))
+		return &LocalStore{Dir: "uploads", URLPrefix: LocalURLPath}
+	}
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
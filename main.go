@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"go-blog/controllers"
 	"go-blog/internal/config"
+	"go-blog/internal/mailer"
 	"go-blog/internal/models"
+	"go-blog/internal/oidc"
 	services "go-blog/internal/service"
+	"go-blog/internal/storage"
 	"go-blog/logger"
 	"go-blog/middlewares"
 	"go-blog/repositories"
@@ -35,8 +38,12 @@ type Application struct {
 	Server    *http.Server
 	Health    *HealthChecker
 	DB        *gorm.DB
+	Jobs      []BackgroundJob
 }
 
+// BackgroundJob runs alongside the HTTP server until ctx is cancelled
+type BackgroundJob func(ctx context.Context) error
+
 // HealthChecker manages health checks
 type HealthChecker struct {
 	checks map[string]func() error
@@ -103,6 +110,21 @@ var (
 		[]string{"method", "path", "status"},
 	)
 
+	loginAttempts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auth_login_attempts_total",
+			Help: "Number of login attempts by result (success, failure, throttled, locked)",
+		},
+		[]string{"result"},
+	)
+
+	accountLockouts = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "auth_account_lockouts_total",
+			Help: "Number of accounts locked after repeated failed logins",
+		},
+	)
+
 	appVersion = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "app_version",
@@ -113,7 +135,7 @@ var (
 )
 
 func init() {
-	prometheus.MustRegister(httpRequests, httpDuration, appVersion)
+	prometheus.MustRegister(httpRequests, httpDuration, loginAttempts, accountLockouts, appVersion)
 }
 
 // --- Middlewares ---
@@ -235,6 +257,13 @@ func VersionHandler(c *gin.Context) {
 	})
 }
 
+// JWKSHandler publishes the public JWT verification keys so that clients can
+// verify tokens offline
+func JWKSHandler(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, config.Keys.JWKS())
+}
+
 // CBEBirrTestHandler - Simple endpoint to test token capture
 func CBEBirrTestHandler(c *gin.Context) {
 	// Log everything about the request
@@ -379,7 +408,7 @@ func initializeApplication(ctx context.Context) (*Application, error) {
 	})
 
 	// 6. Initialize Gin
-	router := setupRouter(healthChecker)
+	router, jobs := setupRouter(healthChecker)
 
 	// 7. Create HTTP server
 	server := &http.Server{
@@ -400,10 +429,11 @@ func initializeApplication(ctx context.Context) (*Application, error) {
 		Server: server,
 		Health: healthChecker,
 		DB:     config.DB,
+		Jobs:   jobs,
 	}, nil
 }
 
-func setupRouter(healthChecker *HealthChecker) *gin.Engine {
+func setupRouter(healthChecker *HealthChecker) (*gin.Engine, []BackgroundJob) {
 	r := gin.New()
 	r.RedirectTrailingSlash = false
 
@@ -411,7 +441,7 @@ func setupRouter(healthChecker *HealthChecker) *gin.Engine {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins: []string{"*"}, // Use wildcard
 		AllowMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "HEAD"},
-		AllowHeaders: []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With", "X-CSRF-Token"},
+		AllowHeaders: []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With", "X-CSRF-Token", "X-Device-ID", "X-API-Key"},
 		ExposeHeaders: []string{"Content-Length", "Content-Type", "X-Request-ID"},
 		AllowCredentials: false, // IMPORTANT: Must be false for wildcard *
 		MaxAge: 12 * time.Hour,
@@ -438,6 +468,7 @@ func setupRouter(healthChecker *HealthChecker) *gin.Engine {
 	r.GET("/ready", ReadyHandler(&Application{Health: healthChecker}))
 	r.GET("/version", VersionHandler)
 	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	r.GET("/.well-known/jwks.json", JWKSHandler)
 
 	// ✅ ADD CBEBirr Test Endpoints
 	r.GET("/api/cbebirr-test", CBEBirrTestHandler)
@@ -448,18 +479,18 @@ func setupRouter(healthChecker *HealthChecker) *gin.Engine {
 	r.OPTIONS("/*path", func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS, HEAD")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Requested-With, X-CSRF-Token")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Requested-With, X-CSRF-Token, X-Device-ID, X-API-Key")
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Status(http.StatusOK)
 	})
 
 	// Initialize dependencies and register routes
-	registerAllRoutes(r)
+	jobs := registerAllRoutes(r)
 
 	// Print all registered routes for debugging
 	printRoutes(r)
 
-	return r
+	return r, jobs
 }
 
 // Add this function to debug routes
@@ -475,25 +506,63 @@ func printRoutes(r *gin.Engine) {
 	logger.Log.Info("=== End Registered Routes ===")
 }
 
-func registerAllRoutes(r *gin.Engine) {
+func registerAllRoutes(r *gin.Engine) []BackgroundJob {
 	// Repositories
 	userRepo := repositories.NewUserRepository(config.DB)
-	authService := services.NewAuthService(userRepo, config.DB)
-	authController := controllers.NewAuthController(authService)
+	refreshTokenRepo := repositories.NewRefreshTokenRepository(config.DB)
+	tokenRevocationRepo := repositories.NewTokenRevocationRepository(config.DB)
+	userTokenRepo := repositories.NewUserTokenRepository(config.DB)
+	gymRepo := repositories.NewGymRepository(config.DB)
+	auditLogRepo := repositories.NewAuditLogRepository(config.DB)
+	recoveryCodeRepo := repositories.NewRecoveryCodeRepository(config.DB)
+	userIdentityRepo := repositories.NewUserIdentityRepository(config.DB)
+	householdRepo := repositories.NewHouseholdRepository(config.DB)
+	mail := mailer.NewFromEnv()
+	blobs := storage.NewFromEnv()
+	if local, ok := blobs.(*storage.LocalStore); ok {
+		r.Static(storage.LocalURLPath, local.Dir)
+	}
+
+	loginThrottle := services.NewLoginThrottle(userRepo, auditLogRepo, loginAttempts, accountLockouts)
+	authService := services.NewAuthService(userRepo, refreshTokenRepo, gymRepo, householdRepo, loginThrottle, config.DB)
+	tokenRevocationService := services.NewTokenRevocationService(tokenRevocationRepo, refreshTokenRepo, config.DB)
+	accountService := services.NewAccountService(userRepo, userTokenRepo, tokenRevocationService, mail, config.DB)
+	mfaService := services.NewMFAService(userRepo, recoveryCodeRepo, auditLogRepo, loginThrottle, authService, tokenRevocationService)
+	authController := controllers.NewAuthController(authService, tokenRevocationService, accountService, loginThrottle, mfaService)
+	accountController := controllers.NewAccountController(accountService)
+	mfaController := controllers.NewMFAController(mfaService, authService)
+	oidcService := services.NewOIDCService(oidc.ProvidersFromEnv(), userIdentityRepo, auditLogRepo, loginThrottle, config.DB)
+	oidcController := controllers.NewOIDCController(oidcService, authService, mfaService)
+
+	// Revoked tokens are rejected by every AuthMiddleware
+	middlewares.SetRevocationChecker(tokenRevocationService)
+
+	apiKeyService := services.NewAPIKeyService(repositories.NewAPIKeyRepository(config.DB), gymRepo, auditLogRepo, config.DB)
+	apiKeyController := controllers.NewAPIKeyController(apiKeyService)
+	// Kiosks and door controllers authenticate with X-API-Key where allowed
+	middlewares.SetAPIKeyAuthenticator(apiKeyService)
+
+	impersonationService := services.NewImpersonationService(userRepo, auditLogRepo)
+	impersonationController := controllers.NewImpersonationController(impersonationService)
+	// Every request made while impersonating is audited under the admin
+	middlewares.SetImpersonationRecorder(impersonationService)
 
 	attendanceRepo := repositories.NewAttendanceRepository(config.DB)
-	attendanceService := services.NewAttendanceService(attendanceRepo)
+	admissionService := services.NewAdmissionService(repositories.NewSessionUsageRepository(config.DB))
+	attendanceService := services.NewAttendanceService(attendanceRepo, admissionService, config.DB)
 	attendanceController := controllers.NewAttendanceController(attendanceService)
 
 	classSessionRepo := repositories.NewClassSessionRepository(config.DB)
 	classSessionService := services.NewClassSessionService(classSessionRepo)
 	classSessionController := controllers.NewClassSessionController(classSessionService)
 
+	bookingService := services.NewBookingService(repositories.NewBookingRepository(config.DB), admissionService, config.DB)
+	bookingController := controllers.NewBookingController(bookingService)
+
 	classRepo := repositories.NewClassRepository(config.DB)
 	classService := services.NewClassService(classRepo)
 	classController := controllers.NewClassController(classService)
 
-	gymRepo := repositories.NewGymRepository(config.DB)
 	gymService := services.NewGymService(gymRepo)
 	gymController := controllers.NewGymController(gymService)
 
@@ -502,47 +571,85 @@ func registerAllRoutes(r *gin.Engine) {
 	paymentRepo := repositories.NewPaymentRepository(config.DB)
 
 	// Services
-	planService := services.NewPlanService(planRepo)
-	memberService := services.NewMembershipService(memberRepo)
-	paymentService := services.NewPaymentService(paymentRepo)
+	planService := services.NewPlanService(planRepo, memberRepo, config.DB)
+	memberService := services.NewMembershipService(memberRepo, paymentRepo, householdRepo, config.DB)
+	paymentService := services.NewPaymentService(paymentRepo, householdRepo, memberService, config.DB)
+	renewalService := services.NewRenewalService(memberRepo, paymentRepo, householdRepo, config.DB)
+	passService := services.NewPassService(repositories.NewPassRepository(config.DB), attendanceRepo, paymentRepo, admissionService, memberService, config.DB)
+	discountService := services.NewDiscountService(repositories.NewPromoRepository(config.DB), repositories.NewReferralRepository(config.DB), config.DB)
 
 	// Controllers
 	planController := controllers.NewPlanController(planService)
 	memberController := controllers.NewMembershipController(memberService)
 	paymentController := controllers.NewPaymentController(paymentService)
+	passController := controllers.NewPassController(passService)
+	discountController := controllers.NewDiscountController(discountService)
+
+	householdService := services.NewHouseholdService(householdRepo, userRepo, paymentRepo, authService, config.DB)
+	householdController := controllers.NewHouseholdController(householdService)
 
 	memberRepo1 := repositories.NewMemberRepository(config.DB)
 	member1Service := services.NewMemberService(memberRepo1)
 	memberController1 := controllers.NewMemberController(member1Service)
+	memberImportService := services.NewMemberImportService(authService, accountService, planRepo, gymRepo, config.DB)
+	memberImportController := controllers.NewMemberImportController(memberImportService)
+
+	profileRepo := repositories.NewProfileRepository(config.DB)
+	profileService := services.NewProfileService(profileRepo, tokenRevocationService, config.DB)
+	profileController := controllers.NewProfileController(profileService, authService)
+	profilePhotoService := services.NewProfilePhotoService(profileRepo, blobs)
+	profilePhotoController := controllers.NewProfilePhotoController(profilePhotoService, member1Service)
+	privacyService := services.NewPrivacyService(repositories.NewPrivacyRepository(config.DB), profilePhotoService, blobs, tokenRevocationService, auditLogRepo, config.DB)
+	privacyController := controllers.NewPrivacyController(privacyService)
 
 	// Auth routes
 	auth := r.Group("/auth")
 	{
-		auth.POST("/register", authController.Register)
+		auth.POST("/register", middlewares.OptionalAuthMiddleware(), authController.Register)
 		auth.POST("/login", authController.Login)
-		auth.GET("/alluser", authController.GetAllUsers)
-		auth.GET("/trainer", authController.GetTrainers)
+		auth.POST("/login/mfa", mfaController.LoginMFA)
+		auth.POST("/refresh", authController.Refresh)
+		auth.POST("/logout", middlewares.AuthMiddleware(), authController.Logout)
+		auth.POST("/logout-all", middlewares.AuthMiddleware(), authController.LogoutAll)
+		auth.POST("/forgot-password", accountController.ForgotPassword)
+		auth.POST("/reset-password", accountController.ResetPassword)
+		auth.POST("/verify-email", accountController.VerifyEmail)
+		auth.POST("/resend-verification", accountController.ResendVerification)
+		auth.GET("/oidc/providers", oidcController.Providers)
+		auth.GET("/oidc/:provider/login", oidcController.Login)
+		auth.GET("/oidc/:provider/callback", oidcController.Callback)
+		auth.POST("/2fa/enroll", middlewares.MFAEnrollmentMiddleware(), mfaController.Enroll)
+		auth.GET("/2fa/qr.png", middlewares.MFAEnrollmentMiddleware(), mfaController.QRCode)
+		auth.POST("/2fa/verify", middlewares.MFAEnrollmentMiddleware(), mfaController.Verify)
+		auth.POST("/2fa/recovery-codes", middlewares.AuthMiddleware(), mfaController.RegenerateRecoveryCodes)
+		auth.POST("/2fa/disable", middlewares.AuthMiddleware(), mfaController.Disable)
+		auth.POST("/users/:id/unlock", middlewares.AuthMiddleware(), middlewares.RequireRoles(models.RoleAdmin), authController.UnlockUser)
+		auth.POST("/users/:id/logout-all", middlewares.AuthMiddleware(), middlewares.RequireRoles(models.RoleAdmin), authController.RevokeUserSessions)
+		auth.POST("/users/:id/impersonate", middlewares.AuthMiddleware(), middlewares.RequireRoles(models.RoleAdmin), impersonationController.Start)
+		auth.GET("/alluser", middlewares.AuthMiddleware(), middlewares.RequireRoles(middlewares.StaffRoles()...), authController.GetAllUsers)
+		auth.GET("/trainer", middlewares.AuthMiddleware(), authController.GetTrainers)
 	}
 
 	// Register all routes
-	routes.RegisterAttendanceRoutes(r, attendanceController)
+	routes.RegisterAttendanceRoutes(r, attendanceController, passController)
 	routes.RegisterClassSessionRoutes(r, classSessionController)
 	routes.RegisterClassRoutes(r, classController)
 	routes.RegisterGymRoutes(r, gymController)
 	routes.RegisterRoutes(r, planController, memberController, paymentController)
-	routes.RegisterMemberRoutes(r, memberController1)
-
-	// Protected routes
-	protected := r.Group("/protected")
-	protected.Use(middlewares.AuthMiddleware())
-	{
-		protected.GET("/profile", func(c *gin.Context) {
-			requestID := c.GetString("requestID")
-			c.JSON(http.StatusOK, gin.H{
-				"message":   "This is protected",
-				"request_id": requestID,
-			})
-		})
+	routes.RegisterMemberRoutes(r, memberController1, profilePhotoController, memberImportController)
+	routes.RegisterMeRoutes(r, profileController, profilePhotoController, privacyController, householdController)
+	routes.RegisterBookingRoutes(r, bookingController)
+	routes.RegisterPassRoutes(r, passController)
+	routes.RegisterDiscountRoutes(r, discountController)
+	routes.RegisterHouseholdRoutes(r, householdController)
+	routes.RegisterPrivacyRoutes(r, privacyController)
+	routes.RegisterAPIKeyRoutes(r, apiKeyController)
+
+	return []BackgroundJob{
+		tokenRevocationService.Run,
+		loginThrottle.Run,
+		memberService.RunLifecycle,
+		renewalService.Run,
 	}
 }
 
@@ -571,6 +678,14 @@ func (app *Application) Run(ctx context.Context) error {
 		return nil
 	})
 
+	// Start background jobs
+	for _, job := range app.Jobs {
+		job := job
+		g.Go(func() error {
+			return job(ctx)
+		})
+	}
+
 	// Graceful shutdown handler
 	g.Go(func() error {
 		<-ctx.Done()
@@ -2,36 +2,181 @@
 package middlewares
 
 import (
+	"errors"
 	"go-blog/internal/config"
+	"go-blog/logger"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
 )
 
+var errMissingToken = errors.New("Authorization header required")
+
+// RevocationChecker reports whether an otherwise valid token was revoked
+// (logout, logout-all, or an admin ending someone's sessions).
+type RevocationChecker interface {
+    IsRevoked(jti string, userID string, issuedAt time.Time) bool
+}
+
+var revocationChecker RevocationChecker
+
+// SetRevocationChecker makes AuthMiddleware reject revoked tokens.
+func SetRevocationChecker(checker RevocationChecker) {
+    revocationChecker = checker
+}
+
+// ImpersonationRecorder writes requests made with an impersonation token to
+// the audit log.
+type ImpersonationRecorder interface {
+    RecordImpersonatedRequest(actorID, userID uuid.UUID, method, path string, status int, ip string)
+}
+
+var impersonationRecorder ImpersonationRecorder
+
+// SetImpersonationRecorder makes AuthMiddleware audit impersonated requests.
+func SetImpersonationRecorder(recorder ImpersonationRecorder) {
+    impersonationRecorder = recorder
+}
+
+// Impersonation tokens may only read, apart from ending the session.
+var impersonationWritableRoutes = map[string]bool{
+    "/auth/logout": true,
+}
+
 func AuthMiddleware() gin.HandlerFunc {
+    return authenticate(config.TokenTypeAccess)
+}
+
+// MFAEnrollmentMiddleware is used on the 2FA enrollment endpoints. Besides
+// normal access tokens it accepts the enrollment token handed out by
+// /auth/login to users who must set up 2FA before they can log in.
+func MFAEnrollmentMiddleware() gin.HandlerFunc {
+    return authenticate(config.TokenTypeAccess, config.TokenTypeMFAEnroll)
+}
+
+func authenticate(tokenTypes ...string) gin.HandlerFunc {
     return func(c *gin.Context) {
-        authHeader := c.GetHeader("Authorization")
-        if authHeader == "" {
-            c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+        claims, err := parseBearerToken(c, tokenTypes...)
+        if err == errMissingToken {
+            c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
             c.Abort()
             return
         }
-
-        tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-        token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-            return config.JwtSecret, nil
-        })
-        if err != nil || !token.Valid {
+        if err != nil {
             c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
             c.Abort()
             return
         }
+        if isRevoked(claims) {
+            c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+            c.Abort()
+            return
+        }
+
+        setClaims(c, claims)
+        next(c)
+    }
+}
+
+// OptionalAuthMiddleware populates the caller identity when a valid token is
+// sent, but lets anonymous requests through. Handlers decide what to allow.
+func OptionalAuthMiddleware() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if claims, err := parseBearerToken(c, config.TokenTypeAccess); err == nil && !isRevoked(claims) {
+            setClaims(c, claims)
+        }
+        next(c)
+    }
+}
 
-        claims := token.Claims.(jwt.MapClaims)
-        c.Set("user_id", claims["user_id"])
-        c.Set("user_type", claims["user_type"])
+// next runs the handlers. Requests made while impersonating are refused if
+// they would change anything, and are logged and audited under the admin.
+func next(c *gin.Context) {
+    actorID, ok := ImpersonatorID(c)
+    if !ok {
         c.Next()
+        return
+    }
+
+    c.Header("X-Impersonator-ID", actorID.String())
+    switch c.Request.Method {
+    case http.MethodGet, http.MethodHead, http.MethodOptions:
+        c.Next()
+    default:
+        if impersonationWritableRoutes[c.FullPath()] {
+            c.Next()
+        } else {
+            c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Impersonation sessions are read-only"})
+        }
+    }
+
+    userID, _ := CurrentUserID(c)
+    logger.Log.WithFields(logrus.Fields{
+        "impersonator_id": actorID,
+        "user_id":         userID,
+        "method":          c.Request.Method,
+        "path":            c.Request.URL.Path,
+        "status":          c.Writer.Status(),
+        "request_id":      c.GetString("requestID"),
+    }).Info("Impersonated request")
+    if impersonationRecorder != nil {
+        impersonationRecorder.RecordImpersonatedRequest(actorID, userID, c.Request.Method, c.FullPath(), c.Writer.Status(), c.ClientIP())
+    }
+}
+
+// ImpersonatorID returns the admin behind an impersonation token. The
+// impersonated user is still reported by CurrentUserID.
+func ImpersonatorID(c *gin.Context) (uuid.UUID, bool) {
+    id, err := uuid.Parse(c.GetString("impersonator_id"))
+    if err != nil {
+        return uuid.Nil, false
+    }
+    return id, true
+}
+
+func parseBearerToken(c *gin.Context, tokenTypes ...string) (jwt.MapClaims, error) {
+    authHeader := c.GetHeader("Authorization")
+    if authHeader == "" {
+        return nil, errMissingToken
+    }
+
+    tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+
+    // ParseJWT also rejects refresh tokens, which must never be usable as access tokens
+    return config.ParseJWT(tokenString, tokenTypes...)
+}
+
+func isRevoked(claims jwt.MapClaims) bool {
+    if revocationChecker == nil {
+        return false
+    }
+    jti, _ := claims["jti"].(string)
+    userID, _ := claims["user_id"].(string)
+    issuedAt, err := claims.GetIssuedAt()
+    if err != nil || issuedAt == nil {
+        return true
+    }
+    // Ending every session of the admin also ends their impersonations
+    if actorID := config.ActorID(claims); actorID != "" && revocationChecker.IsRevoked(jti, actorID, issuedAt.Time) {
+        return true
+    }
+    return revocationChecker.IsRevoked(jti, userID, issuedAt.Time)
+}
+
+func setClaims(c *gin.Context, claims jwt.MapClaims) {
+    c.Set("user_id", claims["user_id"])
+    c.Set("user_type", claims["user_type"])
+    c.Set("jti", claims["jti"])
+    c.Set("token_type", claims["typ"])
+    if actorID := config.ActorID(claims); actorID != "" {
+        c.Set("impersonator_id", actorID)
+    }
+    if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+        c.Set("token_expires_at", exp.Time)
     }
 }
@@ -0,0 +1,45 @@
+// middlewares/policy.go
+package middlewares
+
+import "go-blog/internal/models"
+
+// RoutePolicy lists the roles allowed to read (GET/HEAD) and write
+// (every other method) within a route group. Scope names the API key
+// resource of the group: keys need "<Scope>:read" or "<Scope>:write".
+// Groups without a Scope cannot be called with API keys.
+type RoutePolicy struct {
+	Read  []string
+	Write []string
+	Scope string
+}
+
+var (
+	allRoles   = []string{models.RoleAdmin, models.RoleStaff, models.RoleTrainer, models.RoleMember}
+	staffRoles = []string{models.RoleAdmin, models.RoleStaff}
+	coachRoles = []string{models.RoleAdmin, models.RoleStaff, models.RoleTrainer}
+)
+
+// RoutePolicies maps each route group to the roles that may call it.
+// Routes that expose another member's data add MemberSelfOnly or a stricter
+// RequireRoles on top of this table.
+var RoutePolicies = map[string]RoutePolicy{
+	"attendance":   {Read: allRoles, Write: coachRoles, Scope: models.ScopeResourceAttendance},
+	"classsession": {Read: allRoles, Write: coachRoles, Scope: models.ScopeResourceSessions},
+	"class":        {Read: allRoles, Write: staffRoles, Scope: models.ScopeResourceClasses},
+	"gymx":         {Read: allRoles, Write: []string{models.RoleAdmin}},
+	"api":          {Read: allRoles, Write: staffRoles},
+	"members":      {Read: allRoles, Write: staffRoles},
+	"bookings":     {Read: allRoles, Write: allRoles},
+	"households":   {Read: allRoles, Write: allRoles},
+	"passes":       {Read: allRoles, Write: allRoles},
+}
+
+// StaffRoles returns the roles that manage members and payments.
+func StaffRoles() []string {
+	return staffRoles
+}
+
+// CoachRoles returns the roles that run classes and record attendance.
+func CoachRoles() []string {
+	return coachRoles
+}
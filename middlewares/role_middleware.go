@@ -0,0 +1,140 @@
+// middlewares/role_middleware.go
+package middlewares
+
+import (
+	"go-blog/internal/config"
+	"go-blog/internal/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CurrentRole returns the normalized role of the authenticated caller, or ""
+// for API key callers, which have no role.
+func CurrentRole(c *gin.Context) string {
+	if IsAPIKey(c) {
+		return ""
+	}
+	return models.NormalizeRole(c.GetString("user_type"))
+}
+
+// CurrentUserID returns the authenticated caller's user ID.
+func CurrentUserID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// HasRole reports whether the caller has one of the given roles.
+func HasRole(c *gin.Context, roles ...string) bool {
+	role := CurrentRole(c)
+	for _, r := range roles {
+		if role == r {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireRoles only lets callers with one of the given roles through.
+// It must run after AuthMiddleware.
+func RequireRoles(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasRole(c, roles...) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You do not have permission to access this resource"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePolicy applies the RoutePolicies entry for a route group, using the
+// read roles for GET/HEAD and the write roles for everything else. API key
+// callers need the matching scope instead of a role.
+func RequirePolicy(group string) gin.HandlerFunc {
+	policy, ok := RoutePolicies[group]
+	if !ok {
+		panic("middlewares: no route policy defined for group " + group)
+	}
+
+	read := RequireRoles(policy.Read...)
+	write := RequireRoles(policy.Write...)
+
+	return func(c *gin.Context) {
+		access, check := models.ScopeWrite, write
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			access, check = models.ScopeRead, read
+		}
+
+		if IsAPIKey(c) {
+			scope := policy.Scope + ":" + access
+			if policy.Scope == "" || !HasScope(c, scope) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API key lacks the required scope", "required_scope": scope})
+				return
+			}
+			c.Next()
+			return
+		}
+		check(c)
+	}
+}
+
+// MemberSelfOnly restricts callers with the Member role to records of their
+// own member profile or of their dependents. param names the route parameter
+// holding the member ID. Other roles are not affected.
+func MemberSelfOnly(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		memberID, err := uuid.Parse(c.Param(param))
+		if CurrentRole(c) == models.RoleMember && (err != nil || !CanActForMember(c, memberID)) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Members may only access their own records or those of their dependents"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// CanActForMember reports whether the caller may act on a member profile:
+// staff and trainers always may, members for themselves and for dependents
+// they hold an active guardian link to.
+func CanActForMember(c *gin.Context, memberID uuid.UUID) bool {
+	if CurrentRole(c) != models.RoleMember {
+		return HasRole(c, coachRoles...)
+	}
+	if own, err := CurrentMemberID(c); err == nil && own == memberID {
+		return true
+	}
+
+	userID, ok := CurrentUserID(c)
+	if !ok {
+		return false
+	}
+	var count int64
+	err := config.DB.Table("guardian_links g").
+		Joins("JOIN members m ON m.user_id = g.dependent_id AND m.deleted_at IS NULL").
+		Where("g.guardian_id = ? AND m.id = ? AND g.revoked_at IS NULL", userID, memberID).
+		Count(&count).Error
+	return err == nil && count > 0
+}
+
+// CurrentMemberID resolves the member profile belonging to the caller.
+// The result is cached on the request context.
+func CurrentMemberID(c *gin.Context) (uuid.UUID, error) {
+	if cached, ok := c.Get("member_id"); ok {
+		return cached.(uuid.UUID), nil
+	}
+
+	userID, ok := CurrentUserID(c)
+	if !ok {
+		return uuid.Nil, errMissingToken
+	}
+
+	var member models.Member
+	if err := config.DB.Select("id").Where("user_id = ?", userID).First(&member).Error; err != nil {
+		return uuid.Nil, err
+	}
+	c.Set("member_id", member.ID)
+	return member.ID, nil
+}
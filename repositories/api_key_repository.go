@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"go-blog/internal/models"
+	"go-blog/internal/query"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type APIKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+func (r *APIKeyRepository) WithTx(tx *gorm.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: tx}
+}
+
+// APIKeyListSpec is what the API key list can be filtered and sorted by
+var APIKeyListSpec = query.Spec{
+	Filters: map[string]query.Field{
+		"gym_id": {Column: "gym_id", Type: query.UUID},
+		"name":   {Column: "name", Type: query.String},
+	},
+	Sorts: map[string]string{
+		"name":       "name",
+		"created_at": "created_at",
+	},
+	DefaultSort: "-created_at",
+}
+
+func (r *APIKeyRepository) List(req *query.Request) (*query.Page[models.APIKey], error) {
+	return query.Find[models.APIKey](r.db, APIKeyListSpec, req)
+}
+
+func (r *APIKeyRepository) Create(key *models.APIKey) error {
+	return r.db.Create(key).Error
+}
+
+func (r *APIKeyRepository) GetByID(id uuid.UUID) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.First(&key, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *APIKeyRepository) GetByHash(hash string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.Where("key_hash = ?", hash).First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *APIKeyRepository) UpdateFields(id uuid.UUID, fields map[string]interface{}) error {
+	return r.db.Model(&models.APIKey{}).Where("id = ?", id).Updates(fields).Error
+}
+
+func (r *APIKeyRepository) TouchUsage(id uuid.UUID, ip string, at time.Time) error {
+	return r.db.Model(&models.APIKey{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+		"last_used_at": at,
+		"last_used_ip": ip,
+	}).Error
+}
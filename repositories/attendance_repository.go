@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"go-blog/internal/models"
+	"go-blog/internal/query"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -15,6 +16,39 @@ func NewAttendanceRepository(db *gorm.DB) *AttendanceRepository {
 	return &AttendanceRepository{db: db}
 }
 
+func (r *AttendanceRepository) WithTx(tx *gorm.DB) *AttendanceRepository {
+	return &AttendanceRepository{db: tx}
+}
+
+// InGym limits the returned repository to check-ins for sessions held at gymID
+func (r *AttendanceRepository) InGym(gymID uuid.UUID) *AttendanceRepository {
+	return &AttendanceRepository{db: r.db.Where(
+		"attendances.session_id IN (SELECT cs.id FROM class_sessions cs JOIN classes c ON c.id = cs.class_id WHERE c.gym_id = ?)", gymID,
+	).Session(&gorm.Session{})}
+}
+
+// SessionGymID returns the gym a session is held at
+func (r *AttendanceRepository) SessionGymID(sessionID uuid.UUID) (uuid.UUID, error) {
+	var row struct{ GymID uuid.UUID }
+	err := r.db.Session(&gorm.Session{NewDB: true}).Raw(
+		"SELECT c.gym_id FROM class_sessions cs JOIN classes c ON c.id = cs.class_id WHERE cs.id = ?", sessionID,
+	).Scan(&row).Error
+	if err == nil && row.GymID == uuid.Nil {
+		err = gorm.ErrRecordNotFound
+	}
+	return row.GymID, err
+}
+
+// MemberGymID returns the home gym of a member's user account, nil when the
+// user has none
+func (r *AttendanceRepository) MemberGymID(memberID uuid.UUID) (*uuid.UUID, error) {
+	var row struct{ GymID *uuid.UUID }
+	err := r.db.Session(&gorm.Session{NewDB: true}).Raw(
+		"SELECT u.gym_id FROM members m JOIN users u ON u.user_id = m.user_id WHERE m.id = ?", memberID,
+	).Scan(&row).Error
+	return row.GymID, err
+}
+
 func (r *AttendanceRepository) Create(attendance *models.Attendance) error {
 	return r.db.Create(attendance).Error
 }
@@ -28,14 +62,27 @@ func (r *AttendanceRepository) FindByMemberAndSession(memberID, sessionID uuid.U
 	return &record, nil
 }
 
-func (r *AttendanceRepository) FindAllByMember(memberID uuid.UUID) ([]models.Attendance, error) {
-	var records []models.Attendance
-	err := r.db.Preload("Session").Where("member_id = ?", memberID).Order("checked_in_at desc").Find(&records).Error
-	return records, err
+// AttendanceListSpec is what attendance lists can be filtered and sorted by
+var AttendanceListSpec = query.Spec{
+	Filters: map[string]query.Field{
+		"member_id":      {Column: "member_id", Type: query.UUID},
+		"session_id":     {Column: "session_id", Type: query.UUID},
+		"checkin_method": {Column: "checkin_method", Type: query.String},
+		"checked_in_at":  {Column: "checked_in_at", Type: query.Time},
+	},
+	Sorts: map[string]string{
+		"checked_in_at": "checked_in_at",
+	},
+	DefaultSort: "-checked_in_at",
+}
+
+// ListByMember returns one page of a member's check-ins
+func (r *AttendanceRepository) ListByMember(memberID uuid.UUID, req *query.Request) (*query.Page[models.Attendance], error) {
+	return query.Find[models.Attendance](r.db.Where("member_id = ?", memberID), AttendanceListSpec, req, "Session")
 }
 
-func (r *AttendanceRepository) FindAll() ([]models.Attendance, error) {
-	var records []models.Attendance
-	err := r.db.Preload("Member").Preload("Session").Find(&records).Error
-	return records, err
+// List returns one page of all check-ins. The member's user carries the
+// profile picture shown on the check-in screen.
+func (r *AttendanceRepository) List(req *query.Request) (*query.Page[models.Attendance], error) {
+	return query.Find[models.Attendance](r.db, AttendanceListSpec, req, "Member.User", "Session")
 }
@@ -0,0 +1,29 @@
+package repositories
+
+import (
+	"go-blog/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type AuditLogRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditLogRepository(db *gorm.DB) *AuditLogRepository {
+	return &AuditLogRepository{db: db}
+}
+
+func (r *AuditLogRepository) Create(entry *models.AuditLog) error {
+	return r.db.Create(entry).Error
+}
+
+// GetByTarget lists the audit trail of one record, newest first
+func (r *AuditLogRepository) GetByTarget(targetType string, targetID uuid.UUID) ([]models.AuditLog, error) {
+	var entries []models.AuditLog
+	err := r.db.Where("target_type = ? AND target_id = ?", targetType, targetID).
+		Order("created_at desc").
+		Find(&entries).Error
+	return entries, err
+}
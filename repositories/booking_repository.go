@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"go-blog/internal/models"
+	"go-blog/internal/query"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BookingRepository struct {
+	db *gorm.DB
+}
+
+func NewBookingRepository(db *gorm.DB) *BookingRepository {
+	return &BookingRepository{db: db}
+}
+
+func (r *BookingRepository) WithTx(tx *gorm.DB) *BookingRepository {
+	return &BookingRepository{db: tx}
+}
+
+// BookingListSpec is what booking lists can be filtered and sorted by
+var BookingListSpec = query.Spec{
+	Filters: map[string]query.Field{
+		"session_id": {Column: "session_id", Type: query.UUID},
+		"status":     {Column: "status", Type: query.String},
+		"created_at": {Column: "created_at", Type: query.Time},
+	},
+	Sorts: map[string]string{
+		"created_at": "created_at",
+	},
+	DefaultSort: "-created_at",
+}
+
+// ListByMember returns one page of a member's bookings with their sessions
+func (r *BookingRepository) ListByMember(memberID uuid.UUID, req *query.Request) (*query.Page[models.Booking], error) {
+	return query.Find[models.Booking](r.db.Where("member_id = ?", memberID), BookingListSpec, req, "Session.Class")
+}
+
+// LockSession loads a session and locks its row until the transaction ends,
+// so concurrent bookings cannot overfill it.
+func (r *BookingRepository) LockSession(id uuid.UUID) (*models.ClassSession, error) {
+	var session models.ClassSession
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&session, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// CountBooked counts the active bookings of a session
+func (r *BookingRepository) CountBooked(sessionID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Booking{}).Where("session_id = ? AND status = ?", sessionID, models.BookingBooked).Count(&count).Error
+	return count, err
+}
+
+// FindBooked returns a member's active booking for a session
+func (r *BookingRepository) FindBooked(memberID, sessionID uuid.UUID) (*models.Booking, error) {
+	var booking models.Booking
+	err := r.db.Where("member_id = ? AND session_id = ? AND status = ?", memberID, sessionID, models.BookingBooked).First(&booking).Error
+	if err != nil {
+		return nil, err
+	}
+	return &booking, nil
+}
+
+func (r *BookingRepository) Create(booking *models.Booking) error {
+	return r.db.Create(booking).Error
+}
+
+func (r *BookingRepository) GetByID(id uuid.UUID) (*models.Booking, error) {
+	var booking models.Booking
+	if err := r.db.Preload("Session").First(&booking, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &booking, nil
+}
+
+func (r *BookingRepository) SetStatus(id uuid.UUID, status string) error {
+	return r.db.Model(&models.Booking{}).Where("id = ?", id).Update("status", status).Error
+}
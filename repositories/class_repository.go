@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"go-blog/internal/models"
+	"go-blog/internal/query"
 
 	"gorm.io/gorm"
 )
@@ -19,9 +20,24 @@ func (r *ClassRepository) Create(class *models.Class) error {
 	return r.db.Create(class).Error
 }
 
-// List all classes
-func (r *ClassRepository) ListAll(classes *[]models.Class) error {
-	return r.db.Preload("Gym").Preload("Trainer").Find(classes).Error
+// ClassListSpec is what the class list can be filtered and sorted by
+var ClassListSpec = query.Spec{
+	Filters: map[string]query.Field{
+		"gym_id":     {Column: "gym_id", Type: query.UUID},
+		"trainer_id": {Column: "trainer_id", Type: query.UUID},
+		"title":      {Column: "title", Type: query.String},
+		"category":   {Column: "category", Type: query.String},
+	},
+	Sorts: map[string]string{
+		"title":      "title",
+		"created_at": "created_at",
+	},
+	DefaultSort: "title",
+}
+
+// List one page of classes
+func (r *ClassRepository) List(req *query.Request) (*query.Page[models.Class], error) {
+	return query.Find[models.Class](r.db, ClassListSpec, req, "Gym", "Trainer")
 }
 
 // Get class by ID
@@ -2,6 +2,9 @@ package repositories
 
 import (
 	"go-blog/internal/models"
+	"go-blog/internal/query"
+
+	"github.com/google/uuid"
 
 	"gorm.io/gorm"
 )
@@ -14,14 +17,41 @@ func NewClassSessionRepository(db *gorm.DB) *ClassSessionRepository {
 	return &ClassSessionRepository{db: db}
 }
 
+// InGym limits the returned repository to sessions of classes held at gymID
+func (r *ClassSessionRepository) InGym(gymID uuid.UUID) *ClassSessionRepository {
+	return &ClassSessionRepository{db: r.db.Where("class_sessions.class_id IN (SELECT id FROM classes WHERE gym_id = ?)", gymID).Session(&gorm.Session{})}
+}
+
+// ClassGymID returns the gym a class is held at
+func (r *ClassSessionRepository) ClassGymID(classID uuid.UUID) (uuid.UUID, error) {
+	var class models.Class
+	err := r.db.Session(&gorm.Session{NewDB: true}).Select("gym_id").First(&class, "id = ?", classID).Error
+	return class.GymID, err
+}
+
 // Create a new session
 func (r *ClassSessionRepository) Create(session *models.ClassSession) error {
 	return r.db.Create(session).Error
 }
 
-// List all sessions
-func (r *ClassSessionRepository) ListAll(sessions *[]models.ClassSession) error {
-	return r.db.Preload("Class").Find(sessions).Error
+// ClassSessionListSpec is what the session list can be filtered and sorted by
+var ClassSessionListSpec = query.Spec{
+	Filters: map[string]query.Field{
+		"class_id":  {Column: "class_id", Type: query.UUID},
+		"status":    {Column: "status", Type: query.String},
+		"starts_at": {Column: "starts_at", Type: query.Time},
+		"ends_at":   {Column: "ends_at", Type: query.Time},
+	},
+	Sorts: map[string]string{
+		"starts_at":  "starts_at",
+		"created_at": "created_at",
+	},
+	DefaultSort: "starts_at",
+}
+
+// List one page of sessions
+func (r *ClassSessionRepository) List(req *query.Request) (*query.Page[models.ClassSession], error) {
+	return query.Find[models.ClassSession](r.db, ClassSessionListSpec, req, "Class")
 }
 
 // Get a session by ID
@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"go-blog/internal/models"
+	"go-blog/internal/query"
 
 	"gorm.io/gorm"
 )
@@ -19,9 +20,22 @@ func (r *GymRepository) Create(gym *models.Gym) error {
 	return r.db.Create(gym).Error
 }
 
-// List all gyms
-func (r *GymRepository) ListAll(gyms *[]models.Gym) error {
-	return r.db.Find(gyms).Error
+// GymListSpec is what the gym list can be filtered and sorted by
+var GymListSpec = query.Spec{
+	Filters: map[string]query.Field{
+		"name":     {Column: "name", Type: query.String},
+		"timezone": {Column: "timezone", Type: query.String},
+	},
+	Sorts: map[string]string{
+		"name":       "name",
+		"created_at": "created_at",
+	},
+	DefaultSort: "name",
+}
+
+// List one page of gyms
+func (r *GymRepository) List(req *query.Request) (*query.Page[models.Gym], error) {
+	return query.Find[models.Gym](r.db, GymListSpec, req)
 }
 
 // Get gym by ID
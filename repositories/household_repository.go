@@ -0,0 +1,155 @@
+package repositories
+
+import (
+	"go-blog/internal/models"
+	"go-blog/internal/query"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type HouseholdRepository struct {
+	db *gorm.DB
+}
+
+func NewHouseholdRepository(db *gorm.DB) *HouseholdRepository {
+	return &HouseholdRepository{db: db}
+}
+
+func (r *HouseholdRepository) WithTx(tx *gorm.DB) *HouseholdRepository {
+	return &HouseholdRepository{db: tx}
+}
+
+// HouseholdListSpec is what the household list can be filtered and sorted by
+var HouseholdListSpec = query.Spec{
+	Filters: map[string]query.Field{
+		"name":             {Column: "name", Type: query.String},
+		"primary_payer_id": {Column: "primary_payer_id", Type: query.UUID},
+		"created_at":       {Column: "created_at", Type: query.Time},
+	},
+	Sorts: map[string]string{
+		"name":       "name",
+		"created_at": "created_at",
+	},
+	DefaultSort: "-created_at",
+}
+
+func (r *HouseholdRepository) List(req *query.Request) (*query.Page[models.Household], error) {
+	return query.Find[models.Household](r.db, HouseholdListSpec, req, "Members.User")
+}
+
+func (r *HouseholdRepository) Create(household *models.Household) error {
+	return r.db.Create(household).Error
+}
+
+// GetByID loads a household with its members
+func (r *HouseholdRepository) GetByID(id uuid.UUID) (*models.Household, error) {
+	var household models.Household
+	if err := r.db.Preload("Members.User").First(&household, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &household, nil
+}
+
+// MembershipOf returns the household row of a user
+func (r *HouseholdRepository) MembershipOf(userID uuid.UUID) (*models.HouseholdMember, error) {
+	var member models.HouseholdMember
+	if err := r.db.Where("user_id = ?", userID).First(&member).Error; err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (r *HouseholdRepository) AddMember(member *models.HouseholdMember) error {
+	return r.db.Create(member).Error
+}
+
+func (r *HouseholdRepository) RemoveMember(householdID, userID uuid.UUID) error {
+	return r.db.Where("household_id = ? AND user_id = ?", householdID, userID).Delete(&models.HouseholdMember{}).Error
+}
+
+func (r *HouseholdRepository) SetRole(householdID, userID uuid.UUID, role string) error {
+	return r.db.Model(&models.HouseholdMember{}).
+		Where("household_id = ? AND user_id = ?", householdID, userID).
+		Update("role", role).Error
+}
+
+func (r *HouseholdRepository) SetPrimaryPayer(householdID, userID uuid.UUID) error {
+	return r.db.Model(&models.Household{}).Where("id = ?", householdID).Update("primary_payer_id", userID).Error
+}
+
+// Delete removes a household; its member rows cascade
+func (r *HouseholdRepository) Delete(id uuid.UUID) error {
+	return r.db.Delete(&models.Household{}, "id = ?", id).Error
+}
+
+// PrimaryPayerOfMember returns the user paying for a member profile, or
+// gorm.ErrRecordNotFound when the member is not in a household.
+func (r *HouseholdRepository) PrimaryPayerOfMember(memberID uuid.UUID) (uuid.UUID, error) {
+	var row struct{ PrimaryPayerID uuid.UUID }
+	err := r.db.Raw(`
+		SELECT h.primary_payer_id FROM households h
+		JOIN household_members hm ON hm.household_id = h.id
+		JOIN members m ON m.user_id = hm.user_id
+		WHERE m.id = ?`, memberID,
+	).Scan(&row).Error
+	if err == nil && row.PrimaryPayerID == uuid.Nil {
+		err = gorm.ErrRecordNotFound
+	}
+	return row.PrimaryPayerID, err
+}
+
+func (r *HouseholdRepository) CreateGuardianLink(link *models.GuardianLink) error {
+	return r.db.Create(link).Error
+}
+
+// GuardianLink returns the link between a guardian and a dependent, active or revoked
+func (r *HouseholdRepository) GuardianLink(guardianID, dependentID uuid.UUID) (*models.GuardianLink, error) {
+	var link models.GuardianLink
+	err := r.db.Where("guardian_id = ? AND dependent_id = ?", guardianID, dependentID).First(&link).Error
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (r *HouseholdRepository) GetGuardianLink(id uuid.UUID) (*models.GuardianLink, error) {
+	var link models.GuardianLink
+	if err := r.db.First(&link, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// RenewGuardianLink reactivates a revoked link with fresh consent
+func (r *HouseholdRepository) RenewGuardianLink(link *models.GuardianLink) error {
+	return r.db.Model(link).Updates(map[string]interface{}{
+		"relationship": link.Relationship,
+		"consented_at": link.ConsentedAt,
+		"consented_by": link.ConsentedBy,
+		"consent_ip":   link.ConsentIP,
+		"revoked_at":   nil,
+	}).Error
+}
+
+func (r *HouseholdRepository) RevokeGuardianLink(id uuid.UUID, at time.Time) error {
+	return r.db.Model(&models.GuardianLink{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", at).Error
+}
+
+// DependentsOf lists the active links of a guardian with the dependents and
+// their member profiles loaded
+func (r *HouseholdRepository) DependentsOf(guardianID uuid.UUID) ([]models.GuardianLink, error) {
+	var links []models.GuardianLink
+	err := r.db.Preload("Dependent.Member").
+		Where("guardian_id = ? AND revoked_at IS NULL", guardianID).
+		Order("created_at").Find(&links).Error
+	return links, err
+}
+
+// GuardiansOf lists the active guardian links of a dependent
+func (r *HouseholdRepository) GuardiansOf(dependentID uuid.UUID) ([]models.GuardianLink, error) {
+	var links []models.GuardianLink
+	err := r.db.Where("dependent_id = ? AND revoked_at IS NULL", dependentID).Order("created_at").Find(&links).Error
+	return links, err
+}
@@ -7,11 +7,13 @@ import (
 	"gorm.io/gorm"
 
 	"go-blog/internal/models"
+	"go-blog/internal/query"
 )
 
 type MemberRepository interface {
 	Create(member *models.Member) error
-	GetAll() ([]models.Member, error)
+	List(req *query.Request) (*query.Page[models.Member], error)
+	Search(term string, limit int) ([]MemberSearchResult, error)
 	GetByID(id uuid.UUID) (*models.Member, error)
 	Update(member *models.Member) error
 	Delete(id uuid.UUID) error
@@ -29,15 +31,27 @@ func (r *memberRepository) Create(member *models.Member) error {
 	return r.db.Create(member).Error
 }
 
-func (r *memberRepository) GetAll() ([]models.Member, error) {
-	var members []models.Member
-	err := r.db.
-		Preload("Memberships").
-		Preload("Bookings").
-		Preload("Attendance").
-		Preload("Payments").
-		Find(&members).Error
-	return members, err
+// MemberListSpec is what the member list can be filtered and sorted by
+var MemberListSpec = query.Spec{
+	Filters: map[string]query.Field{
+		"user_id":    {Column: "user_id", Type: query.UUID},
+		"first_name": {Column: "first_name", Type: query.String},
+		"last_name":  {Column: "last_name", Type: query.String},
+		"gender":     {Column: "gender", Type: query.String},
+		"created_at": {Column: "created_at", Type: query.Time},
+	},
+	Sorts: map[string]string{
+		"created_at": "created_at",
+		"first_name": "first_name",
+		"last_name":  "last_name",
+	},
+	DefaultSort: "last_name,first_name",
+}
+
+// List returns one page of members with their user. Memberships, bookings,
+// attendance and payments have their own list endpoints.
+func (r *memberRepository) List(req *query.Request) (*query.Page[models.Member], error) {
+	return query.Find[models.Member](r.db, MemberListSpec, req, "User")
 }
 
 func (r *memberRepository) GetByID(id uuid.UUID) (*models.Member, error) {
@@ -53,6 +67,8 @@ func (r *memberRepository) Update(member *models.Member) error {
 	return r.db.Save(member).Error
 }
 
+// Delete soft deletes a member. Memberships, payments and attendance stay in
+// place for accounting; use the erasure workflow to remove personal data.
 func (r *memberRepository) Delete(id uuid.UUID) error {
 	result := r.db.Delete(&models.Member{}, "id = ?", id)
 	if result.RowsAffected == 0 {
@@ -0,0 +1,118 @@
+package repositories
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// MemberSearchResult is one hit of the front-desk member search
+type MemberSearchResult struct {
+	MemberID            uuid.UUID  `json:"member_id"`
+	UserID              uuid.UUID  `json:"user_id"`
+	FirstName           string     `json:"first_name"`
+	LastName            string     `json:"last_name"`
+	Email               string     `json:"email"`
+	PhoneNumber         string     `json:"phone_number"`
+	ProfileThumbnailURL string     `json:"profile_thumbnail_url"`
+	MembershipID        *uuid.UUID `json:"membership_id"`
+	MembershipStatus    string     `json:"membership_status"` // none, expired, or the stored status
+	MembershipEndDate   *time.Time `json:"membership_end_date"`
+	PlanTitle           *string    `json:"plan_title"`
+	Rank                float64    `json:"rank"`
+}
+
+// memberSearchSQL ranks full-text matches (prefix aware, so it works while
+// typing) and trigram matches (substrings and typos) over user and member
+// fields. The lateral join picks the membership that is current, else the
+// most recent one.
+const memberSearchSQL = `
+SELECT m.id AS member_id, u.user_id, u.first_name, u.last_name, u.email, u.phone_number, u.profile_thumbnail_url,
+	ms.id AS membership_id,
+	CASE
+		WHEN ms.id IS NULL THEN 'none'
+		WHEN ms.status = 'active' AND ms.end_date < now() THEN 'expired'
+		ELSE ms.status
+	END AS membership_status,
+	ms.end_date AS membership_end_date,
+	p.title AS plan_title,
+	(
+		{{rank}}
+		+ greatest(
+			similarity(u.first_name || ' ' || u.last_name, @term),
+			word_similarity(@term, u.first_name || ' ' || u.last_name),
+			similarity(u.email, @term)
+		)
+		+ CASE WHEN lower(u.email) = lower(@term) THEN 1 ELSE 0 END
+	) AS rank
+FROM members m
+JOIN users u ON u.user_id = m.user_id
+LEFT JOIN LATERAL (
+	SELECT id, status, end_date, plan_id FROM memberships
+	WHERE member_id = m.id
+	ORDER BY (status = 'active' AND start_date <= now() AND end_date >= now()) DESC, end_date DESC
+	LIMIT 1
+) ms ON true
+LEFT JOIN plans p ON p.id = ms.plan_id
+WHERE m.deleted_at IS NULL AND ({{match}}
+	(u.first_name || ' ' || u.last_name) ILIKE @like
+	OR (m.first_name || ' ' || m.last_name) ILIKE @like
+	OR u.email ILIKE @like
+	OR (@digits <> '' AND regexp_replace(u.phone_number, '\D', '', 'g') LIKE @digits_like)
+	OR @term % (u.first_name || ' ' || u.last_name)
+)
+ORDER BY rank DESC, u.last_name, u.first_name
+LIMIT @limit`
+
+// Search finds members by partial name, email or phone number
+func (r *memberRepository) Search(term string, limit int) ([]MemberSearchResult, error) {
+	tsQuery := prefixTSQuery(term)
+	digits := strings.Map(func(c rune) rune {
+		if c >= '0' && c <= '9' {
+			return c
+		}
+		return -1
+	}, term)
+	// A couple of digits would match almost every phone number
+	if len(digits) < 3 {
+		digits = ""
+	}
+
+	sql := memberSearchSQL
+	if tsQuery != "" {
+		sql = strings.Replace(sql, "{{rank}}", "ts_rank(u.search_vector, to_tsquery('simple', @tsquery)) + ts_rank(m.search_vector, to_tsquery('simple', @tsquery))", 1)
+		sql = strings.Replace(sql, "{{match}}", "u.search_vector @@ to_tsquery('simple', @tsquery) OR m.search_vector @@ to_tsquery('simple', @tsquery) OR", 1)
+	} else {
+		sql = strings.Replace(sql, "{{rank}}", "0", 1)
+		sql = strings.Replace(sql, "{{match}}", "", 1)
+	}
+
+	var results []MemberSearchResult
+	err := r.db.Raw(sql, map[string]interface{}{
+		"term":        term,
+		"tsquery":     tsQuery,
+		"like":        "%" + escapeLike(term) + "%",
+		"digits":      digits,
+		"digits_like": "%" + digits + "%",
+		"limit":       limit,
+	}).Scan(&results).Error
+	return results, err
+}
+
+// prefixTSQuery turns "jo smi" into "jo:* & smi:*". Everything but letters
+// and digits is dropped, so user input can never break the tsquery syntax.
+func prefixTSQuery(term string) string {
+	words := strings.FieldsFunc(strings.ToLower(term), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	})
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -2,8 +2,12 @@ package repositories
 
 import (
 	"go-blog/internal/models"
+	"go-blog/internal/query"
+	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type MembershipRepository struct {
@@ -14,20 +18,138 @@ func NewMembershipRepository(db *gorm.DB) *MembershipRepository {
 	return &MembershipRepository{db: db}
 }
 
+func (r *MembershipRepository) WithTx(tx *gorm.DB) *MembershipRepository {
+	return &MembershipRepository{db: tx}
+}
+
 func (r *MembershipRepository) Create(m *models.Membership) error {
 	return r.db.Create(m).Error
 }
 
-func (r *MembershipRepository) GetByMember(memberID string) ([]models.Membership, error) {
-	var memberships []models.Membership
-	err := r.db.Where("member_id = ?", memberID).Preload("Plan").Find(&memberships).Error
-	return memberships, err
+// MembershipListSpec is what membership lists can be filtered and sorted by
+var MembershipListSpec = query.Spec{
+	Filters: map[string]query.Field{
+		"member_id":    {Column: "member_id", Type: query.UUID},
+		"plan_id":      {Column: "plan_id", Type: query.UUID},
+		"plan_version": {Column: "plan_version", Type: query.Int},
+		"status":       {Column: "status", Type: query.String},
+		"auto_renew":   {Column: "auto_renew", Type: query.Bool},
+		"start_date":   {Column: "start_date", Type: query.Time},
+		"end_date":     {Column: "end_date", Type: query.Time},
+	},
+	Sorts: map[string]string{
+		"start_date": "start_date",
+		"end_date":   "end_date",
+		"created_at": "created_at",
+	},
+	DefaultSort: "-start_date",
+}
+
+// ListByPlan returns one page of the memberships sold on a plan with their
+// member
+func (r *MembershipRepository) ListByPlan(planID uuid.UUID, req *query.Request) (*query.Page[models.Membership], error) {
+	return query.Find[models.Membership](r.db.Where("plan_id = ?", planID), MembershipListSpec, req, "Member")
+}
+
+// ListByMember returns one page of a member's memberships with their plan
+func (r *MembershipRepository) ListByMember(memberID string, req *query.Request) (*query.Page[models.Membership], error) {
+	return query.Find[models.Membership](r.db.Where("member_id = ?", memberID), MembershipListSpec, req, "Plan")
 }
 
 func (r *MembershipRepository) Update(m *models.Membership) error {
-	return r.db.Save(m).Error
+	return r.db.Omit(clause.Associations).Save(m).Error
 }
 
 func (r *MembershipRepository) Delete(id string) error {
 	return r.db.Delete(&models.Membership{}, "id = ?", id).Error
 }
+
+// GetByID returns a membership with its plan
+func (r *MembershipRepository) GetByID(id uuid.UUID) (*models.Membership, error) {
+	var m models.Membership
+	if err := r.db.Preload("Plan").First(&m, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Lock reads a membership FOR UPDATE so concurrent status changes queue up
+// behind each other. It must run inside a transaction.
+func (r *MembershipRepository) Lock(id uuid.UUID) (*models.Membership, error) {
+	var m models.Membership
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Member", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		First(&m, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// LockCurrent locks the active membership of a member that ends last
+func (r *MembershipRepository) LockCurrent(memberID uuid.UUID) (*models.Membership, error) {
+	var m models.Membership
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("member_id = ? AND status = ?", memberID, models.MembershipActive).
+		Order("end_date DESC").
+		First(&m).Error
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// DueForTransition returns the IDs of memberships whose dates call for a
+// status change: pending ones that have started, freezes that have run out,
+// active ones past their cancellation or end date and past due ones whose
+// grace period is over.
+func (r *MembershipRepository) DueForTransition(now time.Time, limit int) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Model(&models.Membership{}).
+		Where("(status = ? AND start_date <= ?)", models.MembershipPending, now).
+		Or("(status = ? AND frozen_until <= ?)", models.MembershipFrozen, now).
+		Or("(status = ? AND (cancel_at <= ? OR end_date < ?))", models.MembershipActive, now, now).
+		Or("(status = ? AND (grace_until <= ? OR end_date < ?))", models.MembershipPastDue, now, now).
+		Order("end_date").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// ClaimForRenewal locks active, auto-renewing memberships that end before
+// the given time and have not been renewed yet. Rows locked by another
+// worker are skipped, so replicas never claim the same membership. It must
+// run inside a transaction.
+func (r *MembershipRepository) ClaimForRenewal(endsBefore time.Time, limit int) ([]models.Membership, error) {
+	var memberships []models.Membership
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Preload("Plan").
+		Preload("Member", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Where("status = ? AND auto_renew AND cancel_at IS NULL AND end_date <= ?", models.MembershipActive, endsBefore).
+		Where("NOT EXISTS (SELECT 1 FROM memberships r WHERE r.renewed_from_id = memberships.id)").
+		Order("end_date").
+		Limit(limit).
+		Find(&memberships).Error
+	return memberships, err
+}
+
+// RenewalOf locks the renewal issued for a membership, if any
+func (r *MembershipRepository) RenewalOf(id uuid.UUID) (*models.Membership, error) {
+	var m models.Membership
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Plan").
+		First(&m, "renewed_from_id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// IsPaid reports whether a payment for the membership has been received
+func (r *MembershipRepository) IsPaid(id uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Payment{}).
+		Where("membership_id = ? AND status = ?", id, models.PaymentPaid).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,150 @@
+package repositories
+
+import (
+	"go-blog/internal/models"
+	"go-blog/internal/query"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PassRepository struct {
+	db *gorm.DB
+}
+
+func NewPassRepository(db *gorm.DB) *PassRepository {
+	return &PassRepository{db: db}
+}
+
+func (r *PassRepository) WithTx(tx *gorm.DB) *PassRepository {
+	return &PassRepository{db: tx}
+}
+
+// PassProductListSpec is what pass product lists can be filtered and sorted by
+var PassProductListSpec = query.Spec{
+	Filters: map[string]query.Field{
+		"kind":        {Column: "kind", Type: query.String},
+		"gym_id":      {Column: "gym_id", Type: query.UUID},
+		"price_cents": {Column: "price_cents", Type: query.Int},
+	},
+	Sorts: map[string]string{
+		"name":        "name",
+		"price_cents": "price_cents",
+		"created_at":  "created_at",
+	},
+	DefaultSort: "price_cents",
+}
+
+func (r *PassRepository) CreateProduct(product *models.PassProduct) error {
+	return r.db.Create(product).Error
+}
+
+// ListProducts returns one page of pass products; archived ones only when
+// asked for
+func (r *PassRepository) ListProducts(req *query.Request, includeArchived bool) (*query.Page[models.PassProduct], error) {
+	db := r.db
+	if !includeArchived {
+		db = db.Where("archived_at IS NULL")
+	}
+	return query.Find[models.PassProduct](db, PassProductListSpec, req)
+}
+
+func (r *PassRepository) GetProduct(id uuid.UUID) (*models.PassProduct, error) {
+	var product models.PassProduct
+	if err := r.db.First(&product, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+func (r *PassRepository) UpdateProduct(product *models.PassProduct) error {
+	return r.db.Save(product).Error
+}
+
+// PassListSpec is what pass lists can be filtered and sorted by
+var PassListSpec = query.Spec{
+	Filters: map[string]query.Field{
+		"product_id":   {Column: "product_id", Type: query.UUID},
+		"status":       {Column: "status", Type: query.String},
+		"member_id":    {Column: "member_id", Type: query.UUID},
+		"sponsor_id":   {Column: "sponsor_id", Type: query.UUID},
+		"holder_email": {Column: "holder_email", Type: query.String},
+		"valid_until":  {Column: "valid_until", Type: query.Time},
+		"created_at":   {Column: "created_at", Type: query.Time},
+	},
+	Sorts: map[string]string{
+		"created_at":  "created_at",
+		"valid_until": "valid_until",
+	},
+	DefaultSort: "-created_at",
+}
+
+func (r *PassRepository) Create(pass *models.Pass) error {
+	return r.db.Create(pass).Error
+}
+
+// List returns one page of passes with their product
+func (r *PassRepository) List(req *query.Request) (*query.Page[models.Pass], error) {
+	return query.Find[models.Pass](r.db, PassListSpec, req, "Product")
+}
+
+// ListByMember returns one page of the passes a member holds or sponsored
+func (r *PassRepository) ListByMember(memberID uuid.UUID, req *query.Request) (*query.Page[models.Pass], error) {
+	return query.Find[models.Pass](r.db.Where("member_id = ? OR sponsor_id = ?", memberID, memberID), PassListSpec, req, "Product")
+}
+
+// GetByID returns a pass with its product and visits
+func (r *PassRepository) GetByID(id uuid.UUID) (*models.Pass, error) {
+	var pass models.Pass
+	err := r.db.Preload("Product").
+		Preload("Visits", func(db *gorm.DB) *gorm.DB { return db.Order("visited_at") }).
+		First(&pass, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &pass, nil
+}
+
+// LockByCode reads a pass FOR UPDATE by its code, so its visits are counted
+// down one at a time. It must run inside a transaction.
+func (r *PassRepository) LockByCode(code string) (*models.Pass, error) {
+	var pass models.Pass
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Product").
+		First(&pass, "code = ?", code).Error
+	if err != nil {
+		return nil, err
+	}
+	return &pass, nil
+}
+
+// Lock reads a pass FOR UPDATE. It must run inside a transaction.
+func (r *PassRepository) Lock(id uuid.UUID) (*models.Pass, error) {
+	var pass models.Pass
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Product").
+		First(&pass, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &pass, nil
+}
+
+func (r *PassRepository) Update(pass *models.Pass) error {
+	return r.db.Omit(clause.Associations).Save(pass).Error
+}
+
+func (r *PassRepository) CreateVisit(visit *models.PassVisit) error {
+	return r.db.Create(visit).Error
+}
+
+// VisitedSession reports whether a pass has already been redeemed for a
+// session
+func (r *PassRepository) VisitedSession(passID, sessionID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.PassVisit{}).
+		Where("pass_id = ? AND session_id = ?", passID, sessionID).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -2,8 +2,11 @@ package repositories
 
 import (
 	"go-blog/internal/models"
+	"go-blog/internal/query"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type PaymentRepository struct {
@@ -14,17 +17,82 @@ func NewPaymentRepository(db *gorm.DB) *PaymentRepository {
 	return &PaymentRepository{db: db}
 }
 
+func (r *PaymentRepository) WithTx(tx *gorm.DB) *PaymentRepository {
+	return &PaymentRepository{db: tx}
+}
+
 func (r *PaymentRepository) Create(p *models.Payment) error {
 	return r.db.Create(p).Error
 }
 
-func (r *PaymentRepository) GetByMember(memberID string) ([]models.Payment, error) {
-	var payments []models.Payment
-	err := r.db.Where("member_id = ?", memberID).Find(&payments).Error
-	return payments, err
+// PaymentListSpec is what payment lists can be filtered and sorted by
+var PaymentListSpec = query.Spec{
+	Filters: map[string]query.Field{
+		"member_id":     {Column: "member_id", Type: query.UUID},
+		"status":        {Column: "status", Type: query.String},
+		"method":        {Column: "method", Type: query.String},
+		"currency":      {Column: "currency", Type: query.String},
+		"reference":     {Column: "reference", Type: query.String},
+		"payer_id":      {Column: "payer_id", Type: query.UUID},
+		"membership_id": {Column: "membership_id", Type: query.UUID},
+		"promo_code_id": {Column: "promo_code_id", Type: query.UUID},
+		"amount_cents":  {Column: "amount_cents", Type: query.Int},
+		"created_at":    {Column: "created_at", Type: query.Time},
+	},
+	Sorts: map[string]string{
+		"created_at":   "created_at",
+		"amount_cents": "amount_cents",
+	},
+	DefaultSort: "-created_at",
+}
+
+// List returns one page of all payments
+func (r *PaymentRepository) List(req *query.Request) (*query.Page[models.Payment], error) {
+	return query.Find[models.Payment](r.db, PaymentListSpec, req)
+}
+
+// ListByMember returns one page of a member's payments
+func (r *PaymentRepository) ListByMember(memberID string, req *query.Request) (*query.Page[models.Payment], error) {
+	return query.Find[models.Payment](r.db.Where("member_id = ?", memberID), PaymentListSpec, req)
+}
+
+// ListByHousehold returns one page of the payments of everyone in a household
+func (r *PaymentRepository) ListByHousehold(householdID string, req *query.Request) (*query.Page[models.Payment], error) {
+	return query.Find[models.Payment](r.db.Where(
+		"member_id IN (SELECT m.id FROM members m JOIN household_members hm ON hm.user_id = m.user_id WHERE hm.household_id = ?)", householdID,
+	), PaymentListSpec, req)
 }
-func (r *PaymentRepository) GetAll() ([]models.Payment, error) {
-	var payments []models.Payment
-	err := r.db.Find(&payments).Error
-	return payments, err
+
+// Lock reads a payment FOR UPDATE. It must run inside a transaction.
+func (r *PaymentRepository) Lock(id uuid.UUID) (*models.Payment, error) {
+	var p models.Payment
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&p, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *PaymentRepository) SetStatus(id uuid.UUID, status string) error {
+	return r.db.Model(&models.Payment{}).Where("id = ?", id).Update("status", status).Error
+}
+
+// DiscountFor sums the promo discounts on the payments of a membership
+// period that were not voided
+func (r *PaymentRepository) DiscountFor(membershipID uuid.UUID) (int, error) {
+	var discount int
+	err := r.db.Model(&models.Payment{}).
+		Where("membership_id = ? AND status <> ?", membershipID, models.PaymentVoid).
+		Select("COALESCE(SUM(discount_cents), 0)").
+		Scan(&discount).Error
+	return discount, err
+}
+
+// VoidPending voids the unpaid payments raised for a membership and returns
+// them
+func (r *PaymentRepository) VoidPending(membershipID uuid.UUID) ([]models.Payment, error) {
+	var voided []models.Payment
+	err := r.db.Model(&voided).Clauses(clause.Returning{}).
+		Where("membership_id = ? AND status = ?", membershipID, models.PaymentPending).
+		Update("status", models.PaymentVoid).Error
+	return voided, err
 }
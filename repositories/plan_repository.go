@@ -2,8 +2,11 @@ package repositories
 
 import (
 	"go-blog/internal/models"
+	"go-blog/internal/query"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type PlanRepository struct {
@@ -14,14 +17,38 @@ func NewPlanRepository(db *gorm.DB) *PlanRepository {
 	return &PlanRepository{db: db}
 }
 
+func (r *PlanRepository) WithTx(tx *gorm.DB) *PlanRepository {
+	return &PlanRepository{db: tx}
+}
+
 func (r *PlanRepository) Create(plan *models.Plan) error {
 	return r.db.Create(plan).Error
 }
 
-func (r *PlanRepository) GetAll() ([]models.Plan, error) {
-	var plans []models.Plan
-	err := r.db.Find(&plans).Error
-	return plans, err
+// PlanListSpec is what the plan list can be filtered and sorted by
+var PlanListSpec = query.Spec{
+	Filters: map[string]query.Field{
+		"title":         {Column: "title", Type: query.String},
+		"billing_cycle": {Column: "billing_cycle", Type: query.String},
+		"access":        {Column: "access", Type: query.String},
+		"price_cents":   {Column: "price_cents", Type: query.Int},
+		"version":       {Column: "version", Type: query.Int},
+	},
+	Sorts: map[string]string{
+		"title":       "title",
+		"price_cents": "price_cents",
+		"created_at":  "created_at",
+	},
+	DefaultSort: "price_cents",
+}
+
+// List returns one page of plans; archived plans only when asked for
+func (r *PlanRepository) List(req *query.Request, includeArchived bool) (*query.Page[models.Plan], error) {
+	db := r.db
+	if !includeArchived {
+		db = db.Where("archived_at IS NULL")
+	}
+	return query.Find[models.Plan](db, PlanListSpec, req)
 }
 
 func (r *PlanRepository) GetByID(id string) (*models.Plan, error) {
@@ -31,9 +58,63 @@ func (r *PlanRepository) GetByID(id string) (*models.Plan, error) {
 }
 
 func (r *PlanRepository) Update(plan *models.Plan) error {
-	return r.db.Save(plan).Error
+	return r.db.Omit(clause.Associations).Save(plan).Error
 }
 
+// Delete removes a plan and its versions
 func (r *PlanRepository) Delete(id string) error {
+	if err := r.db.Where("plan_id = ?", id).Delete(&models.PlanVersion{}).Error; err != nil {
+		return err
+	}
 	return r.db.Delete(&models.Plan{}, "id = ?", id).Error
 }
+
+// Lock reads a plan FOR UPDATE so version numbers are handed out one at a
+// time. It must run inside a transaction.
+func (r *PlanRepository) Lock(id uuid.UUID) (*models.Plan, error) {
+	var plan models.Plan
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&plan, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func (r *PlanRepository) CreateVersion(version *models.PlanVersion) error {
+	return r.db.Create(version).Error
+}
+
+// PlanVersionSummary is a plan version with how many memberships were sold
+// at it
+type PlanVersionSummary struct {
+	models.PlanVersion
+	Memberships       int64 `json:"memberships"`
+	ActiveMemberships int64 `json:"active_memberships"`
+}
+
+// Versions lists the versions of a plan, newest first, with membership counts
+func (r *PlanRepository) Versions(planID uuid.UUID) ([]PlanVersionSummary, error) {
+	var versions []PlanVersionSummary
+	err := r.db.Model(&models.PlanVersion{}).
+		Select(`plan_versions.*,
+			(SELECT count(*) FROM memberships m WHERE m.plan_id = plan_versions.plan_id AND m.plan_version = plan_versions.version) AS memberships,
+			(SELECT count(*) FROM memberships m WHERE m.plan_id = plan_versions.plan_id AND m.plan_version = plan_versions.version AND m.status = ?) AS active_memberships`,
+			models.MembershipActive).
+		Where("plan_id = ?", planID).
+		Order("version DESC").
+		Scan(&versions).Error
+	return versions, err
+}
+
+// CountMemberships counts the memberships ever sold on a plan
+func (r *PlanRepository) CountMemberships(planID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Membership{}).Where("plan_id = ?", planID).Count(&count).Error
+	return count, err
+}
+
+// All returns every plan, for lookups by title
+func (r *PlanRepository) All() ([]models.Plan, error) {
+	var plans []models.Plan
+	err := r.db.Order("title").Find(&plans).Error
+	return plans, err
+}
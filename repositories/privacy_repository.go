@@ -0,0 +1,205 @@
+package repositories
+
+import (
+	"go-blog/internal/models"
+	"go-blog/internal/query"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PrivacyRepository gathers and erases the personal data of a user across tables
+type PrivacyRepository struct {
+	db *gorm.DB
+}
+
+func NewPrivacyRepository(db *gorm.DB) *PrivacyRepository {
+	return &PrivacyRepository{db: db}
+}
+
+func (r *PrivacyRepository) WithTx(tx *gorm.DB) *PrivacyRepository {
+	return &PrivacyRepository{db: tx}
+}
+
+// PersonalData is everything stored about one user
+type PersonalData struct {
+	User          models.User
+	Member        *models.Member // nil for staff without a member profile
+	Memberships   []models.Membership
+	Payments      []models.Payment
+	Bookings      []models.Booking
+	Attendance    []models.Attendance
+	Notifications []models.Notification
+	Identities    []models.UserIdentity
+}
+
+// CollectPersonalData loads the data of userID, including a soft-deleted
+// member profile.
+func (r *PrivacyRepository) CollectPersonalData(userID uuid.UUID) (*PersonalData, error) {
+	data := &PersonalData{}
+	if err := r.db.First(&data.User, "user_id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+
+	var member models.Member
+	err := r.db.Unscoped().Where("user_id = ?", userID).First(&member).Error
+	switch {
+	case err == nil:
+		data.Member = &member
+	case err != gorm.ErrRecordNotFound:
+		return nil, err
+	}
+
+	if data.Member != nil {
+		memberID := data.Member.ID
+		if err := r.db.Preload("Plan").Where("member_id = ?", memberID).Order("start_date").Find(&data.Memberships).Error; err != nil {
+			return nil, err
+		}
+		if err := r.db.Where("member_id = ?", memberID).Order("created_at").Find(&data.Payments).Error; err != nil {
+			return nil, err
+		}
+		if err := r.db.Preload("Session.Class").Where("member_id = ?", memberID).Order("created_at").Find(&data.Bookings).Error; err != nil {
+			return nil, err
+		}
+		if err := r.db.Preload("Session.Class").Where("member_id = ?", memberID).Order("checked_in_at").Find(&data.Attendance).Error; err != nil {
+			return nil, err
+		}
+	}
+	if err := r.db.Where("user_id = ?", userID).Order("created_at").Find(&data.Notifications).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Where("user_id = ?", userID).Order("created_at").Find(&data.Identities).Error; err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (r *PrivacyRepository) ProfilePictureKey(userID uuid.UUID) (string, error) {
+	var user models.User
+	err := r.db.Select("profile_picture_key").First(&user, "user_id = ?", userID).Error
+	return user.ProfilePictureKey, err
+}
+
+// Anonymize overwrites the personal data of userID and deletes what is only
+// personal. Payments and memberships stay, attached to the anonymized member,
+// because they must be retained for accounting.
+func (r *PrivacyRepository) Anonymize(userID uuid.UUID, now time.Time) error {
+	anonymous := "erased-" + userID.String()
+	err := r.db.Model(&models.User{}).Where("user_id = ?", userID).Updates(map[string]interface{}{
+		"first_name":              "Erased",
+		"last_name":               "User",
+		"email":                   anonymous + "@erased.invalid",
+		"password_hash":           "",
+		"phone_number":            "",
+		"date_of_birth":           nil,
+		"fitness_goals":           "",
+		"emergency_contact_name":  "",
+		"emergency_contact_phone": "",
+		"gender":                  "",
+		"profile_picture_url":     "",
+		"profile_thumbnail_url":   "",
+		"profile_picture_key":     "",
+		"status":                  "Erased",
+		"email_verified":          false,
+		"email_verified_at":       nil,
+		"totp_secret":             "",
+		"totp_enabled":            false,
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	err = r.db.Unscoped().Model(&models.Member{}).Where("user_id = ?", userID).Updates(map[string]interface{}{
+		"first_name":        "Erased",
+		"last_name":         "User",
+		"dob":               nil,
+		"gender":            "",
+		"emergency_contact": "{}",
+		"notes":             "",
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	memberIDs := r.db.Unscoped().Model(&models.Member{}).Select("id").Where("user_id = ?", userID)
+	// Nothing may renew or stay booked for an erased member
+	if err := r.db.Model(&models.Membership{}).Where("member_id IN (?)", memberIDs).Update("auto_renew", false).Error; err != nil {
+		return err
+	}
+	err = r.db.Model(&models.Booking{}).
+		Where("member_id IN (?) AND status = ?", memberIDs, models.BookingBooked).
+		Where("session_id IN (?)", r.db.Model(&models.ClassSession{}).Select("id").Where("starts_at > ?", now)).
+		Update("status", models.BookingCancelled).Error
+	if err != nil {
+		return err
+	}
+
+	for _, model := range []interface{}{&models.Notification{}, &models.RecoveryCode{}, &models.UserToken{}, &models.UserIdentity{}, &models.HouseholdMember{}} {
+		if err := r.db.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	if err := r.db.Where("guardian_id = ? OR dependent_id = ?", userID, userID).Delete(&models.GuardianLink{}).Error; err != nil {
+		return err
+	}
+
+	// The audit trail stays, minus the personal details in its metadata
+	return r.db.Model(&models.AuditLog{}).Where("target_type = ? AND target_id = ?", "user", userID).
+		Update("metadata", gorm.Expr("metadata - 'email' - 'ip_address'")).Error
+}
+
+// ErasureRequestListSpec is what the erasure request list can be filtered and sorted by
+var ErasureRequestListSpec = query.Spec{
+	Filters: map[string]query.Field{
+		"status":     {Column: "status", Type: query.String},
+		"user_id":    {Column: "user_id", Type: query.UUID},
+		"created_at": {Column: "created_at", Type: query.Time},
+	},
+	Sorts: map[string]string{
+		"created_at": "created_at",
+	},
+	DefaultSort: "-created_at",
+}
+
+func (r *PrivacyRepository) ListErasureRequests(req *query.Request) (*query.Page[models.ErasureRequest], error) {
+	return query.Find[models.ErasureRequest](r.db, ErasureRequestListSpec, req)
+}
+
+func (r *PrivacyRepository) ErasureRequestsOf(userID uuid.UUID) ([]models.ErasureRequest, error) {
+	var requests []models.ErasureRequest
+	err := r.db.Where("user_id = ?", userID).Order("created_at desc").Find(&requests).Error
+	return requests, err
+}
+
+func (r *PrivacyRepository) GetErasureRequest(id uuid.UUID) (*models.ErasureRequest, error) {
+	var request models.ErasureRequest
+	if err := r.db.First(&request, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (r *PrivacyRepository) HasPendingErasure(userID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.ErasureRequest{}).Where("user_id = ? AND status = ?", userID, models.ErasurePending).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *PrivacyRepository) CreateErasureRequest(request *models.ErasureRequest) error {
+	return r.db.Create(request).Error
+}
+
+// ReviewErasureRequest records the decision on a pending request. It reports
+// false when the request was no longer pending.
+func (r *PrivacyRepository) ReviewErasureRequest(id, reviewerID uuid.UUID, status, note string, at time.Time) (bool, error) {
+	result := r.db.Model(&models.ErasureRequest{}).
+		Where("id = ? AND status = ?", id, models.ErasurePending).
+		Updates(map[string]interface{}{
+			"status":         status,
+			"reviewed_by_id": reviewerID,
+			"reviewed_at":    at,
+			"review_note":    note,
+		})
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"errors"
+	"go-blog/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ProfileRepository loads and updates the data a user sees about themselves
+type ProfileRepository struct {
+	db *gorm.DB
+}
+
+func NewProfileRepository(db *gorm.DB) *ProfileRepository {
+	return &ProfileRepository{db: db}
+}
+
+func (r *ProfileRepository) WithTx(tx *gorm.DB) *ProfileRepository {
+	return &ProfileRepository{db: tx}
+}
+
+// GetUser loads the user together with the linked member profile, if any
+func (r *ProfileRepository) GetUser(userID uuid.UUID) (*models.User, error) {
+	var user models.User
+	if err := r.db.Preload("Member").First(&user, "user_id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetMemberByUserID returns nil without an error when the user has no member profile
+func (r *ProfileRepository) GetMemberByUserID(userID uuid.UUID) (*models.Member, error) {
+	var member models.Member
+	err := r.db.First(&member, "user_id = ?", userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (r *ProfileRepository) UpdateUser(userID uuid.UUID, fields map[string]interface{}) error {
+	return r.db.Model(&models.User{}).Where("user_id = ?", userID).Updates(fields).Error
+}
+
+func (r *ProfileRepository) UpdateMember(memberID uuid.UUID, fields map[string]interface{}) error {
+	return r.db.Model(&models.Member{}).Where("id = ?", memberID).Updates(fields).Error
+}
+
+// ActiveMembership returns the membership covering now, or nil
+func (r *ProfileRepository) ActiveMembership(memberID uuid.UUID, now time.Time) (*models.Membership, error) {
+	var membership models.Membership
+	err := r.db.Preload("Plan").
+		Where("member_id = ? AND status = ? AND start_date <= ? AND end_date >= ?", memberID, "active", now, now).
+		Order("end_date DESC").
+		First(&membership).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// UpcomingBookings lists booked sessions that have not started yet, soonest first
+func (r *ProfileRepository) UpcomingBookings(memberID uuid.UUID, now time.Time, limit int) ([]models.Booking, error) {
+	var bookings []models.Booking
+	err := r.db.
+		Joins("JOIN class_sessions ON class_sessions.id = bookings.session_id").
+		Preload("Session.Class").
+		Where("bookings.member_id = ? AND bookings.status = ? AND class_sessions.starts_at > ?", memberID, "booked", now).
+		Order("class_sessions.starts_at ASC").
+		Limit(limit).
+		Find(&bookings).Error
+	return bookings, err
+}
+
+// RecentAttendance lists the latest check-ins, newest first
+func (r *ProfileRepository) RecentAttendance(memberID uuid.UUID, limit int) ([]models.Attendance, error) {
+	var attendance []models.Attendance
+	err := r.db.
+		Preload("Session.Class").
+		Where("member_id = ?", memberID).
+		Order("checked_in_at DESC").
+		Limit(limit).
+		Find(&attendance).Error
+	return attendance, err
+}
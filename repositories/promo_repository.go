@@ -0,0 +1,133 @@
+package repositories
+
+import (
+	"go-blog/internal/models"
+	"go-blog/internal/query"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PromoRepository struct {
+	db *gorm.DB
+}
+
+func NewPromoRepository(db *gorm.DB) *PromoRepository {
+	return &PromoRepository{db: db}
+}
+
+func (r *PromoRepository) WithTx(tx *gorm.DB) *PromoRepository {
+	return &PromoRepository{db: tx}
+}
+
+// PromoCodeListSpec is what promo code lists can be filtered and sorted by
+var PromoCodeListSpec = query.Spec{
+	Filters: map[string]query.Field{
+		"kind":              {Column: "kind", Type: query.String},
+		"first_period_only": {Column: "first_period_only", Type: query.Bool},
+		"valid_until":       {Column: "valid_until", Type: query.Time},
+		"created_at":        {Column: "created_at", Type: query.Time},
+	},
+	Sorts: map[string]string{
+		"code":        "code",
+		"redemptions": "redemptions",
+		"valid_until": "valid_until",
+		"created_at":  "created_at",
+	},
+	DefaultSort: "-created_at",
+}
+
+// PromoRedemptionListSpec is what redemption lists can be filtered and
+// sorted by
+var PromoRedemptionListSpec = query.Spec{
+	Filters: map[string]query.Field{
+		"member_id":  {Column: "member_id", Type: query.UUID},
+		"renewal":    {Column: "renewal", Type: query.Bool},
+		"created_at": {Column: "created_at", Type: query.Time},
+	},
+	Sorts: map[string]string{
+		"created_at":     "created_at",
+		"discount_cents": "discount_cents",
+	},
+	DefaultSort: "-created_at",
+}
+
+func (r *PromoRepository) Create(promo *models.PromoCode) error {
+	return r.db.Create(promo).Error
+}
+
+// List returns one page of promo codes; archived ones only when asked for
+func (r *PromoRepository) List(req *query.Request, includeArchived bool) (*query.Page[models.PromoCode], error) {
+	db := r.db
+	if !includeArchived {
+		db = db.Where("archived_at IS NULL")
+	}
+	return query.Find[models.PromoCode](db, PromoCodeListSpec, req)
+}
+
+func (r *PromoRepository) GetByID(id uuid.UUID) (*models.PromoCode, error) {
+	var promo models.PromoCode
+	if err := r.db.First(&promo, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &promo, nil
+}
+
+func (r *PromoRepository) GetByCode(code string) (*models.PromoCode, error) {
+	var promo models.PromoCode
+	if err := r.db.First(&promo, "code = ?", code).Error; err != nil {
+		return nil, err
+	}
+	return &promo, nil
+}
+
+// LockByCode loads a promo code for update so concurrent redemptions
+// cannot go over its cap
+func (r *PromoRepository) LockByCode(code string) (*models.PromoCode, error) {
+	var promo models.PromoCode
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&promo, "code = ?", code).Error
+	if err != nil {
+		return nil, err
+	}
+	return &promo, nil
+}
+
+func (r *PromoRepository) Lock(id uuid.UUID) (*models.PromoCode, error) {
+	var promo models.PromoCode
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&promo, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &promo, nil
+}
+
+func (r *PromoRepository) Update(promo *models.PromoCode) error {
+	return r.db.Save(promo).Error
+}
+
+// CountByMember counts the first uses of a promo code by a member
+func (r *PromoRepository) CountByMember(promoID, memberID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.PromoRedemption{}).
+		Where("promo_code_id = ? AND member_id = ? AND renewal = ?", promoID, memberID, false).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *PromoRepository) CreateRedemption(redemption *models.PromoRedemption) error {
+	return r.db.Create(redemption).Error
+}
+
+// DeleteRedemptionsOf removes the redemptions recorded on a payment and
+// returns them
+func (r *PromoRepository) DeleteRedemptionsOf(paymentID uuid.UUID) ([]models.PromoRedemption, error) {
+	var deleted []models.PromoRedemption
+	err := r.db.Clauses(clause.Returning{}).Where("payment_id = ?", paymentID).Delete(&deleted).Error
+	return deleted, err
+}
+
+// Redemptions returns one page of the discounts a promo code gave
+func (r *PromoRepository) Redemptions(promoID uuid.UUID, req *query.Request) (*query.Page[models.PromoRedemption], error) {
+	return query.Find[models.PromoRedemption](r.db.Where("promo_code_id = ?", promoID), PromoRedemptionListSpec, req)
+}
@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"go-blog/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReferralRepository stores referrals, referral codes and the account
+// credit members earn with them
+type ReferralRepository struct {
+	db *gorm.DB
+}
+
+func NewReferralRepository(db *gorm.DB) *ReferralRepository {
+	return &ReferralRepository{db: db}
+}
+
+func (r *ReferralRepository) WithTx(tx *gorm.DB) *ReferralRepository {
+	return &ReferralRepository{db: tx}
+}
+
+// MemberByCode finds the member a referral code belongs to
+func (r *ReferralRepository) MemberByCode(code string) (*models.Member, error) {
+	var member models.Member
+	if err := r.db.First(&member, "referral_code = ?", code).Error; err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// LockMember loads a member for update. Spending credit locks the member so
+// two payments cannot spend the same balance.
+func (r *ReferralRepository) LockMember(id uuid.UUID) (*models.Member, error) {
+	var member models.Member
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&member, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (r *ReferralRepository) SetCode(memberID uuid.UUID, code string) error {
+	return r.db.Model(&models.Member{}).Where("id = ?", memberID).Update("referral_code", code).Error
+}
+
+// GymOfMember returns the gym of a member's user account
+func (r *ReferralRepository) GymOfMember(memberID uuid.UUID) (*models.Gym, error) {
+	var gym models.Gym
+	err := r.db.Joins("JOIN users ON users.gym_id = gyms.id").
+		Joins("JOIN members ON members.user_id = users.user_id").
+		Where("members.id = ?", memberID).
+		First(&gym).Error
+	if err != nil {
+		return nil, err
+	}
+	return &gym, nil
+}
+
+func (r *ReferralRepository) Create(referral *models.Referral) error {
+	return r.db.Create(referral).Error
+}
+
+// LockPending loads the unrewarded referral of a member for update
+func (r *ReferralRepository) LockPending(referredID uuid.UUID) (*models.Referral, error) {
+	var referral models.Referral
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&referral, "referred_id = ? AND status = ?", referredID, models.ReferralPending).Error
+	if err != nil {
+		return nil, err
+	}
+	return &referral, nil
+}
+
+func (r *ReferralRepository) Update(referral *models.Referral) error {
+	return r.db.Omit(clause.Associations).Save(referral).Error
+}
+
+// ListByReferrer returns the referrals a member made, newest first
+func (r *ReferralRepository) ListByReferrer(referrerID uuid.UUID) ([]models.Referral, error) {
+	var referrals []models.Referral
+	err := r.db.Where("referrer_id = ?", referrerID).Order("created_at DESC").Find(&referrals).Error
+	return referrals, err
+}
+
+// Balance is a member's unspent account credit
+func (r *ReferralRepository) Balance(memberID uuid.UUID) (int, error) {
+	var balance int
+	err := r.db.Model(&models.MemberCredit{}).
+		Where("member_id = ?", memberID).
+		Select("COALESCE(SUM(amount_cents), 0)").
+		Scan(&balance).Error
+	return balance, err
+}
+
+func (r *ReferralRepository) CreateCredit(credit *models.MemberCredit) error {
+	return r.db.Create(credit).Error
+}
+
+// Credits lists a member's credit entries, newest first
+func (r *ReferralRepository) Credits(memberID uuid.UUID) ([]models.MemberCredit, error) {
+	var credits []models.MemberCredit
+	err := r.db.Where("member_id = ?", memberID).Order("created_at DESC").Find(&credits).Error
+	return credits, err
+}
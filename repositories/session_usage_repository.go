@@ -0,0 +1,108 @@
+package repositories
+
+import (
+	"go-blog/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SessionUsageRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionUsageRepository(db *gorm.DB) *SessionUsageRepository {
+	return &SessionUsageRepository{db: db}
+}
+
+func (r *SessionUsageRepository) WithTx(tx *gorm.DB) *SessionUsageRepository {
+	return &SessionUsageRepository{db: tx}
+}
+
+// CoveringMembership returns the member's membership in one of the given
+// statuses whose period includes at, with its plan
+func (r *SessionUsageRepository) CoveringMembership(memberID uuid.UUID, at time.Time, statuses ...string) (*models.Membership, error) {
+	var m models.Membership
+	err := r.db.Preload("Plan").
+		Where("member_id = ? AND status IN ? AND start_date <= ? AND end_date >= ?", memberID, statuses, at, at).
+		// Past due memberships only cover their grace period
+		Where("(status <> ? OR grace_until > ?)", models.MembershipPastDue, at).
+		Order("end_date DESC").
+		First(&m).Error
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// LockMembership locks a membership row so credits of one period are taken
+// one at a time. It must run inside a transaction.
+func (r *SessionUsageRepository) LockMembership(id uuid.UUID) error {
+	var m models.Membership
+	return r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&m, "id = ?", id).Error
+}
+
+// ActiveForSession returns the unrefunded credit a member holds for a session
+func (r *SessionUsageRepository) ActiveForSession(memberID, sessionID uuid.UUID) (*models.SessionUsage, error) {
+	var usage models.SessionUsage
+	err := r.db.Where("member_id = ? AND session_id = ? AND refunded_at IS NULL", memberID, sessionID).First(&usage).Error
+	if err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// CountUsed counts the unrefunded credits of a membership period
+func (r *SessionUsageRepository) CountUsed(membershipID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.SessionUsage{}).
+		Where("membership_id = ? AND refunded_at IS NULL", membershipID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *SessionUsageRepository) Create(usage *models.SessionUsage) error {
+	return r.db.Create(usage).Error
+}
+
+// RefundBooking gives back the credit taken by a booking, unless the member
+// has already checked in to the session
+func (r *SessionUsageRepository) RefundBooking(bookingID uuid.UUID, at time.Time) error {
+	return r.db.Model(&models.SessionUsage{}).
+		Where("booking_id = ? AND refunded_at IS NULL", bookingID).
+		Where("NOT EXISTS (SELECT 1 FROM attendances a WHERE a.member_id = session_usages.member_id AND a.session_id = session_usages.session_id)").
+		Update("refunded_at", at).Error
+}
+
+// SessionClass returns the class a session belongs to, with its gym
+func (r *SessionUsageRepository) SessionClass(sessionID uuid.UUID) (*models.Class, error) {
+	var class models.Class
+	err := r.db.Preload("Gym").
+		Joins("JOIN class_sessions cs ON cs.class_id = classes.id").
+		Where("cs.id = ?", sessionID).
+		First(&class).Error
+	if err != nil {
+		return nil, err
+	}
+	return &class, nil
+}
+
+// GuestsUsed counts the guests a member brought in between from and to,
+// at check-in or on guest passes they issued
+func (r *SessionUsageRepository) GuestsUsed(memberID uuid.UUID, from, to time.Time) (int, error) {
+	var total int
+	err := r.db.Model(&models.Attendance{}).
+		Select("COALESCE(SUM(guests), 0)").
+		Where("member_id = ? AND checked_in_at BETWEEN ? AND ?", memberID, from, to).
+		Scan(&total).Error
+	if err != nil {
+		return 0, err
+	}
+	var passes int64
+	err = r.db.Model(&models.Pass{}).
+		Where("sponsor_id = ? AND status <> ? AND created_at BETWEEN ? AND ?", memberID, models.PassVoid, from, to).
+		Count(&passes).Error
+	return total + int(passes), err
+}
@@ -0,0 +1,57 @@
+package repositories
+
+import (
+	"go-blog/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TokenRevocationRepository struct {
+	db *gorm.DB
+}
+
+func NewTokenRevocationRepository(db *gorm.DB) *TokenRevocationRepository {
+	return &TokenRevocationRepository{db: db}
+}
+
+func (r *TokenRevocationRepository) RevokeToken(token *models.RevokedToken) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(token).Error
+}
+
+// ClaimToken revokes a single-use token and reports whether this call did;
+// false means it had already been revoked
+func (r *TokenRevocationRepository) ClaimToken(token *models.RevokedToken) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(token)
+	return result.RowsAffected == 1, result.Error
+}
+
+// SetWatermark stores (or moves forward) the "issued before" cut-off of a user
+func (r *TokenRevocationRepository) SetWatermark(userID uuid.UUID, before time.Time) error {
+	watermark := models.TokenWatermark{UserID: userID, RevokedBefore: before}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"revoked_before", "updated_at"}),
+	}).Create(&watermark).Error
+}
+
+// ListRevokedTokens returns revoked tokens that have not expired yet
+func (r *TokenRevocationRepository) ListRevokedTokens(now time.Time) ([]models.RevokedToken, error) {
+	var tokens []models.RevokedToken
+	err := r.db.Where("expires_at > ?", now).Find(&tokens).Error
+	return tokens, err
+}
+
+// ListWatermarksSince returns watermarks set after the given time
+func (r *TokenRevocationRepository) ListWatermarksSince(since time.Time) ([]models.TokenWatermark, error) {
+	var watermarks []models.TokenWatermark
+	err := r.db.Where("revoked_before > ?", since).Find(&watermarks).Error
+	return watermarks, err
+}
+
+// DeleteExpired drops revocations of tokens that would have expired anyway
+func (r *TokenRevocationRepository) DeleteExpired(now time.Time) error {
+	return r.db.Where("expires_at <= ?", now).Delete(&models.RevokedToken{}).Error
+}
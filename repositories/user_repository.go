@@ -2,15 +2,22 @@ package repositories
 
 import (
 	"go-blog/internal/models"
+	"go-blog/internal/query"
+	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type UserRepository interface {
 	CreateUser(user *models.User) error
 	GetUserByEmail(email string) (*models.User, error)
-	GetAllUsers() ([]*models.User, error)
-	GetUsersByUserType(userType string) ([]*models.User, error)
+	GetUserByID(id uuid.UUID) (*models.User, error)
+	List(req *query.Request) (*query.Page[models.User], error)
+	IncrementFailedLogins(id uuid.UUID) (int, error)
+	LockUntil(id uuid.UUID, until time.Time) error
+	ResetFailedLogins(id uuid.UUID) error
+	UpdateFields(id uuid.UUID, fields map[string]interface{}) error
 }
 
 type userRepository struct {
@@ -31,18 +38,69 @@ func (r *userRepository) GetUserByEmail(email string) (*models.User, error) {
 	return &user, result.Error
 }
 
-// GetAllUsers retrieves all users from the database
-func (r *userRepository) GetAllUsers() ([]*models.User, error) {
-	var users []*models.User
-	result := r.db.Find(&users)
-	return users, result.Error
+func (r *userRepository) GetUserByID(id uuid.UUID) (*models.User, error) {
+	var user models.User
+	result := r.db.Where("user_id = ?", id).First(&user)
+	return &user, result.Error
+}
+
+// UserListSpec is what the user directory can be filtered and sorted by
+var UserListSpec = query.Spec{
+	Filters: map[string]query.Field{
+		"user_type":       {Column: "user_type", Type: query.String},
+		"status":          {Column: "status", Type: query.String},
+		"membership_type": {Column: "membership_type", Type: query.String},
+		"gender":          {Column: "gender", Type: query.String},
+		"gym_id":          {Column: "gym_id", Type: query.UUID},
+		"email":           {Column: "email", Type: query.String},
+		"first_name":      {Column: "first_name", Type: query.String},
+		"last_name":       {Column: "last_name", Type: query.String},
+		"email_verified":  {Column: "email_verified", Type: query.Bool},
+		"join_date":       {Column: "join_date", Type: query.Time},
+		"created_at":      {Column: "created_at", Type: query.Time},
+	},
+	Sorts: map[string]string{
+		"created_at": "created_at",
+		"first_name": "first_name",
+		"last_name":  "last_name",
+		"email":      "email",
+	},
+	DefaultSort: "-created_at",
+	Key:         "user_id",
+}
+
+// List returns one page of users
+func (r *userRepository) List(req *query.Request) (*query.Page[models.User], error) {
+	return query.Find[models.User](r.db, UserListSpec, req)
+}
+
+// IncrementFailedLogins bumps the consecutive failed login counter and
+// returns its new value. One statement does both, so concurrent failures
+// each see their own count.
+func (r *userRepository) IncrementFailedLogins(id uuid.UUID) (int, error) {
+	var count int
+	err := r.db.Raw(
+		"UPDATE users SET failed_login_attempts = failed_login_attempts + 1 WHERE user_id = ? RETURNING failed_login_attempts",
+		id,
+	).Scan(&count).Error
+	return count, err
+}
+
+func (r *userRepository) LockUntil(id uuid.UUID, until time.Time) error {
+	return r.db.Model(&models.User{}).Where("user_id = ?", id).UpdateColumn("locked_until", until).Error
+}
+
+// ResetFailedLogins clears the failed login counter and any lockout
+func (r *userRepository) ResetFailedLogins(id uuid.UUID) error {
+	return r.db.Model(&models.User{}).Where("user_id = ?", id).UpdateColumns(map[string]interface{}{
+		"failed_login_attempts": 0,
+		"locked_until":          nil,
+	}).Error
 }
 
-// GetUsersByUserType retrieves users filtered by user_type
-func (r *userRepository) GetUsersByUserType(userType string) ([]*models.User, error) {
-	var users []*models.User
-	result := r.db.Where("user_type = ?", userType).Find(&users)
-	return users, result.Error
+// UpdateFields updates the given columns of one user
+func (r *userRepository) UpdateFields(id uuid.UUID, fields map[string]interface{}) error {
+	return r.db.Model(&models.User{}).Where("user_id = ?", id).Updates(fields).Error
 }
 
 // Helper to expose DB if needed (optional)
@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"go-blog/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type UserTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewUserTokenRepository(db *gorm.DB) *UserTokenRepository {
+	return &UserTokenRepository{db: db}
+}
+
+// WithTx returns a repository bound to the given transaction
+func (r *UserTokenRepository) WithTx(tx *gorm.DB) *UserTokenRepository {
+	return &UserTokenRepository{db: tx}
+}
+
+func (r *UserTokenRepository) Create(token *models.UserToken) error {
+	return r.db.Create(token).Error
+}
+
+// GetUsableForUpdate loads an unused, unexpired token and locks it so it can
+// only be consumed once.
+func (r *UserTokenRepository) GetUsableForUpdate(purpose, hash string, now time.Time) (*models.UserToken, error) {
+	var token models.UserToken
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("purpose = ? AND token_hash = ? AND used_at IS NULL AND expires_at > ?", purpose, hash, now).
+		First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *UserTokenRepository) MarkUsed(id uuid.UUID, at time.Time) error {
+	return r.db.Model(&models.UserToken{}).Where("id = ?", id).Update("used_at", at).Error
+}
+
+// InvalidateForUser marks every outstanding token of a purpose as used, so
+// only the most recently mailed link works.
+func (r *UserTokenRepository) InvalidateForUser(userID uuid.UUID, purpose string, at time.Time) error {
+	return r.db.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", at).Error
+}
@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"go-blog/controllers"
+	"go-blog/internal/models"
+	"go-blog/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterAPIKeyRoutes exposes API key management to admins
+func RegisterAPIKeyRoutes(r *gin.Engine, ctrl *controllers.APIKeyController) {
+	group := r.Group("/api-keys", middlewares.AuthMiddleware(), middlewares.RequireRoles(models.RoleAdmin))
+	{
+		group.POST("", ctrl.Create)
+		group.GET("", ctrl.List)
+		group.GET("/scopes", ctrl.Scopes)
+		group.GET("/:id", ctrl.Get)
+		group.PATCH("/:id", ctrl.Update)
+		group.DELETE("/:id", ctrl.Revoke)
+		group.GET("/:id/usage", ctrl.Usage)
+	}
+}
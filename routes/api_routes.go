@@ -2,6 +2,8 @@ package routes
 
 import (
 	"go-blog/controllers"
+	"go-blog/internal/models"
+	"go-blog/middlewares"
 
 	"github.com/gin-gonic/gin"
 )
@@ -9,17 +11,37 @@ import (
 func RegisterRoutes(r *gin.Engine, planController *controllers.PlanController, membershipController *controllers.MembershipController, paymentController *controllers.PaymentController) {
 	api := r.Group("/api")
 
-	// Plan routes
-	api.POST("/plans", planController.CreatePlan)
+	// Plans are listed publicly so the registration form can offer them
 	api.GET("/plans", planController.GetPlans)
+	api.GET("/plans/:id", planController.GetPlan)
+
+	secured := api.Group("", middlewares.AuthMiddleware(), middlewares.RequirePolicy("api"))
+
+	// Plan routes
+	secured.POST("/plans", planController.CreatePlan)
+	secured.GET("/plans/all", middlewares.RequireRoles(middlewares.StaffRoles()...), planController.GetAllPlans) // archived plans too
+	secured.PUT("/plans/:id", planController.UpdatePlan)
+	secured.POST("/plans/:id/archive", planController.ArchivePlan)
+	secured.POST("/plans/:id/unarchive", planController.UnarchivePlan)
+	secured.DELETE("/plans/:id", middlewares.RequireRoles(models.RoleAdmin), planController.DeletePlan)
+	secured.GET("/plans/:id/versions", middlewares.RequireRoles(models.RoleAdmin), planController.Versions)
+	secured.GET("/plans/:id/memberships", middlewares.RequireRoles(models.RoleAdmin), planController.Memberships)
 
 	// Membership routes
-	api.POST("/memberships", membershipController.CreateMembership)
-	api.GET("/memberships/:memberID", membershipController.GetByMember)
+	secured.POST("/memberships", membershipController.CreateMembership)
+	secured.GET("/memberships/:memberID", middlewares.MemberSelfOnly("memberID"), membershipController.GetByMember)
+	secured.PUT("/memberships/:id", membershipController.Update)
+	secured.DELETE("/memberships/:id", middlewares.RequireRoles(models.RoleAdmin), membershipController.Delete)
+	secured.POST("/memberships/:id/freeze", membershipController.Freeze)
+	secured.POST("/memberships/:id/unfreeze", membershipController.Unfreeze)
+	secured.POST("/memberships/:id/cancel", membershipController.Cancel)
+	secured.GET("/memberships/change-plan/preview", membershipController.PreviewPlanChange)
+	secured.POST("/memberships/:id/change-plan", membershipController.ChangePlan)
 
 	// Payment routes
-	api.POST("/payments", paymentController.RecordPayment)
-	api.GET("/payments/:memberID", paymentController.GetPayments)
-	api.GET("/payments", paymentController.GetAllPayments) // <-- all payments
+	secured.POST("/payments", paymentController.RecordPayment)
+	secured.GET("/payments/:memberID", middlewares.MemberSelfOnly("memberID"), paymentController.GetPayments)
+	secured.PUT("/payments/:id/status", paymentController.SetStatus)
+	secured.GET("/payments", middlewares.RequireRoles(middlewares.StaffRoles()...), paymentController.GetAllPayments) // <-- all payments
 
 }
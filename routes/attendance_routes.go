@@ -2,15 +2,18 @@ package routes
 
 import (
 	"go-blog/controllers"
+	"go-blog/middlewares"
 
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterAttendanceRoutes(router *gin.Engine, c *controllers.AttendanceController) {
-	group := router.Group("/attendance")
+func RegisterAttendanceRoutes(router *gin.Engine, c *controllers.AttendanceController, passes *controllers.PassController) {
+	group := router.Group("/attendance", middlewares.AuthOrAPIKeyMiddleware(), middlewares.RequirePolicy("attendance"))
 	{
 		group.POST("/checkin", c.CheckIn)
-		group.GET("/member/:member_id", c.GetMemberAttendance)
-		group.GET("/all", c.GetAllAttendance)
+		group.POST("/pass-checkin", passes.Redeem)
+		group.GET("/member/:member_id", middlewares.MemberSelfOnly("member_id"), c.GetMemberAttendance)
+		group.GET("/member/:member_id/remaining", middlewares.MemberSelfOnly("member_id"), c.GetRemainingSessions)
+		group.GET("/all", middlewares.RequireRoles(middlewares.CoachRoles()...), c.GetAllAttendance)
 	}
 }
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"go-blog/controllers"
+	"go-blog/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterBookingRoutes lets members book sessions for themselves and their
+// dependents, and staff book for anyone.
+func RegisterBookingRoutes(r *gin.Engine, ctrl *controllers.BookingController) {
+	group := r.Group("/bookings", middlewares.AuthMiddleware(), middlewares.RequirePolicy("bookings"))
+	{
+		group.POST("", ctrl.Book)
+		group.DELETE("/:id", ctrl.Cancel)
+		group.GET("/member/:member_id", middlewares.MemberSelfOnly("member_id"), ctrl.GetMemberBookings)
+	}
+}
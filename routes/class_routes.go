@@ -2,12 +2,13 @@ package routes
 
 import (
 	"go-blog/controllers"
+	"go-blog/middlewares"
 
 	"github.com/gin-gonic/gin"
 )
 
 func RegisterClassRoutes(r *gin.Engine, ctrl *controllers.ClassController) {
-	group := r.Group("/class")
+	group := r.Group("/class", middlewares.AuthOrAPIKeyMiddleware(), middlewares.RequirePolicy("class"))
 	{
 		group.POST("", ctrl.CreateClass)     // Remove trailing slash - should be "" not "/"
 		group.GET("/get", ctrl.ListClasses)      // Remove trailing slash - should be "" not "/"
@@ -2,12 +2,13 @@ package routes
 
 import (
 	"go-blog/controllers"
+	"go-blog/middlewares"
 
 	"github.com/gin-gonic/gin"
 )
 
 func RegisterClassSessionRoutes(r *gin.Engine, ctrl *controllers.ClassSessionController) {
-	group := r.Group("/classsession")
+	group := r.Group("/classsession", middlewares.AuthOrAPIKeyMiddleware(), middlewares.RequirePolicy("classsession"))
 	{
 		group.POST("/create", ctrl.CreateSession)      // Create a session
 		group.GET("/get", ctrl.ListSessions)        // List all sessions
@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"go-blog/controllers"
+	"go-blog/internal/models"
+	"go-blog/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterDiscountRoutes lets admins run promo codes and members see their
+// referral code and rewards. Codes are redeemed at registration and on
+// plan changes.
+func RegisterDiscountRoutes(r *gin.Engine, ctrl *controllers.DiscountController) {
+	api := r.Group("/api")
+
+	// Checked publicly so the registration form can show the discount
+	api.GET("/promo-codes/check", ctrl.CheckPromo)
+
+	secured := api.Group("", middlewares.AuthMiddleware(), middlewares.RequirePolicy("api"))
+	staff := middlewares.RequireRoles(middlewares.StaffRoles()...)
+	admin := middlewares.RequireRoles(models.RoleAdmin)
+	{
+		secured.GET("/promo-codes", staff, ctrl.ListPromos)
+		secured.POST("/promo-codes", admin, ctrl.CreatePromo)
+		secured.POST("/promo-codes/:id/archive", admin, ctrl.ArchivePromo)
+		secured.GET("/promo-codes/:id/redemptions", staff, ctrl.Redemptions)
+		secured.GET("/referrals/:memberID", middlewares.MemberSelfOnly("memberID"), ctrl.Referrals)
+	}
+}
@@ -2,12 +2,13 @@ package routes
 
 import (
 	"go-blog/controllers"
+	"go-blog/middlewares"
 
 	"github.com/gin-gonic/gin"
 )
 
 func RegisterGymRoutes(r *gin.Engine, ctrl *controllers.GymController) {
-	group := r.Group("/gymx")
+	group := r.Group("/gymx", middlewares.AuthMiddleware(), middlewares.RequirePolicy("gymx"))
 	{
 		group.POST("", ctrl.CreateGym)      // Remove trailing slash - should be "" not "/"
 		group.GET("", ctrl.ListGyms)        // Remove trailing slash - should be "" not "/"
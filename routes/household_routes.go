@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"go-blog/controllers"
+	"go-blog/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterHouseholdRoutes exposes households to their members and staff,
+// and guardian links to staff. The caller's own household and dependents
+// are under /me.
+func RegisterHouseholdRoutes(r *gin.Engine, ctrl *controllers.HouseholdController) {
+	group := r.Group("/households", middlewares.AuthMiddleware(), middlewares.RequirePolicy("households"))
+	{
+		group.POST("", ctrl.Create)
+		group.GET("", middlewares.RequireRoles(middlewares.StaffRoles()...), ctrl.List)
+		group.GET("/:id", ctrl.Get)
+		group.POST("/:id/members", ctrl.AddMember)
+		group.DELETE("/:id/members/:user_id", ctrl.RemoveMember)
+		group.PUT("/:id/primary-payer", ctrl.SetPrimaryPayer)
+		group.GET("/:id/payments", ctrl.Payments)
+	}
+
+	guardians := r.Group("/guardians", middlewares.AuthMiddleware(), middlewares.RequireRoles(middlewares.StaffRoles()...))
+	{
+		guardians.POST("", ctrl.LinkGuardian)
+		guardians.DELETE("/:id", ctrl.RevokeGuardian)
+	}
+}
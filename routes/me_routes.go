@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"go-blog/controllers"
+	"go-blog/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterMeRoutes exposes the caller's own account. Any authenticated role may use it.
+func RegisterMeRoutes(r *gin.Engine, ctrl *controllers.ProfileController, photos *controllers.ProfilePhotoController, privacy *controllers.PrivacyController, households *controllers.HouseholdController) {
+	group := r.Group("/me", middlewares.AuthMiddleware())
+	{
+		group.GET("", ctrl.GetProfile)
+		group.PATCH("", ctrl.UpdateProfile)
+		group.POST("/password", ctrl.ChangePassword)
+		group.GET("/overview", ctrl.GetOverview)
+		group.PUT("/photo", photos.UploadMine)
+		group.DELETE("/photo", photos.DeleteMine)
+		group.GET("/data-export", privacy.ExportMine)
+		group.POST("/erasure-request", privacy.RequestMyErasure)
+		group.GET("/erasure-requests", privacy.MyErasureRequests)
+		group.GET("/household", households.Mine)
+		group.GET("/dependents", households.Dependents)
+	}
+}
@@ -2,17 +2,22 @@ package routes
 
 import (
 	"go-blog/controllers"
+	"go-blog/middlewares"
 
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterMemberRoutes(router *gin.Engine, memberController *controllers.MemberController) {
-	memberRoutes := router.Group("/members")
+func RegisterMemberRoutes(router *gin.Engine, memberController *controllers.MemberController, photoController *controllers.ProfilePhotoController, importController *controllers.MemberImportController) {
+	memberRoutes := router.Group("/members", middlewares.AuthMiddleware(), middlewares.RequirePolicy("members"))
 	{
 		memberRoutes.POST("", memberController.CreateMember)
-		memberRoutes.GET("", memberController.GetAllMembers)
-		memberRoutes.GET("/:id", memberController.GetMemberByID)
+		memberRoutes.GET("", middlewares.RequireRoles(middlewares.CoachRoles()...), memberController.GetAllMembers)
+		memberRoutes.POST("/import", middlewares.RequireRoles(middlewares.StaffRoles()...), importController.Import)
+		memberRoutes.GET("/search", middlewares.RequireRoles(middlewares.CoachRoles()...), memberController.SearchMembers)
+		memberRoutes.GET("/:id", middlewares.MemberSelfOnly("id"), memberController.GetMemberByID)
 		memberRoutes.PUT("/:id", memberController.UpdateMember)
 		memberRoutes.DELETE("/:id", memberController.DeleteMember)
+		memberRoutes.PUT("/:id/photo", photoController.UploadForMember)
+		memberRoutes.DELETE("/:id/photo", photoController.DeleteForMember)
 	}
 }
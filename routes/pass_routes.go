@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"go-blog/controllers"
+	"go-blog/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterPassRoutes lets staff sell single visits and trials and manage
+// pass products, and members issue guest passes. Passes are redeemed at
+// /attendance/pass-checkin.
+func RegisterPassRoutes(r *gin.Engine, ctrl *controllers.PassController) {
+	staff := middlewares.RequireRoles(middlewares.StaffRoles()...)
+	group := r.Group("/passes", middlewares.AuthMiddleware(), middlewares.RequirePolicy("passes"))
+	{
+		group.GET("/products", ctrl.ListProducts)
+		group.POST("/products", staff, ctrl.CreateProduct)
+		group.POST("/products/:id/archive", staff, ctrl.ArchiveProduct)
+		group.POST("", staff, ctrl.Sell)
+		group.POST("/guest", ctrl.IssueGuestPass)
+		group.GET("", staff, ctrl.List)
+		group.GET("/member/:member_id", middlewares.MemberSelfOnly("member_id"), ctrl.ListByMember)
+		group.GET("/:id", ctrl.Get)
+		group.POST("/:id/convert", staff, ctrl.Convert)
+		group.POST("/:id/void", staff, ctrl.Void)
+	}
+}
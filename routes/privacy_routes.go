@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"go-blog/controllers"
+	"go-blog/internal/models"
+	"go-blog/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterPrivacyRoutes exposes data exports and erasure requests to admins.
+// Users reach their own under /me.
+func RegisterPrivacyRoutes(r *gin.Engine, ctrl *controllers.PrivacyController) {
+	group := r.Group("/privacy", middlewares.AuthMiddleware(), middlewares.RequireRoles(models.RoleAdmin))
+	{
+		group.GET("/users/:id/export", ctrl.ExportUser)
+		group.GET("/erasure-requests", ctrl.ListErasureRequests)
+		group.POST("/erasure-requests", ctrl.CreateErasureRequest)
+		group.POST("/erasure-requests/:id/approve", ctrl.Approve)
+		group.POST("/erasure-requests/:id/reject", ctrl.Reject)
+	}
+}